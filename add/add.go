@@ -0,0 +1,121 @@
+// Package add implements `puku add`, which adds new modules to the go.mod, syncs them to the third party build file,
+// and updates the packages that import them.
+package add
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/fs"
+	"github.com/please-build/puku/generate"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+	"github.com/please-build/puku/sync"
+	"github.com/please-build/puku/work"
+)
+
+var log = logging.GetLogger()
+
+// Add adds the modules to the go.mod with go get, syncs the go.mod to the third party build file, and then updates the
+// build files of any packages that import the modules. Modules can have a version, e.g. github.com/foo/bar@v1.2.3,
+// otherwise the latest version is added.
+func Add(plzConf *please.Config, opts options.Options, modules []string) error {
+	if len(modules) == 0 {
+		return fmt.Errorf("no modules to add")
+	}
+	if plzConf.ModFile() == "" {
+		return fmt.Errorf("puku add needs the go.mod to be exposed as a build target, and specified in the plzconfig under Plugin.Go.ModFile")
+	}
+
+	conf, err := config.ReadConfig(".")
+	if err != nil {
+		return err
+	}
+
+	if err := goGet(labels.Parse(plzConf.ModFile()).Package, modules); err != nil {
+		return err
+	}
+
+	if err := sync.Sync(plzConf, graph.New(plzConf.BuildFileNames(), opts)); err != nil {
+		return fmt.Errorf("failed to sync the go.mod: %v", err)
+	}
+
+	paths, err := work.ExpandPaths(".", []string{"..."})
+	if err != nil {
+		return err
+	}
+	importers, err := importingPackages(paths, conf.GetThirdPartyDir(), modulePaths(modules))
+	if err != nil {
+		return err
+	}
+	if len(importers) == 0 {
+		return nil
+	}
+	log.Infof("Updating %v packages that import the new modules", len(importers))
+	return generate.Update(plzConf, opts, importers...)
+}
+
+// goGet runs go get for the modules in the directory containing the go.mod
+func goGet(dir string, modules []string) error {
+	cmd := exec.Command("go", append([]string{"get"}, modules...)...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("go get %v failed: %v", strings.Join(modules, " "), err)
+	}
+	return nil
+}
+
+// modulePaths strips any versions from the module arguments
+func modulePaths(modules []string) []string {
+	ret := make([]string, 0, len(modules))
+	for _, mod := range modules {
+		path, _, _ := strings.Cut(mod, "@")
+		ret = append(ret, path)
+	}
+	return ret
+}
+
+// importingPackages returns the packages that import a package from any of the modules
+func importingPackages(paths []string, thirdPartyDir string, modules []string) ([]string, error) {
+	var ret []string
+	for _, path := range paths {
+		if fs.IsSubdir(thirdPartyDir, path) {
+			continue
+		}
+		files, err := generate.ImportDir(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if importsAny(files, modules) {
+			ret = append(ret, filepath.Clean(path))
+		}
+	}
+	return ret, nil
+}
+
+// importsAny returns whether any of the files import a package from any of the modules
+func importsAny(files map[string]*generate.GoFile, modules []string) bool {
+	for _, f := range files {
+		for _, i := range f.Imports {
+			for _, mod := range modules {
+				if fs.IsSubdir(mod, i) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
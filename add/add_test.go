@@ -0,0 +1,31 @@
+package add
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/repotest"
+)
+
+func TestModulePaths(t *testing.T) {
+	assert.Equal(t, []string{"github.com/foo/bar", "github.com/foo/baz"}, modulePaths([]string{"github.com/foo/bar@v1.2.3", "github.com/foo/baz"}))
+}
+
+func TestImportingPackages(t *testing.T) {
+	files := map[string]string{
+		"foo/foo.go":              "package foo\n\nimport \"github.com/foo/bar/pkg\"\n",
+		"baz/baz_test.go":         "package baz\n\nimport \"github.com/foo/bar\"\n",
+		"qux/qux.go":              "package qux\n\nimport \"github.com/foo/barista\"\n",
+		"third_party/go/tools.go": "package tools\n\nimport \"github.com/foo/bar\"\n",
+		"empty/README.md":         "Nothing to see here",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		paths := []string{".", "foo", "baz", "qux", "third_party/go", "empty", "deleted"}
+		importers, err := importingPackages(paths, "third_party/go", []string{"github.com/foo/bar"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"foo", "baz"}, importers)
+	})
+}
@@ -0,0 +1,283 @@
+// Package cli implements puku's command line interface. The puku binary just calls Main, so a binary that registers
+// more languages with the language package can provide the same commands by calling it too, e.g.
+//
+//	import (
+//		"github.com/please-build/puku/cli"
+//		_ "example.com/puku-thrift/thrift" // Registers the thrift language in its init function
+//	)
+//
+//	func main() {
+//		cli.Main()
+//	}
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/peterebden/go-cli-init/v5/flags"
+	clilogging "github.com/peterebden/go-cli-init/v5/logging"
+
+	"github.com/please-build/puku/add"
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/depgraph"
+	"github.com/please-build/puku/generate"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/licences"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/migrate"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+	"github.com/please-build/puku/proxy"
+	"github.com/please-build/puku/sync"
+	"github.com/please-build/puku/version"
+	"github.com/please-build/puku/watch"
+	"github.com/please-build/puku/work"
+)
+
+var opts = struct {
+	options.Options
+
+	Usage     string
+	Verbosity clilogging.Verbosity `short:"v" long:"verbosity" description:"Verbosity of output (error, warning, notice, info, debug)" default:"info"`
+
+	Version struct{} `command:"version" description:"Print the version of puku"`
+	Fmt     struct {
+		Args struct {
+			Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+		} `positional-args:"true"`
+	} `command:"fmt" description:"Format build files in the provided paths"`
+	Sync struct {
+		Format string `short:"f" long:"stdout_format" choice:"json" choice:"text" choice:"diff" default:"text" description:"output format when outputting to stdout"` //nolint
+		Write  bool   `short:"w" long:"write" description:"Whether to write the files back or just print them to stdout"`
+	} `command:"sync" description:"Synchronises the go.mod, and any Python, Rust or Maven dependencies, to the third party build files"`
+	Lint struct {
+		Format string `short:"f" long:"stdout_format" choice:"json" choice:"text" choice:"diff" choice:"report" default:"text" description:"output format when outputting to stdout. report describes the changes to each rule as JSON"` //nolint
+		Args   struct {
+			Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+		} `positional-args:"true"`
+	} `command:"lint" description:"Lint build files in the provided paths"`
+	Watch struct {
+		Args struct {
+			Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+		} `positional-args:"true"`
+	} `command:"watch" description:"Watch build files in the provided paths and update them when needed"`
+	Migrate struct {
+		Write          bool     `short:"w" long:"write" description:"Whether to write the files back or just print them to stdout"`
+		Format         string   `short:"f" long:"stdout_format" choice:"json" choice:"text" choice:"diff" default:"text" description:"output format when outputting to stdout"` //nolint
+		ThirdPartyDirs []string `long:"third_party_dir" description:"Directories to find go_module rules to migrate. Not used by js"`
+		UpdateGoMod    bool     `short:"g" long:"update_go_mod" description:"Update the go mod with the module(s) being migrated. Not used by js"`
+		Args           struct {
+			Modules []string `positional-arg-name:"modules" description:"The modules to migrate to go_repo"`
+		} `positional-args:"true"`
+		Js struct {
+			Args struct {
+				Paths []string `positional-arg-name:"paths" description:"The directories with the package.json files to migrate, e.g. the root of a yarn workspace"`
+			} `positional-args:"true"`
+		} `command:"js" description:"Scaffolds Please targets for the build, bundle and test scripts in package.json files and their workspaces"`
+	} `command:"migrate" subcommands-optional:"true" description:"Migrates from go_module to go_repo, or from package.json scripts with the js subcommand" long-description:"Without a subcommand, migrates the go_module rules of the provided modules, or all of them, to go_repo. With the js subcommand, scaffolds Please targets for the scripts in the package.json files in the provided paths."`
+	Add struct {
+		Args struct {
+			Modules []string `positional-arg-name:"modules" description:"The modules to add, optionally with a version e.g. github.com/foo/bar@v1.2.3"`
+		} `positional-args:"true"`
+	} `command:"add" description:"Adds modules to the go.mod, syncs them to the third party build file, and updates the packages that import them"`
+	Imports struct {
+		Args struct {
+			Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+		} `positional-args:"true"`
+	} `command:"imports" description:"Print the imports of the sources in the provided paths, and the targets they resolve to, as JSON"`
+	Graph struct {
+		Format     string   `short:"f" long:"graph_format" choice:"dot" choice:"mermaid" default:"dot" description:"output format of the graph"`
+		Depth      int      `long:"depth" description:"How many deps away from the targets in the provided paths to go. 0 means there's no limit"`
+		Kinds      []string `long:"kind" description:"Kinds of rule to include in the graph, e.g. go_library. Can be repeated"`
+		ThirdParty bool     `long:"third_party" description:"Include third party targets in the graph"`
+		Args       struct {
+			Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+		} `positional-args:"true"`
+	} `command:"graph" description:"Print the dependency graph of the targets in the provided paths as DOT or mermaid"`
+	Licenses struct {
+		Update struct {
+			Format string `short:"f" long:"stdout_format" choice:"json" choice:"text" choice:"diff" default:"text" description:"output format when outputting to stdout"` //nolint
+			Write  bool   `short:"w" long:"write" description:"Whether to write the files back or just print them to stdout"`
+			Args   struct {
+				Paths []string `positional-arg-name:"packages" description:"The packages to process"`
+			} `positional-args:"true"`
+		} `command:"update" description:"Updates licences in the given paths"`
+	} `command:"licences" description:"Commands relating to licences"`
+}{
+	Usage: `
+puku is a tool used to generate and update Go targets in build files
+`,
+}
+
+var log = logging.GetLogger()
+
+var funcs = map[string]func(conf *config.Config, plzConf *please.Config, orignalWD string) int{
+	"fmt": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Fmt.Args.Paths)
+		if err := generate.Update(plzConf, opts.Options, paths...); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"sync": func(_ *config.Config, plzConf *please.Config, _ string) int {
+		g := graph.New(plzConf.BuildFileNames(), opts.Options)
+		if opts.Sync.Write {
+			if err := sync.Sync(plzConf, g); err != nil {
+				log.Fatalf("%v", err)
+			}
+		} else {
+			if err := sync.SyncToStdout(opts.Sync.Format, plzConf, g); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		return 0
+	},
+	"lint": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Lint.Args.Paths)
+		if err := generate.UpdateToStdout(opts.Lint.Format, plzConf, opts.Options, paths...); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"watch": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Watch.Args.Paths)
+		session := generate.NewSession(plzConf, opts.Options)
+		if err := session.Update(paths...); err != nil {
+			log.Fatalf("%v", err)
+		}
+
+		if err := watch.Watch(plzConf, session, paths...); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"migrate": func(conf *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := opts.Migrate.ThirdPartyDirs
+		if len(paths) == 0 {
+			paths = []string{conf.GetThirdPartyDir()}
+		}
+		paths = work.MustExpandPaths(orignalWD, paths)
+		if opts.Migrate.Write {
+			if err := migrate.Migrate(conf, plzConf, opts.Migrate.UpdateGoMod, opts.Migrate.Args.Modules, paths, opts.Options); err != nil {
+				log.Fatalf("%v", err)
+			}
+		} else {
+			if err := migrate.MigrateToStdout(opts.Migrate.Format, conf, plzConf, opts.Migrate.UpdateGoMod, opts.Migrate.Args.Modules, paths, opts.Options); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		return 0
+	},
+	"js": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := opts.Migrate.Js.Args.Paths
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		paths = work.MustExpandPaths(orignalWD, paths)
+		if opts.Migrate.Write {
+			if err := migrate.MigrateJS(plzConf, paths, opts.Options); err != nil {
+				log.Fatalf("%v", err)
+			}
+		} else {
+			if err := migrate.MigrateJSToStdout(opts.Migrate.Format, plzConf, paths, opts.Options); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		return 0
+	},
+	"add": func(_ *config.Config, plzConf *please.Config, _ string) int {
+		if err := add.Add(plzConf, opts.Options, opts.Add.Args.Modules); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"imports": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Imports.Args.Paths)
+		imports, err := generate.Imports(plzConf, opts.Options, paths...)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if err := json.NewEncoder(os.Stdout).Encode(imports); err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"graph": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Graph.Args.Paths)
+		g, err := depgraph.Build(graph.New(plzConf.BuildFileNames(), opts.Options), paths, depgraph.Options{
+			Depth:      opts.Graph.Depth,
+			Kinds:      opts.Graph.Kinds,
+			ThirdParty: opts.Graph.ThirdParty,
+		})
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		if opts.Graph.Format == "mermaid" {
+			err = g.WriteMermaid(os.Stdout)
+		} else {
+			err = g.WriteDOT(os.Stdout)
+		}
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		return 0
+	},
+	"update": func(_ *config.Config, plzConf *please.Config, orignalWD string) int {
+		paths := work.MustExpandPaths(orignalWD, opts.Licenses.Update.Args.Paths)
+		l := licences.New(proxy.NewFromEnv(), graph.New(plzConf.BuildFileNames(), opts.Options))
+		if opts.Licenses.Update.Write {
+			if err := l.Update(paths); err != nil {
+				log.Fatalf("%v", err)
+			}
+		} else {
+			if err := l.UpdateToStdout(opts.Licenses.Update.Format, paths); err != nil {
+				log.Fatalf("%v", err)
+			}
+		}
+		return 0
+	},
+}
+
+// Main parses the command line and runs the command, exiting when it's done
+func Main() {
+	cmd := flags.ParseFlagsOrDie("puku", &opts, nil)
+	logging.InitLogging(opts.Verbosity)
+
+	if cmd == "version" {
+		fmt.Println("puku version", version.PukuVersion)
+		return
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		log.Fatalf("failed to get wd: %v", err)
+	}
+
+	root, err := work.FindRoot()
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+
+	wd, err = filepath.Rel(root, wd)
+	if err != nil {
+		log.Fatalf("failed to get wd: %v", err)
+	}
+
+	if err := os.Chdir(root); err != nil {
+		log.Fatalf("failed to set working dir to repo root: %v", err)
+	}
+
+	conf, err := config.ReadConfig(".")
+	if err != nil {
+		log.Fatalf("failed to read config: %v", err)
+	}
+
+	plzConf, err := please.QueryConfig(conf.GetPlzPath())
+	if err != nil {
+		log.Fatalf("failed to query config: %w", err)
+	}
+	os.Exit(funcs[cmd](conf, plzConf, wd))
+}
@@ -19,6 +19,11 @@ type KindConfig struct {
 	ProvidedDeps      []string `json:"providedDeps"`
 	DefaultVisibility []string `json:"defaultVisibility"`
 	SrcsArg           string   `json:"srcsArg"`
+	// CgoSrcsArg is the argument that Go sources importing "C" are passed to. Setting this marks the kind as able to
+	// compile cgo sources.
+	CgoSrcsArg string `json:"cgoSrcsArg"`
+	// AsmSrcsArg is the argument that Go assembly sources are passed to
+	AsmSrcsArg string `json:"asmSrcsArg"`
 }
 
 func (kc *KindConfig) srcsArg() string {
@@ -28,21 +33,62 @@ func (kc *KindConfig) srcsArg() string {
 	return kc.SrcsArg
 }
 
+// BuildTagSet is a set of build tags that puku evaluates Go build constraints against. Imports only needed when these
+// tags are set are added to deps under Condition in a select(). If Condition is empty, they're added unconditionally.
+type BuildTagSet struct {
+	Tags      []string `json:"tags"`
+	Condition string   `json:"condition"`
+}
+
+// GeneratedCode configures how puku handles Go files with a "Code generated ... DO NOT EDIT." header
+type GeneratedCode struct {
+	// Exclude stops puku from allocating these files to rules
+	Exclude bool `json:"exclude"`
+	// Deps are added to the rule instead of the targets the file's imports resolve to. This is usually the target that
+	// generates the file, or the runtime library the generated code needs.
+	Deps []string `json:"deps"`
+}
+
 // Config represents a puku.json file discovered in the repo. These are loaded for each directory, and form a chain of
 // configs all the way up to the root config. Configs at a deeper level in the file tree override values from configs at
 // a shallower level. The shallower config file is stored in (*Config).base` and the methods on this struct will recurse
 // into this base config where appropriate.
 type Config struct {
 	base                *Config
-	ThirdPartyDir       string                 `json:"thirdPartyDir"`
-	PleasePath          string                 `json:"pleasePath"`
-	KnownTargets        map[string]string      `json:"knownTargets"`
-	LibKinds            map[string]*KindConfig `json:"libKinds"`
-	TestKinds           map[string]*KindConfig `json:"testKinds"`
-	BinKinds            map[string]*KindConfig `json:"binKinds"`
-	Stop                *bool                  `json:"stop"`
-	EnsureSubincludes   *bool                  `json:"ensureSubincludes"`
-	ExcludeBuiltinKinds []string               `json:"excludeBuiltinKinds"`
+	ThirdPartyDir       string                    `json:"thirdPartyDir"`
+	PleasePath          string                    `json:"pleasePath"`
+	KnownTargets        map[string]string         `json:"knownTargets"`
+	LibKinds            map[string]*KindConfig    `json:"libKinds"`
+	TestKinds           map[string]*KindConfig    `json:"testKinds"`
+	BinKinds            map[string]*KindConfig    `json:"binKinds"`
+	Stop                *bool                     `json:"stop"`
+	EnsureSubincludes   *bool                     `json:"ensureSubincludes"`
+	ExcludeBuiltinKinds []string                  `json:"excludeBuiltinKinds"`
+	BuildTagSets        map[string]*BuildTagSet   `json:"buildTagSets"`
+	GoGenerateTools     map[string]string         `json:"goGenerateTools"`
+	FuzzKind            string                    `json:"fuzzKind"`
+	BenchmarkKind       string                    `json:"benchmarkKind"`
+	GeneratedCode       map[string]*GeneratedCode `json:"generatedCode"`
+	VendorDir           string                    `json:"vendorDir"`
+	WasmKind            string                    `json:"wasmKind"`
+	PruneModules        *bool                     `json:"pruneModules"`
+	KeepModules         []string                  `json:"keepModules"`
+	Resolver            string                    `json:"resolver"`
+	DetectTestData      *bool                     `json:"detectTestData"`
+	SplitBuildTagSets   *bool                     `json:"splitBuildTagSets"`
+	Languages           []string                  `json:"languages"`
+	PythonThirdPartyDir string                    `json:"pythonThirdPartyDir"`
+	PythonRequirements  string                    `json:"pythonRequirements"`
+	RustThirdPartyDir   string                    `json:"rustThirdPartyDir"`
+	CargoLock           string                    `json:"cargoLock"`
+	JavaThirdPartyDir   string                    `json:"javaThirdPartyDir"`
+	MavenDependencies   string                    `json:"mavenDependencies"`
+	CcIncludeDirs       []string                  `json:"ccIncludeDirs"`
+	ThriftIncludeDirs   []string                  `json:"thriftIncludeDirs"`
+	JsonnetJpath        []string                  `json:"jsonnetJpath"`
+	DiffColor           string                    `json:"diffColor"`
+	DiffWidth           int                       `json:"diffWidth"`
+	DiffPager           string                    `json:"diffPager"`
 }
 
 // TODO we should reload this during plz watch so this probably needs to become a member of Update
@@ -120,6 +166,19 @@ func (c *Config) GetThirdPartyDir() string {
 	return "third_party/go"
 }
 
+// GetVendorDir returns the directory containing vendored modules, i.e. as created by go mod vendor. If this is set,
+// third party imports resolve to targets in this directory rather than go_repo rules. Returns an empty string if
+// vendoring isn't enabled.
+func (c *Config) GetVendorDir() string {
+	if c.VendorDir != "" {
+		return c.VendorDir
+	}
+	if c.base != nil {
+		return c.base.GetVendorDir()
+	}
+	return ""
+}
+
 func (c *Config) GetStop() bool {
 	if c.Stop != nil {
 		return *c.Stop
@@ -127,6 +186,44 @@ func (c *Config) GetStop() bool {
 	return c.base != nil && c.base.GetStop()
 }
 
+// GetPruneModules returns whether sync should only keep third party rules for the modules that are imported
+func (c *Config) GetPruneModules() bool {
+	if c.PruneModules != nil {
+		return *c.PruneModules
+	}
+	return c.base != nil && c.base.GetPruneModules()
+}
+
+// GetKeepModules returns the modules that sync should keep rules for when pruning, even if they're not imported
+func (c *Config) GetKeepModules() []string {
+	if c.KeepModules != nil {
+		return c.KeepModules
+	}
+	if c.base != nil {
+		return c.base.GetKeepModules()
+	}
+	return nil
+}
+
+// GetDetectTestData returns whether files read by tests with literal paths should be added to their data
+func (c *Config) GetDetectTestData() bool {
+	if c.DetectTestData != nil {
+		return *c.DetectTestData
+	}
+	return c.base != nil && c.base.GetDetectTestData()
+}
+
+// GetResolver returns the executable to run to resolve imports that puku can't resolve itself, if any
+func (c *Config) GetResolver() string {
+	if c.Resolver != "" {
+		return c.Resolver
+	}
+	if c.base != nil {
+		return c.base.GetResolver()
+	}
+	return ""
+}
+
 func (c *Config) GetKnownTarget(importPath string) string {
 	if t, ok := c.KnownTargets[importPath]; ok {
 		return t
@@ -137,6 +234,41 @@ func (c *Config) GetKnownTarget(importPath string) string {
 	return ""
 }
 
+// GetGeneratedCode returns how files generated by the given tool should be handled, or nil if they should be treated
+// like any other source file. Tools are matched case-insensitively, and "*" matches any generated file.
+func (c *Config) GetGeneratedCode(generator string) *GeneratedCode {
+	if generator != "" {
+		if gen := c.getGeneratedCode(generator); gen != nil {
+			return gen
+		}
+	}
+	return c.getGeneratedCode("*")
+}
+
+func (c *Config) getGeneratedCode(generator string) *GeneratedCode {
+	for name, gen := range c.GeneratedCode {
+		if strings.EqualFold(name, generator) {
+			return gen
+		}
+	}
+	if c.base != nil {
+		return c.base.getGeneratedCode(generator)
+	}
+	return nil
+}
+
+// GetGoGenerateTool returns the target for the tool run by a //go:generate directive. Puku will only scaffold genrules
+// for directives that run a tool configured here.
+func (c *Config) GetGoGenerateTool(command string) string {
+	if t, ok := c.GoGenerateTools[command]; ok {
+		return t
+	}
+	if c.base != nil {
+		return c.base.GetGoGenerateTool(command)
+	}
+	return ""
+}
+
 func (c *Config) GetPlzPath() string {
 	if c.PleasePath != "" {
 		return c.PleasePath
@@ -147,6 +279,190 @@ func (c *Config) GetPlzPath() string {
 	return "plz"
 }
 
+// GetBuildTagSets returns the build tag sets to compute deps for. When none are configured, the imports of all files are
+// added regardless of their build constraints.
+func (c *Config) GetBuildTagSets() map[string]*BuildTagSet {
+	if c.BuildTagSets != nil {
+		return c.BuildTagSets
+	}
+	if c.base != nil {
+		return c.base.GetBuildTagSets()
+	}
+	return nil
+}
+
+// GetSplitBuildTagSets returns whether to generate a separate library for each build tag set, rather than one library
+// with its deps in a select()
+func (c *Config) GetSplitBuildTagSets() bool {
+	if c.SplitBuildTagSets != nil {
+		return *c.SplitBuildTagSets
+	}
+	return c.base != nil && c.base.GetSplitBuildTagSets()
+}
+
+// GetLanguages returns the languages other than Go that puku should generate rules for
+func (c *Config) GetLanguages() []string {
+	if c.Languages != nil {
+		return c.Languages
+	}
+	if c.base != nil {
+		return c.base.GetLanguages()
+	}
+	return nil
+}
+
+// HasLanguage returns whether puku should generate rules for the given language. Go is always enabled.
+func (c *Config) HasLanguage(lang string) bool {
+	if lang == "go" {
+		return true
+	}
+	for _, l := range c.GetLanguages() {
+		if l == lang {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPythonThirdPartyDir returns the directory containing the pip_library rules for third party Python packages
+func (c *Config) GetPythonThirdPartyDir() string {
+	if c.PythonThirdPartyDir != "" {
+		return c.PythonThirdPartyDir
+	}
+	if c.base != nil {
+		return c.base.GetPythonThirdPartyDir()
+	}
+	return "third_party/python"
+}
+
+// GetPythonRequirements returns the requirements.txt, poetry.lock or uv.lock file to sync the third party Python
+// packages from. If this is empty, sync looks for one at the repo root.
+func (c *Config) GetPythonRequirements() string {
+	if c.PythonRequirements != "" {
+		return c.PythonRequirements
+	}
+	if c.base != nil {
+		return c.base.GetPythonRequirements()
+	}
+	return ""
+}
+
+// GetRustThirdPartyDir returns the directory containing the cargo_crate rules for third party Rust crates
+func (c *Config) GetRustThirdPartyDir() string {
+	if c.RustThirdPartyDir != "" {
+		return c.RustThirdPartyDir
+	}
+	if c.base != nil {
+		return c.base.GetRustThirdPartyDir()
+	}
+	return "third_party/rust"
+}
+
+// GetCargoLock returns the Cargo.lock to sync the third party Rust crates from
+func (c *Config) GetCargoLock() string {
+	if c.CargoLock != "" {
+		return c.CargoLock
+	}
+	if c.base != nil {
+		return c.base.GetCargoLock()
+	}
+	return "Cargo.lock"
+}
+
+// GetJavaThirdPartyDir returns the directory containing the maven_jar rules for third party Java, Kotlin and Scala
+// artifacts
+func (c *Config) GetJavaThirdPartyDir() string {
+	if c.JavaThirdPartyDir != "" {
+		return c.JavaThirdPartyDir
+	}
+	if c.base != nil {
+		return c.base.GetJavaThirdPartyDir()
+	}
+	return "third_party/java"
+}
+
+// GetMavenDependencies returns the gradle.lockfile or Maven BOM to sync the third party artifacts from. If this is
+// empty, sync looks for one at the repo root.
+func (c *Config) GetMavenDependencies() string {
+	if c.MavenDependencies != "" {
+		return c.MavenDependencies
+	}
+	if c.base != nil {
+		return c.base.GetMavenDependencies()
+	}
+	return ""
+}
+
+// GetCcIncludeDirs returns the directories, relative to the repo root, that C and C++ includes are looked up in as well
+// as the repo root
+func (c *Config) GetCcIncludeDirs() []string {
+	if c.CcIncludeDirs != nil {
+		return c.CcIncludeDirs
+	}
+	if c.base != nil {
+		return c.base.GetCcIncludeDirs()
+	}
+	return nil
+}
+
+// GetThriftIncludeDirs returns the directories, relative to the repo root, that Thrift includes are looked up in as well
+// as the repo root
+func (c *Config) GetThriftIncludeDirs() []string {
+	if c.ThriftIncludeDirs != nil {
+		return c.ThriftIncludeDirs
+	}
+	if c.base != nil {
+		return c.base.GetThriftIncludeDirs()
+	}
+	return nil
+}
+
+// GetJsonnetJpath returns the library search paths, relative to the repo root, that Jsonnet imports are looked up in
+// when they aren't relative to the importing file, as with jsonnet -J
+func (c *Config) GetJsonnetJpath() []string {
+	if c.JsonnetJpath != nil {
+		return c.JsonnetJpath
+	}
+	if c.base != nil {
+		return c.base.GetJsonnetJpath()
+	}
+	return nil
+}
+
+// GetDiffColor returns whether to colour the diffs printed with --format=diff. This is auto, always or never, and
+// defaults to auto, which colours them when stdout is a terminal.
+func (c *Config) GetDiffColor() string {
+	if c.DiffColor != "" {
+		return c.DiffColor
+	}
+	if c.base != nil {
+		return c.base.GetDiffColor()
+	}
+	return "auto"
+}
+
+// GetDiffWidth returns the width that the lines of diffs are truncated to. They aren't truncated if this is 0.
+func (c *Config) GetDiffWidth() int {
+	if c.DiffWidth != 0 {
+		return c.DiffWidth
+	}
+	if c.base != nil {
+		return c.base.GetDiffWidth()
+	}
+	return 0
+}
+
+// GetDiffPager returns the command that diffs are piped to when stdout is a terminal, if any
+func (c *Config) GetDiffPager() string {
+	if c.DiffPager != "" {
+		return c.DiffPager
+	}
+	if c.base != nil {
+		return c.base.GetDiffPager()
+	}
+	return ""
+}
+
 func (c *Config) ShouldEnsureSubincludes() bool {
 	if c.EnsureSubincludes != nil {
 		return *c.EnsureSubincludes
@@ -169,7 +485,74 @@ func (c *Config) isExcludedDefaultKind(kind string) bool {
 	return c.base.isExcludedDefaultKind(kind)
 }
 
+// GetFuzzKind returns the kind that test files containing only fuzz tests should be allocated to. If this is empty,
+// they're treated like any other test file.
+func (c *Config) GetFuzzKind() string {
+	if c.FuzzKind != "" {
+		return c.FuzzKind
+	}
+	if c.base != nil {
+		return c.base.GetFuzzKind()
+	}
+	return ""
+}
+
+// GetBenchmarkKind returns the kind that test files containing only benchmarks should be allocated to. If this is
+// empty, they're treated like any other test file.
+func (c *Config) GetBenchmarkKind() string {
+	if c.BenchmarkKind != "" {
+		return c.BenchmarkKind
+	}
+	if c.base != nil {
+		return c.base.GetBenchmarkKind()
+	}
+	return ""
+}
+
+// GetWasmKind returns the kind that files only built for js/wasm should be allocated to. If this is empty, these files
+// aren't allocated to any rule.
+func (c *Config) GetWasmKind() string {
+	if c.WasmKind != "" {
+		return c.WasmKind
+	}
+	if c.base != nil {
+		return c.base.GetWasmKind()
+	}
+	return ""
+}
+
 func (c *Config) GetKind(kind string) *kinds.Kind {
+	k := c.getKind(kind)
+	if kind == "" {
+		return k
+	}
+
+	var t kinds.Type
+	switch kind {
+	case c.GetFuzzKind():
+		t = kinds.Fuzz
+	case c.GetBenchmarkKind():
+		t = kinds.Benchmark
+	case c.GetWasmKind():
+		t = kinds.Wasm
+	default:
+		return k
+	}
+
+	// These kinds may also be configured as library or test kinds, e.g. to set provided deps
+	if k == nil {
+		return &kinds.Kind{
+			Name:     kind,
+			Type:     t,
+			SrcsAttr: "srcs",
+		}
+	}
+	typedKind := *k
+	typedKind.Type = t
+	return &typedKind
+}
+
+func (c *Config) getKind(kind string) *kinds.Kind {
 	if k, ok := c.LibKinds[kind]; ok {
 		return &kinds.Kind{
 			Name:              kind,
@@ -178,6 +561,8 @@ func (c *Config) GetKind(kind string) *kinds.Kind {
 			SrcsAttr:          k.srcsArg(),
 			DefaultVisibility: k.DefaultVisibility,
 			NonGoSources:      k.NonGoSources,
+			CgoSrcsAttr:       k.CgoSrcsArg,
+			AsmSrcsAttr:       k.AsmSrcsArg,
 		}
 	}
 	if k, ok := c.TestKinds[kind]; ok {
@@ -199,7 +584,7 @@ func (c *Config) GetKind(kind string) *kinds.Kind {
 		}
 	}
 	if c.base != nil {
-		return c.base.GetKind(kind)
+		return c.base.getKind(kind)
 	}
 
 	if k, ok := kinds.DefaultKinds[kind]; ok {
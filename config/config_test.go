@@ -43,6 +43,83 @@ func TestGetKind(t *testing.T) {
 	})
 }
 
+func TestGetFuzzKind(t *testing.T) {
+	t.Run("unknown fuzz kind", func(t *testing.T) {
+		c := Config{base: &Config{FuzzKind: "go_fuzz_test"}}
+		kind := c.GetKind("go_fuzz_test")
+		require.NotNil(t, kind)
+		assert.Equal(t, kinds.Fuzz, kind.Type)
+		assert.Equal(t, "srcs", kind.SrcsAttr)
+	})
+
+	t.Run("fuzz kind configured as a test kind", func(t *testing.T) {
+		c := Config{
+			FuzzKind: "my_fuzz_test",
+			TestKinds: map[string]*KindConfig{
+				"my_fuzz_test": {ProvidedDeps: []string{"//fuzz:lib"}},
+			},
+		}
+		kind := c.GetKind("my_fuzz_test")
+		require.NotNil(t, kind)
+		assert.Equal(t, kinds.Fuzz, kind.Type)
+		assert.Equal(t, []string{"//fuzz:lib"}, kind.ProvidedDeps)
+
+		assert.Equal(t, kinds.Test, c.GetKind("go_test").Type)
+	})
+}
+
+func TestGetGeneratedCode(t *testing.T) {
+	c := Config{
+		base: &Config{
+			GeneratedCode: map[string]*GeneratedCode{
+				"*":             {Exclude: true},
+				"protoc-gen-go": {Deps: []string{"//third_party/go:protobuf"}},
+			},
+		},
+		GeneratedCode: map[string]*GeneratedCode{
+			"mockgen": {Deps: []string{"//third_party/go:mock"}},
+		},
+	}
+
+	assert.Equal(t, []string{"//third_party/go:mock"}, c.GetGeneratedCode("MockGen").Deps)
+	assert.Equal(t, []string{"//third_party/go:protobuf"}, c.GetGeneratedCode("protoc-gen-go").Deps)
+	assert.True(t, c.GetGeneratedCode("stringer").Exclude)
+	assert.True(t, c.GetGeneratedCode("").Exclude)
+	assert.Nil(t, new(Config).GetGeneratedCode("stringer"))
+}
+
+func TestGetBenchmarkKind(t *testing.T) {
+	c := Config{
+		base:          &Config{FuzzKind: "go_fuzz_test"},
+		BenchmarkKind: "go_benchmark",
+	}
+	assert.Equal(t, "go_benchmark", c.GetBenchmarkKind())
+
+	kind := c.GetKind("go_benchmark")
+	require.NotNil(t, kind)
+	assert.Equal(t, kinds.Benchmark, kind.Type)
+	assert.True(t, kind.Type.IsTest())
+	assert.Equal(t, kinds.Fuzz, c.GetKind("go_fuzz_test").Type)
+}
+
+func TestGetWasmKind(t *testing.T) {
+	c := Config{base: &Config{WasmKind: "go_wasm_library"}}
+	assert.Equal(t, "go_wasm_library", c.GetWasmKind())
+
+	kind := c.GetKind("go_wasm_library")
+	require.NotNil(t, kind)
+	assert.Equal(t, kinds.Wasm, kind.Type)
+	assert.False(t, kind.Type.IsTest())
+}
+
+func TestHasLanguage(t *testing.T) {
+	c := Config{base: &Config{Languages: []string{"python"}}}
+	assert.True(t, c.HasLanguage("go"))
+	assert.True(t, c.HasLanguage("python"))
+	assert.False(t, c.HasLanguage("rust"))
+	assert.False(t, new(Config).HasLanguage("python"))
+}
+
 func TestGetStop(t *testing.T) {
 	ptr := func(val bool) *bool {
 		return &val
@@ -0,0 +1,243 @@
+// Package depgraph exports the dependency graph of the targets in the repo, as puku sees it in the deps of the rules in
+// BUILD files, as DOT for graphviz or as a mermaid flowchart
+package depgraph
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/generate"
+	"github.com/please-build/puku/graph"
+)
+
+// Options filter the targets in the graph
+type Options struct {
+	// Depth is how many deps away from the targets in the paths to go. 0 means there's no limit.
+	Depth int
+	// Kinds are the kinds of rule to include, e.g. go_library. All kinds are included if this is empty.
+	Kinds []string
+	// ThirdParty includes third party targets, i.e. ones in subrepos or third party directories. Their deps aren't
+	// followed.
+	ThirdParty bool
+}
+
+// Node is a target in the graph
+type Node struct {
+	// Label is the canonical label of the target
+	Label string
+	// Kind is the kind of rule, which is empty if it couldn't be found, e.g. because it's in a subrepo
+	Kind       string
+	ThirdParty bool
+}
+
+// Edge is a dependency of one target on another
+type Edge struct {
+	From, To string
+}
+
+// Graph is a dependency graph of targets
+type Graph struct {
+	Nodes map[string]*Node
+	Edges []Edge
+}
+
+type builder struct {
+	graph *graph.Graph
+	opts  Options
+	kinds map[string]bool
+	ret   *Graph
+}
+
+// Build builds the graph of the targets in the given paths and what they depend on, following the deps of each rule
+func Build(g *graph.Graph, paths []string, opts Options) (*Graph, error) {
+	b := &builder{
+		graph: g,
+		opts:  opts,
+		kinds: map[string]bool{},
+		ret:   &Graph{Nodes: map[string]*Node{}},
+	}
+	for _, kind := range opts.Kinds {
+		b.kinds[kind] = true
+	}
+
+	var queue []string
+	for _, path := range paths {
+		file, err := g.LoadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range file.Rules("") {
+			if rule.Name() == "" || !b.included(rule.Kind()) {
+				continue
+			}
+			label := edit.BuildTarget(rule.Name(), path, "")
+			if _, ok := b.ret.Nodes[label]; !ok {
+				b.ret.Nodes[label] = &Node{Label: label, Kind: rule.Kind()}
+				queue = append(queue, label)
+			}
+		}
+	}
+
+	for depth := 1; len(queue) > 0 && (opts.Depth == 0 || depth <= opts.Depth); depth++ {
+		var next []string
+		for _, label := range queue {
+			deps, err := b.visit(label)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, deps...)
+		}
+		queue = next
+	}
+
+	sort.Slice(b.ret.Edges, func(i, j int) bool {
+		if b.ret.Edges[i].From != b.ret.Edges[j].From {
+			return b.ret.Edges[i].From < b.ret.Edges[j].From
+		}
+		return b.ret.Edges[i].To < b.ret.Edges[j].To
+	})
+	return b.ret, nil
+}
+
+// visit adds the deps of a target to the graph, returning the ones that haven't been visited yet
+func (b *builder) visit(label string) ([]string, error) {
+	node := b.ret.Nodes[label]
+	if node.ThirdParty {
+		return nil, nil
+	}
+	l := labels.Parse(label)
+	rule, err := b.rule(l)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+	conf, err := config.ReadConfig(l.Package)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, dep := range rule.AttrStrings("deps") {
+		dep = canonical(dep, l.Package)
+		thirdParty := generate.Classify(conf, l.Package, dep) == generate.ThirdParty
+		if thirdParty && !b.opts.ThirdParty {
+			continue
+		}
+		depNode, ok := b.ret.Nodes[dep]
+		if !ok {
+			depNode = &Node{Label: dep, ThirdParty: thirdParty}
+			if !thirdParty {
+				depRule, err := b.rule(labels.Parse(dep))
+				if err != nil {
+					return nil, err
+				}
+				if depRule != nil {
+					depNode.Kind = depRule.Kind()
+				}
+			}
+			if !thirdParty && !b.included(depNode.Kind) {
+				continue
+			}
+			b.ret.Nodes[dep] = depNode
+			ret = append(ret, dep)
+		}
+		b.ret.Edges = append(b.ret.Edges, Edge{From: label, To: dep})
+	}
+	return ret, nil
+}
+
+// rule returns the rule for a label in the repo, or nil if it isn't in its package's BUILD file, e.g. because a
+// macro creates it
+func (b *builder) rule(l labels.Label) (*build.Rule, error) {
+	pkg := l.Package
+	if pkg == "" {
+		pkg = "."
+	}
+	file, err := b.graph.LoadFile(pkg)
+	if err != nil {
+		return nil, err
+	}
+	return edit.FindTargetByName(file, l.Target), nil
+}
+
+// included returns whether rules of the kind are included in the graph. Rules of unknown kinds are included unless
+// the kinds are filtered.
+func (b *builder) included(kind string) bool {
+	return len(b.kinds) == 0 || b.kinds[kind]
+}
+
+// canonical returns a dep in the form that labels are compared in. Subrepo labels are left alone.
+func canonical(dep, pkg string) string {
+	if strings.HasPrefix(dep, "///") {
+		return dep
+	}
+	return labels.ParseRelative(dep, pkg).Format()
+}
+
+// sortedNodes returns the nodes of the graph ordered by label
+func (g *Graph) sortedNodes() []*Node {
+	ret := make([]*Node, 0, len(g.Nodes))
+	for _, node := range g.Nodes {
+		ret = append(ret, node)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Label < ret[j].Label })
+	return ret
+}
+
+// description returns the text of a node in the rendered graph
+func (n *Node) description() string {
+	if n.Kind == "" {
+		return n.Label
+	}
+	return fmt.Sprintf("%v\n%v", n.Label, n.Kind)
+}
+
+// WriteDOT writes the graph as DOT, for graphviz to render. Third party targets are drawn with dashed lines.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("digraph deps {\n")
+	sb.WriteString("  rankdir = LR;\n")
+	sb.WriteString("  node [shape = box];\n")
+	for _, node := range g.sortedNodes() {
+		attrs := fmt.Sprintf("label = %q", node.description())
+		if node.ThirdParty {
+			attrs += ", style = dashed"
+		}
+		fmt.Fprintf(&sb, "  %q [%v];\n", node.Label, attrs)
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %q -> %q;\n", edge.From, edge.To)
+	}
+	sb.WriteString("}\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// WriteMermaid writes the graph as a mermaid flowchart. Labels can't be used as node IDs in mermaid, so the nodes are
+// numbered in the order of their labels.
+func (g *Graph) WriteMermaid(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	ids := make(map[string]string, len(g.Nodes))
+	for i, node := range g.sortedNodes() {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.Label] = id
+		text := strings.ReplaceAll(node.description(), "\n", "<br>")
+		if node.ThirdParty {
+			fmt.Fprintf(&sb, "  %v([\"%v\"])\n", id, text)
+		} else {
+			fmt.Fprintf(&sb, "  %v[\"%v\"]\n", id, text)
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %v --> %v\n", ids[edge.From], ids[edge.To])
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
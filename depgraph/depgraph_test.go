@@ -0,0 +1,135 @@
+package depgraph
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestBuild(t *testing.T) {
+	files := map[string]string{
+		"foo/BUILD": `go_library(
+    name = "foo",
+    srcs = ["foo.go"],
+    deps = [
+        "///third_party/go/github.com_example_module//:module",
+        "//bar",
+    ],
+)
+
+go_test(
+    name = "foo_test",
+    srcs = ["foo_test.go"],
+    deps = [":foo"],
+)
+`,
+		"bar/BUILD": `go_library(
+    name = "bar",
+    srcs = ["bar.go"],
+    deps = [
+        "//baz:gen",
+        "//third_party/go:yaml",
+    ],
+)
+`,
+		"baz/BUILD": `genrule(
+    name = "gen",
+    outs = ["gen.go"],
+    deps = ["//qux"],
+)
+`,
+		"qux/BUILD": `go_library(name = "qux")
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		load := func() *graph.Graph { return graph.New([]string{"BUILD"}, options.TestOptions) }
+
+		t.Run("follows the deps of the targets", func(t *testing.T) {
+			g, err := Build(load(), []string{"foo"}, Options{})
+			require.NoError(t, err)
+			assert.Equal(t, map[string]*Node{
+				"//foo":          {Label: "//foo", Kind: "go_library"},
+				"//foo:foo_test": {Label: "//foo:foo_test", Kind: "go_test"},
+				"//bar":          {Label: "//bar", Kind: "go_library"},
+				"//baz:gen":      {Label: "//baz:gen", Kind: "genrule"},
+				"//qux":          {Label: "//qux", Kind: "go_library"},
+			}, g.Nodes)
+			assert.Equal(t, []Edge{
+				{From: "//bar", To: "//baz:gen"},
+				{From: "//baz:gen", To: "//qux"},
+				{From: "//foo", To: "//bar"},
+				{From: "//foo:foo_test", To: "//foo"},
+			}, g.Edges)
+		})
+
+		t.Run("limits the depth", func(t *testing.T) {
+			g, err := Build(load(), []string{"foo"}, Options{Depth: 1})
+			require.NoError(t, err)
+			assert.Len(t, g.Nodes, 3)
+			assert.Contains(t, g.Nodes, "//bar")
+			assert.NotContains(t, g.Nodes, "//baz:gen")
+		})
+
+		t.Run("filters by kind", func(t *testing.T) {
+			g, err := Build(load(), []string{"foo", "bar", "baz", "qux"}, Options{Kinds: []string{"go_library"}})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"//bar", "//foo", "//qux"}, sortedLabels(g))
+			assert.Equal(t, []Edge{{From: "//foo", To: "//bar"}}, g.Edges)
+		})
+
+		t.Run("includes third party targets", func(t *testing.T) {
+			g, err := Build(load(), []string{"foo"}, Options{Depth: 2, ThirdParty: true})
+			require.NoError(t, err)
+			assert.Equal(t, &Node{Label: "///third_party/go/github.com_example_module//:module", ThirdParty: true}, g.Nodes["///third_party/go/github.com_example_module//:module"])
+			assert.Equal(t, &Node{Label: "//third_party/go:yaml", ThirdParty: true}, g.Nodes["//third_party/go:yaml"])
+		})
+	})
+}
+
+func sortedLabels(g *Graph) []string {
+	var ret []string
+	for _, node := range g.sortedNodes() {
+		ret = append(ret, node.Label)
+	}
+	return ret
+}
+
+func TestWrite(t *testing.T) {
+	g := &Graph{
+		Nodes: map[string]*Node{
+			"//foo": {Label: "//foo", Kind: "go_library"},
+			"///third_party/go/github.com_example_module//:module": {Label: "///third_party/go/github.com_example_module//:module", ThirdParty: true},
+		},
+		Edges: []Edge{{From: "//foo", To: "///third_party/go/github.com_example_module//:module"}},
+	}
+
+	t.Run("DOT", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		require.NoError(t, g.WriteDOT(out))
+		assert.Equal(t, `digraph deps {
+  rankdir = LR;
+  node [shape = box];
+  "///third_party/go/github.com_example_module//:module" [label = "///third_party/go/github.com_example_module//:module", style = dashed];
+  "//foo" [label = "//foo\ngo_library"];
+  "//foo" -> "///third_party/go/github.com_example_module//:module";
+}
+`, out.String())
+	})
+
+	t.Run("mermaid", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		require.NoError(t, g.WriteMermaid(out))
+		assert.Equal(t, `flowchart LR
+  n0(["///third_party/go/github.com_example_module//:module"])
+  n1["//foo<br>go_library"]
+  n1 --> n0
+`, out.String())
+	})
+}
@@ -0,0 +1,162 @@
+// Package diff prints unified diffs of the changes puku would make to BUILD files, so they can be reviewed before
+// they're written
+package diff
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"golang.org/x/term"
+
+	"github.com/please-build/puku/config"
+)
+
+const (
+	reset = "\033[0m"
+	bold  = "\033[1m"
+	red   = "\033[31m"
+	green = "\033[32m"
+	cyan  = "\033[36m"
+)
+
+// Unified returns a unified diff between the contents of a file before and after it's changed. The contents before are
+// nil if the file doesn't exist yet.
+func Unified(path string, before, after []byte) (string, error) {
+	from := "a/" + path
+	if before == nil {
+		from = "/dev/null"
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(before),
+		B:        splitLines(after),
+		FromFile: from,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+}
+
+// splitLines splits a file into lines, keeping their line endings. Unlike difflib.SplitLines, there isn't an empty line
+// after the final line ending.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	lines := strings.SplitAfter(string(content), "\n")
+	if last := len(lines) - 1; lines[last] == "" {
+		lines = lines[:last]
+	} else {
+		lines[last] += "\n"
+	}
+	return lines
+}
+
+// Writer writes diffs to the terminal, colouring them and truncating their lines as configured. If a pager is
+// configured, and the output is a terminal, the diffs are piped to it.
+type Writer struct {
+	out   io.Writer
+	color bool
+	width int
+	// partial is the end of the last write, if it wasn't a whole line
+	partial []byte
+	pager   *exec.Cmd
+	stdin   io.WriteCloser
+}
+
+// NewWriter returns a Writer that writes to out using the diffColor, diffWidth and diffPager options in the config. It
+// has to be closed once the diffs have been written, to wait for the pager to exit.
+func NewWriter(out io.Writer, conf *config.Config) (*Writer, error) {
+	w := &Writer{out: out, width: conf.GetDiffWidth()}
+	tty := isTerminal(out)
+	switch color := conf.GetDiffColor(); color {
+	case "always":
+		w.color = true
+	case "auto":
+		w.color = tty && os.Getenv("NO_COLOR") == ""
+	case "never":
+	default:
+		return nil, fmt.Errorf("invalid diffColor %q, must be auto, always or never", color)
+	}
+
+	if pager := conf.GetDiffPager(); pager != "" && tty {
+		w.pager = exec.Command("sh", "-c", pager)
+		w.pager.Stdout = out
+		w.pager.Stderr = os.Stderr
+		stdin, err := w.pager.StdinPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := w.pager.Start(); err != nil {
+			return nil, fmt.Errorf("failed to start pager %q: %v", pager, err)
+		}
+		w.stdin = stdin
+		w.out = stdin
+	}
+	return w, nil
+}
+
+// Write writes the lines of a diff, holding back any partial line at the end until the rest of it is written
+func (w *Writer) Write(p []byte) (int, error) {
+	buf := append(w.partial, p...)
+	w.partial = nil
+	for len(buf) > 0 {
+		i := bytes.IndexByte(buf, '\n')
+		if i == -1 {
+			w.partial = buf
+			break
+		}
+		if _, err := io.WriteString(w.out, w.formatLine(string(buf[:i]))+"\n"); err != nil {
+			return 0, err
+		}
+		buf = buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Close writes any partial line that's left, and waits for the pager to exit
+func (w *Writer) Close() error {
+	if len(w.partial) > 0 {
+		if _, err := io.WriteString(w.out, w.formatLine(string(w.partial))); err != nil {
+			return err
+		}
+		w.partial = nil
+	}
+	if w.pager == nil {
+		return nil
+	}
+	if err := w.stdin.Close(); err != nil {
+		return err
+	}
+	return w.pager.Wait()
+}
+
+// formatLine truncates a line of a diff to the width, and colours it by what it shows
+func (w *Writer) formatLine(line string) string {
+	if w.width > 0 && utf8.RuneCountInString(line) > w.width {
+		line = string([]rune(line)[:w.width-1]) + "…"
+	}
+	if !w.color {
+		return line
+	}
+	switch {
+	case strings.HasPrefix(line, "+++ ") || strings.HasPrefix(line, "--- "):
+		return bold + line + reset
+	case strings.HasPrefix(line, "@@"):
+		return cyan + line + reset
+	case strings.HasPrefix(line, "+"):
+		return green + line + reset
+	case strings.HasPrefix(line, "-"):
+		return red + line + reset
+	}
+	return line
+}
+
+func isTerminal(out io.Writer) bool {
+	f, ok := out.(*os.File)
+	return ok && term.IsTerminal(int(f.Fd()))
+}
@@ -0,0 +1,84 @@
+package diff
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+)
+
+func TestUnified(t *testing.T) {
+	before := []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n)\n")
+	after := []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n    deps = [\"//bar\"],\n)\n")
+
+	t.Run("diffs the changes", func(t *testing.T) {
+		d, err := Unified("foo/BUILD", before, after)
+		require.NoError(t, err)
+		assert.Equal(t, `--- a/foo/BUILD
++++ b/foo/BUILD
+@@ -1,4 +1,5 @@
+ go_library(
+     name = "foo",
+     srcs = ["foo.go"],
++    deps = ["//bar"],
+ )
+`, d)
+	})
+
+	t.Run("diffs new files against /dev/null", func(t *testing.T) {
+		d, err := Unified("foo/BUILD", nil, before)
+		require.NoError(t, err)
+		assert.Contains(t, d, "--- /dev/null\n+++ b/foo/BUILD\n")
+	})
+}
+
+func TestWriter(t *testing.T) {
+	diff := "--- a/foo/BUILD\n+++ b/foo/BUILD\n@@ -1,2 +1,2 @@\n go_library(\n-    name = \"foo\",\n+    name = \"foo_library_with_a_long_name\",\n"
+
+	t.Run("colours the diff", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w, err := NewWriter(out, &config.Config{DiffColor: "always"})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(diff[:20]))
+		require.NoError(t, err)
+		_, err = w.Write([]byte(diff[20:]))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+
+		assert.Equal(t, bold+"--- a/foo/BUILD"+reset+"\n"+
+			bold+"+++ b/foo/BUILD"+reset+"\n"+
+			cyan+"@@ -1,2 +1,2 @@"+reset+"\n"+
+			" go_library(\n"+
+			red+"-    name = \"foo\","+reset+"\n"+
+			green+"+    name = \"foo_library_with_a_long_name\","+reset+"\n", out.String())
+	})
+
+	t.Run("doesn't colour the diff if the output isn't a terminal", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w, err := NewWriter(out, &config.Config{})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(diff))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Equal(t, diff, out.String())
+	})
+
+	t.Run("truncates lines to the width", func(t *testing.T) {
+		out := new(bytes.Buffer)
+		w, err := NewWriter(out, &config.Config{DiffWidth: 20})
+		require.NoError(t, err)
+		_, err = w.Write([]byte(diff))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		assert.Contains(t, out.String(), "\n+    name = \"foo_li…\n")
+		assert.Contains(t, out.String(), "\n-    name = \"foo\",\n")
+	})
+
+	t.Run("rejects invalid colour options", func(t *testing.T) {
+		_, err := NewWriter(new(bytes.Buffer), &config.Config{DiffColor: "sometimes"})
+		assert.Error(t, err)
+	})
+}
@@ -9,6 +9,11 @@ import (
 )
 
 func EnsureSubinclude(file *build.File) {
+	EnsureSubincludeOf(file, "///go//build_defs:go")
+}
+
+// EnsureSubincludeOf adds the given build definitions to the file's subinclude, unless they're already subincluded
+func EnsureSubincludeOf(file *build.File, label string) {
 	var subinclude *build.CallExpr
 	for _, expr := range file.Stmt {
 		call, ok := expr.(*build.CallExpr)
@@ -34,7 +39,7 @@ func EnsureSubinclude(file *build.File) {
 				continue
 			}
 
-			if str.Value == "///go//build_defs:go" {
+			if str.Value == label {
 				return
 			}
 		}
@@ -45,7 +50,7 @@ func EnsureSubinclude(file *build.File) {
 		}
 		file.Stmt = append([]build.Expr{subinclude}, file.Stmt...)
 	}
-	subinclude.List = append(subinclude.List, NewStringExpr("///go//build_defs:go"))
+	subinclude.List = append(subinclude.List, NewStringExpr(label))
 }
 
 func FindTargetByName(file *build.File, name string) *build.Rule {
@@ -1,6 +1,8 @@
 package edit
 
 import (
+	"sort"
+
 	"github.com/please-build/buildtools/build"
 
 	"github.com/please-build/puku/kinds"
@@ -20,12 +22,215 @@ func (rule *Rule) SetOrDeleteAttr(name string, values []string) {
 		return
 	}
 
+	listExpr, _ := rule.Attr(name).(*build.ListExpr)
+	rule.SetAttr(name, mergeStringList(listExpr, values))
+}
+
+// SetOrDeleteSelectAttr works like SetOrDeleteAttr, but also maintains a select() appended to the list for values that
+// are only needed under certain config conditions. If there are no conditional values, any existing select() is
+// removed.
+func (rule *Rule) SetOrDeleteSelectAttr(name string, values []string, conditional map[string][]string) {
+	listExpr, selectExpr := splitSelect(rule.Attr(name))
+	if len(conditional) == 0 {
+		if len(values) == 0 {
+			rule.DelAttr(name)
+			return
+		}
+		rule.SetAttr(name, mergeStringList(listExpr, values))
+		return
+	}
+
+	var existing *build.DictExpr
+	if selectExpr != nil && len(selectExpr.List) == 1 {
+		existing, _ = selectExpr.List[0].(*build.DictExpr)
+	}
+	existingValues := map[string]*build.ListExpr{}
+	if existing != nil {
+		for _, kv := range existing.List {
+			key, ok := kv.Key.(*build.StringExpr)
+			if !ok {
+				continue
+			}
+			list, _ := kv.Value.(*build.ListExpr)
+			existingValues[key.Value] = list
+		}
+	}
+
+	conditions := make([]string, 0, len(conditional))
+	for condition := range conditional {
+		if condition != DefaultCondition {
+			conditions = append(conditions, condition)
+		}
+	}
+	sort.Strings(conditions)
+
+	// The default condition always comes last, and is empty unless there are values for when no other condition matches
+	dict := &build.DictExpr{ForceMultiLine: true}
+	for _, condition := range append(conditions, DefaultCondition) {
+		dict.List = append(dict.List, &build.KeyValueExpr{
+			Key:   NewStringExpr(condition),
+			Value: mergeStringList(existingValues[condition], conditional[condition]),
+		})
+	}
+
+	call := &build.CallExpr{
+		X:    &build.Ident{Name: "select"},
+		List: []build.Expr{dict},
+	}
+	if len(values) == 0 {
+		rule.SetAttr(name, call)
+		return
+	}
+	rule.SetAttr(name, &build.BinaryExpr{
+		X:  mergeStringList(listExpr, values),
+		Op: "+",
+		Y:  call,
+	})
+}
+
+// UpdateSelectAttr works like SetOrDeleteSelectAttr, but only adds or removes the values that managed returns true for.
+// Anything else in the attribute, e.g. labels added by hand, is left where it is, including under its select()
+// conditions.
+func (rule *Rule) UpdateSelectAttr(name string, values []string, conditional map[string][]string, managed func(string) bool) {
+	listExpr, selectExpr := splitSelect(rule.Attr(name))
+
+	var existing *build.DictExpr
+	if selectExpr != nil && len(selectExpr.List) == 1 {
+		existing, _ = selectExpr.List[0].(*build.DictExpr)
+	}
+	existingValues := map[string]*build.ListExpr{}
+	conditions := []string{}
+	for condition := range conditional {
+		if condition != DefaultCondition {
+			conditions = append(conditions, condition)
+		}
+	}
+	if existing != nil {
+		for _, kv := range existing.List {
+			key, ok := kv.Key.(*build.StringExpr)
+			if !ok {
+				continue
+			}
+			list, _ := kv.Value.(*build.ListExpr)
+			existingValues[key.Value] = list
+			if _, ok := conditional[key.Value]; !ok && key.Value != DefaultCondition {
+				conditions = append(conditions, key.Value)
+			}
+		}
+	}
+	sort.Strings(conditions)
+
+	// Conditions are dropped once they have nothing left under them, other than the default condition, which stays as
+	// long as there's a select()
+	dict := &build.DictExpr{ForceMultiLine: true}
+	for _, condition := range conditions {
+		list := mergeManagedList(existingValues[condition], conditional[condition], managed)
+		if len(list.List) > 0 {
+			dict.List = append(dict.List, &build.KeyValueExpr{Key: NewStringExpr(condition), Value: list})
+		}
+	}
+	if def := mergeManagedList(existingValues[DefaultCondition], conditional[DefaultCondition], managed); len(dict.List) > 0 || len(def.List) > 0 {
+		dict.List = append(dict.List, &build.KeyValueExpr{Key: NewStringExpr(DefaultCondition), Value: def})
+	}
+
+	list := mergeManagedList(listExpr, values, managed)
+	if len(dict.List) == 0 {
+		if len(list.List) == 0 {
+			rule.DelAttr(name)
+			return
+		}
+		rule.SetAttr(name, list)
+		return
+	}
+	call := &build.CallExpr{
+		X:    &build.Ident{Name: "select"},
+		List: []build.Expr{dict},
+	}
+	if len(list.List) == 0 {
+		rule.SetAttr(name, call)
+		return
+	}
+	rule.SetAttr(name, &build.BinaryExpr{X: list, Op: "+", Y: call})
+}
+
+// mergeManagedList works like mergeStringList, but only adds or removes the managed strings. Any other expressions in
+// the list are kept where they are.
+func mergeManagedList(listExpr *build.ListExpr, values []string, managed func(string) bool) *build.ListExpr {
+	if listExpr == nil {
+		listExpr = &build.ListExpr{}
+	}
+	valuesMap := make(map[string]struct{}, len(values))
+	for _, v := range values {
+		valuesMap[v] = struct{}{}
+	}
+
+	exprs := make([]build.Expr, 0, len(listExpr.List)+len(values))
+	done := map[string]struct{}{}
+	for _, expr := range listExpr.List {
+		val, ok := expr.(*build.StringExpr)
+		if !ok {
+			exprs = append(exprs, expr)
+			continue
+		}
+		if _, ok := done[val.Value]; ok {
+			continue
+		}
+		if _, ok := valuesMap[val.Value]; ok || !managed(val.Value) {
+			exprs = append(exprs, val)
+			done[val.Value] = struct{}{}
+		}
+	}
+	for _, v := range values {
+		if _, ok := done[v]; !ok {
+			exprs = append(exprs, NewStringExpr(v))
+			done[v] = struct{}{}
+		}
+	}
+
+	listExpr.List = exprs
+	return listExpr
+}
+
+// DefaultCondition is the select() key that matches when no other condition does
+const DefaultCondition = "//conditions:default"
+
+// splitSelect splits an attribute value of the form `[...] + select({...})` into its list and the select() call. Either
+// may be nil if not present.
+func splitSelect(expr build.Expr) (*build.ListExpr, *build.CallExpr) {
+	switch e := expr.(type) {
+	case *build.ListExpr:
+		return e, nil
+	case *build.CallExpr:
+		if isSelect(e) {
+			return nil, e
+		}
+	case *build.BinaryExpr:
+		if e.Op != "+" {
+			return nil, nil
+		}
+		list, _ := e.X.(*build.ListExpr)
+		call, _ := e.Y.(*build.CallExpr)
+		if call != nil && !isSelect(call) {
+			call = nil
+		}
+		return list, call
+	}
+	return nil, nil
+}
+
+func isSelect(call *build.CallExpr) bool {
+	ident, ok := call.X.(*build.Ident)
+	return ok && ident.Name == "select"
+}
+
+// mergeStringList returns a list expression containing the values passed in. It will keep the existing expressions in
+// the list to maintain things like comments.
+func mergeStringList(listExpr *build.ListExpr, values []string) *build.ListExpr {
 	valuesMap := make(map[string]struct{})
 	for _, v := range values {
 		valuesMap[v] = struct{}{}
 	}
 
-	listExpr, _ := rule.Attr(name).(*build.ListExpr)
 	if listExpr == nil {
 		listExpr = &build.ListExpr{}
 	}
@@ -53,33 +258,60 @@ func (rule *Rule) SetOrDeleteAttr(name string, values []string) {
 	}
 
 	listExpr.List = exprs
-	rule.SetAttr(name, listExpr)
+	return listExpr
 }
 
 func (rule *Rule) IsTest() bool {
-	return rule.Kind.Type == kinds.Test
+	return rule.Kind.Type.IsTest()
 }
 
 func (rule *Rule) SrcsAttr() string {
 	return rule.Kind.SrcsAttr
 }
 
+// SrcsAttrs returns all the attributes that take Go sources. For cgo kinds, this includes the attribute for sources
+// that import "C".
+func (rule *Rule) SrcsAttrs() []string {
+	if rule.Kind.IsCgo() {
+		return []string{rule.SrcsAttr(), rule.Kind.CgoSrcsAttr}
+	}
+	return []string{rule.SrcsAttr()}
+}
+
 func (rule *Rule) AddSrc(src string) {
-	srcsAttr := rule.SrcsAttr()
-	srcs := rule.AttrStrings(srcsAttr)
-	rule.SetOrDeleteAttr(srcsAttr, append(srcs, src))
+	rule.addToAttr(rule.SrcsAttr(), src)
+}
+
+// AddCgoSrc adds a source that imports "C" to the rule. This should only be called on cgo kinds.
+func (rule *Rule) AddCgoSrc(src string) {
+	rule.addToAttr(rule.Kind.CgoSrcsAttr, src)
+}
+
+func (rule *Rule) addToAttr(attr, src string) {
+	srcs := rule.AttrStrings(attr)
+	rule.SetOrDeleteAttr(attr, append(srcs, src))
 }
 
 func (rule *Rule) RemoveSrc(rem string) {
-	srcsAttr := rule.SrcsAttr()
-	srcs := rule.AttrStrings(srcsAttr)
-	set := make([]string, 0, len(srcs))
-	for _, src := range srcs {
-		if src != rem {
-			set = append(set, src)
+	for _, srcsAttr := range rule.SrcsAttrs() {
+		srcs := rule.AttrStrings(srcsAttr)
+		set := make([]string, 0, len(srcs))
+		for _, src := range srcs {
+			if src != rem {
+				set = append(set, src)
+			}
+		}
+		if len(set) == len(srcs) && len(rule.SrcsAttrs()) > 1 {
+			continue // Not in this attribute so leave it alone
 		}
+		rule.SetOrDeleteAttr(srcsAttr, set)
 	}
-	rule.SetOrDeleteAttr(srcsAttr, set)
+}
+
+// SetKind changes the kind of the rule, updating the function called in the BUILD file
+func (rule *Rule) SetKind(kind *kinds.Kind) {
+	rule.Kind = kind
+	rule.Call.X = &build.Ident{Name: kind.Name}
 }
 
 func (rule *Rule) LocalLabel() string {
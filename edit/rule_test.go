@@ -0,0 +1,92 @@
+package edit
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/please-build/puku/kinds"
+)
+
+func TestSetOrDeleteSelectAttr(t *testing.T) {
+	newRule := func(deps string) *Rule {
+		file, err := build.ParseBuild("BUILD", []byte("go_library(\n    name = \"foo\",\n    deps = "+deps+",\n)\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return NewRule(file.Rules("")[0], kinds.DefaultKinds["go_library"], "foo")
+	}
+
+	t.Run("adds a select for conditional values", func(t *testing.T) {
+		rule := newRule(`["//bar"]`)
+		rule.SetOrDeleteSelectAttr("deps", []string{"//bar"}, map[string][]string{"//config:linux": {"//baz"}})
+
+		assert.Equal(t, `["//bar"] + select({
+    "//config:linux": ["//baz"],
+    "//conditions:default": [],
+})`, build.FormatString(rule.Attr("deps")))
+	})
+
+	t.Run("keeps comments on existing conditional values", func(t *testing.T) {
+		rule := newRule(`select({
+        "//config:linux": [
+            # needed for linux
+            "//baz",
+        ],
+        "//conditions:default": [],
+    })`)
+		rule.SetOrDeleteSelectAttr("deps", nil, map[string][]string{"//config:linux": {"//baz", "//qux"}})
+
+		assert.Contains(t, build.FormatString(rule.Attr("deps")), "# needed for linux")
+		assert.NotContains(t, build.FormatString(rule.Attr("deps")), "+")
+	})
+
+	t.Run("removes the select when there are no conditional values", func(t *testing.T) {
+		rule := newRule(`["//bar"] + select({"//config:linux": ["//baz"]})`)
+		rule.SetOrDeleteSelectAttr("deps", []string{"//bar"}, nil)
+
+		assert.Equal(t, []string{"//bar"}, rule.AttrStrings("deps"))
+	})
+}
+
+func TestUpdateSelectAttr(t *testing.T) {
+	file, err := build.ParseBuild("BUILD", []byte(`go_library(
+    name = "foo",
+    asm_srcs = [":gen", "old.s", "a.s", VAR] + select({
+        "//config:amd64": [":gen_amd64", "old_amd64.s"],
+        "//config:arm64": ["old_arm64.s"],
+        "//conditions:default": [],
+    }),
+)
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := NewRule(file.Rules("")[0], kinds.DefaultKinds["go_library"], "foo")
+	managed := func(src string) bool { return strings.HasSuffix(src, ".s") }
+
+	rule.UpdateSelectAttr("asm_srcs", []string{"a.s", "b.s"}, map[string][]string{"//config:amd64": {"a_amd64.s"}}, managed)
+	assert.Equal(t, `[
+    ":gen",
+    "a.s",
+    VAR,
+    "b.s",
+] + select({
+    "//config:amd64": [
+        ":gen_amd64",
+        "a_amd64.s",
+    ],
+    "//conditions:default": [],
+})`, build.FormatString(rule.Attr("asm_srcs")))
+
+	rule.UpdateSelectAttr("asm_srcs", nil, nil, managed)
+	assert.Equal(t, `[
+    ":gen",
+    VAR,
+] + select({
+    "//config:amd64": [":gen_amd64"],
+    "//conditions:default": [],
+})`, build.FormatString(rule.Attr("asm_srcs")))
+}
@@ -0,0 +1,134 @@
+package generate
+
+import (
+	"bufio"
+	"go/build/constraint"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+)
+
+// updateAsmSrcs keeps the assembly sources of the package's libraries up to date with the .s files in the directory.
+// Each file goes on the library that has the package's Go sources. When the libraries are split by build tag set, that's
+// the library of each tag set that builds the file. Otherwise, it's the package's library, where files excluded by the
+// build constraints of every configured tag set, including the ones implied by their GOOS and GOARCH file name
+// suffixes, are left out, and files only some of the tag sets include are added under a select(). Only the .s files in
+// the directory are added or removed, so anything else in the attribute, e.g. the output of a genrule, is left alone.
+func (u *updater) updateAsmSrcs(conf *config.Config, pkgDir string, rules []*edit.Rule) error {
+	var libs []*edit.Rule
+	for _, r := range rules {
+		if r.Kind.Type == kinds.Lib && !r.Kind.NonGoSources && r.Kind.AsmSrcsAttr != "" && r.Name() != filepath.Base(pkgDir)+"_wasm" {
+			libs = append(libs, r)
+		}
+	}
+	if len(libs) == 0 {
+		return nil
+	}
+
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return err
+	}
+	var names []string
+	constraints := map[string]constraint.Expr{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || filepath.Ext(entry.Name()) != ".s" {
+			continue
+		}
+		expr, err := readBuildConstraint(filepath.Join(pkgDir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		names = append(names, entry.Name())
+		constraints[entry.Name()] = andConstraints(expr, fileNameConstraint(entry.Name()))
+	}
+
+	tagSets := conf.GetBuildTagSets()
+	owner := true
+	for _, rule := range libs {
+		var asmSrcs []string
+		conditionalAsmSrcs := map[string][]string{}
+		if _, set := splitTagSet(conf, rule); set != nil {
+			// The library is only built with its own tag set, so it has the files that set builds
+			for _, name := range names {
+				if expr := constraints[name]; expr == nil || expr.Eval(tagMatcher(set.Tags)) {
+					asmSrcs = append(asmSrcs, name)
+				}
+			}
+		} else if owner {
+			owner = false
+			// Like deps, files that only some of the tag sets build are added under the select() conditions of those sets
+			for _, name := range names {
+				for _, condition := range constraintConditions(constraints[name], tagSets) {
+					if condition == "" {
+						asmSrcs = append(asmSrcs, name)
+					} else {
+						conditionalAsmSrcs[condition] = append(conditionalAsmSrcs[condition], name)
+					}
+				}
+			}
+		} else {
+			// Any other libraries in the package are written by hand, so their sources are left alone
+			continue
+		}
+
+		// Leave the attribute alone if it's been written by hand as something we don't understand, e.g. a glob
+		if existing := rule.Attr(rule.Kind.AsmSrcsAttr); existing != nil && !isListOrSelect(existing) {
+			continue
+		}
+		rule.UpdateSelectAttr(rule.Kind.AsmSrcsAttr, asmSrcs, conditionalAsmSrcs, isLocalAsmSrc)
+	}
+	return nil
+}
+
+// isLocalAsmSrc returns whether an entry in the assembly sources is a .s file in the package's directory, rather than
+// e.g. a label or a file in a subdirectory
+func isLocalAsmSrc(src string) bool {
+	return filepath.Ext(src) == ".s" && !strings.ContainsAny(src, "/:")
+}
+
+// isListOrSelect returns whether the expression is a list, a select(), or a list with a select() appended, which are the
+// forms SetOrDeleteSelectAttr maintains
+func isListOrSelect(expr build.Expr) bool {
+	switch e := expr.(type) {
+	case *build.ListExpr:
+		return true
+	case *build.CallExpr:
+		ident, ok := e.X.(*build.Ident)
+		return ok && ident.Name == "select"
+	case *build.BinaryExpr:
+		_, ok := e.X.(*build.ListExpr)
+		return ok && e.Op == "+" && isListOrSelect(e.Y)
+	}
+	return false
+}
+
+// readBuildConstraint reads the build constraint from the header of a non-Go source file, such as assembly. Like Go
+// files, the constraint must appear before the first line that isn't blank or a // comment.
+func readBuildConstraint(path string) (constraint.Expr, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "//") {
+			break
+		}
+		lines = append(lines, line)
+	}
+	return parseBuildConstraint(lines), scanner.Err()
+}
@@ -0,0 +1,122 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestUpdateAsmSrcs(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("foo.go", "package foo\n")
+	write("sum.s", "#include \"textflag.h\"\n")
+	write("sum_amd64.s", "// Copyright notice\n\n//go:build amd64\n\n#include \"textflag.h\"\n")
+	write("sum_arm64.s", "//go:build arm64\n#include \"textflag.h\"\n")
+
+	newRule := func() *edit.Rule {
+		rule := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], dir)
+		rule.AddSrc("foo.go")
+		return rule
+	}
+
+	t.Run("adds all assembly sources", func(t *testing.T) {
+		rule := newRule()
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(new(config.Config), dir, []*edit.Rule{rule}))
+		assert.Equal(t, []string{"sum.s", "sum_amd64.s", "sum_arm64.s"}, rule.AttrStrings("asm_srcs"))
+	})
+
+	t.Run("respects build tag sets", func(t *testing.T) {
+		conf := &config.Config{
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"linux_amd64": {Tags: []string{"linux", "amd64"}},
+			},
+		}
+		rule := newRule()
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(conf, dir, []*edit.Rule{rule}))
+		assert.Equal(t, []string{"sum.s", "sum_amd64.s"}, rule.AttrStrings("asm_srcs"))
+	})
+
+	t.Run("selects files for tag sets with conditions", func(t *testing.T) {
+		conf := &config.Config{
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"amd64": {Tags: []string{"linux", "amd64"}, Condition: "//config:amd64"},
+				"arm64": {Tags: []string{"linux", "arm64"}, Condition: "//config:arm64"},
+			},
+		}
+		rule := newRule()
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(conf, dir, []*edit.Rule{rule}))
+
+		bin, ok := rule.Attr("asm_srcs").(*build.BinaryExpr)
+		require.True(t, ok)
+		assert.Equal(t, []string{"sum.s"}, build.Strings(bin.X))
+		assert.Equal(t, map[string][]string{
+			"//config:amd64":       {"sum_amd64.s"},
+			"//config:arm64":       {"sum_arm64.s"},
+			"//conditions:default": {},
+		}, selectValues(bin.Y.(*build.CallExpr)))
+	})
+
+	t.Run("respects file name suffixes", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "mul_amd64.s"), []byte("#include \"textflag.h\"\n"), 0644))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "mul_arm64.s"), []byte("#include \"textflag.h\"\n"), 0644))
+		conf := &config.Config{
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"linux_arm64": {Tags: []string{"linux", "arm64"}},
+			},
+		}
+		rule := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], dir)
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(conf, dir, []*edit.Rule{rule}))
+		assert.Equal(t, []string{"mul_arm64.s"}, rule.AttrStrings("asm_srcs"))
+	})
+
+	t.Run("keeps entries that aren't assembly files in the directory", func(t *testing.T) {
+		rule := newRule()
+		rule.SetAttr("asm_srcs", edit.NewStringList([]string{":gen_asm", "old.s", "sum.s", "sub/extra.s"}))
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(new(config.Config), dir, []*edit.Rule{rule}))
+		assert.Equal(t, []string{":gen_asm", "sum.s", "sub/extra.s", "sum_amd64.s", "sum_arm64.s"}, rule.AttrStrings("asm_srcs"))
+	})
+
+	t.Run("adds files to the split library of each tag set that builds them", func(t *testing.T) {
+		split := true
+		conf := &config.Config{
+			SplitBuildTagSets: &split,
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"amd64": {Tags: []string{"linux", "amd64"}, Condition: "//config:amd64"},
+				"arm64": {Tags: []string{"linux", "arm64"}},
+			},
+		}
+		amd64 := edit.NewRule(edit.NewRuleExpr("go_library", filepath.Base(dir)+"_amd64"), kinds.DefaultKinds["go_library"], dir)
+		arm64 := edit.NewRule(edit.NewRuleExpr("go_library", filepath.Base(dir)+"_arm64"), kinds.DefaultKinds["go_library"], dir)
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(conf, dir, []*edit.Rule{amd64, arm64}))
+		assert.Equal(t, []string{"sum.s", "sum_amd64.s"}, amd64.AttrStrings("asm_srcs"))
+		assert.Equal(t, []string{"sum.s", "sum_arm64.s"}, arm64.AttrStrings("asm_srcs"))
+	})
+
+	t.Run("skips kinds without assembly support", func(t *testing.T) {
+		kind := &kinds.Kind{Name: "my_go_library", Type: kinds.Lib, SrcsAttr: "srcs"}
+		rule := edit.NewRule(edit.NewRuleExpr("my_go_library", "foo"), kind, dir)
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateAsmSrcs(new(config.Config), dir, []*edit.Rule{rule}))
+		assert.Nil(t, rule.Attr("asm_srcs"))
+	})
+}
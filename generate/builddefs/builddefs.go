@@ -0,0 +1,286 @@
+// Package builddefs maintains the filegroups that provide build definitions, i.e. .build_defs files, so that new files
+// are added to them as they appear, and they depend on the build definitions their files subinclude.
+package builddefs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Kind is the kind of rule that puku maintains for build definitions
+var Kind = &kinds.Kind{
+	Name:     "filegroup",
+	Type:     kinds.Lib,
+	SrcsAttr: "srcs",
+}
+
+// Generator updates the build definition filegroups in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"build_defs"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// IsSource returns whether the file contains build definitions
+func IsSource(name string) bool {
+	return filepath.Ext(name) == ".build_defs"
+}
+
+// Scan reads the .build_defs files in the directory, along with its BUILD file. Their imports are the build definitions
+// they subinclude.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := importDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if subincludes := Subincludes(file); len(subincludes) > 0 {
+		files[filepath.Base(file.Path)] = subincludes
+	}
+
+	ret := make([]*language.File, 0, len(files))
+	for name, subincludes := range files {
+		ret = append(ret, &language.File{Name: name, Imports: subincludes})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves a subincluded label to the target it refers to, checking that it exists. Labels in subrepos can't be
+// checked, so they're returned as they are.
+func (g *Generator) Resolve(_ *config.Config, dir, label string) (string, error) {
+	if strings.HasPrefix(label, "///") || strings.HasPrefix(label, "@") {
+		return label, nil
+	}
+	l := labels.ParseRelative(label, dir)
+	file, err := g.graph.LoadFile(l.Package)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", l.Package, err)
+	}
+	if edit.FindTargetByName(file, l.Target) == nil {
+		return "", fmt.Errorf("there's no %v target in %v", l.Target, l.Package)
+	}
+	return edit.BuildTarget(l.Target, l.Package, ""), nil
+}
+
+// Update adds the .build_defs files in the directory that aren't in the srcs of a filegroup to the first filegroup there
+// that provides build definitions, creating one if needed, and adds the build definitions they subinclude to the deps
+// of their filegroup. Files that no longer exist are removed from the srcs. Build definitions subincluded by the BUILD
+// file are checked so missing ones are reported.
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := importDir(dir)
+	if err != nil {
+		return err
+	}
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+	for _, label := range Subincludes(file) {
+		if _, err := g.Resolve(conf, dir, label); err != nil {
+			log.Warningf("%v subincludes %v: %v", file.Path, label, err)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	allocated := map[string]bool{}
+	var rules []*edit.Rule
+	for _, expr := range file.Rules(Kind.Name) {
+		srcs, err := g.eval.EvalGlobs(dir, expr, Kind.SrcsAttr)
+		if err != nil {
+			return err
+		}
+		defs := false
+		for _, src := range srcs {
+			allocated[src] = true
+			defs = defs || IsSource(src)
+		}
+		if defs {
+			rules = append(rules, edit.NewRule(expr, Kind, dir))
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !allocated[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 && len(rules) == 0 {
+		rule := edit.NewRule(edit.NewRuleExpr(Kind.Name, ruleName(file, dir)), Kind, dir)
+		rule.SetAttr("visibility", edit.NewStringList([]string{"PUBLIC"}))
+		file.Stmt = append(file.Stmt, rule.Call)
+		rules = append(rules, rule)
+	}
+	for _, name := range names {
+		rules[0].AddSrc(name)
+	}
+
+	for _, rule := range rules {
+		if err := g.updateRule(conf, rule, files); err != nil {
+			return fmt.Errorf("failed to update %v: %v", rule.Label(), err)
+		}
+	}
+	return nil
+}
+
+// ruleName returns the name of the filegroup generated for a directory. This is named after the directory, unless
+// that's taken by another rule.
+func ruleName(file *build.File, dir string) string {
+	name := "build_defs"
+	if dir != "." {
+		name = filepath.Base(dir)
+	}
+	if edit.FindTargetByName(file, name) != nil {
+		return name + "_defs"
+	}
+	return name
+}
+
+// updateRule removes the files that no longer exist from the srcs of the filegroup, and adds the build definitions that
+// its files subinclude to its deps. Existing deps are left alone, as filegroups can depend on anything.
+func (g *Generator) updateRule(conf *config.Config, rule *edit.Rule, files map[string][]string) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	has := map[string]bool{}
+	for _, dep := range rule.AttrStrings("deps") {
+		has[canonical(dep, rule.Dir)] = true
+	}
+	var added []string
+	for _, src := range srcs {
+		if isLabel(src) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(rule.Dir, src)); err != nil {
+			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			continue
+		}
+		for _, subinclude := range files[src] {
+			dep, err := g.Resolve(conf, rule.Dir, subinclude)
+			if err != nil {
+				g.graph.Unresolved(label, subinclude, err)
+				continue
+			}
+			if dep == label || has[canonical(dep, rule.Dir)] {
+				continue
+			}
+			has[canonical(dep, rule.Dir)] = true
+			if !strings.HasPrefix(dep, "///") {
+				dep = labels.Shorten(dep, rule.Dir)
+			}
+			added = append(added, dep)
+		}
+	}
+
+	if len(added) > 0 {
+		sort.Strings(added)
+		rule.SetOrDeleteAttr("deps", append(rule.AttrStrings("deps"), added...))
+	}
+	return nil
+}
+
+// importDir reads the .build_defs files in the directory, returning the build definitions each subincludes
+func importDir(dir string) (map[string][]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := map[string][]string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !IsSource(entry.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		file, err := build.ParseDefault(path, bs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %v: %v", path, err)
+		}
+		ret[entry.Name()] = Subincludes(file)
+	}
+	return ret, nil
+}
+
+// Subincludes returns the labels that a file subincludes, in the order they're subincluded
+func Subincludes(file *build.File) []string {
+	var ret []string
+	seen := map[string]bool{}
+	build.Walk(file, func(expr build.Expr, _ []build.Expr) {
+		call, ok := expr.(*build.CallExpr)
+		if !ok {
+			return
+		}
+		if ident, ok := call.X.(*build.Ident); !ok || ident.Name != "subinclude" {
+			return
+		}
+		for _, arg := range call.List {
+			if str, ok := arg.(*build.StringExpr); ok && !seen[str.Value] {
+				seen[str.Value] = true
+				ret = append(ret, str.Value)
+			}
+		}
+	})
+	return ret
+}
+
+// canonical returns the label in a canonical form, so labels for the same target can be compared. Subrepo labels are
+// returned as they are.
+func canonical(label, dir string) string {
+	if strings.HasPrefix(label, "///") {
+		return label
+	}
+	return labels.ParseRelative(label, dir).Format()
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
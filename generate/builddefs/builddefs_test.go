@@ -0,0 +1,95 @@
+package builddefs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"build_defs/go.build_defs": "def go_thing(name):\n    pass\n",
+		"build_defs/docker.build_defs": `subinclude("//build_defs:go", "///shell//build_defs:shell")
+
+def image(name):
+    subinclude(":common")
+    go_thing(name)
+`,
+		"build_defs/common.build_defs": "",
+		"build_defs/BUILD": `filegroup(
+    name = "go",
+    srcs = [
+        "go.build_defs",
+        "deleted.build_defs",
+    ],
+    visibility = ["PUBLIC"],
+)
+
+filegroup(
+    name = "common",
+    srcs = ["common.build_defs"],
+)
+`,
+		"tools/defs/lint.build_defs": "subinclude(\"//build_defs:go\")\n",
+		"tools/defs/BUILD":           "go_library(\n    name = \"defs\",\n    srcs = [\"defs.go\"],\n)\n",
+		"service/BUILD":              "subinclude(\"//build_defs:go\", \"//build_defs:missing\")\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{}
+
+		t.Run("adds new files to the existing filegroup", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "build_defs"))
+			file, err := g.graph.LoadFile("build_defs")
+			require.NoError(t, err)
+			require.Len(t, file.Rules("filegroup"), 2)
+
+			rule := edit.FindTargetByName(file, "go")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"go.build_defs", "docker.build_defs"}, rule.AttrStrings("srcs"))
+			assert.Equal(t, []string{"///shell//build_defs:shell", ":common"}, rule.AttrStrings("deps"))
+		})
+
+		t.Run("generates a filegroup", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "tools/defs"))
+			file, err := g.graph.LoadFile("tools/defs")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "defs_defs")
+			require.NotNil(t, rule)
+			assert.Equal(t, "filegroup", rule.Kind())
+			assert.Equal(t, []string{"lint.build_defs"}, rule.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//build_defs:go"}, rule.AttrStrings("deps"))
+			assert.Equal(t, []string{"PUBLIC"}, rule.AttrStrings("visibility"))
+		})
+
+		t.Run("records the subincludes of BUILD files", func(t *testing.T) {
+			files, err := g.Scan("service")
+			require.NoError(t, err)
+			require.Len(t, files, 1)
+			assert.Equal(t, "BUILD", files[0].Name)
+			assert.Equal(t, []string{"//build_defs:go", "//build_defs:missing"}, files[0].Imports)
+
+			label, err := g.Resolve(conf, "service", "//build_defs:go")
+			require.NoError(t, err)
+			assert.Equal(t, "//build_defs:go", label)
+
+			label, err = g.Resolve(conf, "build_defs", ":common")
+			require.NoError(t, err)
+			assert.Equal(t, "//build_defs:common", label)
+
+			_, err = g.Resolve(conf, "service", "//build_defs:missing")
+			assert.ErrorContains(t, err, "there's no missing target in build_defs")
+		})
+	})
+}
@@ -0,0 +1,246 @@
+// Package cc generates cc_library, cc_test and cc_binary rules for the C and C++ sources in a directory, with deps on
+// the rules that provide the headers they include.
+package cc
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the C and C++ rules
+const Subinclude = "///cc//build_defs:cc"
+
+// Kinds are the kinds of rule that puku generates for C and C++ sources
+var Kinds = map[string]*kinds.Kind{
+	"cc_library": {
+		Name:     "cc_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"cc_test": {
+		Name:     "cc_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+	"cc_binary": {
+		Name:     "cc_binary",
+		Type:     kinds.Bin,
+		SrcsAttr: "srcs",
+	},
+}
+
+// fileAttrs are the attributes of a rule that take sources or headers
+var fileAttrs = []string{"srcs", "hdrs", "private_hdrs"}
+
+// Generator updates the C and C++ rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+
+	resolvedIncludes map[string]string
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf:          plzConf,
+		graph:            g,
+		eval:             e,
+		resolvedIncludes: map[string]string{},
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"cc"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the C and C++ sources and headers in the directory. Their imports are the headers they include, as
+// they're written in the #include directive.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		lf := &language.File{Name: f.Name}
+		for _, i := range f.Includes {
+			lf.Imports = append(lf.Imports, i.String())
+		}
+		ret = append(ret, lf)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves a header included by a file in the directory to the target that provides it
+func (g *Generator) Resolve(conf *config.Config, dir, include string) (string, error) {
+	return g.resolveInclude(conf, dir, ParseInclude(include))
+}
+
+// Update allocates the C and C++ sources and headers in the directory to rules, creating them as necessary, and updates
+// the deps of the rules there based on the headers their files include
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	return language.UpdateRules(g.plzConf, conf, g.graph, dir, &language.Rules{
+		Kinds:      Kinds,
+		Subinclude: func(*kinds.Kind) string { return Subinclude },
+		Allocate: func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error) {
+			return g.allocateSources(dir, file.Rules(""), files, rules)
+		},
+		UpdateDeps: func(rule *edit.Rule) error {
+			return g.updateRuleDeps(conf, rule, files)
+		},
+	})
+}
+
+// allocation allocates C and C++ files to rules. Headers go in the hdrs of libraries, rather than their srcs.
+var allocation = &language.Allocation{
+	Kinds:  Kinds,
+	Lib:    "cc_library",
+	Test:   "cc_test",
+	Bin:    "cc_binary",
+	Suffix: "_cc",
+	Add: func(rule *edit.Rule, src string) {
+		if _, ok := hdrExtensions[filepath.Ext(src)]; ok {
+			rule.SetOrDeleteAttr("hdrs", append(rule.AttrStrings("hdrs"), src))
+		} else {
+			rule.AddSrc(src)
+		}
+	},
+}
+
+// allocateSources allocates the files that don't belong to a rule yet. Sources that define main get a cc_binary each,
+// named after the file. Tests go to the first test in the package, and other sources and headers go to the first
+// library, which are created if needed. Files that belong to any other rule, e.g. the C sources of a cgo_library, are
+// left alone.
+func (g *Generator) allocateSources(dir string, existing []*build.Rule, files map[string]*File, rules []*edit.Rule) ([]*edit.Rule, error) {
+	allocated := map[string]bool{}
+	for _, rule := range existing {
+		for _, attr := range append(fileAttrs, "c_srcs") {
+			srcs, err := g.eval.EvalGlobs(dir, rule, attr)
+			if err != nil {
+				return nil, err
+			}
+			for _, src := range srcs {
+				allocated[src] = true
+			}
+		}
+	}
+
+	srcs := map[string]language.Source{}
+	for name, f := range files {
+		if !allocated[name] {
+			srcs[name] = f
+		}
+	}
+	return language.AllocateSources(allocation, dir, existing, rules, srcs), nil
+}
+
+// ruleFiles returns the sources and headers of a rule
+func (g *Generator) ruleFiles(dir string, rule *build.Rule) ([]string, error) {
+	var ret []string
+	for _, attr := range fileAttrs {
+		srcs, err := g.eval.EvalGlobs(dir, rule, attr)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, srcs...)
+	}
+	return ret, nil
+}
+
+// updateRuleDeps sets the deps of the rule to the targets that provide the headers its files include. Files that no
+// longer exist are removed from the rule.
+func (g *Generator) updateRuleDeps(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.ruleFiles(rule.Dir, rule.Rule)
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	deps := map[string]bool{}
+	for _, src := range srcs {
+		if isLabel(src) {
+			continue
+		}
+		f := files[src]
+		if f == nil {
+			// Files in subdirectories, e.g. from a glob, aren't in the files read from the package
+			if !isFile(filepath.Join(rule.Dir, src)) {
+				removeFile(rule, src) // The file doesn't exist so remove it from the rule
+				continue
+			}
+			if f, err = importFile(rule.Dir, src); err != nil {
+				return err
+			}
+		}
+		for _, i := range f.Includes {
+			dep, err := g.resolveInclude(conf, filepath.Dir(filepath.Join(rule.Dir, src)), i)
+			if err != nil {
+				g.graph.Unresolved(label, i.Path, err)
+				continue
+			}
+			if dep == "" || dep == label {
+				continue
+			}
+			deps[dep] = true
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, language.Shorten(rule.Dir, dep))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
+
+// removeFile removes a source or header from whichever of the rule's attributes it's in
+func removeFile(rule *edit.Rule, rem string) {
+	for _, attr := range fileAttrs {
+		files := rule.AttrStrings(attr)
+		set := make([]string, 0, len(files))
+		for _, f := range files {
+			if f != rem {
+				set = append(set, f)
+			}
+		}
+		if len(set) != len(files) {
+			rule.SetOrDeleteAttr(attr, set)
+		}
+	}
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
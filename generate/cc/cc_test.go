@@ -0,0 +1,116 @@
+package cc
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"server/server.h":       "#include <string>\n#include \"util/strings.h\"\n#include \"proto/api.h\"\n",
+		"server/server.cc":      "#include \"server.h\"\n#include <vector>\n#include \"missing.h\"\n",
+		"server/server_test.cc": "#include \"server/server.h\"\n#include <gtest/gtest.h>\n",
+		"server/main.cc":        "#include \"server.h\"\n\nint main() {\n  return 0;\n}\n",
+		"util/strings.h":        "",
+		"util/strings.cc":       "#include \"strings.h\"\n",
+		"util/BUILD":            "cc_library(\n    name = \"strings_lib\",\n    srcs = [\"strings.cc\"],\n    hdrs = [\"strings.h\"],\n)\n",
+		"include/proto/api.h":   "",
+		"include/BUILD":         "cc_library(\n    name = \"headers\",\n    hdrs = glob([\"**/*.h\"]),\n)\n",
+		"legacy/old.cc":         "",
+		"legacy/new.cc":         "#include \"util/strings.h\"\n",
+		"legacy/BUILD":          "cc_library(\n    name = \"legacy\",\n    srcs = [\n        \"old.cc\",\n        \"deleted.cc\",\n    ],\n)\n",
+		"mixed/mixed.c":         "",
+		"mixed/mixed.h":         "",
+		"mixed/BUILD":           "cgo_library(\n    name = \"mixed\",\n    srcs = [\"mixed.go\"],\n    c_srcs = [\"mixed.c\"],\n    hdrs = [\"mixed.h\"],\n)\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{
+			KnownTargets:  map[string]string{"gtest": "//third_party/cc:gtest"},
+			CcIncludeDirs: []string{"include"},
+		}
+
+		require.NoError(t, g.Update(conf, "server"))
+		file, err := g.graph.LoadFile("server")
+		require.NoError(t, err)
+
+		t.Run("generates a library with the sources and headers", func(t *testing.T) {
+			lib := edit.FindTargetByName(file, "server")
+			require.NotNil(t, lib)
+			assert.Equal(t, "cc_library", lib.Kind())
+			assert.Equal(t, []string{"server.cc"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{"server.h"}, lib.AttrStrings("hdrs"))
+			assert.Equal(t, []string{"//include:headers", "//util:strings_lib"}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("generates a test", func(t *testing.T) {
+			test := edit.FindTargetByName(file, "server_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "cc_test", test.Kind())
+			assert.Equal(t, []string{"server_test.cc"}, test.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/cc:gtest", ":server"}, test.AttrStrings("deps"))
+		})
+
+		t.Run("generates a binary for sources that define main", func(t *testing.T) {
+			bin := edit.FindTargetByName(file, "main")
+			require.NotNil(t, bin)
+			assert.Equal(t, "cc_binary", bin.Kind())
+			assert.Equal(t, []string{"main.cc"}, bin.AttrStrings("srcs"))
+			assert.Equal(t, []string{":server"}, bin.AttrStrings("deps"))
+		})
+
+		t.Run("subincludes the cc rules", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///cc//build_defs:cc", call.List[0].(*build.StringExpr).Value)
+		})
+
+		t.Run("updates existing rules", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "legacy"))
+			file, err := g.graph.LoadFile("legacy")
+			require.NoError(t, err)
+			lib := edit.FindTargetByName(file, "legacy")
+			require.NotNil(t, lib)
+			assert.Equal(t, []string{"old.cc", "new.cc"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//util:strings_lib"}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("scans and resolves includes", func(t *testing.T) {
+			files, err := g.Scan("server")
+			require.NoError(t, err)
+			require.Len(t, files, 4)
+			assert.Equal(t, "server.cc", files[1].Name)
+			assert.Equal(t, []string{`"server.h"`, "<vector>", `"missing.h"`}, files[1].Imports)
+
+			dep, err := g.Resolve(conf, "server", `"server.h"`)
+			require.NoError(t, err)
+			assert.Equal(t, "//server", dep)
+
+			dep, err = g.Resolve(conf, "server", "<vector>")
+			require.NoError(t, err)
+			assert.Empty(t, dep)
+		})
+
+		t.Run("leaves the C sources of cgo rules alone", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "mixed"))
+			file, err := g.graph.LoadFile("mixed")
+			require.NoError(t, err)
+			assert.Len(t, file.Stmt, 1)
+		})
+	})
+}
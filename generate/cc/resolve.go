@@ -0,0 +1,120 @@
+package cc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+)
+
+// resolveInclude resolves a header included by a file in the given directory to the target that provides it. It
+// returns an empty string for system headers, i.e. headers included with <...> that aren't in the repo.
+func (g *Generator) resolveInclude(conf *config.Config, dir string, i Include) (string, error) {
+	// Headers included with quotes are looked for next to the file first, so depend on where it is
+	key := i.Path
+	if !i.System {
+		key = dir + ":" + i.Path
+	}
+	if t, ok := g.resolvedIncludes[key]; ok {
+		return t, nil
+	}
+	t, err := g.reallyResolveInclude(conf, dir, i)
+	if err != nil {
+		return "", err
+	}
+	g.resolvedIncludes[key] = t
+	return t, nil
+}
+
+func (g *Generator) reallyResolveInclude(conf *config.Config, dir string, i Include) (string, error) {
+	parts := strings.Split(i.Path, "/")
+	for n := len(parts); n > 0; n-- {
+		if t := conf.GetKnownTarget(strings.Join(parts[:n], "/")); t != "" {
+			return t, nil
+		}
+	}
+
+	var candidates []string
+	if !i.System {
+		candidates = append(candidates, filepath.Join(dir, i.Path))
+	}
+	candidates = append(candidates, filepath.Clean(i.Path))
+	for _, includeDir := range conf.GetCcIncludeDirs() {
+		candidates = append(candidates, filepath.Join(includeDir, i.Path))
+	}
+	for _, path := range candidates {
+		if strings.HasPrefix(path, "../") || !isFile(path) {
+			continue
+		}
+		return g.fileTarget(path)
+	}
+
+	if i.System {
+		return "", nil
+	}
+	return "", fmt.Errorf("no such header in %v, the repo root or ccIncludeDirs", dir)
+}
+
+// fileTarget returns the target that provides a header. This is the rule in the nearest package that has it in its
+// sources or headers. If there isn't one yet, it's assumed it'll be allocated to the first library in its directory when
+// puku updates it.
+func (g *Generator) fileTarget(path string) (string, error) {
+	dir := filepath.Dir(path)
+	lib := language.LibName(dir)
+	if pkg := g.packageOf(dir); pkg != "" {
+		file, err := g.graph.LoadFile(pkg)
+		if err != nil {
+			return "", fmt.Errorf("failed to parse BUILD files in %v: %v", pkg, err)
+		}
+		rel, err := filepath.Rel(pkg, path)
+		if err != nil {
+			return "", err
+		}
+		if libs := file.Rules("cc_library"); pkg == dir && len(libs) > 0 {
+			lib = libs[0].Name()
+		}
+		for _, expr := range file.Rules("") {
+			srcs, err := g.ruleFiles(pkg, expr)
+			if err != nil {
+				return "", err
+			}
+			for _, src := range srcs {
+				if src != rel {
+					continue
+				}
+				if kind, ok := Kinds[expr.Kind()]; ok && kind.Type != kinds.Lib {
+					return "", fmt.Errorf("%v is in %v, which isn't a library", path, edit.BuildTarget(expr.Name(), pkg, ""))
+				}
+				return edit.BuildTarget(expr.Name(), pkg, ""), nil
+			}
+		}
+	}
+	return edit.BuildTarget(lib, dir, ""), nil
+}
+
+// packageOf returns the nearest directory at or above the given one that has a BUILD file, or an empty string if there
+// isn't one
+func (g *Generator) packageOf(dir string) string {
+	for {
+		for _, name := range g.plzConf.BuildFileNames() {
+			if isFile(filepath.Join(dir, name)) {
+				return dir
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,191 @@
+package cc
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Include is a header included by a source file
+type Include struct {
+	// Path is the path of the header as it's written in the #include directive
+	Path string
+	// System is set for #include <...>, which the compiler only looks for on the include path. These are taken to be
+	// system headers unless they're found in the repo.
+	System bool
+}
+
+// String returns the include as it's written in the #include directive, e.g. <vector> or "foo/bar.h"
+func (i Include) String() string {
+	if i.System {
+		return "<" + i.Path + ">"
+	}
+	return `"` + i.Path + `"`
+}
+
+// ParseInclude parses an include in the form returned by String
+func ParseInclude(s string) Include {
+	if strings.HasPrefix(s, "<") && strings.HasSuffix(s, ">") {
+		return Include{Path: s[1 : len(s)-1], System: true}
+	}
+	return Include{Path: strings.Trim(s, `"`)}
+}
+
+// File represents a single C or C++ source file or header
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Includes are the headers the file includes, in the order they're included
+	Includes []Include
+	// HasMain is set when the file defines a main function
+	HasMain bool
+}
+
+// IsHeader returns whether the file is a header
+func (f *File) IsHeader() bool {
+	_, ok := hdrExtensions[filepath.Ext(f.Name)]
+	return ok
+}
+
+// IsTest returns whether the file contains tests, i.e. it's named *_test.cc, *_unittest.cc or test_*.cc
+func (f *File) IsTest() bool {
+	if f.IsHeader() {
+		return false
+	}
+	base := strings.TrimSuffix(f.Name, filepath.Ext(f.Name))
+	return strings.HasSuffix(base, "_test") || strings.HasSuffix(base, "_unittest") || strings.HasPrefix(base, "test_")
+}
+
+// IsBinary returns whether the file is the main source of a program that should be its own cc_binary
+func (f *File) IsBinary() bool {
+	return f.HasMain && !f.IsTest() && !f.IsHeader()
+}
+
+// srcExtensions are the extensions of C and C++ sources
+var srcExtensions = map[string]struct{}{
+	".c":   {},
+	".cc":  {},
+	".cpp": {},
+	".cxx": {},
+	".c++": {},
+}
+
+// hdrExtensions are the extensions of C and C++ headers
+var hdrExtensions = map[string]struct{}{
+	".h":   {},
+	".hh":  {},
+	".hpp": {},
+	".hxx": {},
+	".h++": {},
+	".inc": {},
+}
+
+var (
+	includeDirective = regexp.MustCompile(`^\s*#\s*include\s*([<"])([^>"]+)[>"]`)
+	mainFunc         = regexp.MustCompile(`\bint\s+main\s*\(`)
+)
+
+// IsSource returns whether the file is a C or C++ source or header
+func IsSource(name string) bool {
+	ext := filepath.Ext(name)
+	_, src := srcExtensions[ext]
+	_, hdr := hdrExtensions[ext]
+	return src || hdr
+}
+
+// ImportDir reads the C and C++ sources and headers in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || !IsSource(info.Name()) {
+			continue
+		}
+		f, err := importFile(dir, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = f
+	}
+	return ret, nil
+}
+
+func importFile(dir, src string) (*File, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, src))
+	if err != nil {
+		return nil, err
+	}
+	return parseFile(src, bs), nil
+}
+
+// parseFile finds the headers that a file includes, and whether it defines main
+func parseFile(name string, src []byte) *File {
+	f := &File{Name: name}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(stripComments(src)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if match := includeDirective.FindStringSubmatch(line); match != nil {
+			path := strings.TrimSpace(match[2])
+			if !seen[path] {
+				seen[path] = true
+				f.Includes = append(f.Includes, Include{Path: path, System: match[1] == "<"})
+			}
+			continue
+		}
+		if !strings.HasPrefix(strings.TrimSpace(line), "#") && mainFunc.MatchString(line) {
+			f.HasMain = true
+		}
+	}
+	return f
+}
+
+// stripComments replaces comments with spaces, keeping the newlines so that directives stay on their own lines. String
+// and character literals are skipped over so that things that look like comments in them are left alone.
+func stripComments(src []byte) []byte {
+	ret := make([]byte, 0, len(src))
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				ret = append(ret, '\n')
+			}
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '*':
+			i += 2
+			for i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/') {
+				if src[i] == '\n' {
+					ret = append(ret, '\n')
+				}
+				i++
+			}
+			i++
+			ret = append(ret, ' ')
+		case src[i] == '"' || src[i] == '\'':
+			quote := src[i]
+			ret = append(ret, quote)
+			for i++; i < len(src) && src[i] != quote && src[i] != '\n'; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					ret = append(ret, src[i])
+					i++
+				}
+				ret = append(ret, src[i])
+			}
+			if i < len(src) {
+				ret = append(ret, src[i])
+			}
+		default:
+			ret = append(ret, src[i])
+		}
+	}
+	return ret
+}
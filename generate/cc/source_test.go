@@ -0,0 +1,79 @@
+package cc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	f := parseFile("server.cc", []byte(`// #include "commented/out.h"
+#include <vector>
+#include "server/server.h"
+#  include   "util/strings.h"  // trailing comment
+#include <absl/strings/str_cat.h>
+#include "server/server.h"
+/* #include "block/comment.h"
+   int main() {} */
+const char* s = "// not a comment";
+
+int main(int argc, char** argv) {
+  return 0;
+}
+`))
+
+	assert.Equal(t, "server.cc", f.Name)
+	assert.Equal(t, []Include{
+		{Path: "vector", System: true},
+		{Path: "server/server.h"},
+		{Path: "util/strings.h"},
+		{Path: "absl/strings/str_cat.h", System: true},
+	}, f.Includes)
+	assert.True(t, f.HasMain)
+	assert.True(t, f.IsBinary())
+}
+
+func TestFileKinds(t *testing.T) {
+	testCases := []struct {
+		name   string
+		header bool
+		test   bool
+	}{
+		{name: "foo.h", header: true},
+		{name: "foo.hpp", header: true},
+		{name: "foo.cc"},
+		{name: "foo.c"},
+		{name: "foo_test.cc", test: true},
+		{name: "foo_unittest.cpp", test: true},
+		{name: "test_foo.c", test: true},
+		{name: "foo_test.h", header: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			f := &File{Name: tc.name, HasMain: true}
+			assert.Equal(t, tc.header, f.IsHeader())
+			assert.Equal(t, tc.test, f.IsTest())
+			assert.Equal(t, !tc.header && !tc.test, f.IsBinary())
+		})
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	testCases := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{name: "line comment", src: "a // b\nc", expected: "a \nc"},
+		{name: "block comment", src: "a /* b */ c", expected: "a   c"},
+		{name: "multi-line block comment keeps newlines", src: "a /* b\nc */ d", expected: "a \n  d"},
+		{name: "comment in string", src: `a "/* b */" c`, expected: `a "/* b */" c`},
+		{name: "escaped quote in string", src: `a "\" // b" c`, expected: `a "\" // b" c`},
+		{name: "char literal", src: `a '"' // b`, expected: `a '"' `},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, string(stripComments([]byte(tc.src))))
+		})
+	}
+}
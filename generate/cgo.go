@@ -0,0 +1,195 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+)
+
+// cSrcExtensions are the extensions of C sources compiled alongside the Go sources of a cgo package
+var cSrcExtensions = map[string]struct{}{
+	".c":   {},
+	".cc":  {},
+	".cpp": {},
+	".cxx": {},
+	".m":   {},
+	".S":   {},
+}
+
+// hdrExtensions are the extensions of C headers available to the sources of a cgo package
+var hdrExtensions = map[string]struct{}{
+	".h":   {},
+	".hh":  {},
+	".hpp": {},
+	".hxx": {},
+}
+
+// cgoFlagAttrs maps the variables set by #cgo directives to the cgo_library attributes that take them
+var cgoFlagAttrs = map[string]string{
+	"CFLAGS":     "compiler_flags",
+	"CPPFLAGS":   "compiler_flags",
+	"CXXFLAGS":   "compiler_flags",
+	"LDFLAGS":    "linker_flags",
+	"pkg-config": "pkg_config",
+}
+
+// ensureCgoKind makes sure the rule can compile cgo sources. A go_library will be converted into a cgo_library, moving
+// its sources to the pure Go sources attribute. Returns false if the rule is of a custom kind that doesn't support cgo.
+func ensureCgoKind(rule *edit.Rule) bool {
+	if rule.Kind.IsCgo() {
+		return true
+	}
+	if rule.Kind.Name != "go_library" {
+		return false
+	}
+
+	srcs := rule.Attr(rule.SrcsAttr())
+	rule.DelAttr(rule.SrcsAttr())
+	rule.SetKind(kinds.DefaultKinds["cgo_library"])
+	if srcs != nil {
+		rule.SetAttr(rule.SrcsAttr(), srcs)
+	}
+	return true
+}
+
+// updateCgoRules updates the C sources, headers and flags of any cgo rules in the package. All C sources and headers in
+// the directory are assumed to belong to the first cgo rule.
+func (u *updater) updateCgoRules(conf *config.Config, pkgDir string, rules []*edit.Rule, sources map[string]*GoFile) error {
+	var rule *edit.Rule
+	for _, r := range rules {
+		if r.Kind.Type == kinds.Lib && r.Kind.IsCgo() {
+			rule = r
+			break
+		}
+	}
+	if rule == nil {
+		return nil
+	}
+
+	entries, err := os.ReadDir(pkgDir)
+	if err != nil {
+		return err
+	}
+
+	var cSrcs, hdrs []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if _, ok := cSrcExtensions[ext]; ok {
+			cSrcs = append(cSrcs, entry.Name())
+		}
+		if _, ok := hdrExtensions[ext]; ok {
+			hdrs = append(hdrs, entry.Name())
+		}
+	}
+	setIfStringList(rule, "c_srcs", cSrcs)
+	setIfStringList(rule, "hdrs", hdrs)
+
+	srcs, files, err := u.allSources(conf, rule, sources)
+	if err != nil {
+		return err
+	}
+	flags := map[string][][]string{}
+	for _, src := range srcs {
+		f := files[src]
+		if f == nil {
+			continue
+		}
+		for variable, directives := range f.CgoFlags {
+			if attr, ok := cgoFlagAttrs[variable]; ok {
+				flags[attr] = append(flags[attr], directives...)
+			}
+		}
+	}
+
+	attrs := make([]string, 0, len(flags))
+	for attr := range flags {
+		attrs = append(attrs, attr)
+	}
+	sort.Strings(attrs)
+	for _, attr := range attrs {
+		addMissingFlags(rule, attr, flags[attr])
+	}
+	return nil
+}
+
+// setIfStringList sets the attribute to the given values, unless it's been set to something other than a list of
+// strings e.g. a glob, in which case we leave it alone.
+func setIfStringList(rule *edit.Rule, attr string, values []string) {
+	if existing := rule.Attr(attr); existing != nil {
+		if _, ok := existing.(*build.ListExpr); !ok {
+			return
+		}
+	}
+	rule.SetOrDeleteAttr(attr, values)
+}
+
+// addMissingFlags adds the arguments of each #cgo directive to the attribute, unless they're already in it in the same
+// order, leaving any existing values in place. Flags are often added by hand, so we shouldn't remove the ones we don't
+// know about. The arguments of a directive are added together, as flags like -I and -framework take the argument after
+// them, so neither can be deduplicated on its own.
+func addMissingFlags(rule *edit.Rule, attr string, directives [][]string) {
+	list := &build.ListExpr{}
+	if existing := rule.Attr(attr); existing != nil {
+		var ok bool
+		if list, ok = existing.(*build.ListExpr); !ok {
+			return
+		}
+	}
+	current := rule.AttrStrings(attr)
+	for _, args := range directives {
+		if containsSequence(current, args) {
+			continue
+		}
+		current = append(current, args...)
+		for _, arg := range args {
+			list.List = append(list.List, edit.NewStringExpr(arg))
+		}
+	}
+	if len(list.List) > 0 {
+		rule.SetAttr(attr, list)
+	}
+}
+
+// containsSequence returns whether the values contain the sequence, in order and next to each other
+func containsSequence(values, seq []string) bool {
+	for i := 0; i+len(seq) <= len(values); i++ {
+		if slices.Equal(values[i:i+len(seq)], seq) {
+			return true
+		}
+	}
+	return false
+}
+
+// addMissingStrings adds any of the values that aren't already in the attribute, leaving any existing values in place.
+// Flags are often added by hand, so we shouldn't remove the ones we don't know about.
+func addMissingStrings(rule *edit.Rule, attr string, values []string) {
+	existing := rule.Attr(attr)
+	if existing != nil {
+		if _, ok := existing.(*build.ListExpr); !ok {
+			return
+		}
+	}
+	current := rule.AttrStrings(attr)
+	seen := make(map[string]struct{}, len(current))
+	for _, v := range current {
+		seen[v] = struct{}{}
+	}
+	for _, v := range values {
+		if _, ok := seen[v]; ok {
+			continue
+		}
+		seen[v] = struct{}{}
+		current = append(current, v)
+	}
+	rule.SetOrDeleteAttr(attr, current)
+}
@@ -0,0 +1,112 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestAllocateCgoSources(t *testing.T) {
+	files := map[string]*GoFile{
+		"foo.go": {
+			Name:     "foo",
+			FileName: "foo.go",
+		},
+		"cgo.go": {
+			Name:     "foo",
+			FileName: "cgo.go",
+			Imports:  []string{"C"},
+		},
+	}
+
+	t.Run("converts an existing go_library", func(t *testing.T) {
+		foo := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], "")
+		foo.AddSrc("foo.go")
+
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(new(config.Config), "foo", files, []*edit.Rule{foo})
+		require.NoError(t, err)
+		require.Len(t, newRules, 0)
+
+		assert.Equal(t, "cgo_library", foo.Rule.Kind())
+		assert.Equal(t, []string{"cgo.go"}, foo.AttrStrings("srcs"))
+		assert.Equal(t, []string{"foo.go"}, foo.AttrStrings("go_srcs"))
+	})
+
+	t.Run("creates a new cgo_library", func(t *testing.T) {
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(new(config.Config), "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 1)
+
+		assert.Equal(t, "cgo_library", newRules[0].Rule.Kind())
+		assert.Equal(t, []string{"cgo.go"}, newRules[0].AttrStrings("srcs"))
+		assert.Equal(t, []string{"foo.go"}, newRules[0].AttrStrings("go_srcs"))
+	})
+
+	t.Run("leaves custom kinds alone", func(t *testing.T) {
+		kind := &kinds.Kind{Name: "my_go_library", Type: kinds.Lib, SrcsAttr: "srcs"}
+		foo := edit.NewRule(edit.NewRuleExpr("my_go_library", "foo"), kind, "")
+		foo.AddSrc("foo.go")
+
+		u := newUpdater(new(please.Config), options.TestOptions)
+		_, err := u.allocateSources(new(config.Config), "foo", files, []*edit.Rule{foo})
+		require.NoError(t, err)
+
+		assert.Equal(t, "my_go_library", foo.Rule.Kind())
+		assert.ElementsMatch(t, []string{"foo.go", "cgo.go"}, foo.AttrStrings("srcs"))
+	})
+}
+
+func TestUpdateCgoRules(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"cgo.go", "foo.c", "bar.cc", "foo.h", "README.md"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), nil, 0644))
+	}
+
+	files := map[string]*GoFile{
+		"cgo.go": {
+			Name:     "foo",
+			FileName: "cgo.go",
+			Imports:  []string{"C"},
+			CgoFlags: map[string][][]string{
+				"CFLAGS":  {{"-Wall"}},
+				"LDFLAGS": {{"-lpng"}},
+			},
+		},
+	}
+
+	rule := edit.NewRule(edit.NewRuleExpr("cgo_library", "foo"), kinds.DefaultKinds["cgo_library"], dir)
+	rule.AddCgoSrc("cgo.go")
+	rule.SetAttr("linker_flags", edit.NewStringList([]string{"-lm"}))
+
+	u := newUpdater(new(please.Config), options.TestOptions)
+	err := u.updateCgoRules(new(config.Config), dir, []*edit.Rule{rule}, files)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"bar.cc", "foo.c"}, rule.AttrStrings("c_srcs"))
+	assert.Equal(t, []string{"foo.h"}, rule.AttrStrings("hdrs"))
+	assert.Equal(t, []string{"-Wall"}, rule.AttrStrings("compiler_flags"))
+	assert.Equal(t, []string{"-lm", "-lpng"}, rule.AttrStrings("linker_flags"))
+}
+
+func TestAddMissingFlags(t *testing.T) {
+	rule := edit.NewRule(edit.NewRuleExpr("cgo_library", "foo"), kinds.DefaultKinds["cgo_library"], ".")
+	rule.SetAttr("compiler_flags", edit.NewStringList([]string{"-I", "a"}))
+	rule.SetAttr("linker_flags", edit.NewStringList([]string{"-framework", "CoreFoundation"}))
+
+	addMissingFlags(rule, "compiler_flags", [][]string{{"-I", "a"}, {"-I", "b"}, {"-I", "a", "-I", "b"}})
+	addMissingFlags(rule, "linker_flags", [][]string{{"-framework", "CoreFoundation"}, {"-framework", "Security"}})
+
+	assert.Equal(t, []string{"-I", "a", "-I", "b"}, rule.AttrStrings("compiler_flags"))
+	assert.Equal(t, []string{"-framework", "CoreFoundation", "-framework", "Security"}, rule.AttrStrings("linker_flags"))
+}
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/please-build/buildtools/build"
+	"golang.org/x/mod/modfile"
 
 	"github.com/please-build/puku/config"
 	"github.com/please-build/puku/edit"
@@ -29,10 +30,22 @@ func (u *updater) resolveImport(conf *config.Config, i string) (string, error) {
 	}
 
 	t, err := u.reallyResolveImport(conf, i)
-	if err == nil {
-		u.resolvedImports[i] = t
+	if err != nil {
+		// Fall back to the configured resolver, if there is one
+		if resolver := conf.GetResolver(); resolver != "" {
+			t, resolverErr := externalResolve(resolver, i)
+			if resolverErr != nil {
+				return "", fmt.Errorf("%v, and %w", err, resolverErr)
+			}
+			if t != "" {
+				u.resolvedImports[i] = t
+				return t, nil
+			}
+		}
+		return "", err
 	}
-	return t, err
+	u.resolvedImports[i] = t
+	return t, nil
 }
 
 // reallyResolveImport actually does the resolution of an import path to a build target.
@@ -61,6 +74,38 @@ func (u *updater) reallyResolveImport(conf *config.Config, i string) (string, er
 		// current module, so we should carry on here in case we can resolve this to a third party module
 	}
 
+	// Modules replaced with a directory in this repo should resolve to the targets there
+	if path := u.replacedPath(i); path != "" {
+		t, err := u.localDepInDir(i, path)
+		if err != nil {
+			return "", err
+		}
+		if t == "" {
+			return "", fmt.Errorf("%v is replaced with %v in go.mod but no library target was found there", i, path)
+		}
+		return t, nil
+	}
+
+	// Packages generated from .proto files in the repo can have any import path, so check their go_package options
+	// unless it's from a module we already know about
+	if moduleForPackage(u.modules, i) == "" {
+		if t, err := u.protoTarget(i); err != nil || t != "" {
+			return t, err
+		}
+	}
+
+	// In vendor mode, third party packages must be vendored
+	if vendorDir := conf.GetVendorDir(); vendorDir != "" {
+		t, err := u.vendorDep(vendorDir, i)
+		if err != nil {
+			return "", err
+		}
+		if t == "" {
+			return "", fmt.Errorf("%v isn't vendored in %v", i, vendorDir)
+		}
+		return t, nil
+	}
+
 	t := depTarget(u.modules, i, thirdPartyDir)
 	if t != "" {
 		return t, nil
@@ -116,6 +161,12 @@ func (u *updater) isInScope(path string) bool {
 // empty string when no target is found.
 func (u *updater) localDep(importPath string) (string, error) {
 	path := strings.Trim(strings.TrimPrefix(importPath, u.plzConf.ImportPath()), "/")
+	return u.localDepInDir(importPath, path)
+}
+
+// localDepInDir finds the library target for the import path in the given directory of this repository. Returns an
+// empty string when no target is found.
+func (u *updater) localDepInDir(importPath, path string) (string, error) {
 	// If we're using GOPATH based resolution, we don't have a prefix to base whether a path is package local or not. In
 	// this case, we need to check if the directory exists. If it doesn't it's not a local import.
 	if _, err := os.Lstat(path); os.IsNotExist(err) {
@@ -170,6 +221,51 @@ func (u *updater) localDep(importPath string) (string, error) {
 	return "", nil
 }
 
+// replacedPath returns the directory in this repository that contains the package, if its module has been replaced with
+// a local path in go.mod. Otherwise, returns an empty string.
+func (u *updater) replacedPath(importPath string) string {
+	modules := make([]string, 0, len(u.localReplaces))
+	for mod := range u.localReplaces {
+		modules = append(modules, mod)
+	}
+	module := moduleForPackage(modules, importPath)
+	if module == "" {
+		return ""
+	}
+	return filepath.Join(u.localReplaces[module], strings.TrimPrefix(importPath, module))
+}
+
+// readGoMod reads the go.mod file at the given path. Returns nil if there isn't one.
+func readGoMod(path string) (*modfile.File, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return modfile.Parse(path, bs, nil)
+}
+
+// localReplaces returns the replace directives from the go.mod file that replace a module with a directory in this
+// repository, as a map of module path to the directory. The directories are joined to dir, which should be the
+// directory containing the go.mod.
+func localReplaces(f *modfile.File, dir string) map[string]string {
+	replaces := map[string]string{}
+	for _, replace := range f.Replace {
+		if !modfile.IsDirectoryPath(replace.New.Path) {
+			continue
+		}
+		path := filepath.Join(dir, replace.New.Path)
+		if !filepath.IsLocal(path) {
+			log.Warningf("ignoring replace directive for %v as %v is outside the repo", replace.Old.Path, replace.New.Path)
+			continue
+		}
+		replaces[replace.Old.Path] = path
+	}
+	return replaces
+}
+
 func depTarget(modules []string, importPath, thirdPartyFolder string) string {
 	module := moduleForPackage(modules, importPath)
 	if module == "" {
@@ -1,6 +1,7 @@
 package generate
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -11,6 +12,7 @@ import (
 	"github.com/please-build/puku/options"
 	"github.com/please-build/puku/please"
 	"github.com/please-build/puku/proxy"
+	"github.com/please-build/puku/repotest"
 	"github.com/please-build/puku/trie"
 )
 
@@ -100,3 +102,62 @@ func TestResolveImport(t *testing.T) {
 		assert.Equal(t, "///third_party/go/github.com_please-build_puku//package", ret)
 	})
 }
+
+func TestLocalReplaces(t *testing.T) {
+	dir := t.TempDir()
+	contents := `module github.com/some/module
+
+require (
+	example.com/local v1.0.0
+	example.com/remote v1.0.0
+	example.com/outside v1.0.0
+)
+
+replace (
+	example.com/local => ./third_party/local
+	example.com/remote => example.com/fork v1.0.1
+	example.com/outside => ../outside
+)
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte(contents), 0644))
+
+	goMod, err := readGoMod(filepath.Join(dir, "go.mod"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"example.com/local": "third_party/local"}, localReplaces(goMod, "."))
+
+	// Paths are relative to the go.mod, which can be in a subdirectory of the repo
+	assert.Equal(t, map[string]string{
+		"example.com/local":   "src/third_party/local",
+		"example.com/outside": "outside",
+	}, localReplaces(goMod, "src"))
+
+	u := &updater{localReplaces: map[string]string{"example.com/local": "third_party/local"}}
+	assert.Equal(t, "third_party/local", u.replacedPath("example.com/local"))
+	assert.Equal(t, "third_party/local/foo/bar", u.replacedPath("example.com/local/foo/bar"))
+	assert.Equal(t, "", u.replacedPath("example.com/localfoo"))
+	assert.Equal(t, "", u.replacedPath("example.com/remote"))
+
+	goMod, err = readGoMod(filepath.Join(dir, "missing.mod"))
+	require.NoError(t, err)
+	assert.Nil(t, goMod)
+}
+
+func TestReadRepoGoModInSubdir(t *testing.T) {
+	files := map[string]string{
+		"src/go.mod": `module github.com/some/module
+
+require example.com/local v1.0.0
+
+replace example.com/local => ./local
+`,
+		"third_party/go/BUILD": "",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		plzConf.Plugin.Go.Modfile = []string{"//src:gomod"}
+		u := newUpdater(plzConf, options.TestOptions)
+		require.NoError(t, u.readRepo(&config.Config{ThirdPartyDir: "third_party/go"}))
+		assert.Equal(t, map[string]string{"example.com/local": "src/local"}, u.localReplaces)
+	})
+}
@@ -0,0 +1,285 @@
+// Package docker maintains the srcs of docker_image rules, so that they include the files their Dockerfile copies into
+// the image, and images are rebuilt when those change.
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the docker rules
+const Subinclude = "///docker//build_defs:docker"
+
+// Kinds are the kinds of rule that puku generates for Dockerfiles
+var Kinds = map[string]*kinds.Kind{
+	"docker_image": {
+		Name:     "docker_image",
+		Type:     kinds.Bin,
+		SrcsAttr: "srcs",
+	},
+}
+
+// Generator updates the docker_image rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"docker"},
+		IsSource: IsDockerfile,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Dockerfiles in the directory. Their imports are the sources they copy from the build context.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ret []*language.File
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !IsDockerfile(entry.Name()) {
+			continue
+		}
+		copies, err := readCopies(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &language.File{Name: entry.Name(), Imports: copies})
+	}
+	return ret, nil
+}
+
+// Resolve returns the rule that provides a path copied by a Dockerfile in the directory. Paths in the directory's own
+// package don't need a rule, as they're added to the image's srcs as they are.
+func (g *Generator) Resolve(_ *config.Config, dir, path string) (string, error) {
+	pkg := g.packageOf(dir, path)
+	if pkg == dir {
+		return "", nil
+	}
+	rel, err := filepath.Rel(pkg, filepath.Join(dir, path))
+	if err != nil {
+		return "", err
+	}
+	label, err := g.ruleWithPath(pkg, rel)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		return "", fmt.Errorf("%v isn't in the srcs of any rule in %v", path, pkg)
+	}
+	return label, nil
+}
+
+// Update generates a docker_image for each Dockerfile in the directory, and adds the files that the Dockerfile copies
+// to its srcs
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var dockerfiles []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && IsDockerfile(entry.Name()) {
+			dockerfiles = append(dockerfiles, entry.Name())
+		}
+	}
+	if len(dockerfiles) == 0 {
+		return nil
+	}
+
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+
+	rules := map[string]*edit.Rule{}
+	for _, expr := range file.Rules("docker_image") {
+		dockerfile := expr.AttrString("dockerfile")
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		rules[dockerfile] = edit.NewRule(expr, Kinds["docker_image"], dir)
+	}
+
+	for _, dockerfile := range dockerfiles {
+		if _, ok := rules[dockerfile]; ok {
+			continue
+		}
+		name := "image"
+		if v := variant(dockerfile); v != "" {
+			name = strings.NewReplacer(".", "_", "-", "_").Replace(v) + "_image"
+		}
+		if edit.FindTargetByName(file, name) != nil {
+			name += "_docker"
+		}
+		rule := edit.NewRule(edit.NewRuleExpr("docker_image", name), Kinds["docker_image"], dir)
+		if dockerfile != "Dockerfile" {
+			rule.SetAttr("dockerfile", edit.NewStringExpr(dockerfile))
+		}
+		file.Stmt = append(file.Stmt, rule.Call)
+		rules[dockerfile] = rule
+	}
+
+	if len(rules) > 0 && !g.plzConf.IsPreloaded(Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, Subinclude)
+	}
+
+	for dockerfile, rule := range rules {
+		if err := g.updateSrcs(rule, filepath.Join(dir, dockerfile)); err != nil {
+			return fmt.Errorf("failed to update %v: %v", rule.Label(), err)
+		}
+	}
+	return nil
+}
+
+// updateSrcs adds the sources that the Dockerfile copies to the rule's srcs. Files in the package are added as they
+// are, and files in other packages are added as the rule that has them in its srcs. Files that no longer exist are
+// removed, but labels are left alone as they may have been added by hand.
+func (g *Generator) updateSrcs(rule *edit.Rule, dockerfile string) error {
+	if _, err := os.Stat(dockerfile); os.IsNotExist(err) {
+		return nil
+	}
+	copies, err := readCopies(dockerfile)
+	if err != nil {
+		return err
+	}
+
+	existing := rule.AttrStrings(rule.SrcsAttr())
+	has := map[string]bool{}
+	var srcs []string
+	for _, src := range existing {
+		if !isLabel(src) {
+			if _, err := os.Stat(filepath.Join(rule.Dir, src)); err != nil {
+				continue
+			}
+		}
+		has[src] = true
+		srcs = append(srcs, src)
+	}
+
+	var added []string
+	for _, c := range copies {
+		paths, err := expandSource(rule.Dir, c)
+		if err != nil {
+			return err
+		}
+		for _, path := range paths {
+			src, err := g.srcFor(rule, path)
+			if err != nil {
+				return err
+			}
+			if src != "" && !has[src] {
+				has[src] = true
+				added = append(added, src)
+			}
+		}
+	}
+	sort.Strings(added)
+
+	rule.SetOrDeleteAttr(rule.SrcsAttr(), append(srcs, added...))
+	return nil
+}
+
+// srcFor returns what to add to the rule's srcs for a path that its Dockerfile copies
+func (g *Generator) srcFor(rule *edit.Rule, path string) (string, error) {
+	pkg := g.packageOf(rule.Dir, path)
+	if pkg == rule.Dir {
+		return path, nil
+	}
+
+	rel, err := filepath.Rel(pkg, filepath.Join(rule.Dir, path))
+	if err != nil {
+		return "", err
+	}
+	if rel == "." {
+		log.Warningf("%v copies the whole of %v, which is another package, so can't be added to its srcs", rule.Label(), pkg)
+		return "", nil
+	}
+	label, err := g.ruleWithPath(pkg, rel)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		log.Warningf("%v copies %v, which is in %v but not in the srcs of any rule there", rule.Label(), path, pkg)
+		return "", nil
+	}
+	g.graph.EnsureVisibility(rule.Label(), label)
+	return label, nil
+}
+
+// ruleWithPath returns the label of the first rule in the package with the path, or a file under it, in its srcs. It
+// returns an empty string if there isn't one.
+func (g *Generator) ruleWithPath(pkg, path string) (string, error) {
+	file, err := g.graph.LoadFile(pkg)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", pkg, err)
+	}
+	for _, expr := range file.Rules("") {
+		srcs, err := g.eval.EvalGlobs(pkg, expr, "srcs")
+		if err != nil {
+			return "", err
+		}
+		for _, src := range srcs {
+			if src == path || strings.HasPrefix(src, path+"/") {
+				return edit.BuildTarget(expr.Name(), pkg, ""), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// packageOf returns the package that a path, relative to the given package, belongs to. This is the deepest directory
+// along the path that has a BUILD file.
+func (g *Generator) packageOf(dir, path string) string {
+	pkg := dir
+	current := dir
+	parts := strings.Split(filepath.Dir(path), string(filepath.Separator))
+	if info, err := os.Stat(filepath.Join(dir, path)); err == nil && info.IsDir() {
+		parts = strings.Split(path, string(filepath.Separator))
+	}
+	for _, part := range parts {
+		if part == "." {
+			continue
+		}
+		current = filepath.Join(current, part)
+		for _, name := range g.plzConf.BuildFileNames() {
+			if info, err := os.Stat(filepath.Join(current, name)); err == nil && info.Mode().IsRegular() {
+				pkg = current
+			}
+		}
+	}
+	return pkg
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
@@ -0,0 +1,81 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"service/Dockerfile": `FROM alpine
+COPY entrypoint.sh config/*.yaml /app/
+COPY static /app/static
+COPY web/dist/index.html /app/web/
+COPY missing.txt /app/
+`,
+		"service/Dockerfile.debug":    "FROM alpine\nCOPY entrypoint.sh /app/\n",
+		"service/entrypoint.sh":       "",
+		"service/config/prod.yaml":    "",
+		"service/config/dev.yaml":     "",
+		"service/static/style.css":    "",
+		"service/web/dist/index.html": "",
+		"service/web/BUILD":           "filegroup(\n    name = \"dist\",\n    srcs = glob([\"dist/**\"]),\n)\n",
+		"service/BUILD": `docker_image(
+    name = "debug",
+    dockerfile = "Dockerfile.debug",
+    srcs = [
+        "deleted.sh",
+        "//third_party:certs",
+    ],
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+
+		require.NoError(t, g.Update(new(config.Config), "service"))
+		file, err := g.graph.LoadFile("service")
+		require.NoError(t, err)
+
+		t.Run("generates an image for the Dockerfile", func(t *testing.T) {
+			image := edit.FindTargetByName(file, "image")
+			require.NotNil(t, image)
+			assert.Equal(t, "docker_image", image.Kind())
+			assert.Empty(t, image.AttrString("dockerfile"))
+			assert.Equal(t, []string{
+				"//service/web:dist",
+				"config/dev.yaml",
+				"config/prod.yaml",
+				"entrypoint.sh",
+				"static",
+			}, image.AttrStrings("srcs"))
+		})
+
+		t.Run("updates existing images", func(t *testing.T) {
+			debug := edit.FindTargetByName(file, "debug")
+			require.NotNil(t, debug)
+			assert.Equal(t, []string{"//third_party:certs", "entrypoint.sh"}, debug.AttrStrings("srcs"))
+		})
+
+		t.Run("subincludes the docker rules", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///docker//build_defs:docker", call.List[0].(*build.StringExpr).Value)
+		})
+	})
+}
@@ -0,0 +1,131 @@
+package docker
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// IsDockerfile returns whether the file is a Dockerfile, i.e. it's called Dockerfile, or is a variant of one like
+// Dockerfile.dev or dev.Dockerfile
+func IsDockerfile(name string) bool {
+	return name == "Dockerfile" || strings.HasPrefix(name, "Dockerfile.") || strings.HasSuffix(name, ".Dockerfile")
+}
+
+// variant returns the variant of a Dockerfile, e.g. dev for Dockerfile.dev or dev.Dockerfile, or an empty string for
+// a plain Dockerfile
+func variant(name string) string {
+	if v := strings.TrimPrefix(name, "Dockerfile."); v != name {
+		return v
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(name, "Dockerfile"), ".")
+}
+
+// readCopies reads the sources that a Dockerfile copies from its build context with COPY or ADD. Copies from other
+// build stages, and ADDs of URLs, aren't from the context so are skipped.
+func readCopies(path string) ([]string, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var srcs []string
+	for _, inst := range instructions(bs) {
+		cmd, args, _ := strings.Cut(inst, " ")
+		cmd = strings.ToUpper(cmd)
+		if cmd != "COPY" && cmd != "ADD" {
+			continue
+		}
+
+		args = strings.TrimSpace(args)
+		fromStage := false
+		for strings.HasPrefix(args, "--") {
+			flag, rest, _ := strings.Cut(args, " ")
+			fromStage = fromStage || strings.HasPrefix(flag, "--from")
+			args = strings.TrimSpace(rest)
+		}
+		if fromStage {
+			continue
+		}
+
+		paths := copyArgs(args)
+		if len(paths) < 2 {
+			continue
+		}
+		for _, src := range paths[:len(paths)-1] {
+			if cmd == "ADD" && strings.Contains(src, "://") {
+				continue
+			}
+			srcs = append(srcs, src)
+		}
+	}
+	return srcs, nil
+}
+
+// copyArgs splits the arguments of a COPY or ADD, which can either be whitespace separated or a JSON array
+func copyArgs(args string) []string {
+	if strings.HasPrefix(args, "[") {
+		var paths []string
+		if err := json.Unmarshal([]byte(args), &paths); err == nil {
+			return paths
+		}
+	}
+	return strings.Fields(args)
+}
+
+// instructions splits a Dockerfile into its instructions, joining lines continued with a backslash and removing comments
+func instructions(bs []byte) []string {
+	var insts []string
+	current := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		if inst := strings.TrimSpace(current + line); inst != "" {
+			insts = append(insts, inst)
+		}
+		current = ""
+	}
+	if inst := strings.TrimSpace(current); inst != "" {
+		insts = append(insts, inst)
+	}
+	return insts
+}
+
+// expandSource expands a source from a COPY, relative to the build context, into the paths it matches. These can be
+// files or directories, and can contain wildcards. Paths outside the context are skipped, as Docker doesn't allow them.
+func expandSource(dir, src string) ([]string, error) {
+	src = filepath.Clean(src)
+	if src == "." || filepath.IsAbs(src) || src == ".." || strings.HasPrefix(src, "../") {
+		return nil, nil
+	}
+	if !strings.ContainsAny(src, "*?[") {
+		if _, err := os.Stat(filepath.Join(dir, src)); err != nil {
+			return nil, nil
+		}
+		return []string{src}, nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, src))
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(matches))
+	for _, match := range matches {
+		rel, err := filepath.Rel(dir, match)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, rel)
+	}
+	return ret, nil
+}
@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCopies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(`# syntax=docker/dockerfile:1
+FROM golang:1.22 AS builder
+COPY go.mod go.sum ./
+COPY --from=builder /out/app /app
+copy --chown=app:app config.yaml \
+    static/ /srv/
+ADD https://example.com/file.tar.gz /tmp/
+ADD ["vendor.tar.gz", "/opt/"]
+# COPY commented.txt /
+RUN echo "COPY not_an_instruction /"
+`), 0644))
+
+	copies, err := readCopies(filepath.Join(dir, "Dockerfile"))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"go.mod", "go.sum", "config.yaml", "static/", "vendor.tar.gz"}, copies)
+}
+
+func TestVariant(t *testing.T) {
+	assert.Equal(t, "", variant("Dockerfile"))
+	assert.Equal(t, "dev", variant("Dockerfile.dev"))
+	assert.Equal(t, "dev", variant("dev.Dockerfile"))
+	assert.True(t, IsDockerfile("prod.Dockerfile"))
+	assert.False(t, IsDockerfile("Dockerfile_notes.md"))
+}
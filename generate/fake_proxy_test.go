@@ -22,6 +22,10 @@ func (f FakeProxy) ResolveModuleForPackage(pattern string) (*proxy.Module, error
 	return nil, errors.New("not found")
 }
 
+func (f FakeProxy) Exclude(_ ...proxy.Module) {}
+
+func (f FakeProxy) VerifyWith(_ proxy.GoSum) {}
+
 func (f FakeProxy) ResolveDeps(_, _ []*proxy.Module) ([]*proxy.Module, error) {
 	panic("not implemented")
 }
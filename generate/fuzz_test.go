@@ -0,0 +1,55 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestAllocateFuzzSources(t *testing.T) {
+	files := map[string]*GoFile{
+		"foo_test.go": {
+			Name:      "foo",
+			FileName:  "foo_test.go",
+			TestFuncs: []string{"TestFoo"},
+		},
+		"fuzz_test.go": {
+			Name:      "foo",
+			FileName:  "fuzz_test.go",
+			TestFuncs: []string{"FuzzFoo"},
+		},
+	}
+
+	t.Run("treats fuzz tests as tests by default", func(t *testing.T) {
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(new(config.Config), "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 1)
+		assert.ElementsMatch(t, []string{"foo_test.go", "fuzz_test.go"}, newRules[0].AttrStrings("srcs"))
+	})
+
+	t.Run("allocates fuzz tests to the fuzz kind", func(t *testing.T) {
+		conf := &config.Config{FuzzKind: "go_fuzz_test"}
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(conf, "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 2)
+
+		byName := map[string]*edit.Rule{}
+		for _, r := range newRules {
+			byName[r.Name()] = r
+		}
+		require.Contains(t, byName, "foo_test")
+		require.Contains(t, byName, "foo_fuzz")
+		assert.Equal(t, "go_test", byName["foo_test"].Rule.Kind())
+		assert.Equal(t, []string{"foo_test.go"}, byName["foo_test"].AttrStrings("srcs"))
+		assert.Equal(t, "go_fuzz_test", byName["foo_fuzz"].Rule.Kind())
+		assert.Equal(t, []string{"fuzz_test.go"}, byName["foo_fuzz"].AttrStrings("srcs"))
+	})
+}
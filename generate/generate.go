@@ -5,6 +5,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/please-build/buildtools/build"
@@ -16,12 +17,26 @@ import (
 	"github.com/please-build/puku/glob"
 	"github.com/please-build/puku/graph"
 	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
 	"github.com/please-build/puku/licences"
 	"github.com/please-build/puku/logging"
 	"github.com/please-build/puku/options"
 	"github.com/please-build/puku/please"
 	"github.com/please-build/puku/proxy"
 	"github.com/please-build/puku/trie"
+
+	// The languages other than Go that puku supports out of the box
+	_ "github.com/please-build/puku/generate/builddefs"
+	_ "github.com/please-build/puku/generate/cc"
+	_ "github.com/please-build/puku/generate/docker"
+	_ "github.com/please-build/puku/generate/java"
+	_ "github.com/please-build/puku/generate/jsonnet"
+	_ "github.com/please-build/puku/generate/migrations"
+	_ "github.com/please-build/puku/generate/python"
+	_ "github.com/please-build/puku/generate/rust"
+	_ "github.com/please-build/puku/generate/shell"
+	_ "github.com/please-build/puku/generate/terraform"
+	_ "github.com/please-build/puku/generate/thrift"
 )
 
 var log = logging.GetLogger()
@@ -29,6 +44,8 @@ var log = logging.GetLogger()
 type Proxy interface {
 	ResolveModuleForPackage(pattern string) (*proxy.Module, error)
 	ResolveDeps(mods, newMods []*proxy.Module) ([]*proxy.Module, error)
+	Exclude(mods ...proxy.Module)
+	VerifyWith(sums proxy.GoSum)
 }
 
 type updater struct {
@@ -39,27 +56,40 @@ type updater struct {
 
 	newModules      []*proxy.Module
 	modules         []string
+	localReplaces   map[string]string
 	resolvedImports map[string]string
 	installs        *trie.Trie
 	eval            *eval.Eval
 
 	paths []string
 
+	// vendorPkgs are the packages in the vendor directory that we need to generate BUILD files for
+	vendorPkgs []string
+	// protoPackages are the .proto files in the repo, keyed by the import path from their go_package option
+	protoPackages map[string][]protoFile
+
 	proxy    Proxy
 	licences *licences.Licenses
+
+	// languages are the registered languages other than Go
+	languages []*language.Backend
 }
 
 func newUpdaterWithGraph(g *graph.Graph, conf *please.Config) *updater {
-	p := proxy.New(proxy.DefaultURL)
+	p := proxy.NewFromEnv()
 	l := licences.New(p, g)
+	e := eval.New(glob.New())
+	// The rules for other languages have globs for more than just .go files
+	files := eval.New(glob.NewAllFiles(conf.BuildFileNames()))
 	return &updater{
 		proxy:           p,
 		licences:        l,
 		plzConf:         conf,
 		graph:           g,
 		installs:        trie.New(),
-		eval:            eval.New(glob.New()),
+		eval:            e,
 		resolvedImports: map[string]string{},
+		languages:       language.NewBackends(conf, g, files),
 	}
 }
 
@@ -150,8 +180,8 @@ func (u *updater) update(paths ...string) error {
 	}
 	u.paths = paths
 
-	if err := u.readAllModules(conf); err != nil {
-		return fmt.Errorf("failed to read third party rules: %v", err)
+	if err := u.readRepo(conf); err != nil {
+		return err
 	}
 
 	for _, path := range u.paths {
@@ -167,12 +197,58 @@ func (u *updater) update(paths ...string) error {
 		if err := u.updateOne(conf, path); err != nil {
 			return fmt.Errorf("failed to update %v: %v", path, err)
 		}
+
+		for _, l := range u.languages {
+			if !l.Enabled(conf) {
+				continue
+			}
+			if err := l.Update(conf, path); err != nil {
+				return fmt.Errorf("failed to update %v rules in %v: %v", l.Name(), path, err)
+			}
+		}
+	}
+
+	if err := u.updateVendorPkgs(); err != nil {
+		return fmt.Errorf("failed to update vendored packages: %v", err)
 	}
 
 	// Save any new modules we needed back to the third party file
 	return u.addNewModules(conf)
 }
 
+// readRepo reads the third party modules, and the go.mod and go.sum, that imports are resolved against
+func (u *updater) readRepo(conf *config.Config) error {
+	if err := u.readAllModules(conf); err != nil {
+		return fmt.Errorf("failed to read third party rules: %v", err)
+	}
+
+	// Like puku sync, find the go.mod from the target configured for it, as it doesn't have to be at the root of the repo
+	goModDir := "."
+	if modFile := u.plzConf.ModFile(); modFile != "" {
+		if pkg := labels.Parse(modFile).Package; pkg != "" {
+			goModDir = pkg
+		}
+	}
+
+	goMod, err := readGoMod(filepath.Join(goModDir, "go.mod"))
+	if err != nil {
+		return fmt.Errorf("failed to read go.mod: %v", err)
+	}
+	if goMod != nil {
+		u.localReplaces = localReplaces(goMod, goModDir)
+		for _, exclude := range goMod.Exclude {
+			u.proxy.Exclude(proxy.Module{Module: exclude.Mod.Path, Version: exclude.Mod.Version})
+		}
+	}
+
+	sums, err := proxy.ReadGoSum(filepath.Join(goModDir, "go.sum"))
+	if err != nil {
+		return fmt.Errorf("failed to read go.sum: %v", err)
+	}
+	u.proxy.VerifyWith(sums)
+	return nil
+}
+
 func (u *updater) updateOne(conf *config.Config, path string) error {
 	// Find all the files in the dir
 	sources, err := ImportDir(path)
@@ -199,10 +275,30 @@ func (u *updater) updateOne(conf *config.Config, path string) error {
 		return err
 	}
 
+	setVendorVisibility(conf, path, newRules)
 	rules = append(rules, newRules...)
 
+	if err := u.updateCgoRules(conf, path, rules, sources); err != nil {
+		return err
+	}
+
+	if err := u.updateAsmSrcs(conf, path, rules); err != nil {
+		return err
+	}
+
+	updateTestdata(path, rules)
+	if err := u.addFileReads(conf, path, rules, sources); err != nil {
+		return err
+	}
+	updateInternalVisibility(path, rules)
+
 	// Update the existing call expressions in the build file
-	return u.updateDeps(conf, file, calls, rules, sources)
+	if err := u.updateDeps(conf, file, calls, rules, sources); err != nil {
+		return err
+	}
+
+	// This is done last as the deps of any new genrule outputs can only be determined once the genrule exists
+	return u.scaffoldGenrules(conf, file, path, rules, sources)
 }
 
 func (u *updater) addNewModules(conf *config.Config) error {
@@ -256,9 +352,13 @@ func (u *updater) addNewModules(conf *config.Config) error {
 // the source doesn't actually exist. In which case, this should be removed from the rule, as the user likely deleted
 // the file.
 func (u *updater) allSources(conf *config.Config, r *edit.Rule, sourceMap map[string]*GoFile) (passedSources []string, goFiles map[string]*GoFile, err error) {
-	srcs, err := u.eval.BuildSources(conf.GetPlzPath(), r.Dir, r.Rule, r.SrcsAttr())
-	if err != nil {
-		return nil, nil, err
+	var srcs []string
+	for _, attr := range r.SrcsAttrs() {
+		attrSrcs, err := u.eval.BuildSources(conf.GetPlzPath(), r.Dir, r.Rule, attr)
+		if err != nil {
+			return nil, nil, err
+		}
+		srcs = append(srcs, attrSrcs...)
 	}
 
 	sources := make(map[string]*GoFile, len(srcs))
@@ -302,7 +402,7 @@ func isExternal(rule *edit.Rule) bool {
 
 // updateRuleDeps updates the dependencies of a build rule based on the imports of its sources
 func (u *updater) updateRuleDeps(conf *config.Config, rule *edit.Rule, rules []*edit.Rule, packageFiles map[string]*GoFile) error {
-	done := map[string]struct{}{}
+	done := map[string]string{}
 
 	// If the rule operates on non-go source files (e.g. *.proto for proto_library) then we should skip updating
 	// it as we can't determine its deps from sources this way.
@@ -316,51 +416,112 @@ func (u *updater) updateRuleDeps(conf *config.Config, rule *edit.Rule, rules []*
 	}
 
 	label := edit.BuildTarget(rule.Name(), rule.Dir, "")
+	tagSets := conf.GetBuildTagSets()
+	setName, ownSet := splitTagSet(conf, rule)
+	if ownSet != nil {
+		// The library is only built with this tag set, so its deps are unconditional
+		tagSets = map[string]*config.BuildTagSet{setName: {Tags: ownSet.Tags}}
+	}
 
 	deps := map[string]struct{}{}
+	conditionalDeps := map[string]map[string]struct{}{}
+	addDep := func(dep string, conditions []string) {
+		for _, condition := range conditions {
+			if condition == "" {
+				deps[dep] = struct{}{}
+				continue
+			}
+			if _, ok := conditionalDeps[condition]; !ok {
+				conditionalDeps[condition] = map[string]struct{}{}
+			}
+			conditionalDeps[condition][dep] = struct{}{}
+		}
+	}
+	// addLibDep adds a dep on a library. If its package is split by build tag set, we depend on the library for each tag
+	// set under its condition, so only one copy of the package is ever linked. A library that's split itself just
+	// depends on the library of its own tag set.
+	addLibDep := func(dep string, conditions []string) error {
+		libs, err := u.localSplitLibs(conf, rule.Dir, dep)
+		if err != nil || libs == nil {
+			addDep(shorten(rule.Dir, dep), conditions)
+			return err
+		}
+		for _, condition := range conditions {
+			if condition == "" && ownSet == nil {
+				for libCondition, lib := range libs {
+					if len(libs) == 1 {
+						libCondition = ""
+					}
+					addDep(shorten(rule.Dir, lib), []string{libCondition})
+				}
+				continue
+			}
+			key := condition
+			if ownSet != nil {
+				key = ownSet.Condition
+			}
+			lib, ok := libs[key]
+			if key == "" || !ok {
+				lib = libs[edit.DefaultCondition]
+			}
+			if lib != "" {
+				addDep(shorten(rule.Dir, lib), []string{condition})
+			}
+		}
+		return nil
+	}
 	for _, src := range srcs {
 		f := targetFiles[src]
 		if f == nil {
 			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
 			continue
 		}
-		for _, i := range f.Imports {
-			if _, ok := done[i]; ok {
-				continue
-			}
-			done[i] = struct{}{}
+		conditions := f.depConditions(tagSets)
 
-			// If the dep is provided by the kind (i.e. the build def adds it) then skip this import
+		// Generated code can be configured to depend on a fixed set of targets rather than what it imports
+		if gen := f.generatedCode(conf); gen != nil && len(gen.Deps) > 0 {
+			for _, dep := range gen.Deps {
+				addDep(shorten(rule.Dir, dep), conditions)
+			}
+			continue
+		}
 
-			dep, err := u.resolveImport(conf, i)
-			if err != nil {
-				log.Warningf("couldn't resolve %q for %v: %v", i, rule.Label(), err)
-				continue
+		for _, i := range f.Imports {
+			dep, ok := done[i]
+			if !ok {
+				// If the dep is provided by the kind (i.e. the build def adds it) then skip this import
+				dep, err = u.resolveImport(conf, i)
+				if err != nil {
+					u.graph.Unresolved(rule.Label(), i, err)
+				}
+				if dep != "" && rule.Kind.IsProvided(dep) {
+					dep = ""
+				}
+				done[i] = dep
 			}
 			if dep == "" {
 				continue
 			}
-			if rule.Kind.IsProvided(dep) {
-				continue
-			}
-
-			dep = shorten(rule.Dir, dep)
-
-			if _, ok := deps[dep]; !ok {
-				deps[dep] = struct{}{}
+			if err := addLibDep(dep, conditions); err != nil {
+				return err
 			}
 		}
 	}
 
-	// Add any libraries for the same package as us
-	if rule.Kind.Type == kinds.Test && !isExternal(rule) {
+	// Add any libraries for the same package as us. External tests are in their own package, but they always need the
+	// library under test.
+	if rule.Kind.Type.IsTest() {
 		pkgName, err := u.rulePkg(conf, packageFiles, rule)
 		if err != nil {
 			return err
 		}
+		if isExternal(rule) {
+			pkgName = strings.TrimSuffix(pkgName, "_test")
+		}
 
 		for _, libRule := range rules {
-			if libRule.Kind.Type == kinds.Test {
+			// Tests in a main package can't depend on the binary
+			if libRule.Kind.Type != kinds.Lib {
 				continue
 			}
 			libPkgName, err := u.rulePkg(conf, packageFiles, libRule)
@@ -372,9 +533,8 @@ func (u *updater) updateRuleDeps(conf *config.Config, rule *edit.Rule, rules []*
 				continue
 			}
 
-			t := libRule.LocalLabel()
-			if _, ok := deps[t]; !ok {
-				deps[t] = struct{}{}
+			if err := addLibDep(libRule.Label(), []string{""}); err != nil {
+				return err
 			}
 		}
 	}
@@ -385,7 +545,23 @@ func (u *updater) updateRuleDeps(conf *config.Config, rule *edit.Rule, rules []*
 		depSlice = append(depSlice, dep)
 	}
 
-	rule.SetOrDeleteAttr("deps", depSlice)
+	conditionalDepSlices := make(map[string][]string, len(conditionalDeps))
+	for condition, ds := range conditionalDeps {
+		for dep := range ds {
+			// No need to add it under the condition if we always depend on it
+			if _, ok := deps[dep]; ok {
+				continue
+			}
+			u.graph.EnsureVisibility(label, dep)
+			conditionalDepSlices[condition] = append(conditionalDepSlices[condition], dep)
+		}
+	}
+	for condition, ds := range conditionalDepSlices {
+		sort.Strings(ds)
+		conditionalDepSlices[condition] = ds
+	}
+
+	rule.SetOrDeleteSelectAttr("deps", depSlice, conditionalDepSlices)
 
 	return nil
 }
@@ -445,9 +621,22 @@ func (u *updater) allocateSources(conf *config.Config, pkgDir string, sources ma
 		if importedFile == nil {
 			continue // Something went wrong and we haven't imported the file don't try to allocate it
 		}
+		if gen := importedFile.generatedCode(conf); gen != nil && gen.Exclude {
+			continue
+		}
+		if importedFile.IsIgnored() {
+			continue // e.g. a //go:build ignore program that's run with go run
+		}
+		if importedFile.IsWasm() && importedFile.kindType(conf) != kinds.Wasm {
+			continue // These can't be built for the host platform, and we've not been configured with a kind for them
+		}
+		if tagSets := splitTagSets(conf); len(tagSets) > 0 && importedFile.kindType(conf) == kinds.Lib {
+			newRules = allocateSplitSource(conf, pkgDir, src, importedFile, tagSets, rules, newRules)
+			continue
+		}
 		var rule *edit.Rule
 		for _, r := range append(rules, newRules...) {
-			if r.Kind.Type != importedFile.kindType() {
+			if r.Kind.Type != importedFile.kindType(conf) {
 				continue
 			}
 
@@ -467,6 +656,9 @@ func (u *updater) allocateSources(conf *config.Config, pkgDir string, sources ma
 		if rule == nil {
 			name := filepath.Base(pkgDir)
 			kind := "go_library"
+			if importedFile.IsCgo() {
+				kind = "cgo_library"
+			}
 			if importedFile.IsTest() {
 				name += "_test"
 				kind = "go_test"
@@ -475,18 +667,58 @@ func (u *updater) allocateSources(conf *config.Config, pkgDir string, sources ma
 				kind = "go_binary"
 				name = "main"
 			}
-			rule = edit.NewRule(edit.NewRuleExpr(kind, name), kinds.DefaultKinds[kind], pkgDir)
-			if importedFile.IsExternal(filepath.Join(u.plzConf.ImportPath(), pkgDir)) {
+			kindType := kinds.DefaultKinds[kind]
+			switch importedFile.kindType(conf) {
+			case kinds.Fuzz:
+				name = filepath.Base(pkgDir) + "_fuzz"
+				kind = conf.GetFuzzKind()
+				kindType = conf.GetKind(kind)
+			case kinds.Benchmark:
+				name = filepath.Base(pkgDir) + "_benchmark"
+				kind = conf.GetBenchmarkKind()
+				kindType = conf.GetKind(kind)
+			case kinds.Wasm:
+				name = filepath.Base(pkgDir) + "_wasm"
+				kind = conf.GetWasmKind()
+				kindType = conf.GetKind(kind)
+			}
+			external := importedFile.IsExternal(filepath.Join(u.plzConf.ImportPath(), pkgDir))
+			if external && ruleNameTaken(append(rules, newRules...), name) {
+				// The internal tests already have this name, so the external tests need their own
+				name = strings.TrimSuffix(name, "_test") + "_external_test"
+			}
+			rule = edit.NewRule(edit.NewRuleExpr(kind, name), kindType, pkgDir)
+			if external {
 				setExternal(rule)
 			}
+			if vis := internalVisibility(pkgDir); vis != "" && rule.Kind.Type == kinds.Lib {
+				rule.SetAttr("visibility", edit.NewStringList([]string{vis}))
+			}
 			newRules = append(newRules, rule)
 		}
 
+		if importedFile.IsCgo() && rule.Kind.Type == kinds.Lib {
+			if ensureCgoKind(rule) {
+				rule.AddCgoSrc(src)
+				continue
+			}
+			log.Warningf("%v imports \"C\" but %v is not a cgo kind", filepath.Join(pkgDir, src), rule.Label())
+		}
 		rule.AddSrc(src)
 	}
 	return newRules, nil
 }
 
+// ruleNameTaken returns whether any of the rules has the given name
+func ruleNameTaken(rules []*edit.Rule, name string) bool {
+	for _, r := range rules {
+		if r.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
 // rulePkg checks the first source it finds for a rule and returns the name from the "package name" directive at the top
 // of the file
 func (u *updater) rulePkg(conf *config.Config, srcs map[string]*GoFile, rule *edit.Rule) (string, error) {
@@ -519,14 +751,16 @@ func (u *updater) unallocatedSources(srcs map[string]*GoFile, rules []*edit.Rule
 				break
 			}
 
-			ruleSrcs, err := u.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
-			if err != nil {
-				return nil, err
-			}
-			for _, s := range ruleSrcs {
-				if s == src {
-					found = true
-					break
+			for _, attr := range rule.SrcsAttrs() {
+				ruleSrcs, err := u.eval.EvalGlobs(rule.Dir, rule.Rule, attr)
+				if err != nil {
+					return nil, err
+				}
+				for _, s := range ruleSrcs {
+					if s == src {
+						found = true
+						break
+					}
 				}
 			}
 		}
@@ -1,8 +1,10 @@
 package generate
 
 import (
+	"go/build/constraint"
 	"testing"
 
+	"github.com/please-build/buildtools/build"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -11,6 +13,7 @@ import (
 	"github.com/please-build/puku/kinds"
 	"github.com/please-build/puku/options"
 	"github.com/please-build/puku/please"
+	"github.com/please-build/puku/repotest"
 )
 
 func TestAllocateSources(t *testing.T) {
@@ -51,7 +54,8 @@ func TestAllocateSources(t *testing.T) {
 	require.NoError(t, err)
 
 	require.Len(t, newRules, 1)
-	assert.Equal(t, "foo_test", newRules[0].Name())
+	assert.Equal(t, "foo_external_test", newRules[0].Name())
+	assert.True(t, isExternal(newRules[0]))
 	assert.ElementsMatch(t, []string{"external_test.go"}, mustGetSources(t, u, newRules[0]))
 
 	assert.ElementsMatch(t, []string{"foo.go", "bar.go"}, mustGetSources(t, u, rules[0]))
@@ -84,6 +88,44 @@ func TestAddingLibDepToTest(t *testing.T) {
 	assert.Equal(t, fooTest.AttrStrings("deps"), []string{":foo"})
 }
 
+func TestAddingLibDepToExternalTest(t *testing.T) {
+	foo := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], "")
+	fooTest := edit.NewRule(edit.NewRuleExpr("go_test", "foo_test"), kinds.DefaultKinds["go_test"], "")
+	fooExternalTest := edit.NewRule(edit.NewRuleExpr("go_test", "foo_external_test"), kinds.DefaultKinds["go_test"], "")
+	setExternal(fooExternalTest)
+
+	files := map[string]*GoFile{
+		"foo.go": {
+			Name:     "foo",
+			FileName: "foo.go",
+		},
+		"foo_test.go": {
+			Name:     "foo",
+			FileName: "foo_test.go",
+			Imports:  []string{"github.com/stretchr/testify/assert"},
+		},
+		"external_test.go": {
+			Name:     "foo_test",
+			FileName: "external_test.go",
+		},
+	}
+
+	foo.SetAttr(foo.SrcsAttr(), edit.NewStringList([]string{"foo.go"}))
+	fooTest.SetAttr(fooTest.SrcsAttr(), edit.NewStringList([]string{"foo_test.go"}))
+	fooExternalTest.SetAttr(fooExternalTest.SrcsAttr(), edit.NewStringList([]string{"external_test.go"}))
+
+	u := newUpdater(new(please.Config), options.TestOptions)
+	u.modules = []string{"github.com/stretchr/testify"}
+	conf := &config.Config{PleasePath: "plz", ThirdPartyDir: "third_party/go"}
+	rules := []*edit.Rule{foo, fooTest, fooExternalTest}
+	for _, rule := range rules {
+		require.NoError(t, u.updateRuleDeps(conf, rule, rules, files))
+	}
+
+	assert.ElementsMatch(t, []string{"///third_party/go/github.com_stretchr_testify//assert", ":foo"}, fooTest.AttrStrings("deps"))
+	assert.Equal(t, []string{":foo"}, fooExternalTest.AttrStrings("deps"))
+}
+
 func TestAllocateSourcesToCustomKind(t *testing.T) {
 	exampleKind := &kinds.Kind{
 		Name:     "go_example_lib",
@@ -297,6 +339,110 @@ func TestUpdateDeps(t *testing.T) {
 	}
 }
 
+func TestUpdateDepsWithBuildTagSets(t *testing.T) {
+	mustParse := func(line string) constraint.Expr {
+		expr, err := constraint.Parse(line)
+		require.NoError(t, err)
+		return expr
+	}
+
+	files := map[string]*GoFile{
+		"foo.go": {
+			FileName: "foo.go",
+			Imports:  []string{"github.com/example/module/foo"},
+			Name:     "foo",
+		},
+		"foo_linux.go": {
+			FileName:   "foo_linux.go",
+			Imports:    []string{"github.com/example/module/linux"},
+			Name:       "foo",
+			Constraint: mustParse("//go:build linux"),
+		},
+		"foo_darwin.go": {
+			FileName:   "foo_darwin.go",
+			Imports:    []string{"github.com/example/module/darwin", "github.com/example/module/foo"},
+			Name:       "foo",
+			Constraint: mustParse("//go:build darwin"),
+		},
+		"foo_integration.go": {
+			FileName:   "foo_integration.go",
+			Imports:    []string{"github.com/example/module/integration"},
+			Name:       "foo",
+			Constraint: mustParse("// +build integration"),
+		},
+	}
+
+	newRule := func() *edit.Rule {
+		r := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], "")
+		for _, src := range []string{"foo.go", "foo_linux.go", "foo_darwin.go", "foo_integration.go"} {
+			r.AddSrc(src)
+		}
+		return r
+	}
+
+	newUpdaterForTest := func() *updater {
+		plzConf := new(please.Config)
+		plzConf.Plugin.Go.ImportPath = []string{"github.com/this/module"}
+		u := newUpdater(plzConf, options.TestOptions)
+		u.modules = []string{"github.com/example/module"}
+		return u
+	}
+
+	t.Run("adds all imports when no tag sets are configured", func(t *testing.T) {
+		r := newRule()
+		err := newUpdaterForTest().updateRuleDeps(new(config.Config), r, []*edit.Rule{}, files)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"///third_party/go/github.com_example_module//foo",
+			"///third_party/go/github.com_example_module//linux",
+			"///third_party/go/github.com_example_module//darwin",
+			"///third_party/go/github.com_example_module//integration",
+		}, r.AttrStrings("deps"))
+	})
+
+	t.Run("skips imports from files that no tag set selects", func(t *testing.T) {
+		conf := &config.Config{
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"linux": {Tags: []string{"linux"}},
+			},
+		}
+		r := newRule()
+		err := newUpdaterForTest().updateRuleDeps(conf, r, []*edit.Rule{}, files)
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{
+			"///third_party/go/github.com_example_module//foo",
+			"///third_party/go/github.com_example_module//linux",
+		}, r.AttrStrings("deps"))
+	})
+
+	t.Run("adds conditional imports under a select", func(t *testing.T) {
+		conf := &config.Config{
+			BuildTagSets: map[string]*config.BuildTagSet{
+				"linux":  {Tags: []string{"linux"}, Condition: "//config:linux"},
+				"darwin": {Tags: []string{"darwin"}, Condition: "//config:darwin"},
+			},
+		}
+		r := newRule()
+		err := newUpdaterForTest().updateRuleDeps(conf, r, []*edit.Rule{}, files)
+		require.NoError(t, err)
+
+		bin, ok := r.Attr("deps").(*build.BinaryExpr)
+		require.True(t, ok)
+		assert.Equal(t, []string{"///third_party/go/github.com_example_module//foo"}, build.Strings(bin.X))
+
+		selectDeps := map[string][]string{}
+		call := bin.Y.(*build.CallExpr)
+		for _, kv := range call.List[0].(*build.DictExpr).List {
+			selectDeps[kv.Key.(*build.StringExpr).Value] = build.Strings(kv.Value)
+		}
+		assert.Equal(t, map[string][]string{
+			"//config:darwin":      {"///third_party/go/github.com_example_module//darwin"},
+			"//config:linux":       {"///third_party/go/github.com_example_module//linux"},
+			"//conditions:default": {},
+		}, selectDeps)
+	})
+}
+
 func mustGetSources(t *testing.T, u *updater, rule *edit.Rule) []string {
 	t.Helper()
 
@@ -304,3 +450,324 @@ func mustGetSources(t *testing.T, u *updater, rule *edit.Rule) []string {
 	require.NoError(t, err)
 	return srcs
 }
+
+func TestAllocateBenchmarkSources(t *testing.T) {
+	files := map[string]*GoFile{
+		"foo_test.go": {
+			Name:      "foo",
+			FileName:  "foo_test.go",
+			TestFuncs: []string{"TestFoo"},
+		},
+		"bench_test.go": {
+			Name:      "foo",
+			FileName:  "bench_test.go",
+			TestFuncs: []string{"BenchmarkFoo"},
+		},
+	}
+
+	conf := &config.Config{BenchmarkKind: "go_benchmark"}
+	u := newUpdater(new(please.Config), options.TestOptions)
+	newRules, err := u.allocateSources(conf, "foo", files, nil)
+	require.NoError(t, err)
+	require.Len(t, newRules, 2)
+
+	byName := map[string]*edit.Rule{}
+	for _, r := range newRules {
+		byName[r.Name()] = r
+	}
+	require.Contains(t, byName, "foo_benchmark")
+	assert.Equal(t, "go_benchmark", byName["foo_benchmark"].Rule.Kind())
+	assert.Equal(t, kinds.Benchmark, byName["foo_benchmark"].Kind.Type)
+	assert.Equal(t, []string{"bench_test.go"}, byName["foo_benchmark"].AttrStrings("srcs"))
+	assert.Equal(t, []string{"foo_test.go"}, byName["foo_test"].AttrStrings("srcs"))
+}
+
+func TestGeneratedCode(t *testing.T) {
+	files := map[string]*GoFile{
+		"foo.go": {
+			Name:     "foo",
+			FileName: "foo.go",
+		},
+		"foo.pb.go": {
+			Name:      "foo",
+			FileName:  "foo.pb.go",
+			Imports:   []string{"google.golang.org/protobuf/proto"},
+			Generated: true,
+			Generator: "protoc-gen-go",
+		},
+		"mock_foo.go": {
+			Name:      "foo",
+			FileName:  "mock_foo.go",
+			Generated: true,
+			Generator: "MockGen",
+		},
+	}
+	conf := &config.Config{
+		PleasePath: "plz",
+		GeneratedCode: map[string]*config.GeneratedCode{
+			"protoc-gen-go": {Deps: []string{"//third_party/go:protobuf", "//foo:foo_proto"}},
+			"mockgen":       {Exclude: true},
+		},
+	}
+
+	t.Run("excluded generated files aren't allocated", func(t *testing.T) {
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(conf, "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 1)
+		assert.ElementsMatch(t, []string{"foo.go", "foo.pb.go"}, newRules[0].AttrStrings("srcs"))
+	})
+
+	t.Run("generated files use the configured deps", func(t *testing.T) {
+		foo := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], "foo")
+		foo.SetAttr("srcs", edit.NewStringList([]string{"foo.go", "foo.pb.go"}))
+
+		u := newUpdater(new(please.Config), options.TestOptions)
+		require.NoError(t, u.updateRuleDeps(conf, foo, []*edit.Rule{foo}, files))
+		assert.ElementsMatch(t, []string{"//third_party/go:protobuf", ":foo_proto"}, foo.AttrStrings("deps"))
+	})
+}
+
+func TestAllocateMainPackageSources(t *testing.T) {
+	mustParse := func(line string) constraint.Expr {
+		expr, err := constraint.Parse(line)
+		require.NoError(t, err)
+		return expr
+	}
+	files := map[string]*GoFile{
+		"main.go":      {Name: "main", FileName: "main.go"},
+		"flags.go":     {Name: "main", FileName: "flags.go"},
+		"main_test.go": {Name: "main", FileName: "main_test.go"},
+		"gen.go":       {Name: "main", FileName: "gen.go", Constraint: mustParse("//go:build ignore")},
+		"tools.go":     {Name: "foo", FileName: "tools.go", Constraint: mustParse("//go:build tools && linux")},
+		"foo_linux.go": {Name: "foo", FileName: "foo_linux.go", Constraint: mustParse("//go:build linux && !ignore")},
+	}
+
+	conf := &config.Config{PleasePath: "plz"}
+	u := newUpdater(new(please.Config), options.TestOptions)
+	newRules, err := u.allocateSources(conf, "foo", files, nil)
+	require.NoError(t, err)
+	require.Len(t, newRules, 3)
+
+	byName := map[string]*edit.Rule{}
+	for _, r := range newRules {
+		byName[r.Name()] = r
+	}
+	require.Contains(t, byName, "main")
+	assert.Equal(t, "go_binary", byName["main"].Rule.Kind())
+	assert.ElementsMatch(t, []string{"main.go", "flags.go"}, byName["main"].AttrStrings("srcs"))
+
+	require.Contains(t, byName, "foo_test")
+	assert.Equal(t, "go_test", byName["foo_test"].Rule.Kind())
+	assert.Equal(t, []string{"main_test.go"}, byName["foo_test"].AttrStrings("srcs"))
+
+	require.Contains(t, byName, "foo")
+	assert.Equal(t, "go_library", byName["foo"].Rule.Kind())
+	assert.Equal(t, []string{"foo_linux.go"}, byName["foo"].AttrStrings("srcs"))
+
+	// The tests can't depend on the binary
+	require.NoError(t, u.updateRuleDeps(conf, byName["foo_test"], newRules, files))
+	assert.Empty(t, byName["foo_test"].AttrStrings("deps"))
+}
+
+func TestIgnoredSourcesInExistingRule(t *testing.T) {
+	constraint, err := constraint.Parse("//go:build ignore")
+	require.NoError(t, err)
+	files := map[string]*GoFile{
+		"gen.go": {Name: "main", FileName: "gen.go", Imports: []string{"github.com/example/module"}, Constraint: constraint},
+	}
+
+	// Programs run by go:generate are often built by a hand written rule, which still needs their deps
+	conf := &config.Config{PleasePath: "plz", ThirdPartyDir: "third_party/go"}
+	u := newUpdater(new(please.Config), options.TestOptions)
+	u.modules = []string{"github.com/example/module"}
+	rule := edit.NewRule(edit.NewRuleExpr("go_binary", "gen"), kinds.DefaultKinds["go_binary"], "foo")
+	rule.AddSrc("gen.go")
+
+	require.NoError(t, u.updateRuleDeps(conf, rule, []*edit.Rule{rule}, files))
+	assert.Equal(t, []string{"///third_party/go/github.com_example_module//:module"}, rule.AttrStrings("deps"))
+}
+
+func TestWasmSourcesInExistingRule(t *testing.T) {
+	files := map[string]*GoFile{
+		"dom.go": {Name: "dom", FileName: "dom.go", Imports: []string{"syscall/js", "github.com/example/module"}},
+	}
+
+	// Without a wasm kind, these files are only left out of new rules. Rules that already have them keep their deps.
+	conf := &config.Config{PleasePath: "plz", ThirdPartyDir: "third_party/go"}
+	u := newUpdater(new(please.Config), options.TestOptions)
+	u.modules = []string{"github.com/example/module"}
+	rule := edit.NewRule(edit.NewRuleExpr("go_library", "dom"), kinds.DefaultKinds["go_library"], "dom")
+	rule.AddSrc("dom.go")
+
+	require.NoError(t, u.updateRuleDeps(conf, rule, []*edit.Rule{rule}, files))
+	assert.Equal(t, []string{"///third_party/go/github.com_example_module//:module"}, rule.AttrStrings("deps"))
+}
+
+func TestAllocateWasmSources(t *testing.T) {
+	mustParse := func(line string) constraint.Expr {
+		expr, err := constraint.Parse(line)
+		require.NoError(t, err)
+		return expr
+	}
+	files := map[string]*GoFile{
+		"foo.go":         {Name: "foo", FileName: "foo.go", Imports: []string{"github.com/example/module"}},
+		"foo_js.go":      {Name: "foo", FileName: "foo_js.go", Constraint: fileNameConstraint("foo_js.go")},
+		"dom.go":         {Name: "foo", FileName: "dom.go", Imports: []string{"syscall/js"}},
+		"browser.go":     {Name: "foo", FileName: "browser.go", Constraint: mustParse("//go:build js && wasm")},
+		"host.go":        {Name: "foo", FileName: "host.go", Constraint: mustParse("//go:build !js")},
+		"foo_js_test.go": {Name: "foo", FileName: "foo_js_test.go", Constraint: fileNameConstraint("foo_js_test.go")},
+	}
+
+	t.Run("excluded by default", func(t *testing.T) {
+		conf := &config.Config{PleasePath: "plz"}
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(conf, "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 1)
+		assert.ElementsMatch(t, []string{"foo.go", "host.go"}, newRules[0].AttrStrings("srcs"))
+	})
+
+	t.Run("allocated to the wasm kind", func(t *testing.T) {
+		conf := &config.Config{PleasePath: "plz", WasmKind: "go_wasm_library"}
+		u := newUpdater(new(please.Config), options.TestOptions)
+		newRules, err := u.allocateSources(conf, "foo", files, nil)
+		require.NoError(t, err)
+		require.Len(t, newRules, 2)
+
+		byName := map[string]*edit.Rule{}
+		for _, r := range newRules {
+			byName[r.Name()] = r
+		}
+		require.Contains(t, byName, "foo_wasm")
+		assert.Equal(t, "go_wasm_library", byName["foo_wasm"].Rule.Kind())
+		assert.Equal(t, kinds.Wasm, byName["foo_wasm"].Kind.Type)
+		assert.ElementsMatch(t, []string{"foo_js.go", "dom.go", "browser.go"}, byName["foo_wasm"].AttrStrings("srcs"))
+		assert.ElementsMatch(t, []string{"foo.go", "host.go"}, byName["foo"].AttrStrings("srcs"))
+
+		// The library doesn't get the deps of the wasm files
+		byName["foo"].AddSrc("dom.go")
+		require.NoError(t, u.updateRuleDeps(conf, byName["foo"], newRules, map[string]*GoFile{"dom.go": files["dom.go"]}))
+		assert.Empty(t, byName["foo"].AttrStrings("deps"))
+	})
+}
+
+func TestSplitBuildTagSets(t *testing.T) {
+	mustParse := func(line string) constraint.Expr {
+		expr, err := constraint.Parse(line)
+		require.NoError(t, err)
+		return expr
+	}
+	files := map[string]*GoFile{
+		"foo.go":      {Name: "foo", FileName: "foo.go", Imports: []string{"github.com/example/common"}},
+		"real.go":     {Name: "foo", FileName: "real.go", Imports: []string{"github.com/example/real"}, Constraint: mustParse("//go:build real")},
+		"stub.go":     {Name: "foo", FileName: "stub.go", Constraint: mustParse("//go:build !real")},
+		"foo_test.go": {Name: "foo", FileName: "foo_test.go"},
+	}
+
+	split := true
+	conf := &config.Config{
+		PleasePath:        "plz",
+		ThirdPartyDir:     "third_party/go",
+		SplitBuildTagSets: &split,
+		BuildTagSets: map[string]*config.BuildTagSet{
+			"real": {Tags: []string{"real"}, Condition: "//build:real"},
+			"stub": {Tags: []string{}},
+		},
+	}
+	plzConf := new(please.Config)
+	plzConf.Parse.BuildFileName = []string{"BUILD"}
+	u := newUpdater(plzConf, options.TestOptions)
+	u.modules = []string{"github.com/example/common", "github.com/example/real"}
+	newRules, err := u.allocateSources(conf, "foo", files, nil)
+	require.NoError(t, err)
+	require.Len(t, newRules, 3)
+
+	byName := map[string]*edit.Rule{}
+	for _, r := range newRules {
+		byName[r.Name()] = r
+	}
+	require.Contains(t, byName, "foo_real")
+	require.Contains(t, byName, "foo_stub")
+	assert.ElementsMatch(t, []string{"foo.go", "real.go"}, byName["foo_real"].AttrStrings("srcs"))
+	assert.ElementsMatch(t, []string{"foo.go", "stub.go"}, byName["foo_stub"].AttrStrings("srcs"))
+
+	for _, rule := range newRules {
+		require.NoError(t, u.updateRuleDeps(conf, rule, newRules, files))
+	}
+
+	// Each library's deps are unconditional, as it's only built with its own tag set
+	assert.ElementsMatch(t, []string{
+		"///third_party/go/github.com_example_common//:common",
+		"///third_party/go/github.com_example_real//:real",
+	}, byName["foo_real"].AttrStrings("deps"))
+	assert.Equal(t, []string{"///third_party/go/github.com_example_common//:common"}, byName["foo_stub"].AttrStrings("deps"))
+
+	// The tests depend on the library for each tag set under its condition, with the library for the tag set without
+	// a condition as the default, so only one of them is ever linked
+	call, ok := byName["foo_test"].Attr("deps").(*build.CallExpr)
+	require.True(t, ok)
+	assert.Equal(t, map[string][]string{
+		"//build:real":         {":foo_real"},
+		"//conditions:default": {":foo_stub"},
+	}, selectValues(call))
+
+}
+
+func TestSplitBuildTagSetsImporter(t *testing.T) {
+	repoFiles := map[string]string{
+		"tagged/puku.json": `{
+  "splitBuildTagSets": true,
+  "buildTagSets": {
+    "real": {"tags": ["real"], "condition": "//build:real"},
+    "stub": {"tags": []}
+  }
+}`,
+		"tagged/BUILD": `go_library(
+    name = "tagged_real",
+    srcs = ["real.go"],
+)
+
+go_library(
+    name = "tagged_stub",
+    srcs = ["stub.go"],
+)
+`,
+	}
+
+	repotest.Run(t, repoFiles, func(t *testing.T) {
+		u := newUpdater(repotest.PleaseConfig(), options.TestOptions)
+		// Imports of the package resolve to whichever library comes first
+		u.resolvedImports["github.com/example/tagged"] = "//tagged:tagged_real"
+
+		bar := edit.NewRule(edit.NewRuleExpr("go_library", "bar"), kinds.DefaultKinds["go_library"], "bar")
+		bar.AddSrc("bar.go")
+		files := map[string]*GoFile{
+			"bar.go": {Name: "bar", FileName: "bar.go", Imports: []string{"github.com/example/tagged"}},
+		}
+		require.NoError(t, u.updateRuleDeps(&config.Config{}, bar, []*edit.Rule{bar}, files))
+
+		call, ok := bar.Attr("deps").(*build.CallExpr)
+		require.True(t, ok)
+		assert.Equal(t, map[string][]string{
+			"//build:real":         {"//tagged:tagged_real"},
+			"//conditions:default": {"//tagged:tagged_stub"},
+		}, selectValues(call))
+	})
+}
+
+func selectValues(call *build.CallExpr) map[string][]string {
+	ret := map[string][]string{}
+	for _, kv := range call.List[0].(*build.DictExpr).List {
+		ret[kv.Key.(*build.StringExpr).Value] = build.Strings(kv.Value)
+	}
+	return ret
+}
+
+func TestSplitLibKind(t *testing.T) {
+	conf := &config.Config{LibKinds: map[string]*config.KindConfig{"my_go_library": {}}}
+	existing := edit.NewRule(edit.NewRuleExpr("my_go_library", "foo"), conf.GetKind("my_go_library"), "foo")
+
+	assert.Equal(t, "my_go_library", splitLibKind(conf, &GoFile{}, []*edit.Rule{existing}).Name)
+	assert.Equal(t, "go_library", splitLibKind(conf, &GoFile{}, nil).Name)
+}
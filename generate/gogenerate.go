@@ -0,0 +1,242 @@
+package generate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+)
+
+// outputFlags are the flags commonly used by code generators to set the file they write to
+var outputFlags = []string{"output", "o", "destination", "out"}
+
+// generateDirective is a parsed //go:generate directive
+type generateDirective struct {
+	// command is the tool being run, or the package passed to `go run`
+	command string
+	args    []string
+}
+
+// parseGenerateDirective splits a //go:generate directive into the command and its arguments. Words are separated by
+// any whitespace, and double-quoted arguments are unquoted as Go strings, the same way the go tool does.
+func parseGenerateDirective(directive string) (*generateDirective, error) {
+	var words []string
+	for rest := strings.TrimSpace(directive); rest != ""; rest = strings.TrimLeftFunc(rest, unicode.IsSpace) {
+		if rest[0] != '"' {
+			end := strings.IndexFunc(rest, unicode.IsSpace)
+			if end < 0 {
+				end = len(rest)
+			}
+			words = append(words, rest[:end])
+			rest = rest[end:]
+			continue
+		}
+		end := 1
+		for ; end < len(rest); end++ {
+			if rest[end] == '\\' {
+				end++
+				continue
+			}
+			if rest[end] == '"' {
+				break
+			}
+		}
+		if end >= len(rest) {
+			return nil, fmt.Errorf("unterminated quoted string in %q", directive)
+		}
+		word, err := strconv.Unquote(rest[:end+1])
+		if err != nil {
+			return nil, err
+		}
+		words = append(words, word)
+		rest = rest[end+1:]
+	}
+
+	if len(words) == 0 {
+		return nil, fmt.Errorf("empty go:generate directive")
+	}
+	if words[0] == "go" && len(words) > 2 && words[1] == "run" {
+		return &generateDirective{command: words[2], args: words[3:]}, nil
+	}
+	return &generateDirective{command: words[0], args: words[1:]}, nil
+}
+
+// flag returns the value of the flag with the given name, supporting both -flag=value and -flag value forms
+func (d *generateDirective) flag(name string) string {
+	for i, arg := range d.args {
+		arg = strings.TrimLeft(arg, "-")
+		if len(arg) == len(d.args[i]) {
+			continue // not a flag
+		}
+		if value, ok := strings.CutPrefix(arg, name+"="); ok {
+			return value
+		}
+		if arg == name && i+1 < len(d.args) {
+			return d.args[i+1]
+		}
+	}
+	return ""
+}
+
+// output returns the file generated by the directive, or an empty string if we can't tell
+func (d *generateDirective) output() string {
+	for _, f := range outputFlags {
+		if out := d.flag(f); out != "" {
+			return out
+		}
+	}
+	if filepath.Base(d.tool()) == "stringer" {
+		if types := d.flag("type"); types != "" {
+			typeName, _, _ := strings.Cut(types, ",")
+			return strings.ToLower(typeName) + "_string.go"
+		}
+	}
+	return ""
+}
+
+// tool returns the command without any version suffix from `go run pkg@version`
+func (d *generateDirective) tool() string {
+	tool, _, _ := strings.Cut(d.command, "@")
+	return tool
+}
+
+// scaffoldGenrules creates genrules for any //go:generate directives that run a tool configured in goGenerateTools. The
+// outputs are added to the srcs of the rule that the file containing the directive belongs to. Directives that
+// generate files that are already checked in are skipped, as are ones where we can't tell what file they generate.
+func (u *updater) scaffoldGenrules(conf *config.Config, file *build.File, pkgDir string, rules []*edit.Rule, sources map[string]*GoFile) error {
+	srcs := make([]string, 0, len(sources))
+	for src := range sources {
+		srcs = append(srcs, src)
+	}
+	sort.Strings(srcs)
+
+	for _, src := range srcs {
+		f := sources[src]
+		for _, directive := range f.Generate {
+			d, err := parseGenerateDirective(directive)
+			if err != nil {
+				log.Warningf("failed to parse go:generate directive in %v: %v", filepath.Join(pkgDir, src), err)
+				continue
+			}
+
+			tool := conf.GetGoGenerateTool(d.command)
+			if tool == "" {
+				tool = conf.GetGoGenerateTool(d.tool())
+			}
+			if tool == "" {
+				continue
+			}
+
+			out := d.output()
+			if out == "" || strings.Contains(out, "/") {
+				log.Warningf("can't determine the file generated in the package by %q in %v", directive, filepath.Join(pkgDir, src))
+				continue
+			}
+			if _, err := os.Lstat(filepath.Join(pkgDir, out)); err == nil {
+				continue // The generated file is checked in
+			}
+
+			rule, err := u.ruleForSrc(rules, src)
+			if err != nil {
+				return err
+			}
+			if rule == nil {
+				continue
+			}
+
+			name := strings.TrimSuffix(out, ".go")
+			if edit.FindTargetByName(file, name) == nil {
+				file.Stmt = append(file.Stmt, newGenerateRule(name, src, out, f.Name, tool, d, generateSrcs(src, sources, d)).Call)
+			}
+
+			label := ":" + name
+			if attr := rule.Attr(rule.SrcsAttr()); attr != nil {
+				if _, ok := attr.(*build.ListExpr); !ok {
+					log.Warningf("can't add %v to the srcs of %v as they aren't a list", label, rule.Label())
+					continue
+				}
+			}
+			if !contains(rule.AttrStrings(rule.SrcsAttr()), label) {
+				rule.AddSrc(label)
+			}
+		}
+	}
+	return nil
+}
+
+// generateSrcs returns the sources the tool reads for a directive in src. This is the file passed with -source, as for
+// mockgen, if it's in the package, or otherwise the non-test Go sources of the package, which tools like stringer load,
+// along with src itself.
+func generateSrcs(src string, sources map[string]*GoFile, d *generateDirective) []string {
+	if source := strings.ReplaceAll(d.flag("source"), "$GOFILE", src); source != "" && !strings.Contains(source, "/") {
+		return []string{source}
+	}
+
+	srcs := []string{src}
+	for name, f := range sources {
+		if name != src && !f.IsTest() && f.Name == sources[src].Name {
+			srcs = append(srcs, name)
+		}
+	}
+	sort.Strings(srcs)
+	return srcs
+}
+
+// newGenerateRule creates a genrule that runs the tool on srcs in the same way `go generate` would
+func newGenerateRule(name, src, out, pkgName, tool string, d *generateDirective, srcs []string) *build.Rule {
+	args := make([]string, 0, len(d.args))
+	for _, arg := range d.args {
+		args = append(args, shellQuote(arg))
+	}
+
+	rule := edit.NewRuleExpr("genrule", name)
+	rule.SetAttr("srcs", edit.NewStringList(srcs))
+	rule.SetAttr("outs", edit.NewStringList([]string{out}))
+	rule.SetAttr("cmd", edit.NewStringExpr(fmt.Sprintf("cd $PKG_DIR && export GOFILE=%s GOPACKAGE=%s && $TOOL %s", src, pkgName, strings.Join(args, " "))))
+	rule.SetAttr("tools", edit.NewStringList([]string{tool}))
+	return rule
+}
+
+// ruleForSrc returns the rule that has the given source in its srcs, if any
+func (u *updater) ruleForSrc(rules []*edit.Rule, src string) (*edit.Rule, error) {
+	for _, rule := range rules {
+		for _, attr := range rule.SrcsAttrs() {
+			ruleSrcs, err := u.eval.EvalGlobs(rule.Dir, rule.Rule, attr)
+			if err != nil {
+				return nil, err
+			}
+			if contains(ruleSrcs, src) {
+				return rule, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func contains(ss []string, s string) bool {
+	for _, v := range ss {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// shellQuote quotes the argument for the shell if it contains anything other than simple characters. Environment
+// variables like $GOFILE are left unquoted so they still get expanded.
+func shellQuote(arg string) string {
+	if arg != "" && strings.IndexFunc(arg, func(r rune) bool {
+		return !strings.ContainsRune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_=./,:$@+", r)
+	}) < 0 {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'"'"'`) + "'"
+}
@@ -0,0 +1,144 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestParseGenerateDirective(t *testing.T) {
+	testCases := []struct {
+		directive string
+		command   string
+		args      []string
+		output    string
+	}{
+		{
+			directive: "stringer -type=Pill,Colour",
+			command:   "stringer",
+			args:      []string{"-type=Pill,Colour"},
+			output:    "pill_string.go",
+		},
+		{
+			directive: "stringer -type Pill -output pills.go",
+			command:   "stringer",
+			args:      []string{"-type", "Pill", "-output", "pills.go"},
+			output:    "pills.go",
+		},
+		{
+			directive: "go run go.uber.org/mock/mockgen@v0.4.0 -source=$GOFILE -destination=mock_foo.go",
+			command:   "go.uber.org/mock/mockgen@v0.4.0",
+			args:      []string{"-source=$GOFILE", "-destination=mock_foo.go"},
+			output:    "mock_foo.go",
+		},
+		{
+			directive: `mytool -header "// Code generated. DO NOT EDIT." --out=gen.go`,
+			command:   "mytool",
+			args:      []string{"-header", "// Code generated. DO NOT EDIT.", "--out=gen.go"},
+			output:    "gen.go",
+		},
+		{
+			directive: "stringer\t-type=Pill  -output\tpills.go",
+			command:   "stringer",
+			args:      []string{"-type=Pill", "-output", "pills.go"},
+			output:    "pills.go",
+		},
+		{
+			directive: "protoc --go_out=. foo.proto",
+			command:   "protoc",
+			args:      []string{"--go_out=.", "foo.proto"},
+			output:    "",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.directive, func(t *testing.T) {
+			d, err := parseGenerateDirective(tc.directive)
+			require.NoError(t, err)
+			assert.Equal(t, tc.command, d.command)
+			assert.Equal(t, tc.args, d.args)
+			assert.Equal(t, tc.output, d.output())
+		})
+	}
+
+	_, err := parseGenerateDirective(`mytool "unterminated`)
+	assert.Error(t, err)
+}
+
+func TestScaffoldGenrules(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "checked_in.go"), nil, 0644))
+
+	sources := map[string]*GoFile{
+		"pill.go": {
+			Name:     "painkiller",
+			FileName: "pill.go",
+			Generate: []string{"stringer -type=Pill", "protoc --go_out=. foo.proto"},
+		},
+		"foo.go": {
+			Name:     "painkiller",
+			FileName: "foo.go",
+			Generate: []string{"stringer -type=Foo -output checked_in.go", "unknown -output unknown.go"},
+		},
+		"foo_test.go": {
+			Name:     "painkiller",
+			FileName: "foo_test.go",
+		},
+		"store.go": {
+			Name:     "painkiller",
+			FileName: "store.go",
+			Generate: []string{"mockgen -source=$GOFILE -destination=mock_store.go"},
+		},
+	}
+
+	conf := &config.Config{
+		GoGenerateTools: map[string]string{
+			"stringer": "//third_party/go:stringer",
+			"protoc":   "//third_party/binary:protoc",
+			"mockgen":  "//third_party/go:mockgen",
+		},
+	}
+
+	rule := edit.NewRule(edit.NewRuleExpr("go_library", "painkiller"), kinds.DefaultKinds["go_library"], dir)
+	rule.AddSrc("foo.go")
+	rule.AddSrc("pill.go")
+	rule.AddSrc("store.go")
+
+	file, err := build.ParseBuild(filepath.Join(dir, "BUILD"), nil)
+	require.NoError(t, err)
+	file.Stmt = append(file.Stmt, rule.Call)
+
+	u := newUpdater(new(please.Config), options.TestOptions)
+	require.NoError(t, u.scaffoldGenrules(conf, file, dir, []*edit.Rule{rule}, sources))
+
+	assert.Equal(t, []string{"foo.go", "pill.go", "store.go", ":pill_string", ":mock_store"}, rule.AttrStrings("srcs"))
+
+	genrule := edit.FindTargetByName(file, "pill_string")
+	require.NotNil(t, genrule)
+	assert.Equal(t, "genrule", genrule.Kind())
+	assert.Equal(t, []string{"foo.go", "pill.go", "store.go"}, genrule.AttrStrings("srcs"), "stringer loads the whole package")
+	assert.Equal(t, []string{"pill_string.go"}, genrule.AttrStrings("outs"))
+	assert.Equal(t, []string{"//third_party/go:stringer"}, genrule.AttrStrings("tools"))
+	assert.Equal(t, "cd $PKG_DIR && export GOFILE=pill.go GOPACKAGE=painkiller && $TOOL -type=Pill", genrule.AttrString("cmd"))
+
+	mockgen := edit.FindTargetByName(file, "mock_store")
+	require.NotNil(t, mockgen)
+	assert.Equal(t, []string{"store.go"}, mockgen.AttrStrings("srcs"), "mockgen only reads the -source file")
+
+	assert.Len(t, file.Rules("genrule"), 2)
+
+	// Running again shouldn't add anything new
+	require.NoError(t, u.scaffoldGenrules(conf, file, dir, []*edit.Rule{rule}, sources))
+	assert.Equal(t, []string{"foo.go", "pill.go", "store.go", ":pill_string", ":mock_store"}, rule.AttrStrings("srcs"))
+	assert.Len(t, file.Rules("genrule"), 2)
+}
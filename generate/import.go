@@ -1,12 +1,19 @@
 package generate
 
 import (
+	"bytes"
+	"go/ast"
+	"go/build/constraint"
 	"go/parser"
 	"go/token"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/please-build/puku/config"
 	"github.com/please-build/puku/kinds"
 )
 
@@ -16,6 +23,23 @@ type GoFile struct {
 	Name, FileName string
 	// Imports are the imports of this file
 	Imports []string
+	// Constraint is the build constraint from the //go:build (or // +build) lines of this file, combined with any GOOS
+	// and GOARCH implied by its file name
+	Constraint constraint.Expr
+	// CgoFlags are the flags set by unconditional #cgo directives in the preamble of import "C", keyed by the
+	// variable they set e.g. CFLAGS, LDFLAGS, or pkg-config. There's a list of arguments for each directive, as flags
+	// such as -framework take the argument after them.
+	CgoFlags map[string][][]string
+	// Generate are the commands from any //go:generate directives in this file
+	Generate []string
+	// TestFuncs are the names of the test, benchmark, fuzz, and example functions in this file
+	TestFuncs []string
+	// Generated is true when the file has a "Code generated ... DO NOT EDIT." header
+	Generated bool
+	// Generator is the tool that generated the file according to its header, if we can tell
+	Generator string
+	// FileReads are the paths passed as string literals to os.ReadFile, os.Open, and os.OpenFile in test files
+	FileReads []string
 }
 
 // ImportDir does _some_ of what the go/build ImportDir does but is more permissive.
@@ -46,7 +70,21 @@ func ImportDir(dir string) (map[string]*GoFile, error) {
 }
 
 func importFile(dir, src string) (*GoFile, error) {
-	f, err := parser.ParseFile(token.NewFileSet(), filepath.Join(dir, src), nil, parser.ImportsOnly|parser.ParseComments)
+	path := filepath.Join(dir, src)
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// We need the function declarations of tests to tell what sort of tests they are
+	mode := parser.ImportsOnly | parser.ParseComments
+	if strings.HasSuffix(src, "_test.go") {
+		mode = parser.ParseComments | parser.SkipObjectResolution
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), path, bs, mode)
+	if err != nil && strings.HasSuffix(src, "_test.go") {
+		// Tests with syntax errors past their imports can still have their deps updated, just not their kind of test
+		f, err = parser.ParseFile(token.NewFileSet(), path, bs, parser.ImportsOnly|parser.ParseComments)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -58,12 +96,256 @@ func importFile(dir, src string) (*GoFile, error) {
 	}
 
 	return &GoFile{
-		Name:     f.Name.Name,
-		FileName: src,
-		Imports:  imports,
+		Name:       f.Name.Name,
+		FileName:   src,
+		Imports:    imports,
+		Constraint: andConstraints(buildConstraint(f), fileNameConstraint(src)),
+		CgoFlags:   cgoFlags(f),
+		Generate:   generateDirectives(bs),
+		TestFuncs:  testFuncs(f),
+		Generated:  ast.IsGenerated(f),
+		Generator:  generator(f),
+		FileReads:  fileReads(f),
 	}, nil
 }
 
+// generator returns the name of the tool that generated the file from its "Code generated by <tool> ... DO NOT EDIT."
+// header, e.g. protoc-gen-go, MockGen, or stringer
+func generator(f *ast.File) string {
+	if !ast.IsGenerated(f) {
+		return ""
+	}
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, comment := range group.List {
+			text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
+			rest, ok := strings.CutPrefix(text, "Code generated by ")
+			if !ok || !strings.HasSuffix(rest, "DO NOT EDIT.") {
+				continue
+			}
+			tool, _, _ := strings.Cut(strings.TrimLeft(rest, `"`), " ")
+			return strings.TrimRight(tool, `.;,"`)
+		}
+	}
+	return ""
+}
+
+// testFuncs returns the names of the top level test, benchmark, fuzz, and example functions in the file
+func testFuncs(f *ast.File) []string {
+	var funcs []string
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Recv != nil {
+			continue
+		}
+		for _, prefix := range []string{"Test", "Benchmark", "Fuzz", "Example"} {
+			if isTestFunc(fn.Name.Name, prefix) {
+				funcs = append(funcs, fn.Name.Name)
+				break
+			}
+		}
+	}
+	return funcs
+}
+
+// isTestFunc returns whether name is a test function with the given prefix, using the same rule as go test, i.e. the
+// prefix isn't followed by a lowercase letter, so helpers like Testdata aren't tests
+func isTestFunc(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	}
+	if len(name) == len(prefix) {
+		return true
+	}
+	r, _ := utf8.DecodeRuneInString(name[len(prefix):])
+	return !unicode.IsLower(r)
+}
+
+// fileReadFuncs are the functions in the os package whose first argument is a file to read
+var fileReadFuncs = map[string]bool{"ReadFile": true, "Open": true, "OpenFile": true}
+
+// fileReads returns the paths of the files that are read with string literals, e.g. os.ReadFile("foo.json"). Paths
+// built with filepath.Join from string literals are included too. Only test files are parsed fully, so this is always
+// empty for other files.
+func fileReads(f *ast.File) []string {
+	osName, filepathName := importName(f, "os"), importName(f, "path/filepath")
+	if osName == "" {
+		return nil
+	}
+
+	var paths []string
+	ast.Inspect(f, func(n ast.Node) bool {
+		call, ok := n.(*ast.CallExpr)
+		if !ok || len(call.Args) == 0 {
+			return true
+		}
+		if fn, ok := selector(call.Fun, osName); !ok || !fileReadFuncs[fn] {
+			return true
+		}
+		if path := literalPath(call.Args[0], filepathName); path != "" {
+			paths = append(paths, path)
+		}
+		return true
+	})
+	return paths
+}
+
+// literalPath returns the path from a string literal, or a filepath.Join call of string literals
+func literalPath(expr ast.Expr, filepathName string) string {
+	switch e := expr.(type) {
+	case *ast.BasicLit:
+		if e.Kind != token.STRING {
+			return ""
+		}
+		path, err := strconv.Unquote(e.Value)
+		if err != nil {
+			return ""
+		}
+		return path
+	case *ast.CallExpr:
+		if fn, ok := selector(e.Fun, filepathName); !ok || fn != "Join" {
+			return ""
+		}
+		parts := make([]string, 0, len(e.Args))
+		for _, arg := range e.Args {
+			part := literalPath(arg, "")
+			if part == "" {
+				return ""
+			}
+			parts = append(parts, part)
+		}
+		return filepath.Join(parts...)
+	}
+	return ""
+}
+
+// selector returns the name selected from the package if the expression is e.g. os.ReadFile
+func selector(expr ast.Expr, pkgName string) (string, bool) {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || pkgName == "" {
+		return "", false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok || x.Name != pkgName {
+		return "", false
+	}
+	return sel.Sel.Name, true
+}
+
+// importName returns the name the package is imported as in the file, or an empty string if it's not imported
+func importName(f *ast.File, importPath string) string {
+	for _, i := range f.Imports {
+		if strings.Trim(i.Path.Value, `"`) != importPath {
+			continue
+		}
+		if i.Name != nil {
+			return i.Name.Name
+		}
+		return filepath.Base(importPath)
+	}
+	return ""
+}
+
+// generateDirectives returns the arguments of any //go:generate directives in the file. Like the go tool, these are
+// only recognised at the start of a line.
+func generateDirectives(bs []byte) []string {
+	var directives []string
+	for _, line := range bytes.Split(bs, []byte("\n")) {
+		if !bytes.HasPrefix(line, []byte("//go:generate ")) {
+			continue
+		}
+		directives = append(directives, strings.TrimSpace(string(line[len("//go:generate "):])))
+	}
+	return directives
+}
+
+// cgoFlags parses the #cgo directives from the preamble of the import "C" declaration. Directives that are conditional
+// on the build configuration (e.g. `#cgo linux LDFLAGS: -lm`) are ignored.
+func cgoFlags(f *ast.File) map[string][][]string {
+	var flags map[string][][]string
+	for _, decl := range f.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for _, spec := range gen.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			if strings.Trim(importSpec.Path.Value, `"`) != "C" {
+				continue
+			}
+			doc := importSpec.Doc
+			if doc == nil && len(gen.Specs) == 1 {
+				doc = gen.Doc
+			}
+			if doc == nil {
+				continue
+			}
+			for _, line := range strings.Split(doc.Text(), "\n") {
+				line = strings.TrimSpace(line)
+				if !strings.HasPrefix(line, "#cgo ") && !strings.HasPrefix(line, "#cgo\t") {
+					continue
+				}
+				directive, args, ok := strings.Cut(strings.TrimSpace(line[4:]), ":")
+				if !ok {
+					continue
+				}
+				fields := strings.Fields(directive)
+				if len(fields) != 1 {
+					continue
+				}
+				if len(strings.Fields(args)) == 0 {
+					continue
+				}
+				if flags == nil {
+					flags = map[string][][]string{}
+				}
+				flags[fields[0]] = append(flags[fields[0]], strings.Fields(args))
+			}
+		}
+	}
+	return flags
+}
+
+// buildConstraint returns the build constraint for the file from the comments above the package clause
+func buildConstraint(f *ast.File) constraint.Expr {
+	var lines []string
+	for _, group := range f.Comments {
+		if group.Pos() >= f.Package {
+			break
+		}
+		for _, c := range group.List {
+			lines = append(lines, c.Text)
+		}
+	}
+	return parseBuildConstraint(lines)
+}
+
+// parseBuildConstraint returns the build constraint from the given comment lines. A //go:build line takes precedence
+// over any // +build lines, which are combined in the same way the go tool does.
+func parseBuildConstraint(lines []string) constraint.Expr {
+	var plusBuild constraint.Expr
+	for _, line := range lines {
+		if !constraint.IsGoBuild(line) && !constraint.IsPlusBuild(line) {
+			continue
+		}
+		expr, err := constraint.Parse(line)
+		if err != nil {
+			continue
+		}
+		if constraint.IsGoBuild(line) {
+			return expr
+		}
+		if plusBuild == nil {
+			plusBuild = expr
+		} else {
+			plusBuild = &constraint.AndExpr{X: plusBuild, Y: expr}
+		}
+	}
+	return plusBuild
+}
+
 // IsExternal returns whether the test is external
 func (f *GoFile) IsExternal(pkgName string) bool {
 	return f.Name == filepath.Base(pkgName)+"_test" && f.IsTest()
@@ -73,14 +355,79 @@ func (f *GoFile) IsTest() bool {
 	return strings.HasSuffix(f.FileName, "_test.go")
 }
 
+// IsCgo returns whether the file imports "C"
+func (f *GoFile) IsCgo() bool {
+	for _, i := range f.Imports {
+		if i == "C" {
+			return true
+		}
+	}
+	return false
+}
+
+// IsCmd returns whether the file is part of the binary for a main package. Tests in a main package aren't.
 func (f *GoFile) IsCmd() bool {
-	return f.Name == "main"
+	return f.Name == "main" && !f.IsTest()
+}
+
+// generatedCode returns how this file should be handled if it's generated code, or nil if it should be treated like any
+// other source file
+func (f *GoFile) generatedCode(conf *config.Config) *config.GeneratedCode {
+	if !f.Generated {
+		return nil
+	}
+	return conf.GetGeneratedCode(f.Generator)
 }
 
-func (f *GoFile) kindType() kinds.Type {
+// IsFuzz returns whether the file is a test file that only contains fuzz tests
+func (f *GoFile) IsFuzz() bool {
+	if !f.IsTest() {
+		return false
+	}
+	fuzz := false
+	for _, fn := range f.TestFuncs {
+		switch {
+		case strings.HasPrefix(fn, "Fuzz"):
+			fuzz = true
+		case fn == "TestMain":
+		default:
+			return false
+		}
+	}
+	return fuzz
+}
+
+// IsBenchmark returns whether the file is a test file that only contains benchmarks
+func (f *GoFile) IsBenchmark() bool {
+	if !f.IsTest() {
+		return false
+	}
+	benchmark := false
+	for _, fn := range f.TestFuncs {
+		switch {
+		case strings.HasPrefix(fn, "Benchmark"):
+			benchmark = true
+		case fn == "TestMain":
+		default:
+			return false
+		}
+	}
+	return benchmark
+}
+
+func (f *GoFile) kindType(conf *config.Config) kinds.Type {
+	if f.IsFuzz() && conf.GetFuzzKind() != "" {
+		return kinds.Fuzz
+	}
+	if f.IsBenchmark() && conf.GetBenchmarkKind() != "" {
+		return kinds.Benchmark
+	}
 	if f.IsTest() {
 		return kinds.Test
 	}
+	if f.IsWasm() && conf.GetWasmKind() != "" {
+		return kinds.Wasm
+	}
 	if f.IsCmd() {
 		return kinds.Bin
 	}
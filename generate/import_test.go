@@ -1,6 +1,8 @@
 package generate
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -45,3 +47,188 @@ func TestImportDir(t *testing.T) {
 	require.False(t, main.IsTest())
 	require.False(t, main.IsExternal("test_project"))
 }
+
+func TestBuildConstraint(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("go_build.go", "//go:build linux && !cgo\n// +build darwin\n\npackage foo\n")
+	write("plus_build.go", "// +build linux darwin\n// +build amd64\n\npackage foo\n")
+	write("none.go", "// Package foo does things\npackage foo\n")
+	write("foo_windows_test.go", "//go:build !cgo\n\npackage foo\n")
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, "linux && !cgo", files["go_build.go"].Constraint.String())
+	assert.Equal(t, "(linux || darwin) && amd64", files["plus_build.go"].Constraint.String())
+	assert.Nil(t, files["none.go"].Constraint)
+	assert.Equal(t, "!cgo && windows", files["foo_windows_test.go"].Constraint.String())
+}
+
+func TestCgoFlags(t *testing.T) {
+	dir := t.TempDir()
+	src := `package foo
+
+// #cgo CFLAGS: -DPNG_DEBUG=1 -Wall
+// #cgo linux LDFLAGS: -lrt
+// #cgo LDFLAGS: -lpng
+// #cgo pkg-config: libpng
+// #include <png.h>
+import "C"
+
+import "fmt"
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.go"), []byte(src), 0644))
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+
+	foo := files["foo.go"]
+	assert.True(t, foo.IsCgo())
+	assert.Equal(t, map[string][][]string{
+		"CFLAGS":     {{"-DPNG_DEBUG=1", "-Wall"}},
+		"LDFLAGS":    {{"-lpng"}},
+		"pkg-config": {{"libpng"}},
+	}, foo.CgoFlags)
+}
+
+func TestIsFuzz(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("fuzz_test.go", "package foo\n\nimport \"testing\"\n\nfunc TestMain(m *testing.M) {}\n\nfunc FuzzFoo(f *testing.F) {}\n")
+	write("mixed_test.go", "package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {}\n\nfunc FuzzBar(f *testing.F) {}\n")
+	write("fuzz.go", "package foo\n\nfunc FuzzFoo() {}\n")
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"TestMain", "FuzzFoo"}, files["fuzz_test.go"].TestFuncs)
+	assert.True(t, files["fuzz_test.go"].IsFuzz())
+	assert.False(t, files["mixed_test.go"].IsFuzz())
+	assert.False(t, files["fuzz.go"].IsFuzz())
+}
+
+func TestTestFuncs(t *testing.T) {
+	dir := t.TempDir()
+	content := `package foo
+
+import "testing"
+
+func Test(t *testing.T) {}
+
+func TestFoo(t *testing.T) {}
+
+func Test_foo(t *testing.T) {}
+
+func Testdata() string { return "testdata" }
+
+func Examples() []string { return nil }
+
+func ExampleFoo() {}
+
+func Benchmarker() {}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo_test.go"), []byte(content), 0644))
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Test", "TestFoo", "Test_foo", "ExampleFoo"}, files["foo_test.go"].TestFuncs)
+}
+
+func TestTestWithSyntaxError(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo_test.go"), []byte("package foo\n\nimport \"testing\"\n\nfunc TestFoo(t *testing.T) {\n"), 0644))
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"testing"}, files["foo_test.go"].Imports)
+	assert.True(t, files["foo_test.go"].IsTest())
+}
+
+func TestIsBenchmark(t *testing.T) {
+	files := map[string]*GoFile{
+		"bench_test.go": {FileName: "bench_test.go", TestFuncs: []string{"TestMain", "BenchmarkFoo"}},
+		"mixed_test.go": {FileName: "mixed_test.go", TestFuncs: []string{"BenchmarkFoo", "ExampleFoo"}},
+		"main_test.go":  {FileName: "main_test.go", TestFuncs: []string{"TestMain"}},
+	}
+
+	assert.True(t, files["bench_test.go"].IsBenchmark())
+	assert.False(t, files["mixed_test.go"].IsBenchmark())
+	assert.False(t, files["main_test.go"].IsBenchmark())
+	assert.False(t, files["bench_test.go"].IsFuzz())
+}
+
+func TestGenerator(t *testing.T) {
+	testCases := map[string]struct {
+		header    string
+		generated bool
+		generator string
+	}{
+		"protoc-gen-go": {
+			header:    "// Code generated by protoc-gen-go. DO NOT EDIT.\n// versions:\n// \tprotoc-gen-go v1.31.0\n",
+			generated: true,
+			generator: "protoc-gen-go",
+		},
+		"mockgen": {
+			header:    "// Code generated by MockGen. DO NOT EDIT.\n// Source: foo.go\n",
+			generated: true,
+			generator: "MockGen",
+		},
+		"stringer": {
+			header:    "// Code generated by \"stringer -type=Pill\"; DO NOT EDIT.\n",
+			generated: true,
+			generator: "stringer",
+		},
+		"unknown generator": {
+			header:    "// Code generated from foo.proto. DO NOT EDIT.\n",
+			generated: true,
+		},
+		"not generated": {
+			header: "// Package foo does things\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.go"), []byte(tc.header+"\npackage foo\n"), 0644))
+
+			f, err := importFile(dir, "foo.go")
+			require.NoError(t, err)
+			assert.Equal(t, tc.generated, f.Generated)
+			assert.Equal(t, tc.generator, f.Generator)
+		})
+	}
+}
+
+func TestFileReads(t *testing.T) {
+	dir := t.TempDir()
+	src := `package foo
+
+import (
+	"os"
+	fp "path/filepath"
+	"testing"
+)
+
+func TestFoo(t *testing.T) {
+	os.ReadFile("fixture.json")
+	os.Open(fp.Join("fixtures", "bar.txt"))
+	os.OpenFile("../outside.txt", os.O_RDONLY, 0)
+	name := "dynamic.json"
+	os.ReadFile(name)
+	os.WriteFile("out.txt", nil, 0644)
+}
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo_test.go"), []byte(src), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "foo.go"), []byte("package foo\n\nimport \"os\"\n\nvar _, _ = os.ReadFile(\"foo.json\")\n"), 0644))
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fixture.json", "fixtures/bar.txt", "../outside.txt"}, files["foo_test.go"].FileReads)
+	assert.Empty(t, files["foo.go"].FileReads)
+}
@@ -0,0 +1,142 @@
+package generate
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/fs"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+// Class classifies an import by what provides it
+type Class string
+
+const (
+	// Builtin imports don't need a dep, e.g. because they're from the standard library
+	Builtin Class = "builtin"
+	// FirstParty imports are provided by a target in the repo
+	FirstParty Class = "first_party"
+	// ThirdParty imports are provided by a third party target, i.e. one in a subrepo or third party directory
+	ThirdParty Class = "third_party"
+	// Unresolved imports couldn't be resolved to a target
+	Unresolved Class = "unresolved"
+)
+
+// Import is an import of a source file, along with the target it resolves to
+type Import struct {
+	// File is the path of the source file that has the import
+	File     string `json:"file"`
+	Language string `json:"language"`
+	Import   string `json:"import"`
+	Class    Class  `json:"class"`
+	Target   string `json:"target,omitempty"`
+	// Error is why the import couldn't be resolved
+	Error string `json:"error,omitempty"`
+}
+
+// Imports returns the imports of the sources in the given paths, ordered by file, and the targets they resolve to. The
+// sources of the other languages enabled in each path are included after the Go sources. Nothing is written, though
+// resolving an import to a module that isn't in the repo yet looks it up through the module proxy.
+func Imports(plzConf *please.Config, opts options.Options, paths ...string) ([]*Import, error) {
+	u := newUpdater(plzConf, opts)
+	conf, err := config.ReadConfig(".")
+	if err != nil {
+		return nil, err
+	}
+	if err := u.readRepo(conf); err != nil {
+		return nil, err
+	}
+
+	var ret []*Import
+	for _, path := range paths {
+		conf, err := config.ReadConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		imports, err := u.importsIn(conf, path)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, imports...)
+	}
+	return ret, nil
+}
+
+// importsIn returns the imports of the Go sources in the directory, and the sources of the other languages the config
+// enables
+func (u *updater) importsIn(conf *config.Config, dir string) ([]*Import, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var ret []*Import
+	for _, name := range names {
+		for _, i := range files[name].Imports {
+			t, err := u.resolveImport(conf, i)
+			ret = append(ret, newImport(conf, filepath.Join(dir, name), "go", dir, i, t, err))
+		}
+	}
+
+	for _, l := range u.languages {
+		if !l.Enabled(conf) {
+			continue
+		}
+		files, err := l.Scan(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			for _, i := range f.Imports {
+				t, err := l.Resolve(conf, dir, i)
+				ret = append(ret, newImport(conf, filepath.Join(dir, f.Name), l.Name(), dir, i, t, err))
+			}
+		}
+	}
+	return ret, nil
+}
+
+func newImport(conf *config.Config, file, lang, dir, imp, target string, err error) *Import {
+	ret := &Import{File: file, Language: lang, Import: imp, Target: target}
+	if err != nil {
+		ret.Class = Unresolved
+		ret.Error = err.Error()
+	} else {
+		ret.Class = Classify(conf, dir, target)
+	}
+	return ret
+}
+
+// Classify returns the class of an import from the target it resolved to, relative to the directory of its source
+func Classify(conf *config.Config, dir, target string) Class {
+	if target == "" {
+		return Builtin
+	}
+	if strings.HasPrefix(target, "///") || strings.HasPrefix(target, "@") {
+		return ThirdParty
+	}
+	pkg := labels.ParseRelative(target, dir).Package
+	thirdPartyDirs := []string{
+		conf.GetThirdPartyDir(),
+		conf.GetVendorDir(),
+		conf.GetPythonThirdPartyDir(),
+		conf.GetRustThirdPartyDir(),
+		conf.GetJavaThirdPartyDir(),
+	}
+	for _, thirdPartyDir := range thirdPartyDirs {
+		if thirdPartyDir != "" && fs.IsSubdir(thirdPartyDir, pkg) {
+			return ThirdParty
+		}
+	}
+	return FirstParty
+}
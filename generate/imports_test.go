@@ -0,0 +1,53 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestImportsIn(t *testing.T) {
+	files := map[string]string{
+		"foo/foo.go":           "package foo\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/repo/bar\"\n\t\"github.com/example/module\"\n)\n",
+		"foo/foo_test.go":      "package foo\n\nimport \"testing\"\n",
+		"foo/rules.build_defs": "subinclude(\"//build_defs:missing\")\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		u := newUpdater(plzConf, options.TestOptions)
+		conf := &config.Config{
+			KnownTargets: map[string]string{
+				"example.com/repo/bar":      "//bar",
+				"github.com/example/module": "///third_party/go/github.com_example_module//:module",
+			},
+			Languages: []string{"build_defs"},
+		}
+
+		imports, err := u.importsIn(conf, "foo")
+		require.NoError(t, err)
+		assert.Equal(t, []*Import{
+			{File: "foo/foo.go", Language: "go", Import: "fmt", Class: Builtin},
+			{File: "foo/foo.go", Language: "go", Import: "example.com/repo/bar", Class: FirstParty, Target: "//bar"},
+			{File: "foo/foo.go", Language: "go", Import: "github.com/example/module", Class: ThirdParty, Target: "///third_party/go/github.com_example_module//:module"},
+			{File: "foo/foo_test.go", Language: "go", Import: "testing", Class: Builtin},
+			{File: "foo/rules.build_defs", Language: "build_defs", Import: "//build_defs:missing", Class: Unresolved, Error: "there's no missing target in build_defs"},
+		}, imports)
+	})
+}
+
+func TestClassify(t *testing.T) {
+	conf := &config.Config{ThirdPartyDir: "third_party/go", PythonThirdPartyDir: "third_party/python"}
+	assert.Equal(t, Builtin, Classify(conf, "foo", ""))
+	assert.Equal(t, FirstParty, Classify(conf, "foo", "//foo/bar:baz"))
+	assert.Equal(t, FirstParty, Classify(conf, "foo", ":foo"))
+	assert.Equal(t, FirstParty, Classify(conf, "foo", "//third_party_tools:foo"))
+	assert.Equal(t, ThirdParty, Classify(conf, "foo", "//third_party/go:module"))
+	assert.Equal(t, ThirdParty, Classify(conf, "foo", "//third_party/python:requests"))
+	assert.Equal(t, ThirdParty, Classify(conf, "foo", "///third_party/go/github.com_example_module//:module"))
+}
@@ -0,0 +1,52 @@
+package java
+
+import (
+	"regexp"
+	"strings"
+)
+
+// packageArtifacts maps packages to the Maven artifact that provides them, for popular libraries where the package
+// doesn't start with the artifact's group. Others can be configured with knownTargets.
+var packageArtifacts = map[string]string{
+	"akka.actor":                       "com.typesafe.akka:akka-actor",
+	"akka.http":                        "com.typesafe.akka:akka-http",
+	"akka.stream":                      "com.typesafe.akka:akka-stream",
+	"cats":                             "org.typelevel:cats-core",
+	"cats.effect":                      "org.typelevel:cats-effect",
+	"com.fasterxml.jackson.annotation": "com.fasterxml.jackson.core:jackson-annotations",
+	"com.fasterxml.jackson.core":       "com.fasterxml.jackson.core:jackson-core",
+	"com.fasterxml.jackson.databind":   "com.fasterxml.jackson.core:jackson-databind",
+	"com.google.common":                "com.google.guava:guava",
+	"com.google.gson":                  "com.google.code.gson:gson",
+	"com.google.protobuf":              "com.google.protobuf:protobuf-java",
+	"com.google.protobuf.util":         "com.google.protobuf:protobuf-java-util",
+	"fs2":                              "co.fs2:fs2-core",
+	"javax.annotation":                 "javax.annotation:javax.annotation-api",
+	"javax.inject":                     "javax.inject:javax.inject",
+	"kotlinx.coroutines":               "org.jetbrains.kotlinx:kotlinx-coroutines-core",
+	"kotlinx.serialization":            "org.jetbrains.kotlinx:kotlinx-serialization-core",
+	"kotlinx.serialization.json":       "org.jetbrains.kotlinx:kotlinx-serialization-json",
+	"munit":                            "org.scalameta:munit",
+	"okhttp3":                          "com.squareup.okhttp3:okhttp",
+	"org.apache.commons.io":            "commons-io:commons-io",
+	"org.apache.commons.lang3":         "org.apache.commons:commons-lang3",
+	"org.apache.logging.log4j":         "org.apache.logging.log4j:log4j-api",
+	"org.assertj.core":                 "org.assertj:assertj-core",
+	"org.hamcrest":                     "org.hamcrest:hamcrest",
+	"org.junit":                        "junit:junit",
+	"org.junit.jupiter.api":            "org.junit.jupiter:junit-jupiter-api",
+	"org.junit.jupiter.params":         "org.junit.jupiter:junit-jupiter-params",
+	"org.mockito":                      "org.mockito:mockito-core",
+	"org.mockito.kotlin":               "org.mockito.kotlin:mockito-kotlin",
+	"org.slf4j":                        "org.slf4j:slf4j-api",
+	"play.api.libs.json":               "com.typesafe.play:play-json",
+	"retrofit2":                        "com.squareup.retrofit2:retrofit",
+	"zio":                              "dev.zio:zio",
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// TargetName returns the name of the maven_jar rule for an artifact, e.g. jackson_databind for jackson-databind
+func TargetName(artifact string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(artifact), "_"), "_")
+}
@@ -0,0 +1,294 @@
+// Package java generates java_library, java_test, kotlin_library, kotlin_test, scala_library and scala_test rules for
+// the Java, Kotlin and Scala sources in a directory, with deps on the packages they import from the repo and from
+// Maven.
+package java
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subincludes are the build definitions that provide the rules for each language
+var Subincludes = map[string]string{
+	"java":   "///java//build_defs:java",
+	"kotlin": "///kotlin//build_defs:kotlin",
+	"scala":  "///scala//build_defs:scala",
+}
+
+// Kinds are the kinds of rule that puku generates for Java, Kotlin and Scala sources
+var Kinds = map[string]*kinds.Kind{
+	"java_library": {
+		Name:     "java_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"java_test": {
+		Name:     "java_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+	"kotlin_library": {
+		Name:     "kotlin_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"kotlin_test": {
+		Name:     "kotlin_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+	"scala_library": {
+		Name:     "scala_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"scala_test": {
+		Name:     "scala_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+}
+
+// kindLangs maps the kinds of rule to the language of their sources
+var kindLangs = map[string]string{
+	"java_library":   "java",
+	"java_test":      "java",
+	"kotlin_library": "kotlin",
+	"kotlin_test":    "kotlin",
+	"scala_library":  "scala",
+	"scala_test":     "scala",
+}
+
+// langSuffixes are the suffixes of the rules for the languages that share a directory with Java sources
+var langSuffixes = map[string]string{
+	"kotlin": "_kt",
+	"scala":  "_scala",
+}
+
+// Generator updates the Java, Kotlin and Scala rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+
+	// packageDirs maps the packages declared in the repo to the directories with library sources for them. This is
+	// populated the first time it's needed.
+	packageDirs map[string][]srcDir
+	// artifacts are the maven_jar rules in each third party directory
+	artifacts map[string][]*artifact
+
+	resolvedImports map[string]string
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf:         plzConf,
+		graph:           g,
+		eval:            e,
+		artifacts:       map[string][]*artifact{},
+		resolvedImports: map[string]string{},
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"java", "kotlin", "scala"},
+		IsSource: func(name string) bool { return Lang(name) != "" },
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Java, Kotlin and Scala sources in the directory
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		ret = append(ret, &language.File{Name: f.Name, Imports: f.Imports})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves an imported class or package to the target that provides it
+func (g *Generator) Resolve(conf *config.Config, _, name string) (string, error) {
+	return g.resolveImport(conf, name)
+}
+
+// Update allocates the Java, Kotlin and Scala sources in the directory to rules, creating them as necessary, and updates
+// the deps of those rules based on what their sources import. Only the languages enabled in the config are updated.
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	all, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]*File, len(all))
+	for name, f := range all {
+		if conf.HasLanguage(f.Lang) {
+			files[name] = f
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	return language.UpdateRules(g.plzConf, conf, g.graph, dir, &language.Rules{
+		Kinds:      Kinds,
+		Include:    func(kind *kinds.Kind) bool { return conf.HasLanguage(kindLangs[kind.Name]) },
+		Subinclude: func(kind *kinds.Kind) string { return Subincludes[kindLangs[kind.Name]] },
+		Allocate: func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error) {
+			return g.allocateSources(file, dir, files, hasJava(all), rules)
+		},
+		UpdateDeps: func(rule *edit.Rule) error {
+			return g.updateRuleDeps(conf, rule, files)
+		},
+	})
+}
+
+// allocateSources allocates the sources that don't belong to a rule yet to the first library or test rule for their
+// language, which is created if needed
+func (g *Generator) allocateSources(file *build.File, dir string, files map[string]*File, java bool, rules []*edit.Rule) ([]*edit.Rule, error) {
+	allocated := map[string]bool{}
+	for _, rule := range rules {
+		srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range srcs {
+			allocated[src] = true
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !allocated[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var newRules []*edit.Rule
+	for _, name := range names {
+		f := files[name]
+		kind := f.Lang + "_library"
+		if f.IsTest {
+			kind = f.Lang + "_test"
+		}
+
+		var rule *edit.Rule
+		for _, r := range append(rules, newRules...) {
+			if r.Kind.Name == kind {
+				rule = r
+				break
+			}
+		}
+		if rule == nil {
+			rule = edit.NewRule(edit.NewRuleExpr(kind, ruleName(file, dir, kind, java)), Kinds[kind], dir)
+			newRules = append(newRules, rule)
+		}
+		rule.AddSrc(name)
+	}
+	return newRules, nil
+}
+
+// hasJava returns whether any of the files are Java sources
+func hasJava(files map[string]*File) bool {
+	for _, f := range files {
+		if f.Lang == "java" {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleName returns the name for a new rule of the given kind in the directory. This is named after the directory, as
+// with Go. Where there are Java sources in the directory too, the Kotlin and Scala rules are suffixed with _kt and
+// _scala, and if another rule already has the name, the rule is suffixed with its language.
+func ruleName(file *build.File, dir, kind string, java bool) string {
+	lang := kindLangs[kind]
+	name := language.LibName(dir)
+	if java {
+		name += langSuffixes[lang]
+	}
+	if strings.HasSuffix(kind, "_test") {
+		name += "_test"
+	}
+
+	if existing := edit.FindTargetByName(file, name); existing != nil && existing.Kind() != kind {
+		name = strings.TrimSuffix(name, "_test") + "_" + lang
+		if strings.HasSuffix(kind, "_test") {
+			name += "_test"
+		}
+	}
+	return name
+}
+
+// updateRuleDeps sets the deps of the rule to the targets that its sources import. Tests also depend on the libraries
+// for their own package elsewhere in the repo, e.g. under src/main, as they can use those classes without importing
+// them.
+func (g *Generator) updateRuleDeps(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	deps := map[string]bool{}
+	for _, src := range srcs {
+		if eval.LookLikeBuildLabel(src) {
+			continue
+		}
+		f := files[src]
+		if f == nil {
+			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			continue
+		}
+
+		if f.IsTest && f.Package != "" {
+			dep, err := g.packageTarget(f.Package)
+			if err != nil {
+				return err
+			}
+			if dep != "" && dep != label {
+				deps[dep] = true
+			}
+		}
+		for _, i := range f.Imports {
+			dep, err := g.resolveImport(conf, i)
+			if err != nil {
+				g.graph.Unresolved(label, i, err)
+				continue
+			}
+			if dep == "" || dep == label {
+				continue
+			}
+			deps[dep] = true
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, language.Shorten(rule.Dir, dep))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
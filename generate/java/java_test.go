@@ -0,0 +1,205 @@
+package java
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"app/src/main/java/com/example/app/App.java": `package com.example.app;
+
+import java.util.List;
+import com.example.model.User;
+import com.google.common.collect.ImmutableList;
+import com.fasterxml.jackson.databind.ObjectMapper;
+import org.slf4j.Logger;
+import org.unknown.Thing;
+`,
+		"app/src/main/java/com/example/app/Util.kt": "package com.example.app\n\nimport kotlinx.coroutines.launch\n",
+		"app/src/test/java/com/example/app/AppTest.java": `package com.example.app;
+
+import org.junit.jupiter.api.Test;
+`,
+		"model/User.java": "package com.example.model;\n\npublic class User {}\n",
+		"model/BUILD":     "java_library(\n    name = \"user\",\n    srcs = [\"User.java\"],\n)\n",
+		"third_party/java/BUILD": `maven_jar(
+    name = "guava",
+    id = "com.google.guava:guava:32.1.3-jre",
+)
+
+maven_jar(
+    name = "jackson_databind",
+    id = "com.fasterxml.jackson.core:jackson-databind:2.16.0",
+)
+
+maven_jar(
+    name = "slf4j_api",
+    id = "org.slf4j:slf4j-api:2.0.9",
+)
+
+maven_jar(
+    name = "junit_jupiter_api",
+    id = "org.junit.jupiter:junit-jupiter-api:5.10.1",
+)
+
+maven_jar(
+    name = "junit_jupiter_engine",
+    id = "org.junit.jupiter:junit-jupiter-engine:5.10.1",
+)
+
+maven_jar(
+    name = "kotlinx_coroutines_core",
+    id = "org.jetbrains.kotlinx:kotlinx-coroutines-core:1.7.3",
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{Languages: []string{"java", "kotlin"}}
+
+		require.NoError(t, g.Update(conf, "app/src/main/java/com/example/app"))
+		file, err := g.graph.LoadFile("app/src/main/java/com/example/app")
+		require.NoError(t, err)
+
+		t.Run("generates a java library", func(t *testing.T) {
+			lib := edit.FindTargetByName(file, "app")
+			require.NotNil(t, lib)
+			assert.Equal(t, "java_library", lib.Kind())
+			assert.Equal(t, []string{"App.java"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{
+				"//model:user",
+				"//third_party/java:guava",
+				"//third_party/java:jackson_databind",
+				"//third_party/java:slf4j_api",
+			}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("generates a kotlin library", func(t *testing.T) {
+			lib := edit.FindTargetByName(file, "app_kt")
+			require.NotNil(t, lib)
+			assert.Equal(t, "kotlin_library", lib.Kind())
+			assert.Equal(t, []string{"Util.kt"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/java:kotlinx_coroutines_core"}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("subincludes the rules for both languages", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			require.Len(t, call.List, 2)
+			assert.Equal(t, "///java//build_defs:java", call.List[0].(*build.StringExpr).Value)
+			assert.Equal(t, "///kotlin//build_defs:kotlin", call.List[1].(*build.StringExpr).Value)
+		})
+
+		t.Run("generates a test depending on the library for its package", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "app/src/test/java/com/example/app"))
+			file, err := g.graph.LoadFile("app/src/test/java/com/example/app")
+			require.NoError(t, err)
+			test := edit.FindTargetByName(file, "app_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "java_test", test.Kind())
+			assert.Equal(t, []string{"AppTest.java"}, test.AttrStrings("srcs"))
+			assert.Equal(t, []string{
+				"//app/src/main/java/com/example/app",
+				"//third_party/java:junit_jupiter_api",
+			}, test.AttrStrings("deps"))
+		})
+
+		t.Run("generates scala rules", func(t *testing.T) {
+			repotest.WriteFiles(t, ".", map[string]string{
+				"service/src/main/scala/com/example/service/Service.scala": `package com.example.service
+
+import com.example.model.User
+import cats.effect.{IO, Resource => Res}
+import org.typelevel.log4cats.Logger
+`,
+				"service/src/main/scala/com/example/service/ServiceSpec.scala": `package com.example.service
+
+import org.scalatest.flatspec.AnyFlatSpec
+`,
+				"third_party/scala/BUILD": `maven_jar(
+    name = "cats_effect",
+    id = "org.typelevel:cats-effect_2.13:3.5.2",
+)
+
+maven_jar(
+    name = "log4cats_core",
+    id = "org.typelevel:log4cats-core_2.13:2.6.0",
+)
+`,
+			})
+			// A new generator, so the packages are indexed again with the new sources
+			g := New(plzConf, g.graph, g.eval)
+			conf := &config.Config{
+				Languages:         []string{"java", "scala"},
+				JavaThirdPartyDir: "third_party/scala",
+				KnownTargets:      map[string]string{"org.scalatest": "//third_party/scala:scalatest"},
+			}
+			require.NoError(t, g.Update(conf, "service/src/main/scala/com/example/service"))
+			file, err := g.graph.LoadFile("service/src/main/scala/com/example/service")
+			require.NoError(t, err)
+
+			lib := edit.FindTargetByName(file, "service")
+			require.NotNil(t, lib)
+			assert.Equal(t, "scala_library", lib.Kind())
+			assert.Equal(t, []string{"Service.scala"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{
+				"//model:user",
+				"//third_party/scala:cats_effect",
+				"//third_party/scala:log4cats_core",
+			}, lib.AttrStrings("deps"))
+
+			test := edit.FindTargetByName(file, "service_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "scala_test", test.Kind())
+			assert.Equal(t, []string{"ServiceSpec.scala"}, test.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/scala:scalatest", ":service"}, test.AttrStrings("deps"))
+		})
+
+		t.Run("only updates enabled languages", func(t *testing.T) {
+			repotest.WriteFiles(t, ".", map[string]string{"kotlin_only/Foo.kt": "package foo\n"})
+			require.NoError(t, g.Update(&config.Config{Languages: []string{"java"}}, "kotlin_only"))
+			file, err := g.graph.LoadFile("kotlin_only")
+			require.NoError(t, err)
+			assert.Empty(t, file.Stmt)
+		})
+	})
+}
+
+func TestThirdPartyTarget(t *testing.T) {
+	artifacts := []*artifact{
+		{group: "org.junit.jupiter", artifact: "junit-jupiter-api", target: "//third_party/java:junit_jupiter_api"},
+		{group: "org.junit.jupiter", artifact: "junit-jupiter-engine", target: "//third_party/java:junit_jupiter_engine"},
+		{group: "io.netty", artifact: "netty-buffer", target: "//third_party/java:netty_buffer"},
+		{group: "io.netty", artifact: "netty-common", target: "//third_party/java:netty_common"},
+	}
+
+	target, err := thirdPartyTarget(artifacts, []string{"org", "junit", "jupiter", "api"})
+	require.NoError(t, err)
+	assert.Equal(t, "//third_party/java:junit_jupiter_api", target)
+
+	target, err = thirdPartyTarget(artifacts, []string{"io", "netty", "buffer"})
+	require.NoError(t, err)
+	assert.Equal(t, "//third_party/java:netty_buffer", target)
+
+	_, err = thirdPartyTarget(artifacts, []string{"io", "netty", "channel"})
+	assert.ErrorContains(t, err, "could be from io.netty:netty-buffer or io.netty:netty-common")
+
+	_, err = thirdPartyTarget(artifacts, []string{"org", "apache"})
+	assert.ErrorContains(t, err, "no artifact for org.apache")
+}
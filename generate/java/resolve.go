@@ -0,0 +1,270 @@
+package java
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+)
+
+// srcDir is a directory containing library sources for a package
+type srcDir struct {
+	dir  string
+	lang string
+}
+
+// artifact is a maven_jar rule for a third party artifact
+type artifact struct {
+	group    string
+	artifact string
+	target   string
+}
+
+// stdlibPackages are the packages that come with the JDK, or the Kotlin and Scala standard libraries
+var stdlibPackages = []string{
+	"java",
+	"javax",
+	"jdk",
+	"sun",
+	"com.sun",
+	"kotlin",
+	"org.ietf.jgss",
+	"org.w3c.dom",
+	"org.xml.sax",
+	"scala",
+}
+
+// resolveImport resolves an imported class or package to the target that provides it. It returns an empty string for
+// the standard library.
+func (g *Generator) resolveImport(conf *config.Config, name string) (string, error) {
+	if t, ok := g.resolvedImports[name]; ok {
+		return t, nil
+	}
+	t, err := g.reallyResolveImport(conf, name)
+	if err != nil {
+		return "", err
+	}
+	g.resolvedImports[name] = t
+	return t, nil
+}
+
+func (g *Generator) reallyResolveImport(conf *config.Config, name string) (string, error) {
+	parts := strings.Split(name, ".")
+	for i := len(parts); i > 0; i-- {
+		if t := conf.GetKnownTarget(strings.Join(parts[:i], ".")); t != "" {
+			return t, nil
+		}
+	}
+
+	// Kotlin can also import top level functions, which are named like packages
+	parts = packageOf(parts)
+	for i := len(parts); i > 0 && i >= len(parts)-1; i-- {
+		t, err := g.packageTarget(strings.Join(parts[:i], "."))
+		if err != nil || t != "" {
+			return t, err
+		}
+	}
+
+	artifacts, err := g.thirdPartyArtifacts(conf.GetJavaThirdPartyDir())
+	if err != nil {
+		return "", err
+	}
+	if a := knownArtifact(artifacts, parts); a != nil {
+		return a.target, nil
+	}
+	if isStdlib(name) {
+		return "", nil
+	}
+	return thirdPartyTarget(artifacts, parts)
+}
+
+// packageOf returns the package part of an imported name. By convention, packages are lower case and classes start
+// with a capital letter, so this is everything up to the first capitalised segment.
+func packageOf(parts []string) []string {
+	for i, part := range parts {
+		if part != "" && part[0] >= 'A' && part[0] <= 'Z' {
+			return parts[:i]
+		}
+	}
+	return parts
+}
+
+func isStdlib(name string) bool {
+	for _, pkg := range stdlibPackages {
+		if name == pkg || strings.HasPrefix(name, pkg+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// packageTarget returns the library for a package declared in the repo, or an empty string if it isn't
+func (g *Generator) packageTarget(pkg string) (string, error) {
+	if g.packageDirs == nil {
+		dirs, err := indexPackages()
+		if err != nil {
+			return "", err
+		}
+		g.packageDirs = dirs
+	}
+	dirs := g.packageDirs[pkg]
+	if len(dirs) == 0 {
+		return "", nil
+	}
+	if len(dirs) > 1 {
+		log.Warningf("package %v is in several directories, using %v", pkg, dirs[0].dir)
+	}
+	return g.libraryTarget(dirs[0])
+}
+
+// libraryTarget returns the library rule for the sources in a directory. If there isn't one yet, it's assumed it'll
+// be generated when puku updates that directory.
+func (g *Generator) libraryTarget(src srcDir) (string, error) {
+	file, err := g.graph.LoadFile(src.dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", src.dir, err)
+	}
+	kind := src.lang + "_library"
+	if rules := file.Rules(kind); len(rules) > 0 {
+		return edit.BuildTarget(rules[0].Name(), src.dir, ""), nil
+	}
+
+	files, err := ImportDir(src.dir)
+	if err != nil {
+		return "", err
+	}
+	return edit.BuildTarget(ruleName(file, src.dir, kind, hasJava(files)), src.dir, ""), nil
+}
+
+// indexPackages finds the packages declared by the library sources in the repo, i.e. not tests
+func indexPackages() (map[string][]srcDir, error) {
+	dirs := map[string][]srcDir{}
+	seen := map[srcDir]map[string]bool{}
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != "." && (d.Name() == "plz-out" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if Lang(path) == "" || !d.Type().IsRegular() {
+			return nil
+		}
+		f, err := importFile(filepath.Dir(path), d.Name())
+		if err != nil {
+			return err
+		}
+		src := srcDir{dir: filepath.Dir(path), lang: f.Lang}
+		if f.IsTest || f.Package == "" || seen[src][f.Package] {
+			return nil
+		}
+		if seen[src] == nil {
+			seen[src] = map[string]bool{}
+		}
+		seen[src][f.Package] = true
+		dirs[f.Package] = append(dirs[f.Package], src)
+		return nil
+	})
+	return dirs, err
+}
+
+var (
+	mavenID = regexp.MustCompile(`^([^:]+):([^:]+)`)
+	// scalaVersion is the suffix of Scala artifacts for the version of Scala they're built for, e.g. cats-core_2.13
+	scalaVersion = regexp.MustCompile(`_[23](\.\d+)?$`)
+)
+
+// thirdPartyArtifacts returns the maven_jar rules in the third party directory, by their id
+func (g *Generator) thirdPartyArtifacts(dir string) ([]*artifact, error) {
+	if artifacts, ok := g.artifacts[dir]; ok {
+		return artifacts, nil
+	}
+	var artifacts []*artifact
+	if _, err := os.Stat(dir); err == nil {
+		file, err := g.graph.LoadFile(dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+		}
+		for _, rule := range file.Rules("") {
+			if match := mavenID.FindStringSubmatch(rule.AttrString("id")); match != nil {
+				artifacts = append(artifacts, &artifact{
+					group:    match[1],
+					artifact: scalaVersion.ReplaceAllString(match[2], ""),
+					target:   edit.BuildTarget(rule.Name(), dir, ""),
+				})
+			}
+		}
+	}
+	g.artifacts[dir] = artifacts
+	return artifacts, nil
+}
+
+// knownArtifact returns the artifact for packages that puku knows aren't named after the artifact's group, if there's
+// a maven_jar for it
+func knownArtifact(artifacts []*artifact, parts []string) *artifact {
+	for i := len(parts); i > 0; i-- {
+		id, ok := packageArtifacts[strings.Join(parts[:i], ".")]
+		if !ok {
+			continue
+		}
+		for _, a := range artifacts {
+			if a.group+":"+a.artifact == id {
+				return a
+			}
+		}
+		return nil
+	}
+	return nil
+}
+
+// thirdPartyTarget resolves a package to the artifact whose group is the longest prefix of it, e.g. org.slf4j:slf4j-api
+// for org.slf4j.Logger. Where there are several artifacts in that group, the one with the most segments of its name in
+// the package is used, e.g. org.junit.jupiter:junit-jupiter-api rather than junit-jupiter-engine for
+// org.junit.jupiter.api.
+func thirdPartyTarget(artifacts []*artifact, parts []string) (string, error) {
+	name := strings.Join(parts, ".")
+	var candidates []*artifact
+	longest := 0
+	for _, a := range artifacts {
+		if name != a.group && !strings.HasPrefix(name, a.group+".") {
+			continue
+		}
+		if len(a.group) > longest {
+			candidates, longest = nil, len(a.group)
+		}
+		if len(a.group) == longest {
+			candidates = append(candidates, a)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no artifact for %v", name)
+	}
+
+	score := func(a *artifact) int {
+		n := 0
+		for _, segment := range strings.FieldsFunc(a.artifact, func(r rune) bool { return r == '-' || r == '.' }) {
+			for _, part := range parts {
+				if part == segment {
+					n++
+					break
+				}
+			}
+		}
+		return n
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return score(candidates[i]) > score(candidates[j]) })
+	if len(candidates) > 1 && score(candidates[0]) == score(candidates[1]) {
+		a, b := candidates[0], candidates[1]
+		return "", fmt.Errorf("%v could be from %v:%v or %v:%v", name, a.group, a.artifact, b.group, b.artifact)
+	}
+	return candidates[0].target, nil
+}
@@ -0,0 +1,249 @@
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// File represents a single Java, Kotlin or Scala source file
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Lang is the language of the file, i.e. java, kotlin or scala
+	Lang string
+	// Package is the package the file declares
+	Package string
+	// Imports are the names the file imports. These are classes, or packages for wildcard imports. Static imports are
+	// recorded as the class the member is imported from.
+	Imports []string
+	// IsTest is set when the file follows the JUnit naming conventions, i.e. FooTest, FooTests or FooIT, or ScalaTest's
+	// FooSpec and FooSuite for Scala, or is under a src/test directory as laid out by Maven, Gradle and sbt
+	IsTest bool
+}
+
+var (
+	packageDecl = regexp.MustCompile(`(?m)^\s*package\s+([A-Za-z_][\w.]*)`)
+	importDecl  = regexp.MustCompile(`(?m)^\s*import\s+(static\s+)?([A-Za-z_][\w.]*?)(\.\*)?\s*(?:\bas\s+\w+)?\s*;?\s*$`)
+	testName    = regexp.MustCompile(`((Test|Tests|IT)\.(java|kt|scala)|(Spec|Suite)\.scala)$`)
+
+	// package object foo declares an object in the enclosing package, rather than another package
+	scalaPackageDecl = regexp.MustCompile(`(?m)^[ \t]*package[ \t]+([A-Za-z_]\w*(?:\.[A-Za-z_]\w*)*)\b[ \t]*(?:[{;]|$)`)
+	scalaImportDecl  = regexp.MustCompile(`(?m)^[ \t]*import[ \t]+`)
+	validImport      = regexp.MustCompile(`^[A-Za-z_]\w*(\.[A-Za-z_]\w*)*$`)
+)
+
+// Lang returns the language of a source file, or an empty string if it's not a Java, Kotlin or Scala file
+func Lang(name string) string {
+	switch filepath.Ext(name) {
+	case ".java":
+		return "java"
+	case ".kt":
+		return "kotlin"
+	case ".scala":
+		return "scala"
+	}
+	return ""
+}
+
+// ImportDir imports the .java, .kt and .scala files in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || Lang(info.Name()) == "" {
+			continue
+		}
+		f, err := importFile(dir, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = f
+	}
+	return ret, nil
+}
+
+func importFile(dir, src string) (*File, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, src))
+	if err != nil {
+		return nil, err
+	}
+	return parseFile(dir, src, bs), nil
+}
+
+func parseFile(dir, src string, bs []byte) *File {
+	code := stripComments(bs)
+	f := &File{
+		Name:   src,
+		Lang:   Lang(src),
+		IsTest: testName.MatchString(src) || isTestDir(dir),
+	}
+	if f.Lang == "scala" {
+		f.Package, f.Imports = parseScala(code)
+		return f
+	}
+	if match := packageDecl.FindStringSubmatch(code); match != nil {
+		f.Package = match[1]
+	}
+
+	imports := map[string]bool{}
+	for _, match := range importDecl.FindAllStringSubmatch(code, -1) {
+		name := match[2]
+		if match[1] != "" && match[3] == "" {
+			// import static foo.Bar.baz imports the member baz of foo.Bar
+			if i := strings.LastIndex(name, "."); i != -1 {
+				name = name[:i]
+			}
+		}
+		imports[name] = true
+	}
+	for i := range imports {
+		f.Imports = append(f.Imports, i)
+	}
+	sort.Strings(f.Imports)
+	return f
+}
+
+// parseScala finds the package and imports of a Scala file. Packages can be declared across several chained package
+// clauses, which are joined together. Imports can pick several names out of a package with selectors, which may rename
+// or hide them, e.g. import foo.{Bar, Baz => Qux}, and wildcard imports are recorded as the package.
+func parseScala(code string) (string, []string) {
+	var pkg []string
+	for _, match := range scalaPackageDecl.FindAllStringSubmatch(code, -1) {
+		pkg = append(pkg, match[1])
+	}
+
+	imports := map[string]bool{}
+	for _, loc := range scalaImportDecl.FindAllStringIndex(code, -1) {
+		for _, clause := range splitImportClauses(code[loc[1]:]) {
+			for _, i := range scalaImports(clause) {
+				imports[i] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(imports))
+	for i := range imports {
+		ret = append(ret, i)
+	}
+	sort.Strings(ret)
+	return strings.Join(pkg, "."), ret
+}
+
+// splitImportClauses splits the import statement at the start of the code into its comma separated clauses. The
+// statement ends at the end of the line or a semicolon, unless it's within the braces of a selector.
+func splitImportClauses(code string) []string {
+	var clauses []string
+	depth, start := 0, 0
+	for i, c := range code {
+		switch {
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			clauses = append(clauses, code[start:i])
+			start = i + 1
+		case (c == '\n' || c == ';') && depth == 0:
+			return append(clauses, code[start:i])
+		}
+	}
+	return append(clauses, code[start:])
+}
+
+// scalaImports returns the names imported by a single import clause, e.g. foo.Bar, foo._ or foo.{Bar, Baz => Qux}
+func scalaImports(clause string) []string {
+	clause = strings.TrimPrefix(strings.TrimSpace(clause), "_root_.")
+	prefix, selectors, ok := strings.Cut(clause, "{")
+	if !ok {
+		// Scala 3 renames without braces, e.g. import foo.Bar as Baz
+		i := strings.LastIndex(clause, ".")
+		prefix, selectors = clause[:i+1], clause[i+1:]
+	}
+	prefix = strings.TrimSuffix(strings.Join(strings.Fields(prefix), ""), ".")
+	if !validImport.MatchString(prefix) || prefix[0] >= 'A' && prefix[0] <= 'Z' {
+		// Imports starting with a capitalised name are from an object in scope, e.g. import Foo._ for a companion
+		return nil
+	}
+
+	var ret []string
+	for _, selector := range strings.Split(strings.TrimSuffix(selectors, "}"), ",") {
+		fields := strings.Fields(selector)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 3 && (fields[1] == "=>" || fields[1] == "as") && fields[2] == "_" {
+			continue // This hides the name rather than importing it
+		}
+		switch name := fields[0]; {
+		case name == "_" || name == "*" || name == "given":
+			ret = append(ret, prefix)
+		case validImport.MatchString(name):
+			ret = append(ret, prefix+"."+name)
+		}
+	}
+	return ret
+}
+
+// isTestDir returns whether the directory is under src/test, where Maven, Gradle and sbt projects keep their tests
+func isTestDir(dir string) bool {
+	dir = "/" + filepath.ToSlash(dir) + "/"
+	return strings.Contains(dir, "/src/test/")
+}
+
+// stripComments removes comments from Java, Kotlin or Scala source code, and the contents of string literals, so they
+// aren't mistaken for code. Kotlin and Scala allow block comments to be nested, which wouldn't be valid Java anyway.
+func stripComments(src []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			depth := 0
+			for ; i < len(src); i++ {
+				if src[i] == '\n' {
+					b.WriteByte('\n') // Keep the lines so ^ still matches the start of them
+				} else if src[i] == '/' && i+1 < len(src) && src[i+1] == '*' {
+					depth++
+					i++
+				} else if src[i] == '*' && i+1 < len(src) && src[i+1] == '/' {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			b.WriteByte(' ')
+		case c == '"' && i+2 < len(src) && src[i+1] == '"' && src[i+2] == '"':
+			// Text blocks, and Kotlin's raw strings
+			end := strings.Index(string(src[i+3:]), `"""`)
+			if end == -1 {
+				i = len(src)
+			} else {
+				i += end + 5
+			}
+			b.WriteString(`""`)
+		case c == '"' || c == '\'':
+			for i++; i < len(src) && src[i] != c && src[i] != '\n'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			b.WriteString(`""`)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
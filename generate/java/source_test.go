@@ -0,0 +1,105 @@
+package java
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	t.Run("java", func(t *testing.T) {
+		f := parseFile("src/main/java/com/example/app", "App.java", []byte(`/*
+import com.example.commented.Out;
+*/
+package com.example.app;
+
+import java.util.List;
+import static org.junit.Assert.assertEquals;
+import static com.example.util.Strings.*;
+import com.google.common.collect.*; // Guava
+import com.example.model.User.Role;
+
+public class App {
+    String s = """
+        import not.an.Import;
+        """;
+}
+`))
+		assert.Equal(t, "App.java", f.Name)
+		assert.Equal(t, "java", f.Lang)
+		assert.Equal(t, "com.example.app", f.Package)
+		assert.Equal(t, []string{
+			"com.example.model.User.Role",
+			"com.example.util.Strings",
+			"com.google.common.collect",
+			"java.util.List",
+			"org.junit.Assert",
+		}, f.Imports)
+		assert.False(t, f.IsTest)
+	})
+
+	t.Run("kotlin", func(t *testing.T) {
+		f := parseFile("app/src/test/kotlin/com/example", "Server.kt", []byte(`package com.example
+
+import kotlinx.coroutines.launch
+import com.example.model.User as Person
+import io.ktor.server.application.*
+`))
+		assert.Equal(t, "kotlin", f.Lang)
+		assert.Equal(t, "com.example", f.Package)
+		assert.Equal(t, []string{"com.example.model.User", "io.ktor.server.application", "kotlinx.coroutines.launch"}, f.Imports)
+		assert.True(t, f.IsTest)
+	})
+
+	t.Run("scala", func(t *testing.T) {
+		f := parseFile("server/src/main/scala", "Server.scala", []byte(`package com.example
+package server
+
+import scala.concurrent.Future
+import cats.effect.{IO, Resource => Res}, fs2._
+import com.example.model.{User, Secret => _, given}
+import _root_.akka.actor.ActorSystem
+import org.http4s.{
+  HttpRoutes,
+  Request
+}
+import io.circe.syntax.*
+import com.example.util.Strings as S
+
+object Server {
+  import Config._
+}
+`))
+		assert.Equal(t, "scala", f.Lang)
+		assert.Equal(t, "com.example.server", f.Package)
+		assert.Equal(t, []string{
+			"akka.actor.ActorSystem",
+			"cats.effect.IO",
+			"cats.effect.Resource",
+			"com.example.model",
+			"com.example.model.User",
+			"com.example.util.Strings",
+			"fs2",
+			"io.circe.syntax",
+			"org.http4s.HttpRoutes",
+			"org.http4s.Request",
+			"scala.concurrent.Future",
+		}, f.Imports)
+		assert.False(t, f.IsTest)
+	})
+
+	t.Run("scala package objects", func(t *testing.T) {
+		f := parseFile("foo", "package.scala", []byte("package com.example\n\npackage object util {}\n"))
+		assert.Equal(t, "com.example", f.Package)
+	})
+
+	t.Run("tests by name", func(t *testing.T) {
+		assert.True(t, parseFile("foo", "FooTest.java", nil).IsTest)
+		assert.True(t, parseFile("foo", "FooTests.kt", nil).IsTest)
+		assert.True(t, parseFile("foo", "FooIT.java", nil).IsTest)
+		assert.True(t, parseFile("foo", "FooSpec.scala", nil).IsTest)
+		assert.True(t, parseFile("foo", "FooSuite.scala", nil).IsTest)
+		assert.False(t, parseFile("foo", "Testing.java", nil).IsTest)
+		assert.False(t, parseFile("foo", "FooSpec.java", nil).IsTest)
+	})
+}
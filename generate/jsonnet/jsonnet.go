@@ -0,0 +1,318 @@
+// Package jsonnet generates a jsonnet_library for the Jsonnet files in each directory, with deps on the rules for the
+// files they import. Files imported with importstr or importbin are added to the srcs of the library where they're in
+// the same package.
+package jsonnet
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the Jsonnet rules
+const Subinclude = "///jsonnet//build_defs:jsonnet"
+
+// Kinds are the kinds of rule that puku generates for Jsonnet files
+var Kinds = map[string]*kinds.Kind{
+	"jsonnet_library": {
+		Name:     "jsonnet_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+}
+
+// Generator updates the Jsonnet rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"jsonnet"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Jsonnet files in the directory. Their imports include the files imported with importstr and
+// importbin.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		imports := make([]string, 0, len(f.Imports))
+		for _, i := range f.Imports {
+			imports = append(imports, i.Path)
+		}
+		ret = append(ret, &language.File{Name: f.Name, Imports: imports})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves a file imported by a Jsonnet file in the directory to the rule that provides it. This is an empty
+// string for data files in the directory's own package, as they're added to the srcs of its library.
+func (g *Generator) Resolve(conf *config.Config, dir, imp string) (string, error) {
+	path, err := findImport(conf, dir, imp)
+	if err != nil {
+		return "", err
+	}
+	if !IsSource(path) && g.packageOf(dir, filepath.Dir(path)) == dir {
+		return "", nil
+	}
+	return g.fileTarget(dir, path)
+}
+
+// Update allocates the Jsonnet files in the directory that aren't in the srcs of a jsonnet_library already to the
+// first one there, which is created if needed, and updates the deps of the Jsonnet rules from what their srcs import
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+
+	allocated := map[string]bool{}
+	var rules []*edit.Rule
+	for _, expr := range file.Rules("jsonnet_library") {
+		rule := edit.NewRule(expr, Kinds["jsonnet_library"], dir)
+		srcs, err := g.eval.EvalGlobs(dir, expr, rule.SrcsAttr())
+		if err != nil {
+			return err
+		}
+		for _, src := range srcs {
+			allocated[src] = true
+		}
+		rules = append(rules, rule)
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !allocated[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 && len(rules) == 0 {
+		rule := edit.NewRule(edit.NewRuleExpr("jsonnet_library", ruleName(file, dir)), Kinds["jsonnet_library"], dir)
+		file.Stmt = append(file.Stmt, rule.Call)
+		rules = append(rules, rule)
+	}
+	for _, name := range names {
+		rules[0].AddSrc(name)
+	}
+
+	if !g.plzConf.IsPreloaded(Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, Subinclude)
+	}
+
+	for _, rule := range rules {
+		if err := g.updateRule(conf, rule, files); err != nil {
+			return fmt.Errorf("failed to update %v: %v", rule.Label(), err)
+		}
+	}
+	return nil
+}
+
+// ruleName returns the name of the library generated for a directory. This is named after the directory, unless that's
+// taken by another rule, e.g. the Go library for the package.
+func ruleName(file *build.File, dir string) string {
+	name := "jsonnet"
+	if dir != "." {
+		name = filepath.Base(dir)
+	}
+	if existing := edit.FindTargetByName(file, name); existing != nil && existing.Kind() != "jsonnet_library" {
+		return name + "_jsonnet"
+	}
+	return name
+}
+
+// updateRule sets the deps of the rule to the rules for the files its srcs import, and adds the data files they import
+// from the package to its srcs. Files that no longer exist are removed from its srcs.
+func (g *Generator) updateRule(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	has := map[string]bool{}
+	deps := map[string]bool{}
+	var added []string
+	for _, src := range srcs {
+		if isLabel(src) {
+			continue
+		}
+		if !isFile(filepath.Join(rule.Dir, src)) {
+			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			continue
+		}
+		has[src] = true
+	}
+	for _, src := range srcs {
+		f := files[src]
+		if f == nil || !has[src] {
+			continue
+		}
+		for _, i := range f.Imports {
+			path, err := findImport(conf, rule.Dir, i.Path)
+			if err != nil {
+				g.graph.Unresolved(label, i.Path, err)
+				continue
+			}
+			if i.Data && g.packageOf(rule.Dir, filepath.Dir(path)) == rule.Dir {
+				rel, err := filepath.Rel(rule.Dir, path)
+				if err != nil {
+					return err
+				}
+				if !has[rel] {
+					has[rel] = true
+					added = append(added, rel)
+				}
+				continue
+			}
+			dep, err := g.fileTarget(rule.Dir, path)
+			if err != nil {
+				g.graph.Unresolved(label, i.Path, err)
+				continue
+			}
+			if dep != label {
+				deps[dep] = true
+			}
+		}
+	}
+
+	if len(added) > 0 {
+		if _, ok := rule.Attr(rule.SrcsAttr()).(*build.CallExpr); ok {
+			log.Warningf("%v imports %v, which can't be added to its srcs as they're a glob", label, strings.Join(added, ", "))
+		} else {
+			sort.Strings(added)
+			rule.SetOrDeleteAttr(rule.SrcsAttr(), append(rule.AttrStrings(rule.SrcsAttr()), added...))
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, labels.Shorten(dep, rule.Dir))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
+
+// findImport returns the path of an imported file. As with the jsonnet command, imports are looked for relative to the
+// importing file first, and then in each of the library search paths in jsonnetJpath.
+func findImport(conf *config.Config, dir, imp string) (string, error) {
+	candidates := []string{filepath.Join(dir, imp)}
+	for _, jpath := range conf.GetJsonnetJpath() {
+		candidates = append(candidates, filepath.Join(jpath, imp))
+	}
+	for _, path := range candidates {
+		if !strings.HasPrefix(path, "../") && isFile(path) {
+			return path, nil
+		}
+	}
+	return "", fmt.Errorf("no such file in %v or jsonnetJpath", dir)
+}
+
+// fileTarget returns the rule in the package of a file that has it in its srcs. Where no rule has a Jsonnet file yet,
+// it's assumed it'll go in the library for its directory when puku updates it.
+func (g *Generator) fileTarget(current, path string) (string, error) {
+	pkg := g.packageOf(current, filepath.Dir(path))
+	rel, err := filepath.Rel(pkg, path)
+	if err != nil {
+		return "", err
+	}
+	file, err := g.graph.LoadFile(pkg)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", pkg, err)
+	}
+	for _, expr := range file.Rules("") {
+		srcs, err := g.eval.EvalGlobs(pkg, expr, "srcs")
+		if err != nil {
+			return "", err
+		}
+		for _, src := range srcs {
+			if src == rel {
+				return edit.BuildTarget(expr.Name(), pkg, ""), nil
+			}
+		}
+	}
+	if !IsSource(path) {
+		return "", fmt.Errorf("%v isn't in the srcs of any rule in %v", rel, pkg)
+	}
+
+	dir := filepath.Dir(path)
+	if dir != pkg {
+		if file, err = g.graph.LoadFile(dir); err != nil {
+			return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+		}
+	}
+	if libs := file.Rules("jsonnet_library"); len(libs) > 0 {
+		return edit.BuildTarget(libs[0].Name(), dir, ""), nil
+	}
+	return edit.BuildTarget(ruleName(file, dir), dir, ""), nil
+}
+
+// packageOf returns the package that a directory belongs to. This is the nearest directory at or above it that has a
+// BUILD file, or is the package being updated, which may not have one yet. It's the repo root if there's neither.
+func (g *Generator) packageOf(current, dir string) string {
+	for dir != "." && dir != current {
+		for _, name := range g.plzConf.BuildFileNames() {
+			if isFile(filepath.Join(dir, name)) {
+				return dir
+			}
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
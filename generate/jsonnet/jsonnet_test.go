@@ -0,0 +1,85 @@
+package jsonnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"deploy/main.jsonnet": `local k = import "k.libsonnet";
+local common = import "../common/common.libsonnet";
+local app = import "app.libsonnet";
+{
+  config: importstr "config/app.yaml",
+  schema: importstr "schemas/app.json",
+  missing: import "missing.libsonnet",
+}
+`,
+		"deploy/app.libsonnet":    "{}\n",
+		"deploy/config/app.yaml":  "",
+		"deploy/BUILD":            "go_library(\n    name = \"deploy\",\n    srcs = [\"deploy.go\"],\n)\n",
+		"common/common.libsonnet": "",
+		"vendor/k.libsonnet":      "",
+		"vendor/BUILD":            "jsonnet_library(\n    name = \"k8s\",\n    srcs = [\n        \"k.libsonnet\",\n        \"deleted.libsonnet\",\n    ],\n)\n",
+		"schemas/app.json":        "{}",
+		"schemas/BUILD":           "filegroup(\n    name = \"schemas\",\n    srcs = [\"app.json\"],\n)\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{JsonnetJpath: []string{"vendor", "."}}
+
+		require.NoError(t, g.Update(conf, "deploy"))
+		file, err := g.graph.LoadFile("deploy")
+		require.NoError(t, err)
+
+		t.Run("generates a library for the directory", func(t *testing.T) {
+			rule := edit.FindTargetByName(file, "deploy_jsonnet")
+			require.NotNil(t, rule)
+			assert.Equal(t, "jsonnet_library", rule.Kind())
+			assert.Equal(t, []string{"app.libsonnet", "main.jsonnet", "config/app.yaml"}, rule.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//common", "//schemas", "//vendor:k8s"}, rule.AttrStrings("deps"))
+		})
+
+		t.Run("removes deleted files", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "vendor"))
+			file, err := g.graph.LoadFile("vendor")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "k8s")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"k.libsonnet"}, rule.AttrStrings("srcs"))
+		})
+
+		t.Run("resolves imports", func(t *testing.T) {
+			files, err := g.Scan("deploy")
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			assert.Equal(t, "main.jsonnet", files[1].Name)
+
+			for imp, expected := range map[string]string{
+				"k.libsonnet":                "//vendor:k8s",
+				"app.libsonnet":              "//deploy:deploy_jsonnet",
+				"config/app.yaml":            "",
+				"../common/common.libsonnet": "//common",
+			} {
+				label, err := g.Resolve(conf, "deploy", imp)
+				require.NoError(t, err)
+				assert.Equal(t, expected, label, imp)
+			}
+			_, err = g.Resolve(conf, "deploy", "missing.libsonnet")
+			assert.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,118 @@
+package jsonnet
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Import is a file imported by a Jsonnet file
+type Import struct {
+	// Path is the path of the file as it's written in the import
+	Path string
+	// Data is set for importstr and importbin, which import the file as a string or bytes rather than as Jsonnet
+	Data bool
+}
+
+// File represents a single Jsonnet file
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Imports are the files it imports, in the order they're first imported
+	Imports []Import
+}
+
+var importExpr = regexp.MustCompile(`\b(import|importstr|importbin)\s*(?:"((?:[^"\\]|\\.)*)"|'((?:[^'\\]|\\.)*)')`)
+
+// IsSource returns whether the file is a Jsonnet file
+func IsSource(name string) bool {
+	ext := filepath.Ext(name)
+	return ext == ".jsonnet" || ext == ".libsonnet"
+}
+
+// ImportDir reads the .jsonnet and .libsonnet files in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || !IsSource(info.Name()) {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = parseFile(info.Name(), bs)
+	}
+	return ret, nil
+}
+
+// parseFile finds the files that a Jsonnet file imports. Imports have to be string literals, so there's no need to
+// evaluate anything to find them.
+func parseFile(name string, src []byte) *File {
+	f := &File{Name: name}
+	seen := map[string]bool{}
+	for _, match := range importExpr.FindAllStringSubmatch(stripComments(src), -1) {
+		path := match[2] + match[3]
+		if !seen[path] {
+			seen[path] = true
+			f.Imports = append(f.Imports, Import{Path: path, Data: match[1] != "import"})
+		}
+	}
+	return f
+}
+
+// stripComments removes the //, # and /* */ comments and text blocks from a Jsonnet file. Strings are kept, as they
+// hold the paths of the imports.
+func stripComments(src []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '#' || (src[i] == '/' && i+1 < len(src) && src[i+1] == '/'):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				sb.WriteByte('\n')
+			}
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end == -1 {
+				i = len(src)
+			} else {
+				i += end + 3
+			}
+			sb.WriteByte(' ')
+		case bytes.HasPrefix(src[i:], []byte("|||")):
+			end := bytes.Index(src[i+3:], []byte("|||"))
+			if end == -1 {
+				i = len(src)
+			} else {
+				i += end + 5
+			}
+			sb.WriteString(`""`)
+		case src[i] == '"' || src[i] == '\'':
+			quote := src[i]
+			sb.WriteByte(quote)
+			for i++; i < len(src) && src[i] != quote; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i])
+					i++
+				}
+				sb.WriteByte(src[i])
+			}
+			if i < len(src) {
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,33 @@
+package jsonnet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	f := parseFile("main.jsonnet", []byte(`// local old = import "commented.libsonnet";
+# local older = import "hashed.libsonnet";
+/* import "block.libsonnet" */
+local k = import 'k.libsonnet';
+local utils = import "lib/utils.libsonnet";
+local banner = |||
+  import "not/an/import.libsonnet"
+|||;
+{
+  config: importstr "config.yaml",
+  logo: importbin "logo.png",
+  name: "import \"escaped.libsonnet\"",
+  again: import "lib/utils.libsonnet",
+}
+`))
+
+	assert.Equal(t, "main.jsonnet", f.Name)
+	assert.Equal(t, []Import{
+		{Path: "k.libsonnet"},
+		{Path: "lib/utils.libsonnet"},
+		{Path: "config.yaml", Data: true},
+		{Path: "logo.png", Data: true},
+	}, f.Imports)
+}
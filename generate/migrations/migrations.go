@@ -0,0 +1,191 @@
+// Package migrations maintains a filegroup of the SQL migrations in a directory, listed in the order they're applied,
+// so services can depend on it to run them. The golang-migrate, goose and Flyway layouts are supported.
+package migrations
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Kind is the kind of rule that puku maintains for migrations
+var Kind = &kinds.Kind{
+	Name:     "filegroup",
+	Type:     kinds.Lib,
+	SrcsAttr: "srcs",
+}
+
+// Generator updates the migration filegroups in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"migrations"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the migrations in the directory, in the order they're applied. Migrations don't import anything.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	_, migrations, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(migrations))
+	for _, m := range migrations {
+		ret = append(ret, &language.File{Name: m.Name})
+	}
+	return ret, nil
+}
+
+// Resolve always fails, as migrations don't import anything
+func (g *Generator) Resolve(_ *config.Config, _, imp string) (string, error) {
+	return "", fmt.Errorf("migrations can't import %v", imp)
+}
+
+// Update generates a filegroup for the migrations in the directory, if there isn't one already, and sets its srcs to
+// the migrations in the order they're applied. Anything else in its srcs is kept after them, other than files that no
+// longer exist.
+func (g *Generator) Update(_ *config.Config, dir string) error {
+	layout, migrations, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(migrations) == 0 {
+		return nil
+	}
+
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+	expr, err := g.findFilegroup(file, dir, migrations)
+	if err != nil {
+		return err
+	}
+	if expr == nil {
+		name := "migrations"
+		if edit.FindTargetByName(file, name) != nil {
+			name = "sql_migrations"
+		}
+		expr = edit.NewRuleExpr(Kind.Name, name)
+		file.Stmt = append(file.Stmt, expr.Call)
+	}
+	rule := edit.NewRule(expr, Kind, dir)
+	if _, ok := rule.Attr(rule.SrcsAttr()).(*build.CallExpr); ok {
+		log.Warningf("%v globs its srcs, so can't list the %v migrations in %v in order", rule.Label(), layout, dir)
+		return nil
+	}
+
+	isMigration := make(map[string]bool, len(migrations))
+	srcs := make([]string, 0, len(migrations))
+	for _, m := range migrations {
+		isMigration[m.Name] = true
+		srcs = append(srcs, m.Name)
+	}
+	for _, src := range rule.AttrStrings(rule.SrcsAttr()) {
+		if isMigration[src] {
+			continue
+		}
+		if !isLabel(src) {
+			if _, err := os.Stat(filepath.Join(dir, src)); err != nil {
+				continue // The src doesn't exist, e.g. it's a migration that's been squashed
+			}
+		}
+		srcs = append(srcs, src)
+	}
+	rule.SetOrDeleteAttr(rule.SrcsAttr(), srcs)
+
+	// SetOrDeleteAttr keeps the existing srcs where they are, so put them back in order, keeping their comments
+	position := make(map[string]int, len(srcs))
+	for i, src := range srcs {
+		position[src] = i
+	}
+	list := rule.Attr(rule.SrcsAttr()).(*build.ListExpr).List
+	sort.SliceStable(list, func(i, j int) bool {
+		return position[list[i].(*build.StringExpr).Value] < position[list[j].(*build.StringExpr).Value]
+	})
+	keepOrder(rule.Rule)
+	return nil
+}
+
+// doNotSort is the comment that stops buildifier, and so plz fmt, from sorting the srcs of the filegroup
+const doNotSort = "# Migrations are applied in this order, do not sort"
+
+// keepOrder marks the srcs of the filegroup so they aren't sorted when the BUILD file is formatted
+func keepOrder(rule *build.Rule) {
+	for _, arg := range rule.Call.List {
+		assign, ok := arg.(*build.AssignExpr)
+		if !ok {
+			continue
+		}
+		if ident, ok := assign.LHS.(*build.Ident); !ok || ident.Name != Kind.SrcsAttr {
+			continue
+		}
+		for _, c := range append(assign.Comments.Before, assign.Comments.Suffix...) {
+			if strings.Contains(strings.ToLower(c.Token), "do not sort") {
+				return
+			}
+		}
+		assign.Comments.Before = append(assign.Comments.Before, build.Comment{Token: doNotSort})
+	}
+}
+
+// findFilegroup returns the filegroup in the package for the migrations. This is the first one with any of them in its
+// srcs, or one named migrations, or nil if there isn't one.
+func (g *Generator) findFilegroup(file *build.File, dir string, migrations []*Migration) (*build.Rule, error) {
+	names := make(map[string]bool, len(migrations))
+	for _, m := range migrations {
+		names[m.Name] = true
+	}
+	var named *build.Rule
+	for _, expr := range file.Rules(Kind.Name) {
+		srcs, err := g.eval.EvalGlobs(dir, expr, Kind.SrcsAttr)
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range srcs {
+			if names[src] {
+				return expr, nil
+			}
+		}
+		if expr.Name() == "migrations" {
+			named = expr
+		}
+	}
+	return named, nil
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
@@ -0,0 +1,93 @@
+package migrations
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"db/migrations/1_create_users.up.sql":   "",
+		"db/migrations/1_create_users.down.sql": "",
+		"db/migrations/2_add_email.up.sql":      "",
+		"db/migrations/2_add_email.down.sql":    "",
+		"db/migrations/10_add_index.up.sql":     "",
+		"db/migrations/10_add_index.down.sql":   "",
+		"db/migrations/BUILD":                   "go_library(\n    name = \"migrations\",\n    srcs = [\"migrations.go\"],\n)\n",
+		"api/migrations/V1__create_users.sql":   "",
+		"api/migrations/V2__add_email.sql":      "",
+		"api/migrations/seed.sql":               "",
+		"api/migrations/BUILD": `filegroup(
+    name = "schema",
+    srcs = [
+        "V2__add_email.sql",
+        "V0__squashed.sql",
+        "seed.sql",
+        "//common:schema",
+    ],
+    visibility = ["//api/..."],
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+
+		t.Run("generates an ordered filegroup", func(t *testing.T) {
+			require.NoError(t, g.Update(&config.Config{}, "db/migrations"))
+			file, err := g.graph.LoadFile("db/migrations")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "sql_migrations")
+			require.NotNil(t, rule)
+			assert.Equal(t, "filegroup", rule.Kind())
+			assert.Equal(t, []string{
+				"1_create_users.up.sql",
+				"1_create_users.down.sql",
+				"2_add_email.up.sql",
+				"2_add_email.down.sql",
+				"10_add_index.up.sql",
+				"10_add_index.down.sql",
+			}, rule.AttrStrings("srcs"))
+
+			formatted := string(build.Format(file))
+			assert.Contains(t, formatted, doNotSort)
+			assert.Less(t, strings.Index(formatted, `"2_add_email.up.sql"`), strings.Index(formatted, `"10_add_index.up.sql"`))
+		})
+
+		t.Run("updates an existing filegroup", func(t *testing.T) {
+			require.NoError(t, g.Update(&config.Config{}, "api/migrations"))
+			file, err := g.graph.LoadFile("api/migrations")
+			require.NoError(t, err)
+			require.Len(t, file.Rules("filegroup"), 1)
+			rule := edit.FindTargetByName(file, "schema")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{
+				"V1__create_users.sql",
+				"V2__add_email.sql",
+				"seed.sql",
+				"//common:schema",
+			}, rule.AttrStrings("srcs"))
+		})
+
+		t.Run("scans migrations in order", func(t *testing.T) {
+			files, err := g.Scan("api/migrations")
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			assert.Equal(t, "V1__create_users.sql", files[0].Name)
+			assert.Equal(t, "V2__add_email.sql", files[1].Name)
+		})
+	})
+}
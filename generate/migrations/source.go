@@ -0,0 +1,141 @@
+package migrations
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Layout is a naming convention for migrations, from one of the popular migration tools
+type Layout string
+
+const (
+	// GolangMigrate is golang-migrate's layout, e.g. 1_create_users.up.sql and 1_create_users.down.sql
+	GolangMigrate Layout = "golang-migrate"
+	// Goose is goose's layout, e.g. 20230101120000_create_users.sql with -- +goose Up and Down annotations
+	Goose Layout = "goose"
+	// Flyway is Flyway's layout, e.g. V1__create_users.sql, U1__create_users.sql for undoing it, and R__views.sql for
+	// repeatable migrations that run after the versioned ones
+	Flyway Layout = "flyway"
+)
+
+// Migration is a single migration file
+type Migration struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Version is the version of the migration, which is empty for Flyway's repeatable migrations
+	Version string
+	// order breaks ties between migrations of the same version, e.g. the up migration comes before the down one
+	order int
+}
+
+var (
+	golangMigrateName = regexp.MustCompile(`^(\d+)_.*\.(up|down)\.sql$`)
+	gooseName         = regexp.MustCompile(`^(\d+)_.*\.sql$`)
+	flywayName        = regexp.MustCompile(`^([VU])(\d+(?:[._]\d+)*)__.*\.sql$`)
+	flywayRepeatable  = regexp.MustCompile(`^R__.*\.sql$`)
+	gooseAnnotation   = []byte("+goose Up")
+)
+
+// IsSource returns whether the file could be a migration
+func IsSource(name string) bool {
+	return filepath.Ext(name) == ".sql"
+}
+
+// ImportDir reads the migrations in the given directory, and returns them in the order they're applied along with the
+// layout they follow. The layout is empty if the directory doesn't contain migrations. Flyway's layout is the most
+// specific, so takes precedence, then golang-migrate's. Goose's names are just a version and a description, so files
+// are only taken to be goose migrations if they have its annotations.
+func ImportDir(dir string) (Layout, []*Migration, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", nil, err
+	}
+
+	found := map[Layout][]*Migration{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !IsSource(name) {
+			continue
+		}
+		if match := flywayName.FindStringSubmatch(name); match != nil {
+			order := 0
+			if match[1] == "U" {
+				order = 1
+			}
+			found[Flyway] = append(found[Flyway], &Migration{Name: name, Version: match[2], order: order})
+		} else if flywayRepeatable.MatchString(name) {
+			found[Flyway] = append(found[Flyway], &Migration{Name: name})
+		} else if match := golangMigrateName.FindStringSubmatch(name); match != nil {
+			order := 0
+			if match[2] == "down" {
+				order = 1
+			}
+			found[GolangMigrate] = append(found[GolangMigrate], &Migration{Name: name, Version: match[1], order: order})
+		} else if match := gooseName.FindStringSubmatch(name); match != nil {
+			bs, err := os.ReadFile(filepath.Join(dir, name))
+			if err != nil {
+				return "", nil, err
+			}
+			if bytes.Contains(bs, gooseAnnotation) {
+				found[Goose] = append(found[Goose], &Migration{Name: name, Version: match[1]})
+			}
+		}
+	}
+
+	for _, layout := range []Layout{Flyway, GolangMigrate, Goose} {
+		if migrations := found[layout]; len(migrations) > 0 {
+			sortMigrations(migrations)
+			return layout, migrations, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// sortMigrations sorts migrations into the order they're applied. Versions are compared numerically, so 10 comes after
+// 9, and repeatable migrations come last, ordered by name.
+func sortMigrations(migrations []*Migration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		a, b := migrations[i], migrations[j]
+		if (a.Version == "") != (b.Version == "") {
+			return b.Version == ""
+		}
+		if c := compareVersions(a.Version, b.Version); c != 0 {
+			return c < 0
+		}
+		if a.order != b.order {
+			return a.order < b.order
+		}
+		return a.Name < b.Name
+	})
+}
+
+// compareVersions compares two versions made of numbers separated by dots or underscores, e.g. 1.2 or 1_2, returning
+// -1, 0 or 1. The numbers can be too big to parse, e.g. timestamps, so they're compared by length and then digit by
+// digit.
+func compareVersions(a, b string) int {
+	split := func(r rune) bool { return r == '.' || r == '_' }
+	as, bs := strings.FieldsFunc(a, split), strings.FieldsFunc(b, split)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, y := strings.TrimLeft(as[i], "0"), strings.TrimLeft(bs[i], "0")
+		if len(x) != len(y) {
+			if len(x) < len(y) {
+				return -1
+			}
+			return 1
+		}
+		if c := strings.Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,100 @@
+package migrations
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImportDir(t *testing.T) {
+	write := func(dir string, files map[string]string) string {
+		dir = filepath.Join(t.TempDir(), dir)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		for name, content := range files {
+			require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+		}
+		return dir
+	}
+	names := func(migrations []*Migration) []string {
+		ret := make([]string, 0, len(migrations))
+		for _, m := range migrations {
+			ret = append(ret, m.Name)
+		}
+		return ret
+	}
+
+	t.Run("golang-migrate", func(t *testing.T) {
+		dir := write("migrate", map[string]string{
+			"10_add_index.up.sql":     "",
+			"10_add_index.down.sql":   "",
+			"9_create_users.down.sql": "",
+			"9_create_users.up.sql":   "",
+			"README.md":               "",
+		})
+		layout, migrations, err := ImportDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, GolangMigrate, layout)
+		assert.Equal(t, []string{
+			"9_create_users.up.sql",
+			"9_create_users.down.sql",
+			"10_add_index.up.sql",
+			"10_add_index.down.sql",
+		}, names(migrations))
+	})
+
+	t.Run("goose", func(t *testing.T) {
+		dir := write("goose", map[string]string{
+			"20230102000000_add_email.sql":    "-- +goose Up\nALTER TABLE users ADD email TEXT;\n",
+			"20230101000000_create_users.sql": "-- +goose Up\nCREATE TABLE users (id INT);\n-- +goose Down\nDROP TABLE users;\n",
+			"00001_seed.sql":                  "INSERT INTO users VALUES (1);\n",
+		})
+		layout, migrations, err := ImportDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, Goose, layout)
+		assert.Equal(t, []string{"20230101000000_create_users.sql", "20230102000000_add_email.sql"}, names(migrations))
+	})
+
+	t.Run("flyway", func(t *testing.T) {
+		dir := write("flyway", map[string]string{
+			"R__views.sql":           "",
+			"V1_10__add_index.sql":   "",
+			"V1_2__add_email.sql":    "",
+			"U1_2__add_email.sql":    "",
+			"V1__create_users.sql":   "",
+			"R__functions.sql":       "",
+			"V2.0__rename_table.sql": "",
+		})
+		layout, migrations, err := ImportDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, Flyway, layout)
+		assert.Equal(t, []string{
+			"V1__create_users.sql",
+			"V1_2__add_email.sql",
+			"U1_2__add_email.sql",
+			"V1_10__add_index.sql",
+			"V2.0__rename_table.sql",
+			"R__functions.sql",
+			"R__views.sql",
+		}, names(migrations))
+	})
+
+	t.Run("not migrations", func(t *testing.T) {
+		dir := write("queries", map[string]string{"get_user.sql": "", "1_report.sql": "SELECT 1;\n"})
+		layout, migrations, err := ImportDir(dir)
+		require.NoError(t, err)
+		assert.Equal(t, Layout(""), layout)
+		assert.Empty(t, migrations)
+	})
+}
+
+func TestCompareVersions(t *testing.T) {
+	assert.Equal(t, -1, compareVersions("9", "10"))
+	assert.Equal(t, 1, compareVersions("1.10", "1.2"))
+	assert.Equal(t, 0, compareVersions("1_2", "1.2"))
+	assert.Equal(t, 0, compareVersions("001", "1"))
+	assert.Equal(t, -1, compareVersions("1", "1.1"))
+	assert.Equal(t, -1, compareVersions("20230101000000000000", "20230101000000000001"))
+}
@@ -0,0 +1,34 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileNameConstraint(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "foo.go"},
+		{name: "linux.go"},
+		{name: "foo_bar.go"},
+		{name: "foo_linux.go", expected: "linux"},
+		{name: "foo_arm64.go", expected: "arm64"},
+		{name: "foo_linux_arm64.go", expected: "linux && arm64"},
+		{name: "foo_linux_test.go", expected: "linux"},
+		{name: "foo_amd64_linux.go", expected: "linux"},
+		{name: "linux_amd64.go", expected: "amd64"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			expr := fileNameConstraint(tc.name)
+			if tc.expected == "" {
+				assert.Nil(t, expr)
+				return
+			}
+			assert.Equal(t, tc.expected, expr.String())
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package generate
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+)
+
+var goPackageRE = regexp.MustCompile(`(?m)^\s*option\s+go_package\s*=\s*"([^"]+)"\s*;`)
+
+// protoFile is a .proto file in the repo that sets the go_package option
+type protoFile struct {
+	dir, name string
+}
+
+// protoTarget resolves an import to the target that generates it from a .proto file with a matching go_package
+// option. The repo is only scanned for .proto files the first time this is needed. Returns an empty string if there's
+// no such target.
+func (u *updater) protoTarget(importPath string) (string, error) {
+	if u.protoPackages == nil {
+		protoPackages, err := findGoPackages(".")
+		if err != nil {
+			return "", err
+		}
+		u.protoPackages = protoPackages
+	}
+
+	for _, proto := range u.protoPackages[importPath] {
+		file, err := u.graph.LoadFile(proto.dir)
+		if err != nil {
+			return "", err
+		}
+		conf, err := config.ReadConfig(proto.dir)
+		if err != nil {
+			return "", err
+		}
+		for _, rule := range file.Rules("") {
+			kind := conf.GetKind(rule.Kind())
+			if kind == nil || !kind.NonGoSources {
+				continue
+			}
+			srcs, err := u.eval.EvalGlobs(proto.dir, rule, "srcs")
+			if err != nil {
+				return "", err
+			}
+			if contains(srcs, proto.name) {
+				return edit.BuildTarget(rule.Name(), proto.dir, ""), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// findGoPackages walks the repo for .proto files, returning them keyed by the Go import path in their go_package option
+func findGoPackages(root string) (map[string][]protoFile, error) {
+	protoPackages := map[string][]protoFile{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (strings.HasPrefix(d.Name(), ".") || d.Name() == "plz-out") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".proto" {
+			return nil
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		match := goPackageRE.FindSubmatch(bs)
+		if match == nil {
+			return nil
+		}
+		// The go_package can include the package name after a semicolon, e.g. "example.com/foo/foopb;foopb"
+		importPath, _, _ := strings.Cut(string(match[1]), ";")
+		protoPackages[importPath] = append(protoPackages[importPath], protoFile{
+			dir:  filepath.Dir(path),
+			name: filepath.Base(path),
+		})
+		return nil
+	})
+	return protoPackages, err
+}
@@ -0,0 +1,47 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestProtoGoPackage(t *testing.T) {
+	files := map[string]string{
+		"proto/foo/foo.proto":             "syntax = \"proto3\";\n\noption go_package = \"example.com/gen/foopb;foopb\";\n",
+		"proto/foo/bar.proto":             "syntax = \"proto3\";\n\noption go_package = \"example.com/gen/barpb\";\n",
+		"proto/foo/BUILD":                 "proto_library(\n    name = \"foo_proto\",\n    srcs = [\"foo.proto\"],\n)\n\ngrpc_library(\n    name = \"bar_proto\",\n    srcs = [\"bar.proto\"],\n)\n",
+		"proto/unbuilt/baz.proto":         "option go_package = \"example.com/gen/bazpb\";\n",
+		"plz-out/gen/proto/foo/foo.proto": "option go_package = \"example.com/gen/foopb\";\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		protoPackages, err := findGoPackages(".")
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]protoFile{
+			"example.com/gen/foopb": {{dir: "proto/foo", name: "foo.proto"}},
+			"example.com/gen/barpb": {{dir: "proto/foo", name: "bar.proto"}},
+			"example.com/gen/bazpb": {{dir: "proto/unbuilt", name: "baz.proto"}},
+		}, protoPackages)
+
+		u := newUpdater(repotest.PleaseConfig(), options.TestOptions)
+		conf := new(config.Config)
+
+		dep, err := u.resolveImport(conf, "example.com/gen/foopb")
+		require.NoError(t, err)
+		assert.Equal(t, "//proto/foo:foo_proto", dep)
+
+		dep, err = u.resolveImport(conf, "example.com/gen/barpb")
+		require.NoError(t, err)
+		assert.Equal(t, "//proto/foo:bar_proto", dep)
+
+		dep, err = u.protoTarget("example.com/gen/bazpb")
+		require.NoError(t, err)
+		assert.Equal(t, "", dep)
+	})
+}
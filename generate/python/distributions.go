@@ -0,0 +1,64 @@
+package python
+
+import (
+	"regexp"
+	"strings"
+)
+
+// moduleDistributions maps modules to the distribution on PyPI that provides them, for popular packages where these are
+// named differently. Others can be configured with knownTargets.
+var moduleDistributions = map[string]string{
+	"attr":                  "attrs",
+	"bs4":                   "beautifulsoup4",
+	"Crypto":                "pycryptodome",
+	"cv2":                   "opencv-python",
+	"dateutil":              "python-dateutil",
+	"docx":                  "python-docx",
+	"dotenv":                "python-dotenv",
+	"fitz":                  "PyMuPDF",
+	"gi":                    "PyGObject",
+	"git":                   "GitPython",
+	"google.cloud.bigquery": "google-cloud-bigquery",
+	"google.cloud.pubsub":   "google-cloud-pubsub",
+	"google.cloud.storage":  "google-cloud-storage",
+	"google.protobuf":       "protobuf",
+	"grpc":                  "grpcio",
+	"jose":                  "python-jose",
+	"jwt":                   "PyJWT",
+	"kafka":                 "kafka-python",
+	"magic":                 "python-magic",
+	"multipart":             "python-multipart",
+	"MySQLdb":               "mysqlclient",
+	"nacl":                  "PyNaCl",
+	"OpenSSL":               "pyOpenSSL",
+	"PIL":                   "Pillow",
+	"pkg_resources":         "setuptools",
+	"pptx":                  "python-pptx",
+	"serial":                "pyserial",
+	"skimage":               "scikit-image",
+	"sklearn":               "scikit-learn",
+	"slugify":               "python-slugify",
+	"usb":                   "pyusb",
+	"websocket":             "websocket-client",
+	"win32api":              "pywin32",
+	"yaml":                  "PyYAML",
+	"zmq":                   "pyzmq",
+}
+
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// TargetName returns the name of the pip_library for a distribution. Distribution names are normalised as described in
+// PEP 503, but with underscores, so e.g. PyYAML is pyyaml, and python-dateutil is python_dateutil.
+func TargetName(distribution string) string {
+	return nameSeparators.ReplaceAllString(strings.ToLower(distribution), "_")
+}
+
+// distribution returns the distribution that provides a module, if it's known to be named differently to the module
+func distribution(parts []string) string {
+	for i := len(parts); i > 0; i-- {
+		if dist, ok := moduleDistributions[strings.Join(parts[:i], ".")]; ok {
+			return dist
+		}
+	}
+	return ""
+}
@@ -0,0 +1,249 @@
+package python
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// File represents a single Python source file
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Imports are the dotted paths of the modules the file imports. Relative imports are made absolute, and names
+	// imported with `from x import y` are recorded as x.y, as y may be a submodule.
+	Imports []string
+	// IsMain is set when the file runs code under `if __name__ == "__main__":`
+	IsMain bool
+}
+
+// IsTest returns whether the file contains tests, i.e. it follows pytest's test_*.py or *_test.py naming conventions, or
+// it's a conftest.py providing fixtures to them
+func (f *File) IsTest() bool {
+	return strings.HasPrefix(f.Name, "test_") || strings.HasSuffix(f.Name, "_test.py") || f.Name == "conftest.py"
+}
+
+// IsBinary returns whether the file is a script that should be its own python_binary
+func (f *File) IsBinary() bool {
+	return f.IsMain && !f.IsTest()
+}
+
+var mainGuard = regexp.MustCompile(`^if\s*\(?\s*(__name__\s*==\s*['"]__main__['"]|['"]__main__['"]\s*==\s*__name__)\s*\)?\s*:`)
+
+// ImportDir imports the .py files in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || filepath.Ext(info.Name()) != ".py" {
+			continue
+		}
+		f, err := importFile(dir, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = f
+	}
+	return ret, nil
+}
+
+func importFile(dir, src string) (*File, error) {
+	bs, err := os.ReadFile(filepath.Join(dir, src))
+	if err != nil {
+		return nil, err
+	}
+	f := &File{Name: src}
+
+	seen := map[string]bool{}
+	for _, stmt := range statements(bs) {
+		if mainGuard.MatchString(stmt) {
+			f.IsMain = true
+			continue
+		}
+		for _, i := range parseImport(stmt, packagePath(dir)) {
+			if !seen[i] {
+				seen[i] = true
+				f.Imports = append(f.Imports, i)
+			}
+		}
+	}
+	sort.Strings(f.Imports)
+	return f, nil
+}
+
+// packagePath returns the path elements of the package in the directory, which relative imports are relative to
+func packagePath(dir string) []string {
+	dir = filepath.Clean(dir)
+	if dir == "." {
+		return nil
+	}
+	return strings.Split(filepath.ToSlash(dir), "/")
+}
+
+// parseImport returns the modules imported by an import statement, or nil if it isn't one. pkg is the package the file
+// is in, as a list of its path elements.
+func parseImport(stmt string, pkg []string) []string {
+	if rest, ok := cutKeyword(stmt, "import"); ok {
+		return importNames(rest)
+	}
+
+	rest, ok := cutKeyword(stmt, "from")
+	if !ok {
+		return nil
+	}
+	module, names, ok := cutMiddleKeyword(rest, "import")
+	if !ok {
+		return nil
+	}
+	module = strings.Join(strings.Fields(module), "")
+
+	// Relative imports are relative to the package the file is in, with each extra dot going up a level
+	dots := len(module) - len(strings.TrimLeft(module, "."))
+	if dots > 0 {
+		if dots-1 > len(pkg) {
+			return nil // This goes above the repo root
+		}
+		base := pkg[:len(pkg)-(dots-1)]
+		if rest := module[dots:]; rest != "" {
+			base = append(append([]string{}, base...), rest)
+		}
+		module = strings.Join(base, ".")
+	}
+
+	var ret []string
+	for _, name := range importNames(strings.Trim(strings.TrimSpace(names), "()")) {
+		switch {
+		case name == "*":
+			if module != "" {
+				ret = append(ret, module)
+			}
+		case module == "":
+			ret = append(ret, name)
+		default:
+			ret = append(ret, module+"."+name)
+		}
+	}
+	return ret
+}
+
+// cutKeyword returns the rest of the statement after the keyword, if it starts with it
+func cutKeyword(stmt, keyword string) (string, bool) {
+	stmt = strings.TrimSpace(stmt)
+	if !strings.HasPrefix(stmt, keyword) {
+		return "", false
+	}
+	rest := stmt[len(keyword):]
+	if rest == "" || !isSpace(rest[0]) {
+		return "", false
+	}
+	return rest, true
+}
+
+// cutMiddleKeyword is like cutKeyword but splits the statement around a keyword in the middle of it, as in
+// `from x import y`
+func cutMiddleKeyword(stmt, keyword string) (string, string, bool) {
+	fields := strings.Fields(stmt)
+	for i, f := range fields {
+		if f == keyword {
+			return strings.Join(fields[:i], " "), strings.Join(fields[i+1:], " "), true
+		}
+	}
+	return "", "", false
+}
+
+// importNames splits a comma separated list of imported names, dropping any aliases
+func importNames(s string) []string {
+	var ret []string
+	for _, part := range strings.Split(s, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		ret = append(ret, fields[0])
+	}
+	return ret
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\f'
+}
+
+// statements splits Python source into its simple statements. Comments and line continuations are removed, and
+// statements spanning lines inside brackets are joined onto one line. This is enough to find import statements without
+// a full parser.
+func statements(src []byte) []string {
+	var stmts []string
+	var current strings.Builder
+	depth := 0
+
+	end := func() {
+		if stmt := strings.TrimSpace(current.String()); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+		current.Reset()
+	}
+
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '#':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			i--
+		case c == '\'' || c == '"':
+			n := stringLen(src[i:])
+			current.Write(src[i : i+n])
+			i += n - 1
+		case c == '\\' && i+1 < len(src) && src[i+1] == '\n':
+			current.WriteByte(' ')
+			i++
+		case c == '\n':
+			if depth > 0 {
+				current.WriteByte(' ')
+				continue
+			}
+			end()
+		case c == ';' && depth == 0:
+			end()
+		case c == '(' || c == '[' || c == '{':
+			depth++
+			current.WriteByte(c)
+		case c == ')' || c == ']' || c == '}':
+			if depth > 0 {
+				depth--
+			}
+			current.WriteByte(c)
+		default:
+			current.WriteByte(c)
+		}
+	}
+	end()
+	return stmts
+}
+
+// stringLen returns the length of the string literal at the start of src, including its quotes
+func stringLen(src []byte) int {
+	quote := src[:1]
+	if len(src) >= 3 && src[1] == src[0] && src[2] == src[0] {
+		quote = src[:3]
+	}
+	for i := len(quote); i < len(src); i++ {
+		switch {
+		case src[i] == '\\':
+			i++
+		case src[i] == '\n' && len(quote) == 1:
+			return i // Unterminated string, so stop at the end of the line
+		case bytes.HasPrefix(src[i:], quote):
+			return i + len(quote)
+		}
+	}
+	return len(src)
+}
@@ -0,0 +1,96 @@
+package python
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImport(t *testing.T) {
+	pkg := []string{"foo", "bar"}
+	testCases := []struct {
+		name     string
+		stmt     string
+		expected []string
+	}{
+		{name: "import", stmt: "import os", expected: []string{"os"}},
+		{name: "multiple imports with aliases", stmt: "import foo.baz as baz, numpy as np", expected: []string{"foo.baz", "numpy"}},
+		{name: "from import", stmt: "from foo.baz import qux, quux as q", expected: []string{"foo.baz.qux", "foo.baz.quux"}},
+		{name: "parenthesised from import", stmt: "from foo.baz import (qux, quux,)", expected: []string{"foo.baz.qux", "foo.baz.quux"}},
+		{name: "wildcard", stmt: "from foo.baz import *", expected: []string{"foo.baz"}},
+		{name: "relative to package", stmt: "from . import baz", expected: []string{"foo.bar.baz"}},
+		{name: "relative module", stmt: "from .baz import qux", expected: []string{"foo.bar.baz.qux"}},
+		{name: "relative to parent", stmt: "from ..baz import qux", expected: []string{"foo.baz.qux"}},
+		{name: "above the repo root", stmt: "from .... import qux", expected: nil},
+		{name: "not an import", stmt: "important = True", expected: nil},
+		{name: "from without import", stmt: "from_x = 1", expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, parseImport(tc.stmt, pkg))
+		})
+	}
+}
+
+func TestStatements(t *testing.T) {
+	src := `"""Module docstring.
+
+import not_an_import
+"""
+import os  # a comment
+from foo import (
+    bar,  # why not
+    baz,
+)
+import sys; import json
+x = "import nope" \
+    + 'from nope import nope'
+
+if __name__ == "__main__":
+    main()
+`
+	assert.Equal(t, []string{
+		`"""Module docstring.
+
+import not_an_import
+"""`,
+		"import os",
+		"from foo import (     bar,       baz, )",
+		"import sys",
+		"import json",
+		`x = "import nope"      + 'from nope import nope'`,
+		`if __name__ == "__main__":`,
+		"main()",
+	}, statements([]byte(src)))
+}
+
+func TestImportDir(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+	write("lib.py", "import requests\nfrom . import util\n")
+	write("util.py", "")
+	write("test_lib.py", "import lib\n")
+	write("lib_test.py", "")
+	write("conftest.py", "")
+	write("main.py", "import lib\n\nif __name__ == '__main__':\n    lib.run()\n")
+	write("README.md", "")
+
+	files, err := ImportDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 6)
+
+	assert.Contains(t, files["lib.py"].Imports, "requests")
+	assert.False(t, files["lib.py"].IsTest())
+	assert.False(t, files["lib.py"].IsBinary())
+	assert.True(t, files["test_lib.py"].IsTest())
+	assert.True(t, files["lib_test.py"].IsTest())
+	assert.True(t, files["conftest.py"].IsTest())
+	assert.True(t, files["main.py"].IsBinary())
+	assert.Equal(t, []string{"lib"}, files["main.py"].Imports)
+}
@@ -0,0 +1,241 @@
+// Package python generates python_library, python_test and python_binary rules for the Python sources in a directory,
+// in the same way puku does for Go.
+package python
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the Python rules
+const Subinclude = "///python//build_defs:python"
+
+// Kinds are the kinds of rule that puku generates for Python sources
+var Kinds = map[string]*kinds.Kind{
+	"python_library": {
+		Name:     "python_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"python_test": {
+		Name:     "python_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+	"python_binary": {
+		Name:     "python_binary",
+		Type:     kinds.Bin,
+		SrcsAttr: "srcs",
+	},
+}
+
+// Generator updates the Python rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+
+	resolvedImports map[string]string
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf:         plzConf,
+		graph:           g,
+		eval:            e,
+		resolvedImports: map[string]string{},
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"python"},
+		IsSource: func(name string) bool { return filepath.Ext(name) == ".py" },
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Python sources in the directory
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		ret = append(ret, &language.File{Name: f.Name, Imports: f.Imports})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves an imported module to the target that provides it
+func (g *Generator) Resolve(conf *config.Config, _, module string) (string, error) {
+	return g.resolveImport(conf, module)
+}
+
+// Update allocates the Python sources in the directory to rules, creating them as necessary, and updates the deps of the
+// Python rules there based on what their sources import
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	return language.UpdateRules(g.plzConf, conf, g.graph, dir, &language.Rules{
+		Kinds:      Kinds,
+		Subinclude: func(*kinds.Kind) string { return Subinclude },
+		Allocate: func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error) {
+			return g.allocateSources(dir, file.Rules(""), files, rules)
+		},
+		UpdateDeps: func(rule *edit.Rule) error {
+			return g.updateRuleDeps(conf, rule, files)
+		},
+	})
+}
+
+// allocation allocates Python sources to rules. Scripts get a python_binary each, with the script as its main module.
+var allocation = &language.Allocation{
+	Kinds:  Kinds,
+	Lib:    "python_library",
+	Test:   "python_test",
+	Bin:    "python_binary",
+	Suffix: "_py",
+	Add: func(rule *edit.Rule, src string) {
+		if rule.Kind.Type == kinds.Bin {
+			rule.SetAttr("main", edit.NewStringExpr(src))
+		} else {
+			rule.AddSrc(src)
+		}
+	},
+}
+
+// allocateSources allocates the sources that don't belong to a rule yet. Scripts get a python_binary each, named after
+// the file. Tests and library modules go to the first test or library rule in the package, which is created if needed.
+func (g *Generator) allocateSources(dir string, existing []*build.Rule, files map[string]*File, rules []*edit.Rule) ([]*edit.Rule, error) {
+	allocated := map[string]bool{}
+	for _, rule := range rules {
+		srcs, err := g.ruleSrcs(rule)
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range srcs {
+			allocated[src] = true
+		}
+	}
+
+	srcs := map[string]language.Source{}
+	for name, f := range files {
+		if !allocated[name] {
+			srcs[name] = f
+		}
+	}
+	return language.AllocateSources(allocation, dir, existing, rules, srcs), nil
+}
+
+// ruleSrcs returns the sources of a rule, including the main module of binaries
+func (g *Generator) ruleSrcs(rule *edit.Rule) ([]string, error) {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return nil, err
+	}
+	if main := rule.AttrString("main"); rule.Kind.Type == kinds.Bin && main != "" {
+		srcs = append(srcs, main)
+	}
+	return srcs, nil
+}
+
+// updateRuleDeps sets the deps of the rule to the targets that its sources import
+func (g *Generator) updateRuleDeps(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.ruleSrcs(rule)
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	deps := map[string]bool{}
+	for _, src := range srcs {
+		if eval.LookLikeBuildLabel(src) {
+			continue
+		}
+		f := files[src]
+		if f == nil {
+			if src != rule.AttrString("main") {
+				rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			}
+			continue
+		}
+		for _, i := range f.Imports {
+			dep, err := g.resolveImport(conf, i)
+			if err != nil {
+				g.graph.Unresolved(label, i, err)
+				continue
+			}
+			if dep == "" || dep == label {
+				continue
+			}
+			deps[dep] = true
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, language.Shorten(rule.Dir, dep))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
+
+// resolveImport resolves an imported module to the target that provides it. It returns an empty string for modules in
+// the standard library.
+func (g *Generator) resolveImport(conf *config.Config, module string) (string, error) {
+	if t, ok := g.resolvedImports[module]; ok {
+		return t, nil
+	}
+	t, err := g.reallyResolveImport(conf, module)
+	if err != nil {
+		return "", err
+	}
+	g.resolvedImports[module] = t
+	return t, nil
+}
+
+func (g *Generator) reallyResolveImport(conf *config.Config, module string) (string, error) {
+	parts := strings.Split(module, ".")
+	for i := len(parts); i > 0; i-- {
+		if t := conf.GetKnownTarget(strings.Join(parts[:i], ".")); t != "" {
+			return t, nil
+		}
+	}
+
+	if IsStdlib(parts[0]) {
+		return "", nil
+	}
+
+	t, err := g.localTarget(parts)
+	if err != nil || t != "" {
+		return t, err
+	}
+	return g.thirdPartyTarget(conf.GetPythonThirdPartyDir(), parts)
+}
@@ -0,0 +1,120 @@
+package python
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"app/__init__.py":    "",
+		"app/models.py":      "import dataclasses\n",
+		"app/server.py":      "import os\nimport requests\nfrom app import models\nfrom lib.util import helper\nimport missing\n",
+		"app/test_server.py": "import pytest\nfrom app import server\n",
+		"app/run.py":         "from app.server import serve\n\nif __name__ == \"__main__\":\n    serve()\n",
+		"lib/util.py":        "",
+		"lib/BUILD":          "python_library(\n    name = \"utils\",\n    srcs = [\"util.py\"],\n)\n",
+		"mixed/BUILD":        "go_library(\n    name = \"mixed\",\n    srcs = [\"mixed.go\"],\n)\n",
+		"mixed/script.py":    "",
+		"third_party/python/BUILD": `pip_library(
+    name = "requests",
+)
+
+pip_library(
+    name = "pytest",
+)
+
+pip_library(
+    name = "pyyaml",
+    package_name = "PyYAML",
+)
+
+pip_library(
+    name = "dateutil",
+    package_name = "python-dateutil",
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := new(config.Config)
+
+		require.NoError(t, g.Update(conf, "app"))
+		file, err := g.graph.LoadFile("app")
+		require.NoError(t, err)
+
+		t.Run("generates a library", func(t *testing.T) {
+			lib := edit.FindTargetByName(file, "app")
+			require.NotNil(t, lib)
+			assert.Equal(t, "python_library", lib.Kind())
+			assert.Equal(t, []string{"__init__.py", "models.py", "server.py"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//lib:utils", "//third_party/python:requests"}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("generates a test", func(t *testing.T) {
+			test := edit.FindTargetByName(file, "app_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "python_test", test.Kind())
+			assert.Equal(t, []string{"test_server.py"}, test.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/python:pytest", ":app"}, test.AttrStrings("deps"))
+		})
+
+		t.Run("generates a binary for scripts", func(t *testing.T) {
+			bin := edit.FindTargetByName(file, "run")
+			require.NotNil(t, bin)
+			assert.Equal(t, "python_binary", bin.Kind())
+			assert.Equal(t, "run.py", bin.AttrString("main"))
+			assert.Equal(t, []string{":app"}, bin.AttrStrings("deps"))
+		})
+
+		t.Run("subincludes the python rules", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///python//build_defs:python", call.List[0].(*build.StringExpr).Value)
+		})
+
+		t.Run("doesn't clash with Go rules", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "mixed"))
+			file, err := g.graph.LoadFile("mixed")
+			require.NoError(t, err)
+			lib := edit.FindTargetByName(file, "mixed_py")
+			require.NotNil(t, lib)
+			assert.Equal(t, []string{"script.py"}, lib.AttrStrings("srcs"))
+		})
+
+		t.Run("resolves modules named differently to their distribution", func(t *testing.T) {
+			dep, err := g.resolveImport(conf, "yaml")
+			require.NoError(t, err)
+			assert.Equal(t, "//third_party/python:pyyaml", dep)
+
+			dep, err = g.resolveImport(conf, "dateutil.parser")
+			require.NoError(t, err)
+			assert.Equal(t, "//third_party/python:dateutil", dep)
+
+			_, err = g.resolveImport(conf, "sklearn")
+			assert.ErrorContains(t, err, "no target for sklearn")
+		})
+
+		t.Run("resolves known targets", func(t *testing.T) {
+			conf := &config.Config{KnownTargets: map[string]string{"google.protobuf": "//third_party/python:protobuf"}}
+			dep, err := g.resolveImport(conf, "google.protobuf.message")
+			require.NoError(t, err)
+			assert.Equal(t, "//third_party/python:protobuf", dep)
+		})
+	})
+}
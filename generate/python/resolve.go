@@ -0,0 +1,97 @@
+package python
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+)
+
+// localTarget resolves a module to a target in this repo. Modules are looked up relative to the repo root, trying the
+// full module path first as names imported from a package may be submodules. Returns an empty string if the module
+// isn't in the repo.
+func (g *Generator) localTarget(parts []string) (string, error) {
+	for i := len(parts); i > 0; i-- {
+		path := filepath.Join(parts[:i]...)
+		if isFile(path + ".py") {
+			return g.fileTarget(filepath.Dir(path), filepath.Base(path)+".py")
+		}
+		if isFile(filepath.Join(path, "__init__.py")) {
+			return g.fileTarget(path, "__init__.py")
+		}
+	}
+	return "", nil
+}
+
+// fileTarget returns the target that provides a source file. If no rule has it in its srcs yet, it's assumed it'll be
+// allocated to the package's library or test when puku updates that directory.
+func (g *Generator) fileTarget(dir, src string) (string, error) {
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+
+	for _, expr := range file.Rules("") {
+		kind, ok := Kinds[expr.Kind()]
+		if !ok {
+			continue
+		}
+		srcs, err := g.ruleSrcs(edit.NewRule(expr, kind, dir))
+		if err != nil {
+			return "", err
+		}
+		for _, s := range srcs {
+			if s == src {
+				if kind.Type == kinds.Bin {
+					return "", fmt.Errorf("%v is the main module of %v", filepath.Join(dir, src), edit.BuildTarget(expr.Name(), dir, ""))
+				}
+				return edit.BuildTarget(expr.Name(), dir, ""), nil
+			}
+		}
+	}
+
+	f, err := importFile(dir, src)
+	if err != nil {
+		return "", err
+	}
+	if f.IsBinary() {
+		return "", fmt.Errorf("%v is a script, so won't be in a library", filepath.Join(dir, src))
+	}
+	name := language.LibName(dir)
+	if f.IsTest() {
+		name += "_test"
+	}
+	return edit.BuildTarget(name, dir, ""), nil
+}
+
+// thirdPartyTarget resolves a module to the pip_library for it in the third party directory. This is the rule for the
+// distribution that provides the module, either by its name or its package_name.
+func (g *Generator) thirdPartyTarget(dir string, parts []string) (string, error) {
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+
+	names := []string{TargetName(parts[0])}
+	if dist := distribution(parts); dist != "" {
+		names = append([]string{TargetName(dist)}, names...)
+	}
+	for _, name := range names {
+		for _, rule := range file.Rules("") {
+			pkg := rule.AttrString("package_name")
+			if rule.Name() == name || (pkg != "" && TargetName(pkg) == name) {
+				return edit.BuildTarget(rule.Name(), dir, ""), nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no target for %v in %v", strings.Join(parts, "."), dir)
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,26 @@
+package python
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed stdlib_modules
+var stdlibModules string
+
+// stdlib contains the top level modules in the Python standard library, as listed in sys.stdlib_module_names
+var stdlib = map[string]struct{}{}
+
+func init() {
+	for _, mod := range strings.Split(stdlibModules, "\n") {
+		if mod := strings.TrimSpace(mod); mod != "" {
+			stdlib[mod] = struct{}{}
+		}
+	}
+}
+
+// IsStdlib returns whether the top level module is part of the Python standard library
+func IsStdlib(module string) bool {
+	_, ok := stdlib[module]
+	return ok
+}
@@ -0,0 +1,30 @@
+package generate
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// externalResolve runs the configured resolver to resolve an import that we couldn't resolve ourselves. The resolver
+// is passed the import path as its only argument, and should print the label of the target that provides it. Printing
+// nothing means the resolver couldn't resolve it either.
+func externalResolve(resolver, importPath string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(resolver, importPath)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("resolver %v failed: %v\n%v", resolver, err, stderr.String())
+	}
+
+	label := strings.TrimSpace(stdout.String())
+	if label == "" {
+		return "", nil
+	}
+	if !strings.HasPrefix(label, "//") && !strings.HasPrefix(label, "@") {
+		return "", fmt.Errorf("resolver %v returned %q for %v, which isn't an absolute build label", resolver, label, importPath)
+	}
+	return label, nil
+}
@@ -0,0 +1,57 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestExternalResolver(t *testing.T) {
+	resolver := filepath.Join(t.TempDir(), "resolve.sh")
+	script := `#!/bin/sh
+case "$1" in
+  example.com/internal/*) echo "//internal/${1#example.com/internal/}" ;;
+  example.com/relative) echo ":relative" ;;
+  example.com/broken) echo "oh no" >&2; exit 1 ;;
+esac
+`
+	require.NoError(t, os.WriteFile(resolver, []byte(script), 0755))
+
+	t.Run("resolves labels", func(t *testing.T) {
+		label, err := externalResolve(resolver, "example.com/internal/foo")
+		require.NoError(t, err)
+		assert.Equal(t, "//internal/foo", label)
+
+		label, err = externalResolve(resolver, "example.com/other")
+		require.NoError(t, err)
+		assert.Equal(t, "", label)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := externalResolve(resolver, "example.com/relative")
+		assert.ErrorContains(t, err, "isn't an absolute build label")
+
+		_, err = externalResolve(resolver, "example.com/broken")
+		assert.ErrorContains(t, err, "oh no")
+	})
+
+	t.Run("used when puku can't resolve the import", func(t *testing.T) {
+		u := newUpdater(new(please.Config), options.TestOptions)
+		u.usingGoModule = true
+		conf := &config.Config{Resolver: resolver}
+
+		dep, err := u.resolveImport(conf, "example.com/internal/foo")
+		require.NoError(t, err)
+		assert.Equal(t, "//internal/foo", dep)
+
+		_, err = u.resolveImport(conf, "example.com/other")
+		assert.ErrorContains(t, err, "module not found")
+	})
+}
@@ -0,0 +1,123 @@
+package rust
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+)
+
+// resolveCrate returns the target for the crate with the given name, or an empty string if there isn't one. Crates in
+// the repo take precedence over third party ones.
+func (g *Generator) resolveCrate(conf *config.Config, name string) (string, error) {
+	if g.localCrates == nil {
+		crates, err := g.indexLocalCrates()
+		if err != nil {
+			return "", err
+		}
+		g.localCrates = crates
+	}
+	if target, ok := g.localCrates[name]; ok {
+		return target, nil
+	}
+
+	dir := conf.GetRustThirdPartyDir()
+	if _, ok := g.thirdPartyCrates[dir]; !ok {
+		crates, err := g.indexThirdPartyCrates(dir)
+		if err != nil {
+			return "", err
+		}
+		g.thirdPartyCrates[dir] = crates
+	}
+	return g.thirdPartyCrates[dir][name], nil
+}
+
+// indexLocalCrates finds the library crates in the repo. Where puku hasn't generated a rust_library for a crate yet,
+// it's assumed it'll be named after the crate when it does.
+func (g *Generator) indexLocalCrates() (map[string]string, error) {
+	crates := map[string]string{}
+	err := filepath.WalkDir(".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != "." && (d.Name() == "plz-out" || strings.HasPrefix(d.Name(), ".")) {
+			return filepath.SkipDir
+		}
+		if !isFile(filepath.Join(path, libRoot)) {
+			return nil
+		}
+
+		file, err := g.graph.LoadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to parse BUILD files in %v: %v", path, err)
+		}
+		if rules := file.Rules("rust_library"); len(rules) > 0 {
+			name := rules[0].AttrString("crate_name")
+			if name == "" {
+				name = rules[0].Name()
+			}
+			crates[CrateName(name)] = edit.BuildTarget(rules[0].Name(), path, "")
+			return nil
+		}
+		name := crateName(path)
+		crates[name] = edit.BuildTarget(name, path, "")
+		return nil
+	})
+	return crates, err
+}
+
+// indexThirdPartyCrates finds the crates in the third party directory, by their crate_name or otherwise their name.
+// Where there are several versions of a crate, the one named after the crate is used.
+func (g *Generator) indexThirdPartyCrates(dir string) (map[string]string, error) {
+	crates := map[string]string{}
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return crates, nil
+	}
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+	for _, rule := range file.Rules("") {
+		name := rule.AttrString("crate_name")
+		if name == "" {
+			name = rule.Name()
+		}
+		if _, ok := crates[CrateName(name)]; !ok || rule.Name() == CrateName(name) {
+			crates[CrateName(name)] = edit.BuildTarget(rule.Name(), dir, "")
+		}
+	}
+	return crates, nil
+}
+
+var (
+	cargoTable = regexp.MustCompile(`^\s*\[([^\]]+)\]\s*$`)
+	cargoName  = regexp.MustCompile(`^\s*name\s*=\s*"([^"]+)"`)
+)
+
+// cargoPackageName returns the name of the package in a Cargo.toml, or an empty string if there isn't one
+func cargoPackageName(path string) string {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	table := ""
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		if match := cargoTable.FindStringSubmatch(scanner.Text()); match != nil {
+			table = strings.TrimSpace(match[1])
+		} else if match := cargoName.FindStringSubmatch(scanner.Text()); match != nil && table == "package" {
+			return match[1]
+		}
+	}
+	return ""
+}
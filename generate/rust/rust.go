@@ -0,0 +1,371 @@
+// Package rust generates rust_library, rust_binary and rust_test rules for the Rust crates in the repo, with deps on
+// the crates they use.
+package rust
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the Rust rules
+const Subinclude = "///rust//build_defs:rust"
+
+// Kinds are the kinds of rule that puku generates for Rust crates
+var Kinds = map[string]*kinds.Kind{
+	"rust_library": {
+		Name:     "rust_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"rust_binary": {
+		Name:     "rust_binary",
+		Type:     kinds.Bin,
+		SrcsAttr: "srcs",
+	},
+	"rust_test": {
+		Name:     "rust_test",
+		Type:     kinds.Test,
+		SrcsAttr: "srcs",
+	},
+}
+
+const (
+	libRoot = "lib.rs"
+	binRoot = "main.rs"
+)
+
+// builtinCrates are the crates that come with the compiler, along with the keywords that can start a path
+var builtinCrates = map[string]bool{
+	"std":        true,
+	"core":       true,
+	"alloc":      true,
+	"proc_macro": true,
+	"test":       true,
+	"crate":      true,
+	"self":       true,
+	"super":      true,
+	"Self":       true,
+}
+
+// Generator updates the Rust rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+
+	// localCrates maps the names of the crates in the repo to their library targets. This is populated the first time
+	// it's needed.
+	localCrates map[string]string
+	// thirdPartyCrates maps the names of third party crates to their targets, keyed by third party directory
+	thirdPartyCrates map[string]map[string]string
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf:          plzConf,
+		graph:            g,
+		eval:             e,
+		thirdPartyCrates: map[string]map[string]string{},
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"rust"},
+		IsSource: func(name string) bool { return filepath.Ext(name) == ".rs" },
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the sources of the crate rooted in the directory, if there is one. Their imports are the crates they use,
+// other than the builtin crates and the crate's own modules.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	if !IsCrateDir(dir) {
+		return nil, nil
+	}
+	files, err := crateSources(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := crateModules(files)
+	ret := make([]*language.File, 0, len(files))
+	for path, f := range files {
+		lf := &language.File{Name: path}
+		for _, name := range f.Uses {
+			if !builtinCrates[name] && !modules[name] {
+				lf.Imports = append(lf.Imports, name)
+			}
+		}
+		ret = append(ret, lf)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves the name of a crate to its target
+func (g *Generator) Resolve(conf *config.Config, _, name string) (string, error) {
+	if builtinCrates[name] {
+		return "", nil
+	}
+	t, err := g.resolveCrate(conf, name)
+	if err != nil {
+		return "", err
+	}
+	if t == "" {
+		return "", fmt.Errorf("no crate called %v", name)
+	}
+	return t, nil
+}
+
+// IsCrateDir returns whether the directory is the root of a crate, i.e. it contains a lib.rs or main.rs
+func IsCrateDir(dir string) bool {
+	return isFile(filepath.Join(dir, libRoot)) || isFile(filepath.Join(dir, binRoot))
+}
+
+// Update generates the rules for the crate rooted in the directory, if there is one. The crate's sources are the .rs
+// files in the directory and its subdirectories, up to the root of any other crate.
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	if !IsCrateDir(dir) {
+		return nil
+	}
+
+	files, err := crateSources(dir)
+	if err != nil {
+		return err
+	}
+
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+
+	rules := map[kinds.Type]*edit.Rule{}
+	for _, expr := range file.Rules("") {
+		if kind, ok := Kinds[expr.Kind()]; ok && rules[kind.Type] == nil {
+			rules[kind.Type] = edit.NewRule(expr, kind, dir)
+		}
+	}
+
+	name := crateName(dir)
+	newRule := func(kindType kinds.Type, kind, name string) {
+		if rules[kindType] != nil {
+			return
+		}
+		if edit.FindTargetByName(file, name) != nil {
+			name += "_rs" // Don't clash with the rules for other languages in this directory
+		}
+		rule := edit.NewRule(edit.NewRuleExpr(kind, name), Kinds[kind], dir)
+		file.Stmt = append(file.Stmt, rule.Call)
+		rules[kindType] = rule
+	}
+
+	hasLib, hasBin, hasTests := files[libRoot] != nil, files[binRoot] != nil, false
+	for _, f := range files {
+		hasTests = hasTests || f.HasTests
+	}
+	if hasLib {
+		newRule(kinds.Lib, "rust_library", name)
+	}
+	if hasBin {
+		binName := name
+		if hasLib {
+			binName = name + "_bin"
+		}
+		newRule(kinds.Bin, "rust_binary", binName)
+	}
+	if hasTests {
+		newRule(kinds.Test, "rust_test", name+"_test")
+	}
+
+	// The binary only gets main.rs when there's a library, as it can use the rest through that
+	srcs := map[kinds.Type][]string{}
+	for path := range files {
+		switch {
+		case path == binRoot && hasLib:
+			srcs[kinds.Bin] = append(srcs[kinds.Bin], path)
+		case hasLib:
+			srcs[kinds.Lib] = append(srcs[kinds.Lib], path)
+			srcs[kinds.Test] = append(srcs[kinds.Test], path)
+		case path != libRoot:
+			srcs[kinds.Bin] = append(srcs[kinds.Bin], path)
+			srcs[kinds.Test] = append(srcs[kinds.Test], path)
+		}
+	}
+
+	for kindType, rule := range rules {
+		if err := g.updateSrcs(rule, srcs[kindType], files); err != nil {
+			return err
+		}
+	}
+
+	if len(rules) > 0 && !g.plzConf.IsPreloaded(Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, Subinclude)
+	}
+
+	for _, rule := range rules {
+		if err := g.updateRuleDeps(conf, rule, files); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateSrcs adds any of the sources that the rule is missing, and removes any that no longer exist
+func (g *Generator) updateSrcs(rule *edit.Rule, srcs []string, files map[string]*File) error {
+	existing, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+	has := map[string]bool{}
+	for _, src := range existing {
+		has[src] = true
+		if files[src] == nil && !eval.LookLikeBuildLabel(src) {
+			rule.RemoveSrc(src)
+		}
+	}
+	sort.Strings(srcs)
+	for _, src := range srcs {
+		if !has[src] {
+			rule.AddSrc(src)
+		}
+	}
+	return nil
+}
+
+// updateRuleDeps sets the deps of the rule to the crates that its sources use
+func (g *Generator) updateRuleDeps(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	// Paths can start with the crate's own modules, which might share a name with a crate
+	modules := crateModules(files)
+
+	label := rule.Label()
+	deps := map[string]bool{}
+	for _, src := range srcs {
+		f := files[src]
+		if f == nil {
+			continue
+		}
+		for _, name := range append(append([]string{}, f.Uses...), f.Paths...) {
+			if builtinCrates[name] || modules[name] {
+				continue
+			}
+			dep, err := g.resolveCrate(conf, name)
+			if err != nil {
+				return err
+			}
+			if dep == "" {
+				// Paths mostly start with types, so we only expect to find crates for the names in use statements
+				if f.uses(name) {
+					g.graph.Unresolved(label, name, fmt.Errorf("no crate found for its use in %v", filepath.Join(rule.Dir, src)))
+				}
+				continue
+			}
+			if dep != label {
+				deps[dep] = true
+			}
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, labels.Shorten(dep, rule.Dir))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
+
+// crateName returns the name of the crate in the directory. This is the package name from its Cargo.toml if it has one,
+// either in the directory or its parent for the src directory of a cargo package. Otherwise, it's the directory name.
+func crateName(dir string) string {
+	manifests := []string{filepath.Join(dir, "Cargo.toml")}
+	if filepath.Base(dir) == "src" {
+		manifests = append(manifests, filepath.Join(filepath.Dir(dir), "Cargo.toml"))
+	}
+	for _, manifest := range manifests {
+		if name := cargoPackageName(manifest); name != "" {
+			return CrateName(name)
+		}
+	}
+	if dir == "." {
+		return "lib"
+	}
+	return CrateName(filepath.Base(dir))
+}
+
+// CrateName returns the name that a package is referred to by in Rust code, i.e. with hyphens replaced with underscores
+func CrateName(pkg string) string {
+	return strings.ReplaceAll(pkg, "-", "_")
+}
+
+// crateSources reads the .rs files in the crate rooted in the directory, keyed by their path relative to it
+func crateSources(dir string) (map[string]*File, error) {
+	files := map[string]*File{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (IsCrateDir(path) || d.Name() == "plz-out" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".rs" || !d.Type().IsRegular() {
+			return nil
+		}
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = parseFile(rel, bs)
+		return nil
+	})
+	return files, err
+}
+
+// crateModules returns the names of the modules in the crate
+func crateModules(files map[string]*File) map[string]bool {
+	modules := map[string]bool{}
+	for path, f := range files {
+		modules[strings.TrimSuffix(filepath.Base(path), ".rs")] = true
+		for _, mod := range f.Mods {
+			modules[mod] = true
+		}
+	}
+	return modules
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
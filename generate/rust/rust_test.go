@@ -0,0 +1,106 @@
+package rust
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"app/Cargo.toml":             "[package]\nname = \"my-app\"\nversion = \"0.1.0\"\n\n[dependencies]\nname = \"not-this\"\n",
+		"app/src/lib.rs":             "pub mod server;\nuse util::helper;\n\n#[cfg(test)]\nmod tests {\n    use super::*;\n}\n",
+		"app/src/server.rs":          "use serde::Serialize;\nuse missing::Thing;\n\npub fn serve() -> String {\n    serde_json::to_string(&1).unwrap()\n}\n",
+		"app/src/server/handlers.rs": "use crate::server;\n",
+		"app/src/main.rs":            "use my_app::server;\n\nfn main() {\n    server::serve();\n}\n",
+		"util/lib.rs":                "pub fn helper() {}\n",
+		"util/BUILD":                 "rust_library(\n    name = \"utils\",\n    srcs = [\"lib.rs\"],\n    crate_name = \"util\",\n)\n",
+		"tool/main.rs":               "use clap::Parser;\n",
+		"tool/old.rs":                "",
+		"tool/BUILD":                 "rust_binary(\n    name = \"tool\",\n    srcs = [\n        \"main.rs\",\n        \"deleted.rs\",\n    ],\n)\n",
+		"third_party/rust/BUILD": `cargo_crate(
+    name = "serde",
+    version = "1.0.190",
+)
+
+cargo_crate(
+    name = "serde_json",
+    crate_name = "serde-json",
+    version = "1.0.108",
+)
+
+cargo_crate(
+    name = "clap",
+    version = "4.4.8",
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := new(config.Config)
+
+		require.NoError(t, g.Update(conf, "app/src"))
+		file, err := g.graph.LoadFile("app/src")
+		require.NoError(t, err)
+
+		t.Run("generates a library named after the package", func(t *testing.T) {
+			lib := edit.FindTargetByName(file, "my_app")
+			require.NotNil(t, lib)
+			assert.Equal(t, "rust_library", lib.Kind())
+			assert.Equal(t, []string{"lib.rs", "server.rs", "server/handlers.rs"}, lib.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/rust:serde", "//third_party/rust:serde_json", "//util:utils"}, lib.AttrStrings("deps"))
+		})
+
+		t.Run("generates a binary that uses the library", func(t *testing.T) {
+			bin := edit.FindTargetByName(file, "my_app_bin")
+			require.NotNil(t, bin)
+			assert.Equal(t, "rust_binary", bin.Kind())
+			assert.Equal(t, []string{"main.rs"}, bin.AttrStrings("srcs"))
+			assert.Equal(t, []string{":my_app"}, bin.AttrStrings("deps"))
+		})
+
+		t.Run("generates a test for the unit tests", func(t *testing.T) {
+			test := edit.FindTargetByName(file, "my_app_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "rust_test", test.Kind())
+			assert.Equal(t, []string{"lib.rs", "server.rs", "server/handlers.rs"}, test.AttrStrings("srcs"))
+		})
+
+		t.Run("subincludes the rust rules", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///rust//build_defs:rust", call.List[0].(*build.StringExpr).Value)
+		})
+
+		t.Run("updates existing rules", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "tool"))
+			file, err := g.graph.LoadFile("tool")
+			require.NoError(t, err)
+			bin := edit.FindTargetByName(file, "tool")
+			require.NotNil(t, bin)
+			assert.Equal(t, []string{"main.rs", "old.rs"}, bin.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//third_party/rust:clap"}, bin.AttrStrings("deps"))
+		})
+
+		t.Run("ignores directories that aren't crates", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "app"))
+			file, err := g.graph.LoadFile("app")
+			require.NoError(t, err)
+			assert.Empty(t, file.Stmt)
+		})
+	})
+}
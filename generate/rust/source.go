@@ -0,0 +1,200 @@
+package rust
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// File represents a single Rust source file
+type File struct {
+	// Path is the path to the file, relative to the crate's directory
+	Path string
+	// Uses are the first segments of the paths the file brings into scope with `use` or `extern crate`. These name
+	// either a crate or one of the crate's own modules.
+	Uses []string
+	// Paths are the first segments of any other paths in the file, e.g. serde_json in serde_json::to_string(). Most of
+	// these are types or modules, but paths can also start with the name of a crate.
+	Paths []string
+	// Mods are the modules declared in the file with `mod foo;` or `mod foo { ... }`
+	Mods []string
+	// HasTests is set when the file contains unit tests, i.e. a #[test] function or a #[cfg(test)] module
+	HasTests bool
+}
+
+var (
+	useStmt     = regexp.MustCompile(`\buse\s+(?:::\s*)?\{?\s*([A-Za-z_][A-Za-z0-9_]*)`)
+	useGroup    = regexp.MustCompile(`\buse\s+(?:::\s*)?\{([^;]*)\}\s*;`)
+	externCrate = regexp.MustCompile(`\bextern\s+crate\s+([A-Za-z_][A-Za-z0-9_]*)`)
+	pathStart   = regexp.MustCompile(`(^|[^A-Za-z0-9_:])([A-Za-z_][A-Za-z0-9_]*)\s*::`)
+	modDecl     = regexp.MustCompile(`\bmod\s+([A-Za-z_][A-Za-z0-9_]*)\s*[;{]`)
+	testAttr    = regexp.MustCompile(`#\[\s*(test|cfg\(\s*test\s*\))\s*\]`)
+)
+
+// keywords can come before a path that starts with ::, e.g. use ::foo::Bar, so aren't the start of a path themselves
+var keywords = map[string]bool{
+	"as":     true,
+	"dyn":    true,
+	"impl":   true,
+	"in":     true,
+	"mut":    true,
+	"pub":    true,
+	"return": true,
+	"use":    true,
+}
+
+// parseFile finds the crates and modules referenced by Rust source code
+func parseFile(path string, src []byte) *File {
+	code := stripCommentsAndStrings(src)
+	f := &File{
+		Path:     path,
+		HasTests: testAttr.MatchString(code),
+	}
+
+	uses := map[string]bool{}
+	for _, m := range useStmt.FindAllStringSubmatch(code, -1) {
+		uses[m[1]] = true
+	}
+	// use {foo::bar, baz}; brings several paths into scope at once
+	for _, m := range useGroup.FindAllStringSubmatch(code, -1) {
+		for _, part := range strings.Split(m[1], ",") {
+			if name := firstSegment(part); name != "" {
+				uses[name] = true
+			}
+		}
+	}
+	for _, m := range externCrate.FindAllStringSubmatch(code, -1) {
+		uses[m[1]] = true
+	}
+	f.Uses = sortedKeys(uses)
+
+	paths := map[string]bool{}
+	for _, m := range pathStart.FindAllStringSubmatch(code, -1) {
+		if !uses[m[2]] && !keywords[m[2]] {
+			paths[m[2]] = true
+		}
+	}
+	f.Paths = sortedKeys(paths)
+
+	mods := map[string]bool{}
+	for _, m := range modDecl.FindAllStringSubmatch(code, -1) {
+		mods[m[1]] = true
+	}
+	f.Mods = sortedKeys(mods)
+	return f
+}
+
+// firstSegment returns the first segment of a path, e.g. foo for foo::bar::Baz
+func firstSegment(path string) string {
+	path = strings.TrimPrefix(strings.TrimSpace(path), "::")
+	path = strings.TrimSpace(path)
+	end := strings.IndexFunc(path, func(r rune) bool {
+		return !(r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	})
+	if end == -1 {
+		return path
+	}
+	return path[:end]
+}
+
+func sortedKeys(m map[string]bool) []string {
+	ret := make([]string, 0, len(m))
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// stripCommentsAndStrings removes comments, and the contents of string and character literals, from Rust source code so
+// they aren't mistaken for code. Block comments can be nested in Rust.
+func stripCommentsAndStrings(src []byte) string {
+	var b strings.Builder
+	for i := 0; i < len(src); i++ {
+		c := src[i]
+		switch {
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			b.WriteByte('\n')
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			depth := 0
+			for ; i < len(src); i++ {
+				if src[i] == '/' && i+1 < len(src) && src[i+1] == '*' {
+					depth++
+					i++
+				} else if src[i] == '*' && i+1 < len(src) && src[i+1] == '/' {
+					depth--
+					i++
+					if depth == 0 {
+						break
+					}
+				}
+			}
+			b.WriteByte(' ')
+		case c == 'r' && isRawString(src[i:]) && (i == 0 || !isIdentByte(src[i-1]) || src[i-1] == 'b'):
+			// Raw strings, e.g. r#"..."#, end at a quote followed by the same number of hashes they started with
+			hashes := 0
+			for i++; src[i] == '#'; i++ {
+				hashes++
+			}
+			end := `"` + strings.Repeat("#", hashes)
+			j := strings.Index(string(src[i+1:]), end)
+			if j == -1 {
+				i = len(src)
+			} else {
+				i += j + len(end)
+			}
+			b.WriteString(`""`)
+		case c == '"':
+			for i++; i < len(src) && src[i] != '"'; i++ {
+				if src[i] == '\\' {
+					i++
+				}
+			}
+			b.WriteString(`""`)
+		case c == '\'':
+			// This is either a character literal, or a lifetime, e.g. 'a
+			if i+1 < len(src) && src[i+1] == '\\' {
+				for i += 3; i < len(src) && src[i] != '\''; i++ {
+				}
+				b.WriteString("' '")
+				continue
+			}
+			_, size := utf8.DecodeRune(src[i+1:])
+			if i+1+size < len(src) && src[i+1+size] == '\'' {
+				i += 1 + size
+				b.WriteString("' '")
+				continue
+			}
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isRawString returns whether src starts with a raw string literal, i.e. r"..." or r#"..."#
+func isRawString(src []byte) bool {
+	i := 1
+	for i < len(src) && src[i] == '#' {
+		i++
+	}
+	return i < len(src) && src[i] == '"'
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+func (f *File) uses(name string) bool {
+	for _, use := range f.Uses {
+		if use == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package rust
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	f := parseFile("lib.rs", []byte(`//! use not_a_crate::Foo;
+extern crate libc;
+
+use std::collections::HashMap;
+use serde::{Deserialize, Serialize};
+use ::anyhow::Result;
+use {regex::Regex, once_cell::sync::Lazy};
+use crate::config::Config;
+
+mod config;
+mod util {
+    pub fn f<'a>(s: &'a str) -> char {
+        let _ = r#"use raw_string::Nope;"#;
+        let _ = "tokio::spawn";
+        let _ = '"';
+        /* use block::Comment; /* nested */ */
+        serde_json::to_string(s).unwrap();
+        'x'
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    #[test]
+    fn it_works() {}
+}
+`))
+
+	assert.Equal(t, "lib.rs", f.Path)
+	assert.Equal(t, []string{"anyhow", "crate", "libc", "once_cell", "regex", "serde", "std"}, f.Uses)
+	assert.Equal(t, []string{"serde_json"}, f.Paths)
+	assert.Equal(t, []string{"config", "tests", "util"}, f.Mods)
+	assert.True(t, f.HasTests)
+}
+
+func TestStripCommentsAndStrings(t *testing.T) {
+	testCases := []struct {
+		name     string
+		src      string
+		expected string
+	}{
+		{name: "line comment", src: "a // b\nc", expected: "a \nc"},
+		{name: "nested block comment", src: "a /* b /* c */ d */ e", expected: "a   e"},
+		{name: "string with escaped quote", src: `a "b \" c" d`, expected: `a "" d`},
+		{name: "raw string", src: `a r##"b "# c"## d`, expected: `a "" d`},
+		{name: "byte string", src: `a b"c" d`, expected: `a b"" d`},
+		{name: "char literal", src: `a 'b' c`, expected: `a ' ' c`},
+		{name: "escaped char literal", src: `a '\'' c`, expected: `a ' ' c`},
+		{name: "lifetime", src: `&'a str`, expected: `&'a str`},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, stripCommentsAndStrings([]byte(tc.src)))
+		})
+	}
+}
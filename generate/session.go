@@ -0,0 +1,80 @@
+package generate
+
+import (
+	"strings"
+
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+	"github.com/please-build/puku/trie"
+)
+
+// Session keeps an updater between updates, so the BUILD files it's parsed and the imports it's resolved don't have to
+// be worked out again each time. This is used by puku watch, which updates a few directories at a time.
+type Session struct {
+	plzConf *please.Config
+	opts    options.Options
+	u       *updater
+}
+
+func NewSession(plzConf *please.Config, opts options.Options) *Session {
+	return &Session{
+		plzConf: plzConf,
+		opts:    opts,
+		u:       newUpdater(plzConf, opts),
+	}
+}
+
+// Forget drops the BUILD files parsed for the given directories, e.g. because they've been edited, so they're read
+// again the next time they're needed. Imports that resolved to targets in them are resolved again too, as the targets
+// may have changed.
+func (s *Session) Forget(dirs ...string) {
+	s.u.forget(dirs)
+}
+
+// Update updates the BUILD files in the given paths, in the same way as the Update function. The BUILD files in the
+// paths are read again, and imports that resolved to targets in them are resolved again, as their sources have changed.
+// If the update fails, everything is read again next time, as the BUILD files may have been left half updated.
+func (s *Session) Update(paths ...string) error {
+	s.u.reset(paths)
+	if err := s.u.update(paths...); err != nil {
+		s.u = newUpdater(s.plzConf, s.opts)
+		return err
+	}
+	return s.u.graph.FormatFiles()
+}
+
+// reset prepares the updater to update the given paths again. The BUILD files and imports for other directories are
+// kept, but the state that each update reads for itself, such as the third party modules, is cleared. The globs and
+// languages' indexes of the sources are started afresh, as the sources will have changed.
+func (u *updater) reset(paths []string) {
+	u.forget(paths)
+
+	u.modules = nil
+	u.newModules = nil
+	u.usingGoModule = false
+	u.installs = trie.New()
+	u.vendorPkgs = nil
+	u.protoPackages = nil
+	u.eval = eval.New(glob.New())
+	u.languages = language.NewBackends(u.plzConf, u.graph, eval.New(glob.NewAllFiles(u.plzConf.BuildFileNames())))
+}
+
+// forget drops the BUILD files parsed for the given directories, and the imports that resolved to targets in them
+func (u *updater) forget(dirs []string) {
+	u.graph.Forget(dirs...)
+
+	changed := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		changed[dir] = true
+	}
+	for i, t := range u.resolvedImports {
+		if strings.HasPrefix(t, "//") && !strings.HasPrefix(t, "///") && changed[labels.Parse(t).Package] {
+			delete(u.resolvedImports, i)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+	"github.com/please-build/puku/proxy"
+)
+
+func TestReset(t *testing.T) {
+	plzConf := new(please.Config)
+	plzConf.Parse.BuildFileName = []string{"BUILD"}
+	u := newUpdater(plzConf, options.TestOptions)
+
+	foo, err := build.ParseBuild("foo/BUILD", []byte(`go_library(name = "foo")`))
+	require.NoError(t, err)
+	bar, err := build.ParseBuild("bar/BUILD", []byte(`go_library(name = "bar")`))
+	require.NoError(t, err)
+	u.graph.SetFile("foo", foo)
+	u.graph.SetFile("bar", bar)
+
+	u.resolvedImports = map[string]string{
+		"github.com/example/repo/foo":     "//foo",
+		"github.com/example/repo/foo/api": "//foo:api",
+		"github.com/example/repo/bar":     "//bar",
+		"github.com/example/module":       "///third_party/go/github.com_example_module//:module",
+		"fmt":                             "",
+	}
+	u.modules = []string{"github.com/example/module"}
+	u.newModules = []*proxy.Module{{Module: "github.com/example/other", Version: "v1.0.0"}}
+
+	u.reset([]string{"foo"})
+
+	t.Run("forgets the BUILD files in the paths", func(t *testing.T) {
+		file, err := u.graph.LoadFile("bar")
+		require.NoError(t, err)
+		assert.Same(t, bar, file)
+
+		file, err = u.graph.LoadFile("foo")
+		require.NoError(t, err)
+		assert.NotSame(t, foo, file)
+	})
+
+	t.Run("forgets imports resolved to targets in the paths", func(t *testing.T) {
+		assert.Equal(t, map[string]string{
+			"github.com/example/repo/bar": "//bar",
+			"github.com/example/module":   "///third_party/go/github.com_example_module//:module",
+			"fmt":                         "",
+		}, u.resolvedImports)
+	})
+
+	t.Run("clears the modules", func(t *testing.T) {
+		assert.Empty(t, u.modules)
+		assert.Empty(t, u.newModules)
+	})
+}
+
+func TestSessionForget(t *testing.T) {
+	plzConf := new(please.Config)
+	plzConf.Parse.BuildFileName = []string{"BUILD"}
+	s := NewSession(plzConf, options.TestOptions)
+
+	s.u.resolvedImports = map[string]string{
+		"github.com/example/repo/foo": "//foo",
+		"github.com/example/repo/bar": "//bar",
+	}
+
+	// An edited BUILD file may have moved targets, even when none of the sources in its directory have changed
+	s.Forget("foo")
+	assert.Equal(t, map[string]string{"github.com/example/repo/bar": "//bar"}, s.u.resolvedImports)
+}
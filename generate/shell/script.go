@@ -0,0 +1,113 @@
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Script represents a single shell script
+type Script struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Executable is set when the file has its executable bit set
+	Executable bool
+	// Sources are the paths of the scripts this one sources, relative to the repo root. These are taken to be relative
+	// to the script, which is also where `source ./lib.sh` looks when it's run from its own directory. Paths that
+	// depend on other variables can't be found statically, so are skipped.
+	Sources []string
+}
+
+// IsTest returns whether the script is a test, i.e. it's named test_*.sh or *_test.sh
+func (s *Script) IsTest() bool {
+	base := strings.TrimSuffix(s.Name, filepath.Ext(s.Name))
+	return strings.HasPrefix(base, "test_") || strings.HasSuffix(base, "_test")
+}
+
+var (
+	sourceStmt = regexp.MustCompile(`^\s*(?:source|\.)\s+(.+)`)
+	// scriptDir matches the ways scripts commonly refer to their own directory, once quotes have been removed
+	scriptDir = regexp.MustCompile(`^(\$\(\s*dirname\s+\$(?:0|\{?BASH_SOURCE(?:\[0\])?\}?)\s*\)|\$\{BASH_SOURCE(?:\[0\])?%/\*\}|\$\{0%/\*\})/`)
+)
+
+// IsScript returns whether the file is a shell script
+func IsScript(name string) bool {
+	return filepath.Ext(name) == ".sh" || filepath.Ext(name) == ".bash"
+}
+
+// ReadDir reads the shell scripts in the given directory
+func ReadDir(dir string) (map[string]*Script, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*Script, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || !IsScript(info.Name()) {
+			continue
+		}
+		s, err := readScript(dir, info.Name())
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = s
+	}
+	return ret, nil
+}
+
+func readScript(dir, name string) (*Script, error) {
+	path := filepath.Join(dir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{
+		Name:       name,
+		Executable: info.Mode()&0111 != 0,
+		Sources:    parseSources(dir, bs),
+	}, nil
+}
+
+// parseSources finds the scripts sourced by a script in the given directory
+func parseSources(dir string, src []byte) []string {
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	for scanner.Scan() {
+		match := sourceStmt.FindStringSubmatch(scanner.Text())
+		if match == nil {
+			continue
+		}
+		path := strings.ReplaceAll(strings.ReplaceAll(match[1], `"`, ""), `'`, "")
+		if loc := scriptDir.FindStringIndex(path); loc != nil {
+			path = path[loc[1]:]
+		}
+		// Anything after the path is arguments to the script, or another command
+		if fields := strings.FieldsFunc(path, func(r rune) bool { return r == ' ' || r == '\t' || r == ';' }); len(fields) > 0 {
+			path = fields[0]
+		}
+		// Anything else that's still using variables can't be resolved
+		if path == "" || strings.ContainsAny(path, "$`") || filepath.IsAbs(path) || strings.HasPrefix(path, "~") {
+			continue
+		}
+		path = filepath.Join(dir, path)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			seen[path] = true
+		}
+	}
+
+	ret := make([]string, 0, len(seen))
+	for path := range seen {
+		ret = append(ret, path)
+	}
+	sort.Strings(ret)
+	return ret
+}
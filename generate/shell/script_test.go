@@ -0,0 +1,42 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSources(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"lib.sh", "common/env.sh", "utils.bash"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), nil, 0644))
+	}
+
+	sources := parseSources(dir, []byte(`#!/usr/bin/env bash
+set -euo pipefail
+
+source ./lib.sh
+. "$(dirname "$0")/common/env.sh"
+source "${BASH_SOURCE%/*}/utils.bash";
+source "$HOME/.bashrc"
+source /etc/profile
+source ./missing.sh
+echo "source ./not_sourced.sh"
+`))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "common/env.sh"),
+		filepath.Join(dir, "lib.sh"),
+		filepath.Join(dir, "utils.bash"),
+	}, sources)
+}
+
+func TestIsTest(t *testing.T) {
+	assert.True(t, (&Script{Name: "test_deploy.sh"}).IsTest())
+	assert.True(t, (&Script{Name: "deploy_test.sh"}).IsTest())
+	assert.False(t, (&Script{Name: "deploy.sh"}).IsTest())
+	assert.False(t, (&Script{Name: "testing.sh"}).IsTest())
+}
@@ -0,0 +1,281 @@
+// Package shell generates sh_binary and sh_test rules for the shell scripts in a directory, with the scripts they
+// source in their data.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the shell rules
+const Subinclude = "///shell//build_defs:shell"
+
+// Kinds are the kinds of rule that puku generates for shell scripts. These take a single script, rather than a list of
+// sources.
+var Kinds = map[string]*kinds.Kind{
+	"sh_binary": {
+		Name:     "sh_binary",
+		Type:     kinds.Bin,
+		SrcsAttr: "main",
+	},
+	"sh_test": {
+		Name:     "sh_test",
+		Type:     kinds.Test,
+		SrcsAttr: "src",
+	},
+}
+
+// Generator updates the shell rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"shell"},
+		IsSource: IsScript,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the shell scripts in the directory. Their imports are the paths of the scripts they source, relative to
+// the repo root.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	scripts, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(scripts))
+	for _, s := range scripts {
+		ret = append(ret, &language.File{Name: s.Name, Imports: s.Sources})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve returns the rule that has a sourced script as one of its sources
+func (g *Generator) Resolve(_ *config.Config, _, path string) (string, error) {
+	label, err := g.ruleWithFile(path)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		return "", fmt.Errorf("%v isn't in the sources of any rule", path)
+	}
+	return label, nil
+}
+
+// Update generates an sh_test for each test script in the directory, and an sh_binary for each executable script,
+// and adds the scripts they source to their data. Other scripts are assumed to be libraries that are only sourced.
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	scripts, err := ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+
+	rules := map[string]*edit.Rule{}
+	for _, expr := range file.Rules("") {
+		if kind, ok := Kinds[expr.Kind()]; ok {
+			if script := expr.AttrString(kind.SrcsAttr); script != "" {
+				rules[script] = edit.NewRule(expr, kind, dir)
+			}
+		}
+	}
+
+	names := make([]string, 0, len(scripts))
+	for name := range scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		script := scripts[name]
+		if _, ok := rules[name]; ok {
+			continue
+		}
+		kind := ""
+		switch {
+		case script.IsTest():
+			kind = "sh_test"
+		case script.Executable:
+			kind = "sh_binary"
+		default:
+			continue
+		}
+
+		ruleName := strings.TrimSuffix(name, filepath.Ext(name))
+		if edit.FindTargetByName(file, ruleName) != nil {
+			ruleName += "_sh" // Don't clash with the rules for other languages in this directory
+		}
+		rule := edit.NewRule(edit.NewRuleExpr(kind, ruleName), Kinds[kind], dir)
+		rule.SetAttr(rule.SrcsAttr(), edit.NewStringExpr(name))
+		file.Stmt = append(file.Stmt, rule.Call)
+		rules[name] = rule
+	}
+
+	if len(rules) > 0 && !g.plzConf.IsPreloaded(Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, Subinclude)
+	}
+
+	for name, rule := range rules {
+		if err := g.updateRuleData(rule, scripts[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateRuleData adds the scripts that the rule's script sources, directly or through other scripts, to its data.
+// Scripts in the same directory are added as files, and ones elsewhere as the rule that has them in its sources.
+// Scripts that no longer exist are removed.
+func (g *Generator) updateRuleData(rule *edit.Rule, script *Script) error {
+	data := rule.AttrStrings("data")
+	has := map[string]bool{}
+	for _, d := range data {
+		has[d] = true
+	}
+
+	var keep []string
+	for _, d := range data {
+		if IsScript(d) && !eval.LookLikeBuildLabel(d) && !isFile(filepath.Join(rule.Dir, d)) {
+			continue
+		}
+		keep = append(keep, d)
+	}
+
+	if script != nil {
+		sourced, err := transitiveSources(script.Sources)
+		if err != nil {
+			return err
+		}
+		for _, path := range sourced {
+			if path == filepath.Join(rule.Dir, script.Name) {
+				continue
+			}
+			d, err := g.dataFor(rule, path)
+			if err != nil {
+				return err
+			}
+			if d != "" && !has[d] {
+				keep = append(keep, d)
+				has[d] = true
+			}
+		}
+	}
+
+	rule.SetOrDeleteAttr("data", keep)
+	return nil
+}
+
+// dataFor returns what to add to the rule's data for a script it sources
+func (g *Generator) dataFor(rule *edit.Rule, path string) (string, error) {
+	if filepath.Dir(path) == filepath.Clean(rule.Dir) {
+		return filepath.Base(path), nil
+	}
+
+	label, err := g.ruleWithFile(path)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		log.Warningf("%v sources %v, which isn't in the sources of any rule so can't be added to its data", rule.Label(), path)
+		return "", nil
+	}
+	g.graph.EnsureVisibility(rule.Label(), label)
+	return label, nil
+}
+
+// ruleWithFile returns the label of the first rule in the script's directory that has it as a source, or an empty
+// string if there isn't one
+func (g *Generator) ruleWithFile(path string) (string, error) {
+	dir := filepath.Dir(path)
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+	if target := findRuleWithFile(file, filepath.Base(path)); target != nil {
+		return edit.BuildTarget(target.Name(), dir, ""), nil
+	}
+	return "", nil
+}
+
+// findRuleWithFile returns the first rule in the file that has the given file as a source
+func findRuleWithFile(file *build.File, name string) *build.Rule {
+	for _, rule := range file.Rules("") {
+		for _, attr := range []string{"src", "srcs", "main"} {
+			if rule.AttrString(attr) == name {
+				return rule
+			}
+			for _, src := range rule.AttrStrings(attr) {
+				if src == name {
+					return rule
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// transitiveSources returns the scripts sourced, and the scripts those source in turn
+func transitiveSources(sources []string) ([]string, error) {
+	seen := map[string]bool{}
+	var ret []string
+	for len(sources) > 0 {
+		path := sources[0]
+		sources = sources[1:]
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+		ret = append(ret, path)
+
+		s, err := readScript(filepath.Dir(path), filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s.Sources...)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,84 @@
+package shell
+
+import (
+	"os"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"scripts/deploy.sh":      "#!/bin/bash\nsource ./lib.sh\n",
+		"scripts/lib.sh":         "source ../common/log.sh\n",
+		"scripts/deploy_test.sh": "#!/bin/bash\nsource ./deploy.sh\n",
+		"scripts/release.sh":     "#!/bin/bash\n",
+		"scripts/BUILD": `sh_binary(
+    name = "release",
+    main = "release.sh",
+    data = [
+        "removed.sh",
+        "config.yaml",
+    ],
+)
+`,
+		"common/log.sh": "",
+		"common/BUILD":  "filegroup(\n    name = \"log\",\n    srcs = [\"log.sh\"],\n)\n",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		require.NoError(t, os.Chmod("scripts/deploy.sh", 0755))
+		require.NoError(t, os.Chmod("scripts/release.sh", 0755))
+
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+
+		require.NoError(t, g.Update(new(config.Config), "scripts"))
+		file, err := g.graph.LoadFile("scripts")
+		require.NoError(t, err)
+
+		t.Run("generates a binary for executable scripts", func(t *testing.T) {
+			bin := edit.FindTargetByName(file, "deploy")
+			require.NotNil(t, bin)
+			assert.Equal(t, "sh_binary", bin.Kind())
+			assert.Equal(t, "deploy.sh", bin.AttrString("main"))
+			assert.Equal(t, []string{"//common:log", "lib.sh"}, bin.AttrStrings("data"))
+		})
+
+		t.Run("generates a test for test scripts", func(t *testing.T) {
+			test := edit.FindTargetByName(file, "deploy_test")
+			require.NotNil(t, test)
+			assert.Equal(t, "sh_test", test.Kind())
+			assert.Equal(t, "deploy_test.sh", test.AttrString("src"))
+			assert.Equal(t, []string{"//common:log", "deploy.sh", "lib.sh"}, test.AttrStrings("data"))
+		})
+
+		t.Run("doesn't generate rules for libraries", func(t *testing.T) {
+			assert.Nil(t, edit.FindTargetByName(file, "lib"))
+		})
+
+		t.Run("removes scripts that no longer exist from data", func(t *testing.T) {
+			release := edit.FindTargetByName(file, "release")
+			require.NotNil(t, release)
+			assert.Equal(t, []string{"config.yaml"}, release.AttrStrings("data"))
+		})
+
+		t.Run("subincludes the shell rules", func(t *testing.T) {
+			require.NotEmpty(t, file.Stmt)
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///shell//build_defs:shell", call.List[0].(*build.StringExpr).Value)
+		})
+	})
+}
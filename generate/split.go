@@ -0,0 +1,173 @@
+package generate
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+)
+
+// splitTagSets returns the build tag sets to generate a library for each of, or nil if we're not splitting libraries by
+// build tag set
+func splitTagSets(conf *config.Config) map[string]*config.BuildTagSet {
+	if !conf.GetSplitBuildTagSets() {
+		return nil
+	}
+	return conf.GetBuildTagSets()
+}
+
+// splitLibName returns the name of the library for the build tag set in the package
+func splitLibName(pkgDir, setName string) string {
+	return filepath.Base(pkgDir) + "_" + setName
+}
+
+// splitTagSet returns the build tag set that the rule is the library for, if we're splitting libraries by build tag set
+func splitTagSet(conf *config.Config, rule *edit.Rule) (string, *config.BuildTagSet) {
+	if rule.Kind.Type != kinds.Lib {
+		return "", nil
+	}
+	prefix := filepath.Base(rule.Dir) + "_"
+	if !strings.HasPrefix(rule.Name(), prefix) {
+		return "", nil
+	}
+	name := strings.TrimPrefix(rule.Name(), prefix)
+	if set, ok := splitTagSets(conf)[name]; ok {
+		return name, set
+	}
+	return "", nil
+}
+
+// allocateSplitSource allocates a library source to the library of each build tag set that includes it, creating the
+// libraries as necessary. Files without a build constraint are included by every set, so they're in every library.
+// Returns the new rules along with any created.
+func allocateSplitSource(conf *config.Config, pkgDir, src string, f *GoFile, tagSets map[string]*config.BuildTagSet, rules, newRules []*edit.Rule) []*edit.Rule {
+	names := make([]string, 0, len(tagSets))
+	for name := range tagSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, setName := range names {
+		if f.Constraint != nil && !f.Constraint.Eval(tagMatcher(tagSets[setName].Tags)) {
+			continue
+		}
+
+		name := splitLibName(pkgDir, setName)
+		var rule *edit.Rule
+		for _, r := range append(rules, newRules...) {
+			if r.Name() == name {
+				rule = r
+				break
+			}
+		}
+		if rule == nil {
+			kind := splitLibKind(conf, f, rules)
+			rule = edit.NewRule(edit.NewRuleExpr(kind.Name, name), kind, pkgDir)
+			if vis := internalVisibility(pkgDir); vis != "" {
+				rule.SetAttr("visibility", edit.NewStringList([]string{vis}))
+			}
+			newRules = append(newRules, rule)
+		}
+
+		if f.IsCgo() {
+			if ensureCgoKind(rule) {
+				rule.AddCgoSrc(src)
+				continue
+			}
+			log.Warningf("%v imports \"C\" but %v is not a cgo kind", filepath.Join(pkgDir, src), rule.Label())
+		}
+		rule.AddSrc(src)
+	}
+	return newRules
+}
+
+// splitLibKind returns the kind of library to create for a build tag set. This is the kind of the package's existing
+// library, if it has one, so libraries of a custom kind stay that kind once they're split.
+func splitLibKind(conf *config.Config, f *GoFile, rules []*edit.Rule) *kinds.Kind {
+	for _, r := range rules {
+		if r.Kind.Type == kinds.Lib && !r.Kind.NonGoSources {
+			return r.Kind
+		}
+	}
+	kind := "go_library"
+	if f.IsCgo() {
+		kind = "cgo_library"
+	}
+	if k := conf.GetKind(kind); k != nil {
+		return k
+	}
+	return kinds.DefaultKinds[kind]
+}
+
+// splitLibs returns the libraries of the tag sets of a package that's split by build tag set, keyed by the select()
+// condition of their tag set, so targets depend on the one library that's built with the active tag set. The library of
+// the tag set without a condition is under //conditions:default. Returns nil if the package isn't split.
+func (u *updater) splitLibs(conf *config.Config, pkgDir string) (map[string]string, error) {
+	tagSets := splitTagSets(conf)
+	if len(tagSets) == 0 {
+		return nil, nil
+	}
+	file, err := u.graph.LoadFile(pkgDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only depend on the libraries that exist, unless none do yet because we're about to generate them
+	libs := map[string]string{}
+	existing := map[string]string{}
+	for setName, set := range tagSets {
+		condition := set.Condition
+		if condition == "" {
+			condition = edit.DefaultCondition
+		}
+		name := splitLibName(pkgDir, setName)
+		label := edit.BuildTarget(name, pkgDir, "")
+		libs[condition] = label
+		if edit.FindTargetByName(file, name) != nil {
+			existing[condition] = label
+		}
+	}
+	if len(existing) > 0 {
+		return existing, nil
+	}
+	return libs, nil
+}
+
+// localSplitLibs returns the libraries to depend on for a library in this repo, if its package is split by build tag
+// set, as splitLibs does. The dep can be any of the package's libraries, or the library we'd generate if it weren't
+// split. Returns nil for any other target. The config is for the directory of the dependent.
+func (u *updater) localSplitLibs(conf *config.Config, dir, dep string) (map[string]string, error) {
+	if strings.HasPrefix(dep, "///") || strings.HasPrefix(dep, "@") {
+		return nil, nil
+	}
+	l := labels.Parse(dep)
+	pkgDir := l.Package
+	if pkgDir == "" {
+		pkgDir = "."
+	}
+	if pkgDir != dir {
+		c, err := config.ReadConfig(pkgDir)
+		if err != nil {
+			return nil, err
+		}
+		conf = c
+	}
+	tagSets := splitTagSets(conf)
+	if len(tagSets) == 0 {
+		return nil, nil
+	}
+
+	isLib := l.Target == filepath.Base(pkgDir)
+	for setName := range tagSets {
+		isLib = isLib || splitLibName(pkgDir, setName) == l.Target
+	}
+	if !isLib {
+		return nil, nil
+	}
+	return u.splitLibs(conf, pkgDir)
+}
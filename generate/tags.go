@@ -0,0 +1,122 @@
+package generate
+
+import (
+	"go/build/constraint"
+	"sort"
+	"strings"
+
+	"github.com/please-build/puku/config"
+)
+
+// tagMatcher returns a function that reports whether a build tag is satisfied by the given set of tags. Release tags
+// (e.g. go1.21) and the gc compiler tag are always satisfied, as we assume the build uses a recent gc toolchain.
+func tagMatcher(tags []string) func(string) bool {
+	return func(tag string) bool {
+		if tag == "gc" || strings.HasPrefix(tag, "go1.") {
+			return true
+		}
+		for _, t := range tags {
+			if t == tag {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// depConditions returns the select() conditions that the imports of this file should be added to deps under. An empty
+// string means the imports are needed unconditionally. When the file's build constraint is not satisfied by any of
+// the tag sets, no conditions are returned and the imports shouldn't be added at all.
+func (f *GoFile) depConditions(tagSets map[string]*config.BuildTagSet) []string {
+	return constraintConditions(f.Constraint, tagSets)
+}
+
+// constraintConditions returns the select() conditions under which a build constraint is satisfied by the tag sets. See
+// depConditions for more information.
+func constraintConditions(expr constraint.Expr, tagSets map[string]*config.BuildTagSet) []string {
+	if len(tagSets) == 0 || expr == nil {
+		return []string{""}
+	}
+
+	names := make([]string, 0, len(tagSets))
+	for name := range tagSets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var conditions []string
+	for _, name := range names {
+		set := tagSets[name]
+		if !expr.Eval(tagMatcher(set.Tags)) {
+			continue
+		}
+		if set.Condition == "" {
+			return []string{""}
+		}
+		conditions = append(conditions, set.Condition)
+	}
+	return conditions
+}
+
+// ignoreTags are build tags that are conventionally never set, and are used to keep files out of the package e.g. a
+// //go:build ignore program run by go:generate, or a //go:build tools file that tracks tool dependencies.
+var ignoreTags = []string{"ignore", "tools"}
+
+// IsIgnored returns whether the file's build constraint can only be satisfied by setting one of the ignoreTags
+func (f *GoFile) IsIgnored() bool {
+	return f.Constraint != nil && requiresTags(f.Constraint, ignoreTags)
+}
+
+// wasmTags are the GOOS and GOARCH values for WebAssembly
+var wasmTags = []string{"js", "wasip1", "wasm"}
+
+// IsWasm returns whether the file is only built for js/wasm. That's when it imports syscall/js, or has a build
+// constraint (including one from its file name) that can only be satisfied with one of the wasmTags.
+func (f *GoFile) IsWasm() bool {
+	for _, i := range f.Imports {
+		if i == "syscall/js" {
+			return true
+		}
+	}
+	return f.Constraint != nil && requiresTags(f.Constraint, wasmTags)
+}
+
+// maxConstraintTags is the most tags we'll try every combination of when checking if a constraint can be satisfied
+const maxConstraintTags = 12
+
+// requiresTags returns whether the build constraint can only be satisfied by setting at least one of the given tags
+func requiresTags(expr constraint.Expr, required []string) bool {
+	var tags []string
+	collectTags(expr, required, &tags)
+	if len(tags) > maxConstraintTags {
+		return false
+	}
+	for i := 0; i < 1<<len(tags); i++ {
+		set := map[string]bool{}
+		for j, tag := range tags {
+			set[tag] = i&(1<<j) != 0
+		}
+		if expr.Eval(func(tag string) bool { return set[tag] }) {
+			return false
+		}
+	}
+	return true
+}
+
+// collectTags adds the tags used in the expression to the slice, other than the excluded ones
+func collectTags(expr constraint.Expr, exclude []string, tags *[]string) {
+	switch e := expr.(type) {
+	case *constraint.AndExpr:
+		collectTags(e.X, exclude, tags)
+		collectTags(e.Y, exclude, tags)
+	case *constraint.OrExpr:
+		collectTags(e.X, exclude, tags)
+		collectTags(e.Y, exclude, tags)
+	case *constraint.NotExpr:
+		collectTags(e.X, exclude, tags)
+	case *constraint.TagExpr:
+		if !contains(exclude, e.Tag) && !contains(*tags, e.Tag) {
+			*tags = append(*tags, e.Tag)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File represents a single Terraform configuration file
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Modules are the sources of the module blocks in the file, as they're written
+	Modules []string
+	// Files are the paths of the files that the file reads with file(), templatefile() and the like, relative to its
+	// directory
+	Files []string
+	// HasBackend is set when the file configures a backend, which makes its directory a root module
+	HasBackend bool
+}
+
+var (
+	moduleBlock  = regexp.MustCompile(`(?m)^\s*module\s+"[^"]*"\s*\{`)
+	sourceAttr   = regexp.MustCompile(`(?m)^\s*source\s*=\s*"([^"]+)"`)
+	backendBlock = regexp.MustCompile(`(?m)^\s*backend\s+"[^"]*"\s*\{`)
+	fileFunc     = regexp.MustCompile(`\b(?:file|filebase64|filemd5|filesha1|filesha256|filesha512|templatefile)\(\s*"([^"]+)"`)
+)
+
+// IsSource returns whether the file is a Terraform configuration file
+func IsSource(name string) bool {
+	return filepath.Ext(name) == ".tf"
+}
+
+// IsLocalModule returns whether a module source is a path in the repo, rather than e.g. the registry or a git repo
+func IsLocalModule(source string) bool {
+	return strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// ImportDir reads the .tf files in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || !IsSource(info.Name()) {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = parseFile(info.Name(), bs)
+	}
+	return ret, nil
+}
+
+// parseFile finds the modules that a Terraform file calls and the files it reads. Only paths that are relative to the
+// module, i.e. start with ${path.module} or have no interpolation at all, are recorded, as others can't be known until
+// Terraform runs.
+func parseFile(name string, src []byte) *File {
+	code := stripComments(src)
+	f := &File{
+		Name:       name,
+		HasBackend: backendBlock.MatchString(code),
+	}
+
+	seen := map[string]bool{}
+	for _, loc := range moduleBlock.FindAllStringIndex(code, -1) {
+		block := code[loc[1]:blockEnd(code, loc[1])]
+		if match := sourceAttr.FindStringSubmatch(block); match != nil && !seen[match[1]] {
+			seen[match[1]] = true
+			f.Modules = append(f.Modules, match[1])
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, match := range fileFunc.FindAllStringSubmatch(code, -1) {
+		path := strings.TrimPrefix(match[1], "${path.module}/")
+		if strings.Contains(path, "${") || filepath.IsAbs(path) {
+			continue
+		}
+		path = filepath.Clean(path)
+		if !seen[path] {
+			seen[path] = true
+			f.Files = append(f.Files, path)
+		}
+	}
+	return f
+}
+
+// blockEnd returns the index of the brace that closes the block whose body starts at the given index, or the end of the
+// code if it isn't closed
+func blockEnd(code string, start int) int {
+	depth := 1
+	for i := start; i < len(code); i++ {
+		switch code[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(code)
+}
+
+// stripComments removes the #, // and /* */ comments from a Terraform file, keeping the newlines so attributes stay at
+// the start of their lines. Strings are kept, as they hold the paths puku is looking for.
+func stripComments(src []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '#' || (src[i] == '/' && i+1 < len(src) && src[i+1] == '/'):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				sb.WriteByte('\n')
+			}
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '*':
+			for i += 2; i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/'); i++ {
+				if src[i] == '\n' {
+					sb.WriteByte('\n')
+				}
+			}
+			i++
+			sb.WriteByte(' ')
+		case src[i] == '"':
+			sb.WriteByte('"')
+			for i++; i < len(src) && src[i] != '"' && src[i] != '\n'; i++ {
+				if src[i] == '\\' && i+1 < len(src) {
+					sb.WriteByte(src[i])
+					i++
+				}
+				sb.WriteByte(src[i])
+			}
+			if i < len(src) {
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,52 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	f := parseFile("main.tf", []byte(`# module "commented" { source = "./commented" }
+terraform {
+  backend "s3" {
+    bucket = "state"
+  }
+}
+
+module "network" {
+  source = "../modules/network"
+  tags   = { team = "infra" }
+}
+
+module "consul" {
+  source  = "hashicorp/consul/aws"
+  version = "0.1.0"
+}
+
+/*
+module "old" {
+  source = "./old"
+}
+*/
+
+resource "aws_instance" "web" {
+  user_data = templatefile("${path.module}/templates/init.sh.tpl", { port = 8080 })
+  policy    = file("policy.json") // Relative to the working directory, which is the module
+  key       = file("${var.key_dir}/id_rsa.pub")
+  other     = filebase64("${path.module}/templates/../files/blob.bin")
+}
+`))
+
+	assert.Equal(t, "main.tf", f.Name)
+	assert.True(t, f.HasBackend)
+	assert.Equal(t, []string{"../modules/network", "hashicorp/consul/aws"}, f.Modules)
+	assert.Equal(t, []string{"templates/init.sh.tpl", "policy.json", "files/blob.bin"}, f.Files)
+}
+
+func TestIsLocalModule(t *testing.T) {
+	assert.True(t, IsLocalModule("./modules/vpc"))
+	assert.True(t, IsLocalModule("../vpc"))
+	assert.False(t, IsLocalModule("hashicorp/consul/aws"))
+	assert.False(t, IsLocalModule("git::https://example.com/vpc.git"))
+}
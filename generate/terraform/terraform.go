@@ -0,0 +1,418 @@
+// Package terraform generates a terraform_module or terraform_root for each directory of Terraform files, with deps on
+// the local modules they call. The files they read with file() and templatefile() are added to their srcs, or to a
+// filegroup where they're in another package.
+package terraform
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the Terraform rules
+const Subinclude = "///terraform//build_defs:terraform"
+
+// Kinds are the kinds of rule that puku generates for Terraform files
+var Kinds = map[string]*kinds.Kind{
+	"terraform_module": {
+		Name:     "terraform_module",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+	"terraform_root": {
+		Name:     "terraform_root",
+		Type:     kinds.Bin,
+		SrcsAttr: "srcs",
+	},
+}
+
+// depsAttrs are the attributes that take the modules each kind of rule calls
+var depsAttrs = map[string]string{
+	"terraform_module": "deps",
+	"terraform_root":   "modules",
+}
+
+// Generator updates the Terraform rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"terraform"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Terraform files in the directory. Their imports are the sources of the modules they call, and the
+// files they read, which are prefixed with ./ so they can be told apart from modules in the registry.
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		imports := append([]string{}, f.Modules...)
+		for _, path := range f.Files {
+			if !IsLocalModule(path) {
+				path = "./" + path
+			}
+			imports = append(imports, path)
+		}
+		ret = append(ret, &language.File{Name: f.Name, Imports: imports})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves a module source or file path used by a Terraform file in the directory to the rule that provides
+// it. Modules from the registry or elsewhere outside the repo, and files in the directory's own package, don't need
+// one.
+func (g *Generator) Resolve(_ *config.Config, dir, imp string) (string, error) {
+	if !IsLocalModule(imp) {
+		return "", nil
+	}
+	path := filepath.Join(dir, imp)
+	if isDir(path) {
+		return g.moduleTarget(path)
+	}
+	if !isFile(path) {
+		return "", fmt.Errorf("no such file or directory %v", path)
+	}
+	pkg := g.packageOf(dir, filepath.Dir(path))
+	if pkg == dir {
+		return "", nil
+	}
+	rel, err := filepath.Rel(pkg, path)
+	if err != nil {
+		return "", err
+	}
+	label, err := g.ruleWithSrc(pkg, rel)
+	if err != nil {
+		return "", err
+	}
+	if label == "" {
+		return "", fmt.Errorf("%v isn't in the srcs of any rule in %v", rel, pkg)
+	}
+	return label, nil
+}
+
+// Update generates a rule for the Terraform files in the directory, if they aren't in one already, and updates the
+// modules it depends on and the files in its srcs. The rule is a terraform_root if any of the files configure a
+// backend, and a terraform_module otherwise.
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	return language.UpdateRules(g.plzConf, conf, g.graph, dir, &language.Rules{
+		Kinds:      Kinds,
+		Subinclude: func(*kinds.Kind) string { return Subinclude },
+		Allocate: func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error) {
+			return g.allocateSources(file, dir, files, rules)
+		},
+		UpdateDeps: func(rule *edit.Rule) error {
+			if err := g.updateRule(rule, files); err != nil {
+				return fmt.Errorf("failed to update %v: %v", rule.Label(), err)
+			}
+			return nil
+		},
+	})
+}
+
+// allocateSources adds the files that aren't in the srcs of a rule yet to the first rule in the package, which is
+// created if needed
+func (g *Generator) allocateSources(file *build.File, dir string, files map[string]*File, rules []*edit.Rule) ([]*edit.Rule, error) {
+	allocated := map[string]bool{}
+	for _, rule := range rules {
+		srcs, err := g.eval.EvalGlobs(dir, rule.Rule, rule.SrcsAttr())
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range srcs {
+			allocated[src] = true
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	root := false
+	for name, f := range files {
+		if !allocated[name] {
+			names = append(names, name)
+		}
+		root = root || f.HasBackend
+	}
+	sort.Strings(names)
+
+	var newRules []*edit.Rule
+	if len(names) > 0 && len(rules) == 0 {
+		kind := "terraform_module"
+		if root {
+			kind = "terraform_root"
+		}
+		rule := edit.NewRule(edit.NewRuleExpr(kind, ruleName(file, dir)), Kinds[kind], dir)
+		rules = append(rules, rule)
+		newRules = append(newRules, rule)
+	}
+	for _, name := range names {
+		rules[0].AddSrc(name)
+	}
+	return newRules, nil
+}
+
+// ruleName returns the name of the rule generated for a directory. This is named after the directory, unless that's
+// taken by another rule, e.g. the Go library for the package.
+func ruleName(file *build.File, dir string) string {
+	name := libName(dir)
+	if edit.FindTargetByName(file, name) != nil {
+		return name + "_tf"
+	}
+	return name
+}
+
+// libName returns the name of the rule generated for a directory
+func libName(dir string) string {
+	if dir == "." {
+		return "terraform"
+	}
+	return filepath.Base(dir)
+}
+
+// updateRule sets the deps of the rule to the local modules its files call, and adds the files they read to its srcs.
+// Files in the package are added as they are, and files in other packages as the rule that has them in its srcs.
+// Files that no longer exist are removed from its srcs, but labels are left alone as they may have been added by hand.
+func (g *Generator) updateRule(rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	has := map[string]bool{}
+	var reads []string
+	modules := map[string]bool{}
+	for _, src := range srcs {
+		if isLabel(src) {
+			has[src] = true
+			continue
+		}
+		if !isFile(filepath.Join(rule.Dir, src)) {
+			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			continue
+		}
+		has[src] = true
+		f := files[src]
+		if f == nil {
+			continue
+		}
+		reads = append(reads, f.Files...)
+		for _, source := range f.Modules {
+			if !IsLocalModule(source) {
+				continue
+			}
+			dep, err := g.moduleTarget(filepath.Join(rule.Dir, source))
+			if err != nil {
+				g.graph.Unresolved(rule.Label(), source, err)
+				continue
+			}
+			modules[dep] = true
+		}
+	}
+
+	var added []string
+	for _, path := range reads {
+		src, err := g.srcFor(rule, path)
+		if err != nil {
+			return err
+		}
+		if src != "" && !has[src] {
+			has[src] = true
+			added = append(added, src)
+		}
+	}
+	if len(added) > 0 {
+		if _, ok := rule.Attr(rule.SrcsAttr()).(*build.CallExpr); ok {
+			log.Warningf("%v reads %v, which can't be added to its srcs as they're a glob", rule.Label(), strings.Join(added, ", "))
+		} else {
+			sort.Strings(added)
+			rule.SetOrDeleteAttr(rule.SrcsAttr(), append(rule.AttrStrings(rule.SrcsAttr()), added...))
+		}
+	}
+
+	label := rule.Label()
+	deps := make([]string, 0, len(modules))
+	for dep := range modules {
+		if dep == label {
+			continue
+		}
+		g.graph.EnsureVisibility(label, dep)
+		deps = append(deps, labels.Shorten(dep, rule.Dir))
+	}
+	sort.Strings(deps)
+	rule.SetOrDeleteAttr(depsAttrs[rule.Kind.Name], deps)
+	return nil
+}
+
+// srcFor returns what to add to the rule's srcs for a file that its Terraform files read. Where the file is in another
+// package and isn't in the srcs of a rule there, it's added to a filegroup in that package.
+func (g *Generator) srcFor(rule *edit.Rule, path string) (string, error) {
+	full := filepath.Join(rule.Dir, path)
+	if strings.HasPrefix(full, "../") {
+		log.Warningf("%v reads %v, which is outside the repo", rule.Label(), path)
+		return "", nil
+	}
+	if !isFile(full) {
+		log.Warningf("%v reads %v, which doesn't exist", rule.Label(), path)
+		return "", nil
+	}
+	pkg := g.packageOf(rule.Dir, filepath.Dir(full))
+	if pkg == rule.Dir {
+		return path, nil
+	}
+
+	rel, err := filepath.Rel(pkg, full)
+	if err != nil {
+		return "", err
+	}
+	label, err := g.ruleWithSrc(pkg, rel)
+	if err != nil || label != "" {
+		return label, err
+	}
+	label, err = g.addToFilegroup(pkg, rel)
+	if err != nil {
+		return "", err
+	}
+	g.graph.EnsureVisibility(rule.Label(), label)
+	return label, nil
+}
+
+// addToFilegroup adds a file to the srcs of the first filegroup in the package, or a new one named after the directory
+// if there isn't one
+func (g *Generator) addToFilegroup(pkg, src string) (string, error) {
+	file, err := g.graph.LoadFile(pkg)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", pkg, err)
+	}
+	var expr *build.Rule
+	if filegroups := file.Rules("filegroup"); len(filegroups) > 0 {
+		expr = filegroups[0]
+	} else {
+		name := libName(pkg)
+		if edit.FindTargetByName(file, name) != nil {
+			name += "_files"
+		}
+		expr = edit.NewRuleExpr("filegroup", name)
+		file.Stmt = append(file.Stmt, expr.Call)
+	}
+	label := edit.BuildTarget(expr.Name(), pkg, "")
+	if _, ok := expr.Attr("srcs").(*build.CallExpr); ok {
+		return "", fmt.Errorf("%v can't be added to the srcs of %v as they're a glob", src, label)
+	}
+	expr.SetAttr("srcs", edit.NewStringList(append(expr.AttrStrings("srcs"), src)))
+	return label, nil
+}
+
+// moduleTarget returns the rule for the module in a directory. If there isn't one yet, it's assumed it'll be generated
+// when puku updates that directory.
+func (g *Generator) moduleTarget(dir string) (string, error) {
+	if strings.HasPrefix(dir, "../") {
+		return "", fmt.Errorf("%v is outside the repo", dir)
+	}
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+	if rules := file.Rules("terraform_module"); len(rules) > 0 {
+		return edit.BuildTarget(rules[0].Name(), dir, ""), nil
+	}
+	files, err := ImportDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("there are no .tf files in %v", dir)
+	}
+	return edit.BuildTarget(ruleName(file, dir), dir, ""), nil
+}
+
+// ruleWithSrc returns the label of the first rule in the package with the file in its srcs, or an empty string if
+// there isn't one
+func (g *Generator) ruleWithSrc(pkg, src string) (string, error) {
+	file, err := g.graph.LoadFile(pkg)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", pkg, err)
+	}
+	for _, expr := range file.Rules("") {
+		srcs, err := g.eval.EvalGlobs(pkg, expr, "srcs")
+		if err != nil {
+			return "", err
+		}
+		for _, s := range srcs {
+			if s == src {
+				return edit.BuildTarget(expr.Name(), pkg, ""), nil
+			}
+		}
+	}
+	return "", nil
+}
+
+// packageOf returns the package that a directory belongs to. This is the nearest directory at or above it that has a
+// BUILD file, or is the package being updated, which may not have one yet. It's the repo root if there's neither.
+func (g *Generator) packageOf(current, dir string) string {
+	for dir != "." && dir != current {
+		for _, name := range g.plzConf.BuildFileNames() {
+			if isFile(filepath.Join(dir, name)) {
+				return dir
+			}
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+func isLabel(src string) bool {
+	return strings.HasPrefix(src, "//") || strings.HasPrefix(src, ":") || strings.HasPrefix(src, "@")
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
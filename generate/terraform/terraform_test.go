@@ -0,0 +1,138 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"envs/prod/main.tf": `terraform {
+  backend "gcs" {}
+}
+
+module "network" {
+  source = "../../modules/network"
+}
+
+module "dns" {
+  source = "../../modules/dns"
+}
+
+module "registry" {
+  source = "terraform-aws-modules/vpc/aws"
+}
+`,
+		"envs/prod/vars.tf": `locals {
+  startup = templatefile("${path.module}/startup.sh.tpl", {})
+  policy  = file("${path.module}/../../policies/admin.json")
+  ca      = file("${path.module}/../../certs/ca.pem")
+}
+`,
+		"envs/prod/startup.sh.tpl": "#!/bin/sh\n",
+		"envs/prod/BUILD":          "go_library(\n    name = \"prod\",\n    srcs = [\"prod.go\"],\n)\n",
+		"modules/network/main.tf":  "resource \"google_compute_network\" \"vpc\" {}\n",
+		"modules/dns/main.tf":      "",
+		"modules/dns/BUILD":        "terraform_module(\n    name = \"zones\",\n    srcs = [\n        \"main.tf\",\n        \"deleted.tf\",\n    ],\n)\n",
+		"policies/admin.json":      "{}",
+		"policies/BUILD":           "filegroup(\n    name = \"policies\",\n    srcs = [\"admin.json\"],\n)\n",
+		"certs/ca.pem":             "",
+		"certs/BUILD":              "",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{}
+
+		require.NoError(t, g.Update(conf, "envs/prod"))
+		file, err := g.graph.LoadFile("envs/prod")
+		require.NoError(t, err)
+
+		t.Run("generates a root for a backend", func(t *testing.T) {
+			rule := edit.FindTargetByName(file, "prod_tf")
+			require.NotNil(t, rule)
+			assert.Equal(t, "terraform_root", rule.Kind())
+			assert.Equal(t, []string{
+				"main.tf",
+				"vars.tf",
+				"//certs",
+				"//policies",
+				"startup.sh.tpl",
+			}, rule.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//modules/dns:zones", "//modules/network"}, rule.AttrStrings("modules"))
+		})
+
+		t.Run("adds files to a filegroup", func(t *testing.T) {
+			certs, err := g.graph.LoadFile("certs")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(certs, "certs")
+			require.NotNil(t, rule)
+			assert.Equal(t, "filegroup", rule.Kind())
+			assert.Equal(t, []string{"ca.pem"}, rule.AttrStrings("srcs"))
+		})
+
+		t.Run("generates a module", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "modules/network"))
+			file, err := g.graph.LoadFile("modules/network")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "network")
+			require.NotNil(t, rule)
+			assert.Equal(t, "terraform_module", rule.Kind())
+			assert.Equal(t, []string{"main.tf"}, rule.AttrStrings("srcs"))
+			assert.Empty(t, rule.AttrStrings("deps"))
+		})
+
+		t.Run("removes deleted files", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "modules/dns"))
+			file, err := g.graph.LoadFile("modules/dns")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "zones")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"main.tf"}, rule.AttrStrings("srcs"))
+		})
+
+		t.Run("adds files to the srcs of a new package", func(t *testing.T) {
+			repotest.WriteFiles(t, ".", map[string]string{
+				"modules/lb/main.tf":            "locals {\n  config = file(\"${path.module}/config/haproxy.cfg\")\n}\n",
+				"modules/lb/config/haproxy.cfg": "",
+			})
+			require.NoError(t, g.Update(conf, "modules/lb"))
+			file, err := g.graph.LoadFile("modules/lb")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "lb")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"main.tf", "config/haproxy.cfg"}, rule.AttrStrings("srcs"))
+		})
+
+		t.Run("resolves modules and files", func(t *testing.T) {
+			files, err := g.Scan("envs/prod")
+			require.NoError(t, err)
+			require.Len(t, files, 2)
+			assert.Equal(t, "main.tf", files[0].Name)
+
+			for imp, expected := range map[string]string{
+				"../../modules/network":         "//modules/network",
+				"terraform-aws-modules/vpc/aws": "",
+				"./startup.sh.tpl":              "",
+				"../../policies/admin.json":     "//policies",
+			} {
+				label, err := g.Resolve(conf, "envs/prod", imp)
+				require.NoError(t, err)
+				assert.Equal(t, expected, label, imp)
+			}
+			_, err = g.Resolve(conf, "envs/prod", "./missing.json")
+			assert.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,104 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+)
+
+// testdataDir is the directory, relative to the package, that go test expects test fixtures to be in. This includes
+// the seed corpus for fuzz tests in testdata/fuzz.
+const testdataDir = "testdata"
+
+// updateTestdata adds the package's testdata directory to the data of its tests, or removes it if the directory no
+// longer exists. Tests that already depend on some of the testdata, e.g. specific files or a :testdata filegroup, are
+// left alone.
+func updateTestdata(pkgDir string, rules []*edit.Rule) {
+	info, err := os.Stat(filepath.Join(pkgDir, testdataDir))
+	exists := err == nil && info.IsDir()
+
+	for _, rule := range rules {
+		if !rule.IsTest() {
+			continue
+		}
+		if exists {
+			if !hasTestdata(rule.AttrStrings("data")) {
+				addMissingStrings(rule, "data", []string{testdataDir})
+			}
+		} else {
+			removeString(rule, "data", testdataDir)
+		}
+	}
+}
+
+// addFileReads adds the files that tests read with literal paths to their data, if configured to. Only files in the
+// package are added, as files elsewhere need to be exposed by a rule in their own package. Files in the testdata
+// directory are skipped, as that's added as a whole.
+func (u *updater) addFileReads(conf *config.Config, pkgDir string, rules []*edit.Rule, sources map[string]*GoFile) error {
+	if !conf.GetDetectTestData() {
+		return nil
+	}
+	for _, rule := range rules {
+		if !rule.IsTest() || rule.Kind.NonGoSources {
+			continue
+		}
+		srcs, files, err := u.allSources(conf, rule, sources)
+		if err != nil {
+			return err
+		}
+
+		var data []string
+		for _, src := range srcs {
+			f := files[src]
+			if f == nil {
+				continue
+			}
+			for _, path := range f.FileReads {
+				path = filepath.Clean(path)
+				if !filepath.IsLocal(path) || path == testdataDir || strings.HasPrefix(path, testdataDir+"/") {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(pkgDir, path)); err != nil {
+					continue
+				}
+				data = append(data, path)
+			}
+		}
+		if len(data) > 0 {
+			addMissingStrings(rule, "data", data)
+		}
+	}
+	return nil
+}
+
+// hasTestdata returns whether the data already includes the testdata directory, or anything in it
+func hasTestdata(data []string) bool {
+	for _, d := range data {
+		if d == testdataDir || strings.HasPrefix(d, testdataDir+"/") || strings.HasSuffix(d, ":"+testdataDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// removeString removes the value from the attribute, if it's a list
+func removeString(rule *edit.Rule, attr, value string) {
+	if _, ok := rule.Attr(attr).(*build.ListExpr); !ok {
+		return
+	}
+	current := rule.AttrStrings(attr)
+	values := make([]string, 0, len(current))
+	for _, v := range current {
+		if v != value {
+			values = append(values, v)
+		}
+	}
+	if len(values) != len(current) {
+		rule.SetOrDeleteAttr(attr, values)
+	}
+}
@@ -0,0 +1,109 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestUpdateTestdata(t *testing.T) {
+	fuzzKind := &kinds.Kind{Name: "go_fuzz_test", Type: kinds.Fuzz, SrcsAttr: "srcs"}
+	newRules := func(dir string) (lib, test, fuzz, filegroup *edit.Rule) {
+		lib = edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], dir)
+		test = edit.NewRule(edit.NewRuleExpr("go_test", "foo_test"), kinds.DefaultKinds["go_test"], dir)
+		fuzz = edit.NewRule(edit.NewRuleExpr("go_fuzz_test", "foo_fuzz"), fuzzKind, dir)
+		filegroup = edit.NewRule(edit.NewRuleExpr("go_test", "bar_test"), kinds.DefaultKinds["go_test"], dir)
+		filegroup.SetAttr("data", edit.NewStringList([]string{":testdata"}))
+		return
+	}
+
+	t.Run("adds testdata to tests", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "testdata", "fuzz", "FuzzFoo"), 0755))
+
+		lib, test, fuzz, filegroup := newRules(dir)
+		updateTestdata(dir, []*edit.Rule{lib, test, fuzz, filegroup})
+
+		assert.Nil(t, lib.Attr("data"))
+		assert.Equal(t, []string{"testdata"}, test.AttrStrings("data"))
+		assert.Equal(t, []string{"testdata"}, fuzz.AttrStrings("data"))
+		assert.Equal(t, []string{":testdata"}, filegroup.AttrStrings("data"))
+
+		// Running it again shouldn't change anything
+		updateTestdata(dir, []*edit.Rule{lib, test, fuzz, filegroup})
+		assert.Equal(t, []string{"testdata"}, test.AttrStrings("data"))
+	})
+
+	t.Run("leaves tests that use specific files alone", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, "testdata"), 0755))
+
+		_, test, _, _ := newRules(dir)
+		test.SetAttr("data", edit.NewStringList([]string{"testdata/golden.json"}))
+		updateTestdata(dir, []*edit.Rule{test})
+
+		assert.Equal(t, []string{"testdata/golden.json"}, test.AttrStrings("data"))
+	})
+
+	t.Run("removes testdata once it's gone", func(t *testing.T) {
+		dir := t.TempDir()
+
+		lib, test, fuzz, filegroup := newRules(dir)
+		test.SetAttr("data", edit.NewStringList([]string{"testdata", "other.json"}))
+		fuzz.SetAttr("data", edit.NewStringList([]string{"testdata"}))
+		updateTestdata(dir, []*edit.Rule{lib, test, fuzz, filegroup})
+
+		assert.Equal(t, []string{"other.json"}, test.AttrStrings("data"))
+		assert.Nil(t, fuzz.Attr("data"))
+		assert.Equal(t, []string{":testdata"}, filegroup.AttrStrings("data"))
+	})
+}
+
+func TestAddFileReads(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"fixture.json", "fixtures/bar.txt", "testdata/baz.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), nil, 0644))
+	}
+	sources := map[string]*GoFile{
+		"foo_test.go": {
+			Name:      "foo",
+			FileName:  "foo_test.go",
+			FileReads: []string{"fixture.json", "./fixtures/bar.txt", "testdata/baz.txt", "missing.json", "../outside.txt"},
+		},
+	}
+	newTest := func() *edit.Rule {
+		test := edit.NewRule(edit.NewRuleExpr("go_test", "foo_test"), kinds.DefaultKinds["go_test"], dir)
+		test.AddSrc("foo_test.go")
+		test.SetAttr("data", edit.NewStringList([]string{"testdata"}))
+		return test
+	}
+	u := newUpdater(new(please.Config), options.TestOptions)
+
+	t.Run("disabled by default", func(t *testing.T) {
+		test := newTest()
+		require.NoError(t, u.addFileReads(&config.Config{PleasePath: "plz"}, dir, []*edit.Rule{test}, sources))
+		assert.Equal(t, []string{"testdata"}, test.AttrStrings("data"))
+	})
+
+	t.Run("adds files in the package", func(t *testing.T) {
+		enabled := true
+		conf := &config.Config{PleasePath: "plz", DetectTestData: &enabled}
+		test := newTest()
+		require.NoError(t, u.addFileReads(conf, dir, []*edit.Rule{test}, sources))
+		assert.Equal(t, []string{"testdata", "fixture.json", "fixtures/bar.txt"}, test.AttrStrings("data"))
+
+		// Running it again shouldn't change anything
+		require.NoError(t, u.addFileReads(conf, dir, []*edit.Rule{test}, sources))
+		assert.Equal(t, []string{"testdata", "fixture.json", "fixtures/bar.txt"}, test.AttrStrings("data"))
+	})
+}
@@ -0,0 +1,96 @@
+package thrift
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// File represents a single Thrift IDL file
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Includes are the paths of the files it includes, as they're written in the include statements
+	Includes []string
+}
+
+var includeStmt = regexp.MustCompile(`(?m)^\s*include\s+(?:"([^"]+)"|'([^']+)')`)
+
+// IsSource returns whether the file is a Thrift IDL file
+func IsSource(name string) bool {
+	return filepath.Ext(name) == ".thrift"
+}
+
+// ImportDir reads the .thrift files in the given directory
+func ImportDir(dir string) (map[string]*File, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]*File, len(files))
+	for _, info := range files {
+		if !info.Type().IsRegular() || !IsSource(info.Name()) {
+			continue
+		}
+		bs, err := os.ReadFile(filepath.Join(dir, info.Name()))
+		if err != nil {
+			return nil, err
+		}
+		ret[info.Name()] = parseFile(info.Name(), bs)
+	}
+	return ret, nil
+}
+
+// parseFile finds the files included by a Thrift file. cpp_include statements are for the generated C++ code, so
+// aren't included.
+func parseFile(name string, src []byte) *File {
+	f := &File{Name: name}
+	seen := map[string]bool{}
+	for _, match := range includeStmt.FindAllStringSubmatch(stripComments(src), -1) {
+		path := match[1] + match[2]
+		if !seen[path] {
+			seen[path] = true
+			f.Includes = append(f.Includes, path)
+		}
+	}
+	return f
+}
+
+// stripComments removes the //, # and /* */ comments from a Thrift file, keeping the newlines so statements stay at the
+// start of their lines
+func stripComments(src []byte) string {
+	var sb strings.Builder
+	for i := 0; i < len(src); i++ {
+		switch {
+		case src[i] == '#' || (src[i] == '/' && i+1 < len(src) && src[i+1] == '/'):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+			if i < len(src) {
+				sb.WriteByte('\n')
+			}
+		case src[i] == '/' && i+1 < len(src) && src[i+1] == '*':
+			for i += 2; i < len(src) && !(src[i] == '*' && i+1 < len(src) && src[i+1] == '/'); i++ {
+				if src[i] == '\n' {
+					sb.WriteByte('\n')
+				}
+			}
+			i++
+			sb.WriteByte(' ')
+		case src[i] == '"' || src[i] == '\'':
+			quote := src[i]
+			sb.WriteByte(quote)
+			for i++; i < len(src) && src[i] != quote && src[i] != '\n'; i++ {
+				sb.WriteByte(src[i])
+			}
+			if i < len(src) {
+				sb.WriteByte(src[i])
+			}
+		default:
+			sb.WriteByte(src[i])
+		}
+	}
+	return sb.String()
+}
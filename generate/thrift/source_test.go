@@ -0,0 +1,27 @@
+package thrift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFile(t *testing.T) {
+	f := parseFile("service.thrift", []byte(`// include "commented.thrift"
+# include "hashed.thrift"
+/* include "block.thrift" */
+include "shared.thrift"
+include 'common/types.thrift'
+cpp_include "<unordered_map>"
+include "shared.thrift"
+
+namespace go service
+
+struct Request {
+  1: string url = "http://example.com" // not a comment
+}
+`))
+
+	assert.Equal(t, "service.thrift", f.Name)
+	assert.Equal(t, []string{"shared.thrift", "common/types.thrift"}, f.Includes)
+}
@@ -0,0 +1,230 @@
+// Package thrift generates a thrift_library for each Thrift IDL file in a directory, with deps on the rules for the
+// files it includes.
+package thrift
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/language"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// Subinclude is the build definitions that provide the Thrift rules
+const Subinclude = "///thrift//build_defs:thrift"
+
+// Kinds are the kinds of rule that puku generates for Thrift files
+var Kinds = map[string]*kinds.Kind{
+	"thrift_library": {
+		Name:     "thrift_library",
+		Type:     kinds.Lib,
+		SrcsAttr: "srcs",
+	},
+}
+
+// Generator updates the Thrift rules in the BUILD files of the graph
+type Generator struct {
+	plzConf *please.Config
+	graph   *graph.Graph
+	eval    *eval.Eval
+}
+
+func New(plzConf *please.Config, g *graph.Graph, e *eval.Eval) *Generator {
+	return &Generator{
+		plzConf: plzConf,
+		graph:   g,
+		eval:    e,
+	}
+}
+
+func init() {
+	language.Register(&language.Registration{
+		Names:    []string{"thrift"},
+		IsSource: IsSource,
+		New: func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) language.Language {
+			return New(plzConf, g, e)
+		},
+	})
+}
+
+// Scan reads the Thrift files in the directory
+func (g *Generator) Scan(dir string) ([]*language.File, error) {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]*language.File, 0, len(files))
+	for _, f := range files {
+		ret = append(ret, &language.File{Name: f.Name, Imports: f.Includes})
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// Resolve resolves a file included by a Thrift file in the directory to the rule that has it in its srcs
+func (g *Generator) Resolve(conf *config.Config, dir, include string) (string, error) {
+	return g.resolveInclude(conf, dir, include)
+}
+
+// Update generates a thrift_library for each Thrift file in the directory that isn't in the srcs of one already, and
+// updates the deps of the Thrift rules there based on the files their srcs include
+func (g *Generator) Update(conf *config.Config, dir string) error {
+	files, err := ImportDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	return language.UpdateRules(g.plzConf, conf, g.graph, dir, &language.Rules{
+		Kinds:      Kinds,
+		Subinclude: func(*kinds.Kind) string { return Subinclude },
+		Allocate: func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error) {
+			return g.allocateSources(file, dir, files, rules)
+		},
+		UpdateDeps: func(rule *edit.Rule) error {
+			return g.updateRuleDeps(conf, rule, files)
+		},
+	})
+}
+
+// allocateSources generates a thrift_library for each file that isn't in the srcs of one already
+func (g *Generator) allocateSources(file *build.File, dir string, files map[string]*File, rules []*edit.Rule) ([]*edit.Rule, error) {
+	allocated := map[string]bool{}
+	for _, rule := range rules {
+		srcs, err := g.eval.EvalGlobs(dir, rule.Rule, rule.SrcsAttr())
+		if err != nil {
+			return nil, err
+		}
+		for _, src := range srcs {
+			allocated[src] = true
+		}
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		if !allocated[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var newRules []*edit.Rule
+	for _, name := range names {
+		rule := edit.NewRule(edit.NewRuleExpr("thrift_library", ruleName(file.Rules(""), name)), Kinds["thrift_library"], dir)
+		rule.AddSrc(name)
+		newRules = append(newRules, rule)
+	}
+	return newRules, nil
+}
+
+// ruleName returns the name of the rule generated for a Thrift file. This is the name of the file, unless that's taken
+// by another rule, e.g. the Go library for the package.
+func ruleName(existing []*build.Rule, src string) string {
+	name := strings.TrimSuffix(src, ".thrift")
+	for _, rule := range existing {
+		if rule.Name() == name {
+			return name + "_thrift"
+		}
+	}
+	return name
+}
+
+// updateRuleDeps sets the deps of the rule to the rules for the files that its srcs include. Files that no longer exist
+// are removed from its srcs.
+func (g *Generator) updateRuleDeps(conf *config.Config, rule *edit.Rule, files map[string]*File) error {
+	srcs, err := g.eval.EvalGlobs(rule.Dir, rule.Rule, rule.SrcsAttr())
+	if err != nil {
+		return err
+	}
+
+	label := rule.Label()
+	deps := map[string]bool{}
+	for _, src := range srcs {
+		if strings.HasPrefix(src, ":") || strings.HasPrefix(src, "//") {
+			continue
+		}
+		f := files[src]
+		if f == nil {
+			rule.RemoveSrc(src) // The src doesn't exist so remove it from the list of srcs
+			continue
+		}
+		for _, include := range f.Includes {
+			dep, err := g.resolveInclude(conf, rule.Dir, include)
+			if err != nil {
+				g.graph.Unresolved(label, include, err)
+				continue
+			}
+			if dep != label {
+				deps[dep] = true
+			}
+		}
+	}
+
+	depSlice := make([]string, 0, len(deps))
+	for dep := range deps {
+		g.graph.EnsureVisibility(label, dep)
+		depSlice = append(depSlice, labels.Shorten(dep, rule.Dir))
+	}
+	sort.Strings(depSlice)
+	rule.SetOrDeleteAttr("deps", depSlice)
+	return nil
+}
+
+// resolveInclude resolves an included file to the rule that has it in its srcs. Includes are looked for relative to the
+// including file first, then the repo root and the directories in thriftIncludeDirs. Where no rule has the file yet,
+// it's assumed it'll get a rule named after it when puku updates its directory.
+func (g *Generator) resolveInclude(conf *config.Config, dir, include string) (string, error) {
+	candidates := []string{filepath.Join(dir, include), filepath.Clean(include)}
+	for _, includeDir := range conf.GetThriftIncludeDirs() {
+		candidates = append(candidates, filepath.Join(includeDir, include))
+	}
+	for _, path := range candidates {
+		if strings.HasPrefix(path, "../") || !isFile(path) {
+			continue
+		}
+		return g.fileTarget(filepath.Dir(path), filepath.Base(path))
+	}
+	return "", fmt.Errorf("no such file in %v, the repo root or thriftIncludeDirs", dir)
+}
+
+// fileTarget returns the rule that has the Thrift file in its srcs
+func (g *Generator) fileTarget(dir, src string) (string, error) {
+	file, err := g.graph.LoadFile(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse BUILD files in %v: %v", dir, err)
+	}
+	rules := file.Rules("")
+	for _, expr := range rules {
+		srcs, err := g.eval.EvalGlobs(dir, expr, "srcs")
+		if err != nil {
+			return "", err
+		}
+		for _, s := range srcs {
+			if s == src {
+				return edit.BuildTarget(expr.Name(), dir, ""), nil
+			}
+		}
+	}
+	return edit.BuildTarget(ruleName(rules, src), dir, ""), nil
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular()
+}
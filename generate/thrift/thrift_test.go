@@ -0,0 +1,70 @@
+package thrift
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/glob"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestUpdate(t *testing.T) {
+	files := map[string]string{
+		"service/service.thrift": "include \"shared.thrift\"\ninclude \"common/types.thrift\"\ninclude \"errors.thrift\"\n",
+		"service/shared.thrift":  "include \"missing.thrift\"\n",
+		"service/BUILD":          "go_library(\n    name = \"shared\",\n    srcs = [\"shared.go\"],\n)\n",
+		"common/types.thrift":    "",
+		"common/BUILD":           "thrift_library(\n    name = \"common\",\n    srcs = [\n        \"types.thrift\",\n        \"deleted.thrift\",\n    ],\n)\n",
+		"idl/errors.thrift":      "",
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		g := New(plzConf, graph.New(plzConf.BuildFileNames(), options.TestOptions), eval.New(glob.NewAllFiles(plzConf.BuildFileNames())))
+		conf := &config.Config{ThriftIncludeDirs: []string{"idl"}}
+
+		require.NoError(t, g.Update(conf, "service"))
+		file, err := g.graph.LoadFile("service")
+		require.NoError(t, err)
+
+		t.Run("generates a rule for each file", func(t *testing.T) {
+			rule := edit.FindTargetByName(file, "service")
+			require.NotNil(t, rule)
+			assert.Equal(t, "thrift_library", rule.Kind())
+			assert.Equal(t, []string{"service.thrift"}, rule.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//common", "//idl:errors", ":shared_thrift"}, rule.AttrStrings("deps"))
+		})
+
+		t.Run("doesn't clash with other rules", func(t *testing.T) {
+			rule := edit.FindTargetByName(file, "shared_thrift")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"shared.thrift"}, rule.AttrStrings("srcs"))
+			assert.Empty(t, rule.AttrStrings("deps"))
+		})
+
+		t.Run("subincludes the thrift rules", func(t *testing.T) {
+			call, ok := file.Stmt[0].(*build.CallExpr)
+			require.True(t, ok)
+			assert.Equal(t, "subinclude", call.X.(*build.Ident).Name)
+			assert.Equal(t, "///thrift//build_defs:thrift", call.List[0].(*build.StringExpr).Value)
+		})
+
+		t.Run("updates existing rules", func(t *testing.T) {
+			require.NoError(t, g.Update(conf, "common"))
+			file, err := g.graph.LoadFile("common")
+			require.NoError(t, err)
+			rule := edit.FindTargetByName(file, "common")
+			require.NotNil(t, rule)
+			assert.Equal(t, []string{"types.thrift"}, rule.AttrStrings("srcs"))
+			assert.Len(t, file.Rules("thrift_library"), 1)
+		})
+	})
+}
@@ -0,0 +1,87 @@
+package generate
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/fs"
+	"github.com/please-build/puku/kinds"
+)
+
+// vendorDep resolves an import to a library in the vendor directory. The package is queued up to have its BUILD file
+// updated, or generated if it doesn't have a library yet, in which case the target it will have is returned. Returns
+// an empty string if the package isn't vendored.
+func (u *updater) vendorDep(vendorDir, importPath string) (string, error) {
+	path := filepath.Join(vendorDir, importPath)
+	if info, err := os.Stat(path); err != nil || !info.IsDir() {
+		return "", nil
+	}
+
+	file, err := u.graph.LoadFile(path)
+	if err != nil {
+		return "", err
+	}
+	conf, err := config.ReadConfig(path)
+	if err != nil {
+		return "", err
+	}
+	for _, rule := range file.Rules("") {
+		if kind := conf.GetKind(rule.Kind()); kind != nil && kind.Type == kinds.Lib {
+			u.vendorPkgs = append(u.vendorPkgs, path)
+			return edit.BuildTarget(rule.Name(), path, ""), nil
+		}
+	}
+
+	files, err := ImportDir(path)
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		if !f.IsTest() {
+			u.vendorPkgs = append(u.vendorPkgs, path)
+			return edit.BuildTarget(filepath.Base(importPath), path, ""), nil
+		}
+	}
+	return "", nil
+}
+
+// updateVendorPkgs generates the BUILD files for the vendored packages that we've resolved imports to. Generating these
+// can resolve imports to further vendored packages, so we keep going until there are none left.
+func (u *updater) updateVendorPkgs() error {
+	done := map[string]bool{}
+	for _, path := range u.paths {
+		done[path] = true
+	}
+	for len(u.vendorPkgs) > 0 {
+		path := u.vendorPkgs[0]
+		u.vendorPkgs = u.vendorPkgs[1:]
+		if done[path] {
+			continue
+		}
+		done[path] = true
+
+		conf, err := config.ReadConfig(path)
+		if err != nil {
+			return err
+		}
+		if err := u.updateOne(conf, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setVendorVisibility makes new libraries in the vendor directory public, as any package could import them
+func setVendorVisibility(conf *config.Config, pkgDir string, rules []*edit.Rule) {
+	vendorDir := conf.GetVendorDir()
+	if vendorDir == "" || !fs.IsSubdir(vendorDir, pkgDir) {
+		return
+	}
+	for _, rule := range rules {
+		if rule.Kind.Type == kinds.Lib && rule.Attr("visibility") == nil {
+			rule.SetAttr("visibility", edit.NewStringList([]string{"PUBLIC"}))
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestVendorDeps(t *testing.T) {
+	files := map[string]string{
+		"vendor/example.com/foo/foo.go":     "package foo\n\nimport _ \"example.com/bar/baz\"\n",
+		"vendor/example.com/bar/baz/baz.go": "package baz\n",
+		"vendor/example.com/qux/BUILD":      "go_library(\n    name = \"qux_lib\",\n    srcs = [\"qux.go\"],\n)\n",
+		"vendor/example.com/qux/qux.go":     "package qux\n",
+		"vendor/modules.txt":                "# example.com/foo v1.0.0\n",
+		"puku.json":                         `{"vendorDir": "vendor"}`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		plzConf.Parse.PreloadSubincludes = []string{"///go//build_defs:go"}
+		conf, err := config.ReadConfig(".")
+		require.NoError(t, err)
+		u := newUpdater(plzConf, options.TestOptions)
+
+		t.Run("resolves imports to vendored packages", func(t *testing.T) {
+			dep, err := u.resolveImport(conf, "example.com/foo")
+			require.NoError(t, err)
+			assert.Equal(t, "//vendor/example.com/foo", dep)
+
+			dep, err = u.resolveImport(conf, "example.com/qux")
+			require.NoError(t, err)
+			assert.Equal(t, "//vendor/example.com/qux:qux_lib", dep)
+
+			_, err = u.resolveImport(conf, "example.com/missing")
+			assert.ErrorContains(t, err, "isn't vendored")
+		})
+
+		t.Run("generates the vendored packages", func(t *testing.T) {
+			require.NoError(t, u.updateVendorPkgs())
+			assert.Empty(t, u.vendorPkgs)
+
+			file, err := u.graph.LoadFile("vendor/example.com/foo")
+			require.NoError(t, err)
+			foo := edit.FindTargetByName(file, "foo")
+			require.NotNil(t, foo)
+			assert.Equal(t, []string{"foo.go"}, foo.AttrStrings("srcs"))
+			assert.Equal(t, []string{"//vendor/example.com/bar/baz"}, foo.AttrStrings("deps"))
+			assert.Equal(t, []string{"PUBLIC"}, foo.AttrStrings("visibility"))
+
+			// The transitive dependency was generated too
+			file, err = u.graph.LoadFile("vendor/example.com/bar/baz")
+			require.NoError(t, err)
+			assert.NotNil(t, edit.FindTargetByName(file, "baz"))
+		})
+	})
+}
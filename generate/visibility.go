@@ -0,0 +1,61 @@
+package generate
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/fs"
+	"github.com/please-build/puku/kinds"
+)
+
+// internalVisibility returns the visibility that libraries in the package should have to follow Go's rules for
+// importing internal packages, i.e. the subtree rooted at the parent of the last internal directory. Returns an empty
+// string if the package isn't internal.
+func internalVisibility(pkgDir string) string {
+	root, ok := internalRoot(pkgDir)
+	if !ok {
+		return ""
+	}
+	return "//" + filepath.Join(root, "...")
+}
+
+// internalRoot returns the directory that a package under internal/ can be imported from
+func internalRoot(pkgDir string) (string, bool) {
+	parts := strings.Split(filepath.ToSlash(pkgDir), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if parts[i] == "internal" {
+			return strings.Join(parts[:i], "/"), true
+		}
+	}
+	return "", false
+}
+
+// updateInternalVisibility keeps the visibility of libraries in internal packages in line with where they can be
+// imported from. We only touch visibilities that are a single subtree, as would have been generated by puku, so they
+// follow the package when it's moved. Subtrees within the allowed subtree are left alone as they're more restrictive.
+func updateInternalVisibility(pkgDir string, rules []*edit.Rule) {
+	root, ok := internalRoot(pkgDir)
+	if !ok || root == "" {
+		return // The root of the repo can import anything under //internal/...
+	}
+	want := internalVisibility(pkgDir)
+
+	for _, rule := range rules {
+		if rule.Kind.Type != kinds.Lib {
+			continue
+		}
+		vis := rule.AttrStrings("visibility")
+		if len(vis) != 1 || vis[0] == want {
+			continue
+		}
+		subtree, ok := strings.CutSuffix(vis[0], "/...")
+		if !ok || !strings.HasPrefix(subtree, "//") || strings.HasPrefix(subtree, "///") {
+			continue
+		}
+		if fs.IsSubdir(root, strings.TrimPrefix(subtree, "//")) {
+			continue
+		}
+		rule.SetAttr("visibility", edit.NewStringList([]string{want}))
+	}
+}
@@ -0,0 +1,69 @@
+package generate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+func TestInternalVisibility(t *testing.T) {
+	assert.Equal(t, "", internalVisibility("foo/bar"))
+	assert.Equal(t, "", internalVisibility("foo/internalfoo"))
+	assert.Equal(t, "//...", internalVisibility("internal/foo"))
+	assert.Equal(t, "//foo/...", internalVisibility("foo/internal"))
+	assert.Equal(t, "//foo/internal/bar/...", internalVisibility("foo/internal/bar/internal/baz"))
+}
+
+func TestUpdateInternalVisibility(t *testing.T) {
+	newLib := func(vis ...string) *edit.Rule {
+		rule := edit.NewRule(edit.NewRuleExpr("go_library", "foo"), kinds.DefaultKinds["go_library"], "bar/internal/foo")
+		if len(vis) > 0 {
+			rule.SetAttr("visibility", edit.NewStringList(vis))
+		}
+		return rule
+	}
+
+	moved := newLib("//baz/...")
+	narrower := newLib("//bar/qux/...")
+	multiple := newLib("//baz/...", "//qux/...")
+	public := newLib("PUBLIC")
+	none := newLib()
+	test := edit.NewRule(edit.NewRuleExpr("go_test", "foo_test"), kinds.DefaultKinds["go_test"], "bar/internal/foo")
+	test.SetAttr("visibility", edit.NewStringList([]string{"//baz/..."}))
+
+	updateInternalVisibility("bar/internal/foo", []*edit.Rule{moved, narrower, multiple, public, none, test})
+
+	assert.Equal(t, []string{"//bar/..."}, moved.AttrStrings("visibility"))
+	assert.Equal(t, []string{"//bar/qux/..."}, narrower.AttrStrings("visibility"))
+	assert.Equal(t, []string{"//baz/...", "//qux/..."}, multiple.AttrStrings("visibility"))
+	assert.Equal(t, []string{"PUBLIC"}, public.AttrStrings("visibility"))
+	assert.Nil(t, none.Attr("visibility"))
+	assert.Equal(t, []string{"//baz/..."}, test.AttrStrings("visibility"))
+}
+
+func TestAllocateSourcesInInternalPackage(t *testing.T) {
+	files := map[string]*GoFile{
+		"bar.go":      {Name: "bar", FileName: "bar.go"},
+		"bar_test.go": {Name: "bar", FileName: "bar_test.go"},
+	}
+
+	u := newUpdater(new(please.Config), options.TestOptions)
+	newRules, err := u.allocateSources(new(config.Config), "foo/internal/bar", files, nil)
+	require.NoError(t, err)
+	require.Len(t, newRules, 2)
+
+	for _, rule := range newRules {
+		if rule.Kind.Type == kinds.Lib {
+			assert.Equal(t, []string{"//foo/..."}, rule.AttrStrings("visibility"))
+		} else {
+			assert.Nil(t, rule.Attr("visibility"))
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package glob
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 type pattern struct {
@@ -11,6 +13,11 @@ type pattern struct {
 
 type Globber struct {
 	cache map[pattern][]string
+
+	// allFiles is set for globbers that match any file, rather than just .go files. buildFileNames are used to find the
+	// subpackages that these globs don't descend into.
+	allFiles       bool
+	buildFileNames []string
 }
 
 type Args struct {
@@ -21,6 +28,12 @@ func New() *Globber {
 	return &Globber{cache: map[pattern][]string{}}
 }
 
+// NewAllFiles returns a globber that matches files of any type, for the rules of languages other than Go. Like the
+// glob builtin, this supports ** to match any number of directories, up to any subpackages.
+func NewAllFiles(buildFileNames []string) *Globber {
+	return &Globber{cache: map[pattern][]string{}, allFiles: true, buildFileNames: buildFileNames}
+}
+
 // Glob is a specialised version of the glob builtin from Please. It assumes:
 // 1) globs should only match .go files as they're being used in go rules
 // 2) go rules will never depend on files outside the package dir, so we don't need to support **
@@ -62,6 +75,14 @@ func (g *Globber) glob(dir, glob string) ([]string, error) {
 	if res, ok := g.cache[p]; ok {
 		return res, nil
 	}
+	if g.allFiles {
+		files, err := g.globAll(dir, glob)
+		if err != nil {
+			return nil, err
+		}
+		g.cache[p] = files
+		return files, nil
+	}
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -93,3 +114,67 @@ func (g *Globber) glob(dir, glob string) ([]string, error) {
 	g.cache[p] = files
 	return files, nil
 }
+
+// globAll matches all regular files under a directory based on a glob pattern, which can match subdirectories
+func (g *Globber) globAll(dir, glob string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && (strings.HasPrefix(d.Name(), ".") || g.isPackage(path)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		match, err := matchPath(strings.Split(glob, "/"), strings.Split(filepath.ToSlash(rel), "/"))
+		if err != nil {
+			return err
+		}
+		if match {
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files, err
+}
+
+func (g *Globber) isPackage(dir string) bool {
+	for _, name := range g.buildFileNames {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && info.Mode().IsRegular() {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches the segments of a path against the segments of a glob, where ** matches any number of them
+func matchPath(glob, path []string) (bool, error) {
+	if len(glob) == 0 {
+		return len(path) == 0, nil
+	}
+	if glob[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if match, err := matchPath(glob[1:], path[i:]); match || err != nil {
+				return match, err
+			}
+		}
+		return false, nil
+	}
+	if len(path) == 0 {
+		return false, nil
+	}
+	match, err := filepath.Match(glob[0], path[0])
+	if !match || err != nil {
+		return false, err
+	}
+	return matchPath(glob[1:], path[1:])
+}
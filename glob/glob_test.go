@@ -1,6 +1,8 @@
 package glob
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -28,3 +30,30 @@ func TestGlob(t *testing.T) {
 		assert.ElementsMatch(t, []string{"main.go", "bar.go"}, files)
 	})
 }
+
+func TestGlobAllFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, path := range []string{"a.txt", "b.py", "sub/c.txt", "sub/deeper/d.txt", "pkg/BUILD", "pkg/e.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), nil, 0644))
+	}
+	g := NewAllFiles([]string{"BUILD"})
+
+	t.Run("matches files of any type", func(t *testing.T) {
+		files, err := g.Glob(dir, &Args{Include: []string{"*"}, Exclude: []string{"*.py"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt"}, files)
+	})
+
+	t.Run("matches subdirectories", func(t *testing.T) {
+		files, err := g.Glob(dir, &Args{Include: []string{"sub/*.txt"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"sub/c.txt"}, files)
+	})
+
+	t.Run("matches any number of directories with **", func(t *testing.T) {
+		files, err := g.Glob(dir, &Args{Include: []string{"**/*.txt"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []string{"a.txt", "sub/c.txt", "sub/deeper/d.txt"}, files)
+	})
+}
@@ -7,15 +7,18 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/please-build/buildtools/build"
 	"github.com/please-build/buildtools/labels"
 
 	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/diff"
 	"github.com/please-build/puku/edit"
 	"github.com/please-build/puku/logging"
 	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/report"
 )
 
 var log = logging.GetLogger()
@@ -30,6 +33,8 @@ type Graph struct {
 	deps             []*Dependency
 	experimentalDirs []string
 	opts             options.Options
+	// unresolved are the imports that couldn't be resolved since the files were last saved
+	unresolved []*report.Unresolved
 }
 
 func New(buildFileNames []string, opts options.Options) *Graph {
@@ -66,6 +71,14 @@ func (g *Graph) SetFile(path string, file *build.File) {
 	g.files[path] = file
 }
 
+// Forget drops the BUILD files loaded for the given directories, so they're read from disk again the next time they're
+// loaded. Any changes made to them that haven't been written are lost.
+func (g *Graph) Forget(paths ...string) {
+	for _, path := range paths {
+		delete(g.files, path)
+	}
+}
+
 func (g *Graph) isExperimental(label labels.Label) bool {
 	for _, e := range g.experimentalDirs {
 		if strings.HasPrefix(label.Package, e) {
@@ -125,28 +138,111 @@ func (g *Graph) loadFile(path string) (*build.File, error) {
 	return build.ParseBuild(validFilename, nil)
 }
 
+// FormatFilesWithWriter writes the BUILD files that have changed to out in the given format, rather than saving them.
+// With the json format from the options, the changes that saving them would make and the imports that couldn't be
+// resolved are written instead, in the same form as when they're saved.
 func (g *Graph) FormatFilesWithWriter(out io.Writer, format string) error {
 	if err := g.ensureVisibilities(); err != nil {
 		return err
 	}
-	for _, file := range g.files {
-		if err := writeFormattedBuildFile(file, out, format, g.opts); err != nil {
+	if g.opts.Format == "json" {
+		results := g.newResults()
+		for _, path := range g.paths() {
+			changes, err := changesToSave(g.files[path])
+			if err != nil {
+				return err
+			}
+			if changes != nil {
+				results.Files = append(results.Files, changes)
+			}
+		}
+		return json.NewEncoder(out).Encode(results)
+	}
+	if format == "diff" {
+		conf, err := config.ReadConfig(".")
+		if err != nil {
+			return err
+		}
+		w, err := diff.NewWriter(out, conf)
+		if err != nil {
+			return err
+		}
+		if err := g.writeFiles(w, format); err != nil {
+			w.Close()
+			return err
+		}
+		return w.Close()
+	}
+	return g.writeFiles(out, format)
+}
+
+// writeFiles writes the BUILD files that have changed to out in the given format, ordered by path
+func (g *Graph) writeFiles(out io.Writer, format string) error {
+	for _, path := range g.paths() {
+		if err := writeFormattedBuildFile(g.files[path], out, format, g.opts); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
+// paths returns the paths of the BUILD files that have been loaded, in order
+func (g *Graph) paths() []string {
+	paths := make([]string, 0, len(g.files))
+	for path := range g.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// newResults returns the results for the json format, taking the imports that couldn't be resolved since they were
+// last returned
+func (g *Graph) newResults() *report.Results {
+	results := &report.Results{Files: []*report.File{}, Unresolved: g.unresolved}
+	if results.Unresolved == nil {
+		results.Unresolved = []*report.Unresolved{}
+	}
+	g.unresolved = nil
+	return results
+}
+
 func (g *Graph) FormatFiles() error {
+	return g.saveFiles(os.Stdout)
+}
+
+// saveFiles writes the BUILD files that have changed to disk. With the json format, the changes made to them and the
+// imports that couldn't be resolved are written to out.
+func (g *Graph) saveFiles(out io.Writer) error {
 	if err := g.ensureVisibilities(); err != nil {
 		return err
 	}
+	results := g.newResults()
 	for _, file := range g.files {
+		if g.opts.Format == "json" {
+			changes, err := changesToSave(file)
+			if err != nil {
+				return err
+			}
+			if changes != nil {
+				results.Files = append(results.Files, changes)
+			}
+		}
 		if err := saveFormattedBuildFile(file, g.opts); err != nil {
 			return err
 		}
 	}
-	return nil
+	if g.opts.Format != "json" {
+		return nil
+	}
+	sort.Slice(results.Files, func(i, j int) bool { return results.Files[i].Path < results.Files[j].Path })
+	return json.NewEncoder(out).Encode(results)
+}
+
+// Unresolved records that an import of a source of the target couldn't be resolved, and warns about it
+func (g *Graph) Unresolved(target, imp string, err error) {
+	log.Warningf("couldn't resolve %q for %v: %v", imp, target, err)
+	g.unresolved = append(g.unresolved, &report.Unresolved{Target: target, Import: imp, Error: err.Error()})
 }
 
 func (g *Graph) ensureVisibilities() error {
@@ -159,6 +255,7 @@ func (g *Graph) ensureVisibilities() error {
 			log.Warningf("failed to set visibility: %v", err)
 		}
 	}
+	g.deps = nil
 	return nil
 }
 
@@ -336,7 +433,48 @@ func outputFormattedBuildFile(buildFile *build.File, outFn func() (io.WriteClose
 	case "json":
 		e := json.NewEncoder(w)
 		return e.Encode(struct{ Path, Content string }{Path: buildFile.Path, Content: string(content)})
+	case "diff":
+		d, err := diff.Unified(buildFile.Path, actual, content)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, d)
+		return err
+	case "report":
+		changes, err := reportChanges(buildFile, actual)
+		if err != nil {
+			return err
+		}
+		return json.NewEncoder(w).Encode(changes)
 	default:
 		return fmt.Errorf("unsupported format %q", format)
 	}
 }
+
+// changesToSave reports the changes that saving a BUILD file would make to it, or nil if it wouldn't change
+func changesToSave(buildFile *build.File) (*report.File, error) {
+	if len(buildFile.Stmt) == 0 {
+		return nil, nil
+	}
+	actual, err := os.ReadFile(buildFile.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if bytes.Equal(build.FormatWithoutRewriting(buildFile), actual) {
+		return nil, nil
+	}
+	return reportChanges(buildFile, actual)
+}
+
+// reportChanges reports the changes between a BUILD file and its contents on disk, which are nil if it doesn't exist yet
+func reportChanges(buildFile *build.File, actual []byte) (*report.File, error) {
+	var before *build.File
+	if actual != nil {
+		f, err := build.ParseBuild(buildFile.Path, actual)
+		if err != nil {
+			return nil, err
+		}
+		before = f
+	}
+	return report.Diff(buildFile.Path, before, buildFile), nil
+}
@@ -2,6 +2,9 @@ package graph
 
 import (
 	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/please-build/buildtools/build"
@@ -34,6 +37,132 @@ func TestLoadBuildFile(t *testing.T) {
 	assert.Equal(t, "test_project/foo/bar/BUILD_FILE", f.Path)
 }
 
+func TestForget(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "BUILD")
+	require.NoError(t, os.WriteFile(path, []byte("go_library(name = \"foo\")\n"), 0644))
+
+	g := New([]string{"BUILD"}, options.TestOptions)
+	f, err := g.LoadFile(dir)
+	require.NoError(t, err)
+	require.Len(t, f.Rules("go_library"), 1)
+
+	require.NoError(t, os.WriteFile(path, []byte("go_library(name = \"foo\")\n\ngo_test(name = \"foo_test\")\n"), 0644))
+	f, err = g.LoadFile(dir)
+	require.NoError(t, err)
+	assert.Empty(t, f.Rules("go_test"), "the file should be cached until it's forgotten")
+
+	g.Forget(dir)
+	f, err = g.LoadFile(dir)
+	require.NoError(t, err)
+	assert.Len(t, f.Rules("go_test"), 1)
+}
+
+func TestWriteReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "BUILD")
+	require.NoError(t, os.WriteFile(path, []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n)\n"), 0644))
+
+	file, err := build.ParseBuild(path, []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n    deps = [\"//bar\"],\n)\n"))
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, writeFormattedBuildFile(file, out, "report", options.TestOptions))
+	assert.JSONEq(t, `{
+		"path": "`+path+`",
+		"rules": [{
+			"name": "foo",
+			"kind": "go_library",
+			"status": "changed",
+			"attrs": [{"attr": "deps", "reason": "missing dep", "added": ["//bar"]}]
+		}]
+	}`, out.String())
+}
+
+func TestSaveFilesJSON(t *testing.T) {
+	dir := t.TempDir()
+	unchanged := []byte("go_library(\n    name = \"bar\",\n    srcs = [\"bar.go\"],\n)\n")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "bar"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "foo"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bar", "BUILD"), unchanged, 0644))
+
+	g := New([]string{"BUILD"}, options.Options{SkipRewriting: true, Format: "json"})
+	_, err := g.LoadFile(filepath.Join(dir, "bar"))
+	require.NoError(t, err)
+
+	foo, err := g.LoadFile(filepath.Join(dir, "foo"))
+	require.NoError(t, err)
+	rule := edit.NewRuleExpr("go_library", "foo")
+	rule.SetAttr("srcs", edit.NewStringList([]string{"foo.go"}))
+	foo.Stmt = append(foo.Stmt, rule.Call)
+	g.Unresolved("//foo", "github.com/example/missing", errors.New("no such module"))
+
+	out := new(bytes.Buffer)
+	require.NoError(t, g.saveFiles(out))
+	assert.JSONEq(t, `{
+		"files": [{
+			"path": "`+filepath.Join(dir, "foo", "BUILD")+`",
+			"rules": [{
+				"name": "foo",
+				"kind": "go_library",
+				"status": "added",
+				"attrs": [{"attr": "srcs", "reason": "missing src", "added": ["foo.go"]}]
+			}]
+		}],
+		"unresolved": [{"target": "//foo", "import": "github.com/example/missing", "error": "no such module"}]
+	}`, out.String())
+	assert.FileExists(t, filepath.Join(dir, "foo", "BUILD"))
+
+	out.Reset()
+	require.NoError(t, g.saveFiles(out))
+	assert.JSONEq(t, `{"files": [], "unresolved": []}`, out.String(), "nothing has changed since the files were saved")
+}
+
+func TestFormatFilesJSON(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "BUILD")
+	before := []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n)\n")
+	require.NoError(t, os.WriteFile(path, before, 0644))
+
+	g := New([]string{"BUILD"}, options.Options{SkipRewriting: true, Format: "json"})
+	file, err := g.LoadFile(dir)
+	require.NoError(t, err)
+	edit.FindTargetByName(file, "foo").SetAttr("deps", edit.NewStringList([]string{"//bar"}))
+	g.Unresolved("//foo", "github.com/example/missing", errors.New("no such module"))
+
+	// The json format from the options takes precedence over the one for printing the files
+	out := new(bytes.Buffer)
+	require.NoError(t, g.FormatFilesWithWriter(out, "text"))
+	assert.JSONEq(t, `{
+		"files": [{
+			"path": "`+path+`",
+			"rules": [{
+				"name": "foo",
+				"kind": "go_library",
+				"status": "changed",
+				"attrs": [{"attr": "deps", "reason": "missing dep", "added": ["//bar"]}]
+			}]
+		}],
+		"unresolved": [{"target": "//foo", "import": "github.com/example/missing", "error": "no such module"}]
+	}`, out.String())
+
+	actual, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, before, actual, "the file isn't written")
+}
+
+func TestWriteDiff(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "BUILD")
+	require.NoError(t, os.WriteFile(path, []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n)\n"), 0644))
+
+	file, err := build.ParseBuild(path, []byte("go_library(\n    name = \"foo\",\n    srcs = [\"foo.go\"],\n    deps = [\"//bar\"],\n)\n"))
+	require.NoError(t, err)
+
+	out := new(bytes.Buffer)
+	require.NoError(t, writeFormattedBuildFile(file, out, "diff", options.TestOptions))
+	assert.Equal(t, "--- a/"+path+"\n+++ b/"+path+"\n@@ -1,4 +1,5 @@\n go_library(\n     name = \"foo\",\n     srcs = [\"foo.go\"],\n+    deps = [\"//bar\"],\n )\n", out.String())
+}
+
 func TestEnsureVisibility(t *testing.T) {
 	g := New(nil, options.TestOptions).WithExperimentalDirs("exp", "experimental")
 
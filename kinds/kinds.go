@@ -7,8 +7,16 @@ const (
 	Test
 	Bin
 	ThirdParty
+	Fuzz
+	Benchmark
+	Wasm
 )
 
+// IsTest returns whether targets of this type are tests. Fuzz tests and benchmarks are kinds of test.
+func (t Type) IsTest() bool {
+	return t == Test || t == Fuzz || t == Benchmark
+}
+
 // Kind is a kind of build target, e.g. go_library. These can either be library, test or binaries. They can also provide
 // dependencies e.g. you could wrap go_test to add a common testing library, in which case, we should not add it as a
 // dep.
@@ -21,6 +29,17 @@ type Kind struct {
 	// NonGoSources indicates the puku that the sources to this rule are not go so we shouldn't try to parse them to
 	// infer their deps, for example, proto_library.
 	NonGoSources bool
+	// CgoSrcsAttr is the attribute that Go sources which import "C" are passed to. It's empty when the kind doesn't
+	// support cgo.
+	CgoSrcsAttr string
+	// AsmSrcsAttr is the attribute that Go assembly sources are passed to. It's empty when the kind doesn't support
+	// assembly.
+	AsmSrcsAttr string
+}
+
+// IsCgo returns whether this kind can compile cgo sources
+func (k *Kind) IsCgo() bool {
+	return k.CgoSrcsAttr != ""
 }
 
 // IsProvided returns whether the dependency is already provided by the kind, and therefore can be omitted from the deps
@@ -37,9 +56,16 @@ func (k *Kind) IsProvided(i string) bool {
 // DefaultKinds are the base kinds that puku supports out of the box
 var DefaultKinds = map[string]*Kind{
 	"go_library": {
-		Name:     "go_library",
-		Type:     Lib,
-		SrcsAttr: "srcs",
+		Name:        "go_library",
+		Type:        Lib,
+		SrcsAttr:    "srcs",
+		AsmSrcsAttr: "asm_srcs",
+	},
+	"cgo_library": {
+		Name:        "cgo_library",
+		Type:        Lib,
+		SrcsAttr:    "go_srcs",
+		CgoSrcsAttr: "srcs",
 	},
 	"go_binary": {
 		Name:     "go_binary",
@@ -0,0 +1,131 @@
+// Package language defines the interface that puku uses to maintain the rules for languages other than Go. Languages
+// register themselves with Register, usually from an init function, and are then updated along with the Go rules in
+// each directory puku visits whenever they're listed in the languages config. This means a language can live outside
+// this repo, and be added to puku by building a binary that imports it alongside the cli package.
+package language
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/please"
+)
+
+// File is a source file of a language, along with what it imports
+type File struct {
+	// Name is the name of the file within its directory
+	Name string
+	// Imports are what the file imports, in the form that Resolve takes them
+	Imports []string
+}
+
+// Language maintains the rules for the sources of a language
+type Language interface {
+	// Scan reads the language's sources in the directory
+	Scan(dir string) ([]*File, error)
+	// Resolve returns the label of the target that provides something imported by a source in the directory. This is
+	// an empty string for imports that don't need a dep, e.g. from the standard library, and an error if nothing
+	// provides it.
+	Resolve(conf *config.Config, dir, imp string) (string, error)
+	// Update allocates the language's sources in the directory to rules, creating them as needed, and updates their
+	// deps from what the sources import
+	Update(conf *config.Config, dir string) error
+}
+
+// Factory constructs a language for a run of puku. The graph and eval are shared with the other languages, so the
+// BUILD files they load and edit are written out together at the end of the run.
+type Factory func(plzConf *please.Config, g *graph.Graph, e *eval.Eval) Language
+
+// Registration registers a language with puku
+type Registration struct {
+	// Names are the names that enable the language in the languages config. A language can have several, e.g. if it
+	// handles both Java and Kotlin, in which case it's updated when any of them are enabled.
+	Names []string
+	// IsSource returns whether a file is one of the language's sources, so puku watch knows to update its directory
+	// when it changes
+	IsSource func(name string) bool
+	// New constructs the language
+	New Factory
+}
+
+var (
+	mux           sync.Mutex
+	registrations []*Registration
+)
+
+// Register registers a language. It panics if a language is already registered with any of the same names, or if
+// the registration is incomplete.
+func Register(r *Registration) {
+	mux.Lock()
+	defer mux.Unlock()
+
+	if len(r.Names) == 0 || r.IsSource == nil || r.New == nil {
+		panic("language: registrations need names, IsSource and New")
+	}
+	for _, name := range r.Names {
+		if name == "go" {
+			panic("language: go is built in to puku, so can't be registered")
+		}
+		for _, existing := range registrations {
+			for _, n := range existing.Names {
+				if n == name {
+					panic(fmt.Sprintf("language: %v is already registered", name))
+				}
+			}
+		}
+	}
+	registrations = append(registrations, r)
+}
+
+// Registrations returns the registered languages, in the order they were registered
+func Registrations() []*Registration {
+	mux.Lock()
+	defer mux.Unlock()
+
+	return append([]*Registration{}, registrations...)
+}
+
+// IsSource returns whether the file is a source of any registered language
+func IsSource(name string) bool {
+	for _, r := range Registrations() {
+		if r.IsSource(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Backend is a registered language constructed for a run of puku
+type Backend struct {
+	Language
+	names []string
+}
+
+// NewBackends constructs each of the registered languages
+func NewBackends(plzConf *please.Config, g *graph.Graph, e *eval.Eval) []*Backend {
+	rs := Registrations()
+	ret := make([]*Backend, 0, len(rs))
+	for _, r := range rs {
+		ret = append(ret, &Backend{Language: r.New(plzConf, g, e), names: r.Names})
+	}
+	return ret
+}
+
+// Name returns a name for the language to use in messages
+func (b *Backend) Name() string {
+	return strings.Join(b.names, " and ")
+}
+
+// Enabled returns whether the config enables the language under any of its names
+func (b *Backend) Enabled(conf *config.Config) bool {
+	for _, name := range b.names {
+		if conf.HasLanguage(name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package language
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/eval"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/please"
+)
+
+type fakeLanguage struct{}
+
+func (fakeLanguage) Scan(string) ([]*File, error)                           { return nil, nil }
+func (fakeLanguage) Resolve(*config.Config, string, string) (string, error) { return "", nil }
+func (fakeLanguage) Update(*config.Config, string) error                    { return nil }
+
+func newFake(*please.Config, *graph.Graph, *eval.Eval) Language {
+	return fakeLanguage{}
+}
+
+func TestRegister(t *testing.T) {
+	Register(&Registration{
+		Names:    []string{"thrift"},
+		IsSource: func(name string) bool { return name == "service.thrift" },
+		New:      newFake,
+	})
+	Register(&Registration{
+		Names:    []string{"scala", "sbt"},
+		IsSource: func(name string) bool { return name == "Main.scala" },
+		New:      newFake,
+	})
+
+	t.Run("IsSource checks each language", func(t *testing.T) {
+		assert.True(t, IsSource("service.thrift"))
+		assert.True(t, IsSource("Main.scala"))
+		assert.False(t, IsSource("main.go"))
+	})
+
+	t.Run("backends are enabled by any of their names", func(t *testing.T) {
+		backends := NewBackends(nil, nil, nil)
+		assert.Len(t, backends, 2)
+		assert.Equal(t, "thrift", backends[0].Name())
+		assert.Equal(t, "scala and sbt", backends[1].Name())
+
+		conf := &config.Config{Languages: []string{"sbt"}}
+		assert.False(t, backends[0].Enabled(conf))
+		assert.True(t, backends[1].Enabled(conf))
+	})
+
+	t.Run("panics on duplicate names", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register(&Registration{Names: []string{"jsonnet", "thrift"}, IsSource: IsSource, New: newFake})
+		})
+	})
+
+	t.Run("panics on go", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register(&Registration{Names: []string{"go"}, IsSource: IsSource, New: newFake})
+		})
+	})
+
+	t.Run("panics on incomplete registrations", func(t *testing.T) {
+		assert.Panics(t, func() {
+			Register(&Registration{Names: []string{"terraform"}})
+		})
+	})
+}
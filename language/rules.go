@@ -0,0 +1,169 @@
+package language
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/kinds"
+	"github.com/please-build/puku/please"
+)
+
+// Rules describes how UpdateRules maintains the rules of a language in a directory
+type Rules struct {
+	// Kinds are the kinds of rule that the language maintains
+	Kinds map[string]*kinds.Kind
+	// Include returns whether an existing rule of one of the kinds is maintained. Nil includes all of them.
+	Include func(kind *kinds.Kind) bool
+	// Subinclude returns the build definitions that provide a kind of rule
+	Subinclude func(kind *kinds.Kind) string
+	// Allocate allocates the sources that don't belong to any of the rules yet, returning the rules it creates for them
+	Allocate func(file *build.File, rules []*edit.Rule) ([]*edit.Rule, error)
+	// UpdateDeps updates the deps of a rule from what its sources import
+	UpdateDeps func(rule *edit.Rule) error
+}
+
+// UpdateRules loads the BUILD file for the directory and allocates the language's sources to its rules, adding any new
+// rules to the file. The build definitions for the rules are subincluded if needed, and then their deps are updated.
+func UpdateRules(plzConf *please.Config, conf *config.Config, g *graph.Graph, dir string, r *Rules) error {
+	file, err := g.LoadFile(dir)
+	if err != nil {
+		return err
+	}
+
+	var rules []*edit.Rule
+	for _, expr := range file.Rules("") {
+		if kind, ok := r.Kinds[expr.Kind()]; ok && (r.Include == nil || r.Include(kind)) {
+			rules = append(rules, edit.NewRule(expr, kind, dir))
+		}
+	}
+
+	newRules, err := r.Allocate(file, rules)
+	if err != nil {
+		return err
+	}
+	for _, rule := range newRules {
+		file.Stmt = append(file.Stmt, rule.Call)
+	}
+	rules = append(rules, newRules...)
+
+	if conf.ShouldEnsureSubincludes() {
+		var subincludes []string
+		for _, rule := range rules {
+			subincludes = append(subincludes, r.Subinclude(rule.Kind))
+		}
+		sort.Strings(subincludes)
+		for i, subinclude := range subincludes {
+			if (i == 0 || subinclude != subincludes[i-1]) && !plzConf.IsPreloaded(subinclude) {
+				edit.EnsureSubincludeOf(file, subinclude)
+			}
+		}
+	}
+
+	for _, rule := range rules {
+		if err := r.UpdateDeps(rule); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Source is a source file that AllocateSources can allocate to a rule
+type Source interface {
+	IsTest() bool
+	IsBinary() bool
+}
+
+// Allocation describes how AllocateSources allocates the sources of a language to rules
+type Allocation struct {
+	// Kinds are the kinds of rule that the language maintains
+	Kinds map[string]*kinds.Kind
+	// Lib, Test and Bin are the kinds of rule created for library, test and binary sources
+	Lib, Test, Bin string
+	// Suffix is added to the name of a new rule when another rule in the package already has it, e.g. the Go library
+	Suffix string
+	// Add adds a source to a rule
+	Add func(rule *edit.Rule, src string)
+}
+
+// AllocateSources allocates sources to rules in the directory. Binaries get a rule each, named after the file. Tests and
+// library sources go to the first test or library rule, which is created if needed, named after the directory. This
+// returns the rules it creates.
+func AllocateSources(a *Allocation, dir string, existing []*build.Rule, rules []*edit.Rule, srcs map[string]Source) []*edit.Rule {
+	taken := map[string]bool{}
+	for _, rule := range existing {
+		taken[rule.Name()] = true
+	}
+	newRule := func(kind, name string) *edit.Rule {
+		// Go rules in the same package are named in the same way
+		if taken[name] {
+			name = strings.TrimSuffix(name, "_test") + a.Suffix
+			if kind == a.Test {
+				name += "_test"
+			}
+		}
+		taken[name] = true
+		return edit.NewRule(edit.NewRuleExpr(kind, name), a.Kinds[kind], dir)
+	}
+
+	names := make([]string, 0, len(srcs))
+	for name := range srcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var newRules []*edit.Rule
+	for _, name := range names {
+		src := srcs[name]
+		if src.IsBinary() {
+			rule := newRule(a.Bin, strings.TrimSuffix(name, filepath.Ext(name)))
+			a.Add(rule, name)
+			newRules = append(newRules, rule)
+			continue
+		}
+
+		kindType := kinds.Lib
+		if src.IsTest() {
+			kindType = kinds.Test
+		}
+		var rule *edit.Rule
+		for _, r := range append(rules, newRules...) {
+			if r.Kind.Type == kindType {
+				rule = r
+				break
+			}
+		}
+		if rule == nil {
+			if src.IsTest() {
+				rule = newRule(a.Test, LibName(dir)+"_test")
+			} else {
+				rule = newRule(a.Lib, LibName(dir))
+			}
+			newRules = append(newRules, rule)
+		}
+		a.Add(rule, name)
+	}
+	return newRules
+}
+
+// LibName returns the name of the library generated for a directory
+func LibName(dir string) string {
+	if dir == "." {
+		return "lib"
+	}
+	return filepath.Base(dir)
+}
+
+// Shorten will shorten labels to the local package
+func Shorten(pkg, label string) string {
+	if strings.HasPrefix(label, "///") || strings.HasPrefix(label, "@") {
+		return label
+	}
+	return labels.Shorten(label, pkg)
+}
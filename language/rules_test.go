@@ -0,0 +1,85 @@
+package language
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/kinds"
+)
+
+type fakeSource struct {
+	test, binary bool
+}
+
+func (s fakeSource) IsTest() bool   { return s.test }
+func (s fakeSource) IsBinary() bool { return s.binary }
+
+var fakeAllocation = &Allocation{
+	Kinds: map[string]*kinds.Kind{
+		"fake_library": {Name: "fake_library", Type: kinds.Lib, SrcsAttr: "srcs"},
+		"fake_test":    {Name: "fake_test", Type: kinds.Test, SrcsAttr: "srcs"},
+		"fake_binary":  {Name: "fake_binary", Type: kinds.Bin, SrcsAttr: "srcs"},
+	},
+	Lib:    "fake_library",
+	Test:   "fake_test",
+	Bin:    "fake_binary",
+	Suffix: "_fake",
+	Add:    func(rule *edit.Rule, src string) { rule.AddSrc(src) },
+}
+
+func TestAllocateSources(t *testing.T) {
+	srcs := map[string]Source{
+		"a.fake":      fakeSource{},
+		"b.fake":      fakeSource{},
+		"a_test.fake": fakeSource{test: true},
+		"main.fake":   fakeSource{binary: true},
+	}
+
+	t.Run("creates rules named after the directory and the binaries", func(t *testing.T) {
+		rules := AllocateSources(fakeAllocation, "foo/bar", nil, nil, srcs)
+		require.Len(t, rules, 3)
+
+		assert.Equal(t, "fake_library", rules[0].Kind.Name)
+		assert.Equal(t, "bar", rules[0].Name())
+		assert.Equal(t, []string{"a.fake", "b.fake"}, rules[0].AttrStrings("srcs"))
+
+		assert.Equal(t, "fake_test", rules[1].Kind.Name)
+		assert.Equal(t, "bar_test", rules[1].Name())
+		assert.Equal(t, []string{"a_test.fake"}, rules[1].AttrStrings("srcs"))
+
+		assert.Equal(t, "fake_binary", rules[2].Kind.Name)
+		assert.Equal(t, "main", rules[2].Name())
+		assert.Equal(t, []string{"main.fake"}, rules[2].AttrStrings("srcs"))
+	})
+
+	t.Run("adds to existing rules", func(t *testing.T) {
+		lib := edit.NewRule(edit.NewRuleExpr("fake_library", "lib"), fakeAllocation.Kinds["fake_library"], "foo")
+		rules := AllocateSources(fakeAllocation, "foo", []*build.Rule{lib.Rule}, []*edit.Rule{lib}, srcs)
+		require.Len(t, rules, 2)
+		assert.Equal(t, "foo_test", rules[0].Name())
+		assert.Equal(t, []string{"a.fake", "b.fake"}, lib.AttrStrings("srcs"))
+	})
+
+	t.Run("suffixes names taken by other rules", func(t *testing.T) {
+		existing := []*build.Rule{edit.NewRuleExpr("go_library", "foo"), edit.NewRuleExpr("go_test", "foo_test")}
+		rules := AllocateSources(fakeAllocation, "foo", existing, nil, srcs)
+		require.Len(t, rules, 3)
+		assert.Equal(t, "foo_fake", rules[0].Name())
+		assert.Equal(t, "foo_fake_test", rules[1].Name())
+	})
+}
+
+func TestLibName(t *testing.T) {
+	assert.Equal(t, "bar", LibName("foo/bar"))
+	assert.Equal(t, "lib", LibName("."))
+}
+
+func TestShorten(t *testing.T) {
+	assert.Equal(t, ":bar", Shorten("foo", "//foo:bar"))
+	assert.Equal(t, "//baz:bar", Shorten("foo", "//baz:bar"))
+	assert.Equal(t, "///python//build_defs:python", Shorten("foo", "///python//build_defs:python"))
+}
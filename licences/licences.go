@@ -1,9 +1,14 @@
 package licences
 
 import (
+	"io/fs"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
 
 	"github.com/google/go-licenses/licenses"
+	"github.com/google/licenseclassifier/v2"
 	"github.com/google/licenseclassifier/v2/assets"
 	"github.com/please-build/buildtools/build"
 
@@ -38,27 +43,18 @@ func getLicences(modPaths []string) (map[string][]string, error) {
 		if err != nil {
 			return nil, err
 		}
+		ls, err := classify(c, paths)
+		if err != nil {
+			return nil, err
+		}
 
-		var ls []string
-		done := make(map[string]struct{})
-		for _, path := range paths {
-			bs, err := os.ReadFile(path)
-			if err != nil {
+		// Fall back to looking for licence files elsewhere in the module, e.g. in a LICENSES directory
+		if len(ls) == 0 {
+			if paths, err = findNestedCandidates(modPath); err != nil {
 				return nil, err
 			}
-			result := c.Match(bs)
-			for _, m := range result.Matches {
-				if m.MatchType != "License" {
-					continue
-				}
-				if m.Confidence < 0.8 {
-					continue
-				}
-				if _, ok := done[m.Name]; ok {
-					continue
-				}
-				ls = append(ls, m.Name)
-				done[m.Name] = struct{}{}
+			if ls, err = classify(c, paths); err != nil {
+				return nil, err
 			}
 		}
 		ret[modPath] = ls
@@ -67,6 +63,63 @@ func getLicences(modPaths []string) (map[string][]string, error) {
 	return ret, nil
 }
 
+// classify returns the names of the licences found in the files
+func classify(c *classifier.Classifier, paths []string) ([]string, error) {
+	var ls []string
+	done := make(map[string]struct{})
+	for _, path := range paths {
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		result := c.Match(bs)
+		for _, m := range result.Matches {
+			if m.MatchType != "License" {
+				continue
+			}
+			if m.Confidence < 0.8 {
+				continue
+			}
+			if _, ok := done[m.Name]; ok {
+				continue
+			}
+			ls = append(ls, m.Name)
+			done[m.Name] = struct{}{}
+		}
+	}
+	return ls, nil
+}
+
+// licenceFile matches the names of files that contain licences
+var licenceFile = regexp.MustCompile(`^(?i)((UN)?LICEN(S|C)E|COPYING|NOTICE).*$`)
+
+// findNestedCandidates finds licence files below the root of the module. These are files with names like LICENSE or
+// COPYING, or any file in a LICENSES directory as used by REUSE. Vendored and test data directories are skipped, as
+// they contain the licences of other code.
+func findNestedCandidates(modPath string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(modPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			switch d.Name() {
+			case "vendor", "testdata", "third_party":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Dir(path) == modPath {
+			return nil // We've already checked these
+		}
+		if licenceFile.MatchString(d.Name()) || strings.EqualFold(filepath.Base(filepath.Dir(path)), "LICENSES") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
 func (l *Licenses) Update(paths []string) error {
 	if err := l.update(paths); err != nil {
 		return err
@@ -112,7 +165,7 @@ func (l *Licenses) update(paths []string) error {
 				return err
 			}
 			if downloadPath == "" {
-				return nil
+				continue
 			}
 			rules[downloadPath] = r
 			mods = append(mods, downloadPath)
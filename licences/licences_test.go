@@ -1,6 +1,8 @@
 package licences
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/please-build/buildtools/build"
@@ -61,3 +63,48 @@ go_repo(
 	require.NotNil(t, protobuf)
 	assert.ElementsMatch(t, []string{"BSD-3-Clause"}, protobuf.AttrStrings("licences"))
 }
+
+const mitLicence = `Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+`
+
+func TestGetNestedLicences(t *testing.T) {
+	write := func(dir, path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+
+	reuse := t.TempDir()
+	write(reuse, "go.mod", "module example.com/reuse\n")
+	write(reuse, "LICENSES/MIT.txt", "MIT License\n\nCopyright (c) 2024 Example\n\n"+mitLicence)
+	write(reuse, "vendor/example.com/other/LICENSE", "This isn't the licence of this module")
+
+	nested := t.TempDir()
+	write(nested, "go.mod", "module example.com/nested\n")
+	write(nested, "docs/COPYING.md", "Copyright (c) 2024 Example\n\n"+mitLicence)
+	write(nested, "testdata/LICENSE", "This isn't the licence of this module either")
+
+	none := t.TempDir()
+	write(none, "go.mod", "module example.com/none\n")
+
+	ls, err := getLicences([]string{reuse, nested, none})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"MIT"}, ls[reuse])
+	assert.Equal(t, []string{"MIT"}, ls[nested])
+	assert.Empty(t, ls[none])
+}
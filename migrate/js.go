@@ -0,0 +1,288 @@
+package migrate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/logging"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/please"
+)
+
+var log = logging.GetLogger()
+
+// packageJSON is the part of a package.json that's needed to scaffold targets for its scripts
+type packageJSON struct {
+	Name            string            `json:"name"`
+	Main            string            `json:"main"`
+	PackageManager  string            `json:"packageManager"`
+	Scripts         map[string]string `json:"scripts"`
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Workspaces      workspaces        `json:"workspaces"`
+}
+
+// workspaces are the globs of the workspace packages of a package.json. Yarn also accepts an object with the globs under
+// "packages".
+type workspaces []string
+
+func (w *workspaces) UnmarshalJSON(data []byte) error {
+	var globs []string
+	if err := json.Unmarshal(data, &globs); err == nil {
+		*w = globs
+		return nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("workspaces must be a list of globs, or an object with the globs under packages: %w", err)
+	}
+	*w = obj.Packages
+	return nil
+}
+
+// jsPackage is a directory with a package.json
+type jsPackage struct {
+	dir      string
+	manifest *packageJSON
+}
+
+// MigrateJS scaffolds Please targets from the scripts in the package.json in each of the paths, and in the packages of
+// their workspaces. The build and bundle scripts become genrules, and the test scripts become gentests, which run the
+// scripts with the package manager. They depend on the build targets of the workspace packages they depend on. These
+// are a starting point: they're not kept up to date like the rules for the languages puku supports.
+func MigrateJS(plzConf *please.Config, paths []string, opts options.Options) error {
+	g := graph.New(plzConf.BuildFileNames(), opts)
+	if err := migrateJS(g, paths); err != nil {
+		return err
+	}
+	return g.FormatFiles()
+}
+
+func MigrateJSToStdout(format string, plzConf *please.Config, paths []string, opts options.Options) error {
+	g := graph.New(plzConf.BuildFileNames(), opts)
+	if err := migrateJS(g, paths); err != nil {
+		return err
+	}
+	return g.FormatFilesWithWriter(os.Stdout, format)
+}
+
+func migrateJS(g *graph.Graph, paths []string) error {
+	for _, path := range paths {
+		root, err := readPackageJSON(path)
+		if err != nil {
+			return err
+		}
+		if root == nil {
+			return fmt.Errorf("no package.json in %v", path)
+		}
+		packages, err := workspacePackages(path, root)
+		if err != nil {
+			return err
+		}
+		manager := packageManager(path, root)
+
+		// The scripts of a workspace root usually run the scripts of its packages, which their own targets do instead
+		if len(root.Workspaces) == 0 {
+			packages = append(packages, &jsPackage{dir: path, manifest: root})
+		}
+
+		byName := make(map[string]*jsPackage, len(packages))
+		for _, pkg := range packages {
+			if pkg.manifest.Name != "" {
+				byName[pkg.manifest.Name] = pkg
+			}
+		}
+		for _, pkg := range packages {
+			if err := scaffoldPackage(g, pkg, manager, byName); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// readPackageJSON reads the package.json in a directory, returning nil if there isn't one
+func readPackageJSON(dir string) (*packageJSON, error) {
+	path := filepath.Join(dir, "package.json")
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	ret := new(packageJSON)
+	if err := json.Unmarshal(bs, ret); err != nil {
+		return nil, fmt.Errorf("failed to parse %v: %w", path, err)
+	}
+	return ret, nil
+}
+
+// workspacePackages returns the packages that match the workspace globs of the package.json in root, ordered by
+// directory
+func workspacePackages(root string, manifest *packageJSON) ([]*jsPackage, error) {
+	var ret []*jsPackage
+	done := map[string]bool{}
+	for _, glob := range manifest.Workspaces {
+		matches, err := filepath.Glob(filepath.Join(root, glob))
+		if err != nil {
+			return nil, fmt.Errorf("invalid workspace glob %q in %v: %w", glob, root, err)
+		}
+		for _, dir := range matches {
+			if done[dir] || strings.Contains(dir, "node_modules") {
+				continue
+			}
+			done[dir] = true
+			pkg, err := readPackageJSON(dir)
+			if err != nil {
+				return nil, err
+			}
+			if pkg != nil {
+				ret = append(ret, &jsPackage{dir: dir, manifest: pkg})
+			}
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].dir < ret[j].dir })
+	return ret, nil
+}
+
+// packageManager returns the package manager that runs the scripts, from the packageManager field of the package.json,
+// or the lock file next to it. This defaults to npm.
+func packageManager(dir string, manifest *packageJSON) string {
+	if manifest.PackageManager != "" {
+		name, _, _ := strings.Cut(manifest.PackageManager, "@")
+		return name
+	}
+	lockFiles := []struct{ file, manager string }{
+		{"yarn.lock", "yarn"},
+		{"pnpm-lock.yaml", "pnpm"},
+	}
+	for _, lockFile := range lockFiles {
+		if _, err := os.Stat(filepath.Join(dir, lockFile.file)); err == nil {
+			return lockFile.manager
+		}
+	}
+	return "npm"
+}
+
+// scriptKind returns the kind of rule for a script, or "" if it isn't a build, bundle or test script. Variants such as
+// test:unit count too.
+func scriptKind(script string) string {
+	name, _, _ := strings.Cut(script, ":")
+	switch name {
+	case "build", "bundle":
+		return "genrule"
+	case "test":
+		return "gentest"
+	}
+	return ""
+}
+
+// scaffoldPackage adds a rule for each of the build, bundle and test scripts of a package to its BUILD file. Rules that
+// already exist are left alone.
+func scaffoldPackage(g *graph.Graph, pkg *jsPackage, manager string, byName map[string]*jsPackage) error {
+	scripts := make([]string, 0, len(pkg.manifest.Scripts))
+	for script := range pkg.manifest.Scripts {
+		if scriptKind(script) != "" {
+			scripts = append(scripts, script)
+		}
+	}
+	if len(scripts) == 0 {
+		return nil
+	}
+	sort.Strings(scripts)
+
+	file, err := g.LoadFile(pkg.dir)
+	if err != nil {
+		return err
+	}
+
+	out := outDir(pkg.manifest)
+	deps := workspaceDeps(pkg, byName)
+	outClaimed := false
+	for _, script := range scripts {
+		name := strings.ReplaceAll(script, ":", "_")
+		if edit.FindTargetByName(file, name) != nil {
+			// The existing rule may well output the directory already
+			outClaimed = outClaimed || scriptKind(script) == "genrule"
+			log.Infof("%v already exists, so the %q script of %v wasn't migrated", edit.BuildTarget(name, pkg.dir, ""), script, pkg.dir)
+			continue
+		}
+
+		cmd := fmt.Sprintf("cd $PKG_DIR && %v run %v", manager, script)
+		rule := edit.NewRuleExpr(scriptKind(script), name)
+		if rule.Kind() == "gentest" {
+			rule.SetAttr("data", srcsGlob(out))
+			rule.SetAttr("test_cmd", edit.NewStringExpr(cmd))
+			rule.SetAttr("no_test_output", &build.Ident{Name: "True"})
+		} else {
+			// Only one rule can output the directory the scripts write to, so the others move it to one named after
+			// themselves
+			outs := out
+			if outClaimed {
+				cmd = fmt.Sprintf("%v && mv %v %v", cmd, out, name)
+				outs = name
+			}
+			outClaimed = true
+			rule.SetAttr("srcs", srcsGlob(out))
+			rule.SetAttr("outs", edit.NewStringList([]string{outs}))
+			rule.SetAttr("cmd", edit.NewStringExpr(cmd))
+		}
+		if len(deps) > 0 {
+			rule.SetAttr("deps", edit.NewStringList(deps))
+		}
+		file.Stmt = append(file.Stmt, rule.Call)
+	}
+	return nil
+}
+
+// outDir returns the directory that the build scripts of a package write to, which is guessed from its main file
+func outDir(manifest *packageJSON) string {
+	main := filepath.ToSlash(filepath.Clean(manifest.Main))
+	if dir, _, ok := strings.Cut(main, "/"); ok && dir != "." && dir != ".." {
+		return dir
+	}
+	return "dist"
+}
+
+// workspaceDeps returns the build targets of the workspace packages that a package depends on
+func workspaceDeps(pkg *jsPackage, byName map[string]*jsPackage) []string {
+	targets := map[string]bool{}
+	for _, deps := range []map[string]string{pkg.manifest.Dependencies, pkg.manifest.DevDependencies} {
+		for name := range deps {
+			dep, ok := byName[name]
+			if !ok || dep == pkg {
+				continue
+			}
+			if _, ok := dep.manifest.Scripts["build"]; ok {
+				targets[edit.BuildTarget("build", dep.dir, "")] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(targets))
+	for target := range targets {
+		ret = append(ret, target)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// srcsGlob returns a glob of the files in a package, other than its node_modules and what its scripts output
+func srcsGlob(out string) build.Expr {
+	return &build.CallExpr{
+		X: &build.Ident{Name: "glob"},
+		List: []build.Expr{
+			edit.NewStringList([]string{"**"}),
+			edit.NewAssignExpr("exclude", edit.NewStringList([]string{"node_modules/**", out + "/**"})),
+		},
+	}
+}
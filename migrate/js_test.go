@@ -0,0 +1,111 @@
+package migrate
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestMigrateJS(t *testing.T) {
+	files := map[string]string{
+		"web/package.json": `{
+  "private": true,
+  "workspaces": ["packages/*"],
+  "scripts": {"build": "yarn workspaces run build"}
+}`,
+		"web/yarn.lock": "",
+		"web/packages/ui/package.json": `{
+  "name": "@web/ui",
+  "main": "lib/index.js",
+  "scripts": {"build": "tsc", "bundle": "webpack", "test": "jest", "lint": "eslint ."}
+}`,
+		"web/packages/app/package.json": `{
+  "name": "@web/app",
+  "dependencies": {"@web/ui": "*", "react": "^18.0.0"},
+  "devDependencies": {"@web/ui": "*"},
+  "scripts": {"build": "vite build", "test:unit": "vitest"}
+}`,
+		"web/packages/app/BUILD": `genrule(
+    name = "build",
+    outs = ["dist"],
+    cmd = "vite build",
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		g := graph.New([]string{"BUILD"}, options.TestOptions)
+		require.NoError(t, migrateJS(g, []string{"web"}))
+
+		// The workspace root's scripts aren't migrated
+		root, err := g.LoadFile("web")
+		require.NoError(t, err)
+		assert.Empty(t, root.Rules(""))
+
+		ui, err := g.LoadFile("web/packages/ui")
+		require.NoError(t, err)
+		require.Len(t, ui.Rules(""), 3)
+
+		buildRule := edit.FindTargetByName(ui, "build")
+		require.NotNil(t, buildRule)
+		assert.Equal(t, "genrule", buildRule.Kind())
+		assert.Equal(t, []string{"lib"}, buildRule.AttrStrings("outs"))
+		assert.Equal(t, "cd $PKG_DIR && yarn run build", buildRule.AttrString("cmd"))
+		srcs, ok := buildRule.Attr("srcs").(*build.CallExpr)
+		require.True(t, ok)
+		assert.Equal(t, "glob", srcs.X.(*build.Ident).Name)
+		assert.Equal(t, []string{"node_modules/**", "lib/**"}, (&build.Rule{Call: srcs}).AttrStrings("exclude"))
+
+		bundle := edit.FindTargetByName(ui, "bundle")
+		require.NotNil(t, bundle)
+		assert.Equal(t, []string{"bundle"}, bundle.AttrStrings("outs"))
+		assert.Equal(t, "cd $PKG_DIR && yarn run bundle && mv lib bundle", bundle.AttrString("cmd"))
+
+		test := edit.FindTargetByName(ui, "test")
+		require.NotNil(t, test)
+		assert.Equal(t, "gentest", test.Kind())
+		assert.Equal(t, "cd $PKG_DIR && yarn run test", test.AttrString("test_cmd"))
+		assert.True(t, edit.BoolAttr(test, "no_test_output"))
+		assert.Nil(t, edit.FindTargetByName(ui, "lint"))
+
+		app, err := g.LoadFile("web/packages/app")
+		require.NoError(t, err)
+		require.Len(t, app.Rules(""), 2)
+
+		// The existing build rule is left alone
+		assert.Equal(t, "vite build", edit.FindTargetByName(app, "build").AttrString("cmd"))
+
+		unit := edit.FindTargetByName(app, "test_unit")
+		require.NotNil(t, unit)
+		assert.Equal(t, "cd $PKG_DIR && yarn run test:unit", unit.AttrString("test_cmd"))
+		assert.Equal(t, []string{"//web/packages/ui:build"}, unit.AttrStrings("deps"))
+	})
+}
+
+func TestPackageJSONWorkspaces(t *testing.T) {
+	var manifest packageJSON
+	require.NoError(t, json.Unmarshal([]byte(`{"workspaces": {"packages": ["packages/*"], "nohoist": ["**/react"]}}`), &manifest))
+	assert.Equal(t, workspaces{"packages/*"}, manifest.Workspaces)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"workspaces": ["apps/*", "libs/*"]}`), &manifest))
+	assert.Equal(t, workspaces{"apps/*", "libs/*"}, manifest.Workspaces)
+}
+
+func TestPackageManager(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, "npm", packageManager(dir, &packageJSON{}))
+	assert.Equal(t, "pnpm", packageManager(dir, &packageJSON{PackageManager: "pnpm@8.6.0"}))
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "yarn.lock"), nil, 0644))
+	assert.Equal(t, "yarn", packageManager(dir, &packageJSON{}))
+}
@@ -37,7 +37,7 @@ func newMigrator(plzConf *please.Config, conf *config.Config, opts options.Optio
 		graph:             g,
 		thirdPartyFolder:  conf.GetThirdPartyDir(),
 		moduleRules:       map[string]*moduleParts{},
-		licences:          licences.New(proxy.New(proxy.DefaultURL), g),
+		licences:          licences.New(proxy.NewFromEnv(), g),
 		existingRepoRules: map[string]*build.Rule{},
 	}
 }
@@ -8,6 +8,9 @@ type Options struct {
 	// SkipRewriting controls whether BUILD files are rewritten with linter-style updates when updates
 	// are made.
 	SkipRewriting bool `long:"skip_rewriting" description:"When generating build files, skip linter-style rewrites"`
+	// Format is the format that the results of commands are printed in when they write BUILD files. With json, the
+	// rules changed and the imports that couldn't be resolved are printed to stdout.
+	Format string `long:"format" choice:"text" choice:"json" default:"text" description:"Format of the results of commands that write build files"` //nolint
 }
 
 // TestOptions provides sane default options for testing.
@@ -2,6 +2,7 @@ package please
 
 import (
 	"encoding/json"
+	"strings"
 )
 
 type Config struct {
@@ -9,9 +10,11 @@ type Config struct {
 		Go struct {
 			ImportPath []string `json:"importpath"`
 			Modfile    []string `json:"modfile"`
+			GoTool     []string `json:"gotool"`
 		} `json:"go"`
 	} `json:"plugin"`
-	Parse struct {
+	BuildConfig map[string]string `json:"buildconfig"`
+	Parse       struct {
 		BuildFileName      []string `json:"buildfilename"`
 		PreloadSubincludes []string `json:"preloadsubincludes"`
 		ExperimentalDir    []string `json:"experimentaldir"`
@@ -27,8 +30,13 @@ func (c *Config) ImportPath() string {
 }
 
 func (c *Config) GoIsPreloaded() bool {
+	return c.IsPreloaded("///go//build_defs:go")
+}
+
+// IsPreloaded returns whether the given build definitions are preloaded, so don't need to be subincluded
+func (c *Config) IsPreloaded(subinclude string) bool {
 	for _, i := range c.Parse.PreloadSubincludes {
-		if i == "///go//build_defs:go" {
+		if i == subinclude {
 			return true
 		}
 	}
@@ -51,6 +59,23 @@ func (c *Config) ModFile() string {
 	return c.Plugin.Go.Modfile[0]
 }
 
+// GoTool returns the Go tool configured for the Go plugin. This may be a path, or a build label for a go_toolchain
+// target.
+func (c *Config) GoTool() string {
+	if c == nil || len(c.Plugin.Go.GoTool) == 0 {
+		return ""
+	}
+	return c.Plugin.Go.GoTool[0]
+}
+
+// BuildConfigValue returns the value of a [BuildConfig] setting, as accessed via CONFIG.NAME in build files
+func (c *Config) BuildConfigValue(name string) string {
+	if c == nil {
+		return ""
+	}
+	return c.BuildConfig[strings.ToLower(strings.ReplaceAll(name, "_", "-"))]
+}
+
 func QueryConfig(plzTool string) (*Config, error) {
 	out, err := execPlease(plzTool, "query", "config", "--json")
 	if err != nil {
@@ -0,0 +1,217 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/semver"
+
+	"github.com/please-build/puku/fs"
+)
+
+// Modules matching GONOPROXY (or GOPRIVATE) are fetched directly from their git repository, like the go tool does
+// when GOPROXY=direct. We discover the repository via the go-import meta tag, and use git to find versions and read
+// go.mod files.
+
+var goImportRE = regexp.MustCompile(`<meta\s+name="go-import"\s+content="([^"]+)"`)
+
+// repo is the repository a module is hosted in, as described by a go-import meta tag
+type repo struct {
+	// prefix is the import path of the root of the repository
+	prefix string
+	url    string
+}
+
+// knownHosts are code hosts with a fixed repository layout, so we don't need to discover their repositories. This saves
+// us needing credentials for the discovery request as well as for git.
+var knownHosts = map[string]int{
+	"github.com":    3,
+	"bitbucket.org": 3,
+}
+
+// discoverRepo finds the repository that hosts the given import path
+func (proxy *Proxy) discoverRepo(path string) (*repo, error) {
+	if r, ok := proxy.repos[path]; ok {
+		return r, nil
+	}
+
+	parts := strings.Split(path, "/")
+	if n, ok := knownHosts[parts[0]]; ok && len(parts) >= n {
+		prefix := strings.Join(parts[:n], "/")
+		r := &repo{prefix: prefix, url: "https://" + prefix}
+		proxy.repos[path] = r
+		return r, nil
+	}
+
+	resp, err := proxy.get(fmt.Sprintf("https://%s?go-get=1", path))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := parseGoImport(path, string(body))
+	if err != nil {
+		return nil, err
+	}
+	proxy.repos[path] = r
+	return r, nil
+}
+
+// parseGoImport finds the go-import meta tag in the page that applies to the given import path
+func parseGoImport(path, page string) (*repo, error) {
+	for _, match := range goImportRE.FindAllStringSubmatch(page, -1) {
+		fields := strings.Fields(match[1])
+		if len(fields) != 3 || !fs.IsSubdir(fields[0], path) {
+			continue
+		}
+		if fields[1] != "git" {
+			return nil, fmt.Errorf("%v is hosted in a %v repository, but only git is supported", path, fields[1])
+		}
+		return &repo{prefix: fields[0], url: fields[2]}, nil
+	}
+	return nil, ModuleNotFound{Path: path}
+}
+
+// tagPrefix returns the prefix of the tags for a module in the repo, which is the module's directory within the repo
+func (r *repo) tagPrefix(modulePath string) string {
+	dir := r.dir(modulePath)
+	if dir == "" {
+		return ""
+	}
+	return dir + "/"
+}
+
+// dir returns the directory of the module within the repo. Major version suffixes may or may not be a directory in the
+// repo, but we assume they're not, as that's more common.
+func (r *repo) dir(modulePath string) string {
+	if prefix, _, ok := module.SplitPathVersion(modulePath); ok {
+		modulePath = prefix
+	}
+	return strings.Trim(strings.TrimPrefix(modulePath, r.prefix), "/")
+}
+
+// listDirectVersions lists the versions of a module from the tags in its git repository
+func (proxy *Proxy) listDirectVersions(modulePath string) ([]string, error) {
+	r, err := proxy.discoverRepo(modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := exec.Command("git", "ls-remote", "--tags", r.url).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags in %v: %w", r.url, err)
+	}
+	return versionsFromTags(modulePath, r.tagPrefix(modulePath), string(out)), nil
+}
+
+// versionsFromTags returns the versions of the module from the output of git ls-remote --tags, sorted from lowest to
+// highest. Only tags with the module's directory as a prefix, and that are valid for the module's major version, are
+// included.
+func versionsFromTags(modulePath, prefix, lsRemote string) []string {
+	_, pathMajor, _ := module.SplitPathVersion(modulePath)
+
+	var versions []string
+	seen := map[string]bool{}
+	for _, line := range strings.Split(lsRemote, "\n") {
+		_, ref, ok := strings.Cut(strings.TrimSpace(line), "\t")
+		if !ok {
+			continue
+		}
+		tag, ok := strings.CutPrefix(strings.TrimSuffix(ref, "^{}"), "refs/tags/"+prefix)
+		if !ok || !semver.IsValid(tag) || semver.Build(tag) != "" || seen[tag] {
+			continue
+		}
+		if module.CheckPathMajor(tag, pathMajor) != nil {
+			continue
+		}
+		seen[tag] = true
+		versions = append(versions, tag)
+	}
+	semver.Sort(versions)
+	return versions
+}
+
+// getLatestDirectVersion returns the latest version of a module from the tags in its git repository. Returns
+// ModuleNotFound if there are no tags for the module, e.g. because the path is a package within a module.
+func (proxy *Proxy) getLatestDirectVersion(modulePath string) (Module, error) {
+	versions, err := proxy.listDirectVersions(modulePath)
+	if err != nil {
+		if _, ok := err.(ModuleNotFound); ok {
+			proxy.latestVer[modulePath] = Module{}
+		}
+		return Module{}, err
+	}
+	if len(versions) == 0 {
+		proxy.latestVer[modulePath] = Module{}
+		return Module{}, ModuleNotFound{Path: modulePath}
+	}
+
+	version, err := proxy.latestAllowed(modulePath, versions)
+	if err != nil {
+		return Module{}, err
+	}
+	proxy.latestVer[modulePath] = Module{Module: modulePath, Version: version}
+	return proxy.latestVer[modulePath], nil
+}
+
+// clone checks out the module at the given version into dest
+func (proxy *Proxy) clone(mod, ver, dest string) error {
+	r, err := proxy.discoverRepo(mod)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), "clone")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	tag := r.tagPrefix(mod) + ver
+	if out, err := exec.Command("git", "clone", "--quiet", "--depth=1", "--branch", tag, r.url, tmp).CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to clone %v at %v: %w\n%s", r.url, tag, err, out)
+	}
+	if err := os.RemoveAll(filepath.Join(tmp, ".git")); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmp, r.dir(mod)), dest)
+}
+
+// getDirectGoMod reads the go.mod of the module at the given version from its git repository
+func (proxy *Proxy) getDirectGoMod(mod, ver string) (*modfile.File, error) {
+	dir, err := os.MkdirTemp("", "puku")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	modRoot := filepath.Join(dir, "mod")
+	if err := proxy.clone(mod, ver, modRoot); err != nil {
+		return nil, err
+	}
+
+	bs, err := os.ReadFile(filepath.Join(modRoot, "go.mod"))
+	if os.IsNotExist(err) {
+		// Modules without a go.mod file are treated as though they have one with just a module directive
+		bs, err = []byte(fmt.Sprintf("module %s\n", mod)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := proxy.sums.verifyGoMod(mod, ver, bs); err != nil {
+		return nil, err
+	}
+	return modfile.Parse(filepath.Join(mod, "go.mod"), bs, nil)
+}
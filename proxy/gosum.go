@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// GoSum holds the hashes from a go.sum file. The hash of a module's go.mod file is keyed by the version with a /go.mod
+// suffix, e.g. v1.2.3/go.mod, as it is in go.sum.
+type GoSum map[Module]string
+
+// ReadGoSum reads the hashes from the go.sum file at the given path. Returns nil if there isn't one.
+func ReadGoSum(path string) (GoSum, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	sums := GoSum{}
+	for i, line := range strings.Split(string(bs), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%v:%v: malformed go.sum line", path, i+1)
+		}
+		sums[Module{Module: fields[0], Version: fields[1]}] = fields[2]
+	}
+	return sums, nil
+}
+
+// Has returns whether go.sum has a hash for the go.mod of the module at the given version. This is the case for every
+// module in the build list once go.mod has been tidied.
+func (sums GoSum) Has(mod, ver string) bool {
+	_, ok := sums[Module{Module: mod, Version: ver + "/go.mod"}]
+	return ok
+}
+
+// verify checks the hash against go.sum. Modules that aren't in go.sum can't be verified, so they're allowed, though we
+// warn about them.
+func (sums GoSum) verify(mod Module, hash string) error {
+	want, ok := sums[mod]
+	if !ok {
+		log.Warningf("%v@%v isn't in go.sum, so it wasn't verified", mod.Module, mod.Version)
+		return nil
+	}
+	if want == hash {
+		return nil
+	}
+	return fmt.Errorf("checksum mismatch for %v@%v\n\tdownloaded: %v\n\tgo.sum:     %v", mod.Module, mod.Version, hash, want)
+}
+
+// verifyGoMod checks the contents of a module's go.mod file against go.sum
+func (sums GoSum) verifyGoMod(mod, ver string, body []byte) error {
+	if len(sums) == 0 {
+		return nil
+	}
+	hash, err := dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	})
+	if err != nil {
+		return err
+	}
+	return sums.verify(Module{Module: mod, Version: ver + "/go.mod"}, hash)
+}
@@ -0,0 +1,88 @@
+package proxy
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+func TestReadGoSum(t *testing.T) {
+	dir := t.TempDir()
+	goSum := "example.com/foo v1.0.0 h1:zip=\nexample.com/foo v1.0.0/go.mod h1:mod=\n\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.sum"), []byte(goSum), 0644))
+
+	sums, err := ReadGoSum(filepath.Join(dir, "go.sum"))
+	require.NoError(t, err)
+	assert.Equal(t, GoSum{
+		{Module: "example.com/foo", Version: "v1.0.0"}:        "h1:zip=",
+		{Module: "example.com/foo", Version: "v1.0.0/go.mod"}: "h1:mod=",
+	}, sums)
+	assert.True(t, sums.Has("example.com/foo", "v1.0.0"))
+	assert.False(t, sums.Has("example.com/foo", "v1.1.0"))
+
+	sums, err = ReadGoSum(filepath.Join(dir, "missing.sum"))
+	require.NoError(t, err)
+	assert.Nil(t, sums)
+}
+
+func TestVerifyWithGoSum(t *testing.T) {
+	goMod := []byte("module example.com/foo\n")
+
+	var zipBuf bytes.Buffer
+	w := zip.NewWriter(&zipBuf)
+	f, err := w.Create("example.com/foo@v1.0.0/foo.go")
+	require.NoError(t, err)
+	_, err = f.Write([]byte("package foo\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	zipPath := filepath.Join(t.TempDir(), "foo.zip")
+	require.NoError(t, os.WriteFile(zipPath, zipBuf.Bytes(), 0644))
+	zipHash, err := dirhash.HashZip(zipPath, dirhash.Hash1)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/example.com/foo/@v/v1.0.0.mod", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(goMod)
+	})
+	mux.HandleFunc("/example.com/foo/@v/v1.0.0.zip", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write(zipBuf.Bytes())
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	t.Run("matching hashes", func(t *testing.T) {
+		require.NoError(t, New(server.URL).sums.verifyGoMod("example.com/foo", "v1.0.0", goMod))
+
+		p := New(server.URL)
+		p.VerifyWith(GoSum{{Module: "example.com/foo", Version: "v1.0.0"}: zipHash})
+		modRoot, err := p.EnsureDownloaded("example.com/foo", "v1.0.0", t.TempDir())
+		require.NoError(t, err)
+
+		content, err := os.ReadFile(filepath.Join(modRoot, "foo.go"))
+		require.NoError(t, err)
+		assert.Equal(t, "package foo\n", string(content))
+	})
+
+	t.Run("mismatched hashes", func(t *testing.T) {
+		p := New(server.URL)
+		p.VerifyWith(GoSum{
+			{Module: "example.com/foo", Version: "v1.0.0"}:        "h1:wrong=",
+			{Module: "example.com/foo", Version: "v1.0.0/go.mod"}: "h1:wrong=",
+		})
+
+		_, err := p.getGoMod("example.com/foo", "v1.0.0")
+		assert.ErrorContains(t, err, "checksum mismatch")
+
+		_, err = p.EnsureDownloaded("example.com/foo", "v1.0.0", t.TempDir())
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+}
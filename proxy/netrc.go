@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netrcEntry is a set of credentials for a machine from a .netrc file
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// readNetrc reads the credentials from the .netrc file pointed to by $NETRC, or ~/.netrc, in the same way the go tool
+// does. Returns nil if there isn't one.
+func readNetrc() []netrcEntry {
+	path := os.Getenv("NETRC")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil
+		}
+		path = filepath.Join(home, ".netrc")
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	return parseNetrc(string(bs))
+}
+
+// parseNetrc parses the machine entries from a .netrc file. Like the go tool, the default entry is ignored, so
+// credentials are only ever sent to the hosts they're for. Macros aren't supported, and entries after the default entry
+// are ignored too, as they can never match.
+func parseNetrc(data string) []netrcEntry {
+	var entries []netrcEntry
+	var entry *netrcEntry
+	fields := strings.Fields(data)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine", "default":
+			if entry != nil {
+				entries = append(entries, *entry)
+				entry = nil
+			}
+			if fields[i] == "default" {
+				return entries
+			}
+			entry = &netrcEntry{}
+			if i+1 < len(fields) {
+				i++
+				entry.machine = fields[i]
+			}
+		case "login", "password", "account":
+			if entry == nil || i+1 >= len(fields) {
+				continue
+			}
+			i++
+			if fields[i-1] == "login" {
+				entry.login = fields[i]
+			} else if fields[i-1] == "password" {
+				entry.password = fields[i]
+			}
+		}
+	}
+	if entry != nil {
+		entries = append(entries, *entry)
+	}
+	return entries
+}
+
+// setAuth adds basic auth to the request from the first .netrc entry for its host
+func setAuth(req *http.Request, entries []netrcEntry) {
+	host := req.URL.Hostname()
+	for _, e := range entries {
+		if e.machine == host {
+			req.SetBasicAuth(e.login, e.password)
+			return
+		}
+	}
+}
@@ -9,13 +9,21 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 	"golang.org/x/mod/semver"
+	"golang.org/x/mod/sumdb/dirhash"
+
+	"github.com/please-build/puku/logging"
 )
 
+var log = logging.GetLogger()
+
 var DefaultURL = "https://proxy.golang.org"
 
 var client = http.DefaultClient
@@ -37,17 +45,177 @@ type Module struct {
 type Proxy struct {
 	latestVer map[string]Module
 	modFiles  map[Module]*modfile.File
-	url       string
+	excluded  map[Module]bool
+	repos     map[string]*repo
+	// url is the module proxy to use. If this is empty, all modules are fetched directly from their repositories.
+	url string
+	// disabled is returned instead of fetching modules that aren't fetched directly, as when GOPROXY=off
+	disabled error
+	// noProxy are the patterns of modules to fetch directly from their repositories rather than through the proxy
+	noProxy string
+	netrc   []netrcEntry
+	// sums are the hashes from go.sum to verify downloaded modules against
+	sums GoSum
 }
 
 func New(url string) *Proxy {
 	return &Proxy{
 		latestVer: map[string]Module{},
 		modFiles:  map[Module]*modfile.File{},
+		excluded:  map[Module]bool{},
+		repos:     map[string]*repo{},
 		url:       url,
 	}
 }
 
+// NewFromEnv creates a proxy configured from the environment in the same way as the go tool. The first proxy in
+// $GOPROXY is used, defaulting to DefaultURL. Modules matching $GONOPROXY, or $GOPRIVATE if that's not set, are fetched
+// directly from their git repositories. Credentials for both are read from .netrc.
+func NewFromEnv() *Proxy {
+	url, err := proxyURL(os.Getenv("GOPROXY"))
+	proxy := New(url)
+	proxy.disabled = err
+	proxy.noProxy = os.Getenv("GONOPROXY")
+	if proxy.noProxy == "" {
+		proxy.noProxy = os.Getenv("GOPRIVATE")
+	}
+	proxy.netrc = readNetrc()
+	return proxy
+}
+
+// errProxyOff is returned for modules that would be fetched through the proxy when GOPROXY=off
+var errProxyOff = errors.New("module lookup disabled by GOPROXY=off")
+
+// proxyURL returns the proxy to use from the value of $GOPROXY. Returns an empty string if modules should be fetched
+// directly, or errProxyOff if they can't be fetched at all. Only the first entry is used: unlike the go tool, we don't
+// fall back to the entries after it when a module can't be found.
+func proxyURL(goproxy string) (string, error) {
+	first := goproxy
+	if i := strings.IndexAny(goproxy, ",|"); i >= 0 {
+		first = goproxy[:i]
+	}
+	switch first = strings.TrimSpace(first); first {
+	case "":
+		return DefaultURL, nil
+	case "direct":
+		return "", nil
+	case "off":
+		return "", errProxyOff
+	default:
+		return strings.TrimSuffix(first, "/"), nil
+	}
+}
+
+// VerifyWith makes the proxy check any go.mod files and modules it downloads against the hashes in go.sum
+func (proxy *Proxy) VerifyWith(sums GoSum) {
+	proxy.sums = sums
+}
+
+// isDirect returns whether the module should be fetched directly from its repository rather than through the proxy.
+// Modules matching $GONOPROXY are still fetched directly when the proxy is disabled, as with the go tool.
+func (proxy *Proxy) isDirect(modulePath string) bool {
+	return (proxy.url == "" && proxy.disabled == nil) || module.MatchPrefixPatterns(proxy.noProxy, modulePath)
+}
+
+// get makes a GET request, adding any credentials for the host from .netrc
+func (proxy *Proxy) get(url string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	setAuth(req, proxy.netrc)
+	return client.Do(req)
+}
+
+// Exclude stops the proxy from choosing these module versions, in the same way exclude directives in a go.mod do. This
+// should be called before resolving any modules.
+func (proxy *Proxy) Exclude(mods ...Module) {
+	for _, mod := range mods {
+		proxy.excluded[mod] = true
+	}
+}
+
+func (proxy *Proxy) isExcluded(mod, ver string) bool {
+	return proxy.excluded[Module{Module: mod, Version: ver}]
+}
+
+// listVersions returns the tagged versions of a module known to the proxy, sorted from lowest to highest
+func (proxy *Proxy) listVersions(modulePath string) ([]string, error) {
+	if proxy.isDirect(modulePath) {
+		return proxy.listDirectVersions(modulePath)
+	}
+	if proxy.disabled != nil {
+		return nil, proxy.disabled
+	}
+
+	resp, err := proxy.get(fmt.Sprintf("%s/%s/@v/list", proxy.url, escapePath(modulePath)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status code listing versions of %v: %v", modulePath, resp.StatusCode)
+	}
+
+	versions := strings.Fields(string(b))
+	semver.Sort(versions)
+	return versions, nil
+}
+
+// latestAllowedVersion returns the latest version of the module that hasn't been excluded, preferring releases over
+// pre-releases like the go tool does
+func (proxy *Proxy) latestAllowedVersion(modulePath string) (string, error) {
+	versions, err := proxy.listVersions(modulePath)
+	if err != nil {
+		return "", err
+	}
+	return proxy.latestAllowed(modulePath, versions)
+}
+
+// latestAllowed returns the latest of the versions that hasn't been excluded, preferring releases over pre-releases
+func (proxy *Proxy) latestAllowed(modulePath string, versions []string) (string, error) {
+	preRelease := ""
+	for i := len(versions) - 1; i >= 0; i-- {
+		v := versions[i]
+		if proxy.isExcluded(modulePath, v) {
+			continue
+		}
+		if semver.Prerelease(v) == "" {
+			return v, nil
+		}
+		if preRelease == "" {
+			preRelease = v
+		}
+	}
+	if preRelease != "" {
+		return preRelease, nil
+	}
+	return "", fmt.Errorf("all versions of %v are excluded", modulePath)
+}
+
+// nextAllowedVersion returns the lowest version of the module, higher than the given version, that hasn't been
+// excluded. This is how the go tool handles requirements on excluded versions.
+func (proxy *Proxy) nextAllowedVersion(modulePath, version string) (string, error) {
+	versions, err := proxy.listVersions(modulePath)
+	if err != nil {
+		return "", err
+	}
+
+	for _, v := range versions {
+		if semver.Compare(v, version) > 0 && !proxy.isExcluded(modulePath, v) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("%v@%v is excluded and there's no later version to use instead", modulePath, version)
+}
+
 // GetLatestVersion returns the latest version for a module from the proxy. Will return an error of type ModuleNotFound
 // if no module exists for the given path
 func (proxy *Proxy) GetLatestVersion(modulePath string) (Module, error) {
@@ -58,7 +226,14 @@ func (proxy *Proxy) GetLatestVersion(modulePath string) (Module, error) {
 		return Module{}, ModuleNotFound{Path: modulePath}
 	}
 
-	resp, err := client.Get(fmt.Sprintf("%s/%s/@latest", proxy.url, strings.ToLower(modulePath)))
+	if proxy.isDirect(modulePath) {
+		return proxy.getLatestDirectVersion(modulePath)
+	}
+	if proxy.disabled != nil {
+		return Module{}, proxy.disabled
+	}
+
+	resp, err := proxy.get(fmt.Sprintf("%s/%s/@latest", proxy.url, escapePath(modulePath)))
 	if err != nil {
 		return Module{}, err
 	}
@@ -85,6 +260,12 @@ func (proxy *Proxy) GetLatestVersion(modulePath string) (Module, error) {
 		return Module{}, err
 	}
 
+	if proxy.isExcluded(modulePath, version.Version) {
+		if version.Version, err = proxy.latestAllowedVersion(modulePath); err != nil {
+			return Module{}, err
+		}
+	}
+
 	proxy.latestVer[modulePath] = Module{
 		Module:  modulePath,
 		Version: version.Version,
@@ -92,12 +273,13 @@ func (proxy *Proxy) GetLatestVersion(modulePath string) (Module, error) {
 	return proxy.latestVer[modulePath], nil
 }
 
-// ResolveModuleForPackage tries to determine the module name for a given package pattern
+// ResolveModuleForPackage tries to determine the module name for a given package pattern. The candidate module paths
+// are tried from longest to shortest, so packages in nested modules resolve to the nested module rather than its parent.
 func (proxy *Proxy) ResolveModuleForPackage(pattern string) (*Module, error) {
-	modulePath := strings.TrimSuffix(pattern, "/...")
+	importPath := strings.TrimSuffix(pattern, "/...")
 
 	var paths []string
-	for modulePath != "." {
+	for _, modulePath := range candidateModulePaths(importPath) {
 		paths = append(paths, modulePath)
 		// Try and get the latest version to determine if we've found the module part yet
 		latest, err := proxy.GetLatestVersion(modulePath)
@@ -110,10 +292,47 @@ func (proxy *Proxy) ResolveModuleForPackage(pattern string) (*Module, error) {
 		if _, ok := err.(ModuleNotFound); !ok {
 			return nil, err
 		}
+	}
+	return nil, ModuleNotFound{Path: importPath}
+}
 
-		modulePath = filepath.Dir(modulePath)
+// candidateModulePaths returns the paths of the modules that could contain the package, longest first. Paths that
+// aren't valid module paths are skipped. Major version suffixes (e.g. /v3) must be part of
+// the module path, so paths above one aren't candidates.
+func candidateModulePaths(importPath string) []string {
+	var candidates []string
+	for modulePath := importPath; modulePath != "." && modulePath != "/"; modulePath = path.Dir(modulePath) {
+		if module.CheckPath(modulePath) == nil {
+			candidates = append(candidates, modulePath)
+		}
+		if isMajorVersion(path.Base(modulePath)) {
+			break
+		}
+	}
+	return candidates
+}
+
+// isMajorVersion returns whether the path element is a major version suffix for v2 or later, e.g. v3
+func isMajorVersion(elem string) bool {
+	n, err := strconv.Atoi(strings.TrimPrefix(elem, "v"))
+	return strings.HasPrefix(elem, "v") && err == nil && n >= 2 && elem == "v"+strconv.Itoa(n)
+}
+
+// escapePath escapes upper case letters in the module path for use in proxy URLs, as the proxy protocol requires
+func escapePath(modulePath string) string {
+	escaped, err := module.EscapePath(modulePath)
+	if err != nil {
+		return modulePath
+	}
+	return escaped
+}
+
+func escapeVersion(version string) string {
+	escaped, err := module.EscapeVersion(version)
+	if err != nil {
+		return version
 	}
-	return nil, ModuleNotFound{Path: modulePath}
+	return escaped
 }
 
 // getGoModWithFallback attempts to get a go.mod for the given module and
@@ -167,6 +386,19 @@ func (proxy *Proxy) ResolveDeps(mods, newMods []*Module) ([]*Module, error) {
 	return ret, nil
 }
 
+// Requirements returns the paths of the modules required by the go.mod of the given module version
+func (proxy *Proxy) Requirements(mod, version string) ([]string, error) {
+	modFile, err := proxy.getGoModWithFallback(mod, version)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(modFile.Require))
+	for _, req := range modFile.Require {
+		ret = append(ret, req.Mod.Path)
+	}
+	return ret, nil
+}
+
 func (proxy *Proxy) getDeps(deps map[string]string, mod, version string) error {
 	modFile, err := proxy.getGoModWithFallback(mod, version)
 	if err != nil {
@@ -174,10 +406,17 @@ func (proxy *Proxy) getDeps(deps map[string]string, mod, version string) error {
 	}
 
 	for _, req := range modFile.Require {
+		reqVer := req.Mod.Version
+		if proxy.isExcluded(req.Mod.Path, reqVer) {
+			if reqVer, err = proxy.nextAllowedVersion(req.Mod.Path, reqVer); err != nil {
+				return err
+			}
+		}
+
 		oldVer, ok := deps[req.Mod.Path]
-		if !ok || semver.Compare(oldVer, req.Mod.Version) < 0 {
-			deps[req.Mod.Path] = req.Mod.Version
-			if err := proxy.getDeps(deps, req.Mod.Path, req.Mod.Version); err != nil {
+		if !ok || semver.Compare(oldVer, reqVer) < 0 {
+			deps[req.Mod.Path] = reqVer
+			if err := proxy.getDeps(deps, req.Mod.Path, reqVer); err != nil {
 				return err
 			}
 		}
@@ -192,8 +431,20 @@ func (proxy *Proxy) getGoMod(mod, ver string) (*modfile.File, error) {
 		return modFile, nil
 	}
 
-	file := fmt.Sprintf("%s/%s/@v/%s.mod", proxy.url, mod, ver)
-	resp, err := client.Get(file)
+	if proxy.isDirect(mod) {
+		modFile, err := proxy.getDirectGoMod(mod, ver)
+		if err != nil {
+			return nil, err
+		}
+		proxy.modFiles[modVer] = modFile
+		return modFile, nil
+	}
+	if proxy.disabled != nil {
+		return nil, proxy.disabled
+	}
+
+	file := fmt.Sprintf("%s/%s/@v/%s.mod", proxy.url, escapePath(mod), escapeVersion(ver))
+	resp, err := proxy.get(file)
 	if err != nil {
 		return nil, err
 	}
@@ -209,6 +460,10 @@ func (proxy *Proxy) getGoMod(mod, ver string) (*modfile.File, error) {
 		return nil, fmt.Errorf("%v %v: \n%v", file, resp.StatusCode, string(body))
 	}
 
+	if err := proxy.sums.verifyGoMod(mod, ver, body); err != nil {
+		return nil, err
+	}
+
 	modFile, err := modfile.Parse(file, body, nil)
 	if err != nil {
 		return nil, err
@@ -224,8 +479,21 @@ func (proxy *Proxy) EnsureDownloaded(mod, ver, dir string) (string, error) {
 		return modRoot, nil // seems to already exist
 	}
 
-	url := fmt.Sprintf("%v/%v/@v/%v.zip", proxy.url, mod, ver)
-	resp, err := http.DefaultClient.Get(url)
+	if proxy.isDirect(mod) {
+		if err := os.MkdirAll(filepath.Dir(modRoot), 0777); err != nil {
+			return "", err
+		}
+		if err := proxy.clone(mod, ver, modRoot); err != nil {
+			return "", err
+		}
+		return modRoot, nil
+	}
+	if proxy.disabled != nil {
+		return "", proxy.disabled
+	}
+
+	url := fmt.Sprintf("%v/%v/@v/%v.zip", proxy.url, escapePath(mod), escapeVersion(ver))
+	resp, err := proxy.get(url)
 	if err != nil {
 		return "", err
 	}
@@ -246,27 +514,61 @@ func (proxy *Proxy) EnsureDownloaded(mod, ver, dir string) (string, error) {
 		return "", err
 	}
 
+	if err := proxy.verifyZip(mod, ver, zipReader); err != nil {
+		return "", err
+	}
+
 	// Read all the files from zip archive
 	for _, zipFile := range zipReader.File {
-		path := filepath.Join(dir, zipFile.Name)
-		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
-			return "", err
-		}
-		dest, err := os.Create(path)
-		if err != nil {
-			return "", err
-		}
-		src, err := zipFile.Open()
-		if err != nil {
-			return "", err
-		}
-		if _, err := io.Copy(dest, src); err != nil {
+		if err := extractFile(zipFile, filepath.Join(dir, zipFile.Name)); err != nil {
 			return "", err
 		}
 	}
 	return modRoot, nil
 }
 
+// extractFile writes a file from the module's zip to the given path
+func extractFile(zipFile *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	src, err := zipFile.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dest, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	return dest.Close()
+}
+
+// verifyZip checks the module's zip against go.sum, in the same way dirhash.HashZip hashes it
+func (proxy *Proxy) verifyZip(mod, ver string, zipReader *zip.Reader) error {
+	if len(proxy.sums) == 0 {
+		return nil
+	}
+	files := make([]string, 0, len(zipReader.File))
+	zipFiles := make(map[string]*zip.File, len(zipReader.File))
+	for _, f := range zipReader.File {
+		files = append(files, f.Name)
+		zipFiles[f.Name] = f
+	}
+	hash, err := dirhash.Hash1(files, func(name string) (io.ReadCloser, error) {
+		return zipFiles[name].Open()
+	})
+	if err != nil {
+		return err
+	}
+	return proxy.sums.verify(Module{Module: mod, Version: ver}, hash)
+}
+
 // IsNotFound returns true if the error is ModuleNotFound
 func IsNotFound(err error) bool {
 	_, ok := err.(ModuleNotFound)
@@ -0,0 +1,273 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/example.com/foo/@latest", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`{"Version": "v1.2.0"}`))
+	})
+	mux.HandleFunc("/example.com/foo/@v/list", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("v1.0.0\nv1.2.0\nv1.1.0\nv1.3.0-rc.1\n"))
+	})
+	mux.HandleFunc("/example.com/bar/@v/v1.0.0.mod", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("module example.com/bar\n\nrequire example.com/foo v1.0.0\n"))
+	})
+	mux.HandleFunc("/example.com/foo/@v/", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("module example.com/foo\n"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExclude(t *testing.T) {
+	server := newTestServer(t)
+
+	t.Run("latest version skips excluded versions", func(t *testing.T) {
+		p := New(server.URL)
+		p.Exclude(Module{Module: "example.com/foo", Version: "v1.2.0"})
+
+		mod, err := p.GetLatestVersion("example.com/foo")
+		require.NoError(t, err)
+		assert.Equal(t, Module{Module: "example.com/foo", Version: "v1.1.0"}, mod)
+	})
+
+	t.Run("falls back to pre-releases", func(t *testing.T) {
+		p := New(server.URL)
+		p.Exclude(
+			Module{Module: "example.com/foo", Version: "v1.0.0"},
+			Module{Module: "example.com/foo", Version: "v1.1.0"},
+			Module{Module: "example.com/foo", Version: "v1.2.0"},
+		)
+
+		mod, err := p.GetLatestVersion("example.com/foo")
+		require.NoError(t, err)
+		assert.Equal(t, "v1.3.0-rc.1", mod.Version)
+	})
+
+	t.Run("resolving deps upgrades excluded requirements", func(t *testing.T) {
+		p := New(server.URL)
+		p.Exclude(Module{Module: "example.com/foo", Version: "v1.0.0"})
+
+		mods, err := p.ResolveDeps(nil, []*Module{{Module: "example.com/bar", Version: "v1.0.0"}})
+		require.NoError(t, err)
+		assert.ElementsMatch(t, []*Module{
+			{Module: "example.com/bar", Version: "v1.0.0"},
+			{Module: "example.com/foo", Version: "v1.1.0"},
+		}, mods)
+	})
+}
+
+func TestEscapesModulePaths(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/github.com/!azure/go-autorest/@v/v1.0.0-!r!c1.mod":
+			_, _ = w.Write([]byte("module github.com/Azure/go-autorest\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	p := New(server.URL)
+
+	t.Run("go.mod", func(t *testing.T) {
+		modFile, err := p.getGoMod("github.com/Azure/go-autorest", "v1.0.0-RC1")
+		require.NoError(t, err)
+		assert.Equal(t, "github.com/Azure/go-autorest", modFile.Module.Mod.Path)
+	})
+
+	t.Run("zip", func(t *testing.T) {
+		_, err := p.EnsureDownloaded("github.com/Azure/go-autorest", "v1.0.0-RC1", t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, requested, "/github.com/!azure/go-autorest/@v/v1.0.0-!r!c1.zip")
+	})
+}
+
+func TestProxyURL(t *testing.T) {
+	testCases := []struct {
+		goproxy, url string
+	}{
+		{goproxy: "", url: DefaultURL},
+		{goproxy: "https://goproxy.example.com/,direct", url: "https://goproxy.example.com"},
+		{goproxy: "https://goproxy.example.com|https://proxy.golang.org", url: "https://goproxy.example.com"},
+		{goproxy: "direct", url: ""},
+		{goproxy: "direct,https://goproxy.example.com", url: ""},
+	}
+	for _, tc := range testCases {
+		url, err := proxyURL(tc.goproxy)
+		require.NoError(t, err)
+		assert.Equal(t, tc.url, url, tc.goproxy)
+	}
+
+	_, err := proxyURL("off")
+	assert.EqualError(t, err, "module lookup disabled by GOPROXY=off")
+}
+
+func TestProxyOff(t *testing.T) {
+	p := New("")
+	p.disabled = errProxyOff
+	p.noProxy = "git.example.com"
+
+	_, err := p.GetLatestVersion("example.com/foo")
+	assert.ErrorIs(t, err, errProxyOff)
+	_, err = p.getGoMod("example.com/foo", "v1.0.0")
+	assert.ErrorIs(t, err, errProxyOff)
+	_, err = p.EnsureDownloaded("example.com/foo", "v1.0.0", t.TempDir())
+	assert.ErrorIs(t, err, errProxyOff)
+
+	// Modules matching GONOPROXY are still fetched directly
+	assert.True(t, p.isDirect("git.example.com/foo"))
+}
+
+func TestIsDirect(t *testing.T) {
+	p := New(DefaultURL)
+	p.noProxy = "*.corp.example.com,github.com/example-corp"
+
+	assert.True(t, p.isDirect("git.corp.example.com/foo/bar"))
+	assert.True(t, p.isDirect("github.com/example-corp/repo/v2"))
+	assert.False(t, p.isDirect("github.com/example/repo"))
+
+	assert.True(t, New("").isDirect("github.com/example/repo"))
+}
+
+func TestNetrc(t *testing.T) {
+	entries := parseNetrc(`
+machine goproxy.example.com
+  login user
+  password secret
+machine git.example.com login other password hunter2
+default login anonymous password guest
+machine ignored.example.com login ignored password ignored
+`)
+	assert.Equal(t, []netrcEntry{
+		{machine: "goproxy.example.com", login: "user", password: "secret"},
+		{machine: "git.example.com", login: "other", password: "hunter2"},
+	}, entries)
+
+	var username, password string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, _ = r.BasicAuth()
+		_, _ = w.Write([]byte(`{"Version": "v1.0.0"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	p := New(server.URL)
+	p.netrc = []netrcEntry{{machine: "127.0.0.1", login: "user", password: "secret"}}
+
+	_, err := p.GetLatestVersion("example.com/foo")
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "secret", password)
+
+	// The default entry isn't sent to hosts that aren't listed
+	username, password = "", ""
+	p.netrc = parseNetrc(`
+machine goproxy.example.com login user password secret
+default login anonymous password guest
+`)
+	_, err = p.GetLatestVersion("example.com/foo")
+	require.NoError(t, err)
+	assert.Empty(t, username)
+	assert.Empty(t, password)
+}
+
+func TestParseGoImport(t *testing.T) {
+	page := `<html><head>
+<meta name="go-import" content="git.example.com/other git https://git.example.com/other.git">
+<meta name="go-import" content="git.example.com/repo git https://git.example.com/repo.git">
+</head></html>`
+
+	r, err := parseGoImport("git.example.com/repo/foo", page)
+	require.NoError(t, err)
+	assert.Equal(t, &repo{prefix: "git.example.com/repo", url: "https://git.example.com/repo.git"}, r)
+
+	_, err = parseGoImport("git.example.com/missing", page)
+	assert.True(t, IsNotFound(err))
+}
+
+func TestVersionsFromTags(t *testing.T) {
+	lsRemote := "1111\trefs/tags/v1.1.0\n" +
+		"2222\trefs/tags/v1.0.0\n" +
+		"3333\trefs/tags/v1.0.0^{}\n" +
+		"4444\trefs/tags/v2.0.0\n" +
+		"5555\trefs/tags/sub/v0.1.0\n" +
+		"6666\trefs/tags/release-1\n"
+
+	r := &repo{prefix: "git.example.com/repo"}
+	assert.Equal(t, []string{"v1.0.0", "v1.1.0"}, versionsFromTags("git.example.com/repo", r.tagPrefix("git.example.com/repo"), lsRemote))
+	assert.Equal(t, []string{"v2.0.0"}, versionsFromTags("git.example.com/repo/v2", r.tagPrefix("git.example.com/repo/v2"), lsRemote))
+	assert.Equal(t, []string{"v0.1.0"}, versionsFromTags("git.example.com/repo/sub", r.tagPrefix("git.example.com/repo/sub"), lsRemote))
+	assert.Empty(t, versionsFromTags("git.example.com/repo/pkg", r.tagPrefix("git.example.com/repo/pkg"), lsRemote))
+}
+
+func TestCandidateModulePaths(t *testing.T) {
+	assert.Equal(t, []string{"example.com/foo/bar", "example.com/foo", "example.com"}, candidateModulePaths("example.com/foo/bar"))
+	assert.Equal(t, []string{"example.com/foo/v3/bar", "example.com/foo/v3"}, candidateModulePaths("example.com/foo/v3/bar"))
+	assert.Equal(t, []string{"example.com/foo/v2"}, candidateModulePaths("example.com/foo/v2"))
+	assert.Equal(t, []string{"gopkg.in/yaml.v3", "gopkg.in"}, candidateModulePaths("gopkg.in/yaml.v3"))
+	assert.Empty(t, candidateModulePaths("internal/foo"))
+}
+
+func TestResolveModuleForPackage(t *testing.T) {
+	var requested []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/example.com/foo/@latest":
+			_, _ = w.Write([]byte(`{"Version": "v1.2.0"}`))
+		case "/example.com/foo/v3/@latest":
+			_, _ = w.Write([]byte(`{"Version": "v3.0.1"}`))
+		case "/example.com/foo/nested/@latest":
+			_, _ = w.Write([]byte(`{"Version": "v0.1.0"}`))
+		case "/example.com/!upper/@latest":
+			_, _ = w.Write([]byte(`{"Version": "v1.0.0"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	p := New(server.URL)
+	mod, err := p.ResolveModuleForPackage("example.com/foo/bar")
+	require.NoError(t, err)
+	assert.Equal(t, &Module{Module: "example.com/foo", Version: "v1.2.0"}, mod)
+
+	mod, err = p.ResolveModuleForPackage("example.com/foo/nested/pkg")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/foo/nested", mod.Module)
+
+	mod, err = p.ResolveModuleForPackage("example.com/foo/v3/bar")
+	require.NoError(t, err)
+	assert.Equal(t, &Module{Module: "example.com/foo/v3", Version: "v3.0.1"}, mod)
+
+	mod, err = p.ResolveModuleForPackage("example.com/Upper/pkg")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com/Upper", mod.Module)
+
+	// The v1 module can't contain packages under a major version suffix
+	_, err = p.ResolveModuleForPackage("example.com/foo/v4/bar")
+	assert.True(t, IsNotFound(err))
+
+	// Results are cached, so resolving packages we've already seen doesn't hit the proxy again
+	requested = nil
+	_, err = p.ResolveModuleForPackage("example.com/foo/bar")
+	require.NoError(t, err)
+	_, err = p.ResolveModuleForPackage("example.com/foo/v4/bar")
+	assert.True(t, IsNotFound(err))
+	assert.Empty(t, requested)
+}
@@ -0,0 +1,262 @@
+// Package report describes the changes puku would make to a BUILD file in a form that tools can consume, e.g. so CI bots
+// can comment on the rules that need updating rather than just saying that puku would change something.
+package report
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+)
+
+// Reason is why puku would change an attribute
+type Reason string
+
+const (
+	// MissingDep is a dep that a rule needs, e.g. for an import of one of its sources, but doesn't have
+	MissingDep Reason = "missing dep"
+	// UnusedDep is a dep that a rule has but doesn't need any more
+	UnusedDep Reason = "unused dep"
+	// MissingSrc is a source file that belongs to a rule but isn't in its srcs
+	MissingSrc Reason = "missing src"
+	// DeletedSrc is a source file in the srcs of a rule that no longer exists
+	DeletedSrc Reason = "deleted src"
+	// Visibility is a change to the visibility of a rule, e.g. so a new dependent can use it
+	Visibility Reason = "visibility"
+	// Updated is any other change to an attribute
+	Updated Reason = "updated"
+)
+
+// Status is what puku would do to a rule
+type Status string
+
+const (
+	Added   Status = "added"
+	Changed Status = "changed"
+	Removed Status = "removed"
+)
+
+// File is the report for a BUILD file that puku would change
+type File struct {
+	Path string `json:"path"`
+	// Subincludes are the build definitions puku would subinclude
+	Subincludes []string `json:"subincludes,omitempty"`
+	// Rules are the rules puku would add, change or remove. This is empty if puku would only reformat the file.
+	Rules []*Rule `json:"rules,omitempty"`
+}
+
+// Rule is a rule that puku would add, change or remove
+type Rule struct {
+	Name   string    `json:"name"`
+	Kind   string    `json:"kind"`
+	Status Status    `json:"status"`
+	Attrs  []*Change `json:"attrs,omitempty"`
+}
+
+// Change is a change to an attribute of a rule. Values that are added and ones that are removed are separate changes,
+// as they're made for different reasons.
+type Change struct {
+	Attr    string   `json:"attr"`
+	Reason  Reason   `json:"reason"`
+	Added   []string `json:"added,omitempty"`
+	Removed []string `json:"removed,omitempty"`
+}
+
+// Unresolved is an import that puku couldn't resolve to a target
+type Unresolved struct {
+	// Target is the rule with the source that has the import
+	Target string `json:"target"`
+	Import string `json:"import"`
+	Error  string `json:"error"`
+}
+
+// Results are the results of a command that updates BUILD files, for the json output format
+type Results struct {
+	// Files are the BUILD files that the command changed
+	Files []*File `json:"files"`
+	// Unresolved are the imports that couldn't be resolved, so weren't added to the deps of their rules
+	Unresolved []*Unresolved `json:"unresolved"`
+}
+
+// Diff reports the changes between a BUILD file as it is on disk and as puku would write it. The file on disk can be
+// nil if it doesn't exist yet.
+func Diff(path string, before, after *build.File) *File {
+	ret := &File{Path: path}
+	if before == nil {
+		before = &build.File{}
+	}
+
+	subincludes := map[string]bool{}
+	for _, label := range subincludesOf(before) {
+		subincludes[label] = true
+	}
+	for _, label := range subincludesOf(after) {
+		if !subincludes[label] {
+			ret.Subincludes = append(ret.Subincludes, label)
+		}
+	}
+
+	existing := map[string]*build.Rule{}
+	for _, rule := range before.Rules("") {
+		if rule.Name() != "" {
+			existing[rule.Name()] = rule
+		}
+	}
+	for _, rule := range after.Rules("") {
+		if rule.Name() == "" {
+			continue
+		}
+		old, ok := existing[rule.Name()]
+		delete(existing, rule.Name())
+
+		status := Changed
+		if !ok {
+			status = Added
+			old = &build.Rule{Call: &build.CallExpr{}}
+		}
+		if changes := diffRule(old, rule); len(changes) > 0 || status == Added {
+			ret.Rules = append(ret.Rules, &Rule{Name: rule.Name(), Kind: rule.Kind(), Status: status, Attrs: changes})
+		}
+	}
+	for _, rule := range before.Rules("") {
+		if _, ok := existing[rule.Name()]; ok {
+			ret.Rules = append(ret.Rules, &Rule{Name: rule.Name(), Kind: rule.Kind(), Status: Removed})
+		}
+	}
+	sort.SliceStable(ret.Rules, func(i, j int) bool { return ret.Rules[i].Name < ret.Rules[j].Name })
+	return ret
+}
+
+// diffRule returns the changes between the attributes of two versions of a rule, ordered by attribute
+func diffRule(before, after *build.Rule) []*Change {
+	attrs := map[string]bool{}
+	for _, attr := range append(before.AttrKeys(), after.AttrKeys()...) {
+		if attr != "name" {
+			attrs[attr] = true
+		}
+	}
+	names := make([]string, 0, len(attrs))
+	for attr := range attrs {
+		names = append(names, attr)
+	}
+	sort.Strings(names)
+
+	var ret []*Change
+	for _, attr := range names {
+		ret = append(ret, diffAttr(attr, before.Attr(attr), after.Attr(attr))...)
+	}
+	return ret
+}
+
+// diffAttr returns the changes between two values of an attribute. Lists of strings are compared as sets, as puku sorts
+// them. Anything else is reported as a whole if its formatted value has changed.
+func diffAttr(attr string, before, after build.Expr) []*Change {
+	oldValues, oldOK := stringList(before)
+	newValues, newOK := stringList(after)
+	if !oldOK || !newOK {
+		oldValue, newValue := format(before), format(after)
+		if oldValue == newValue {
+			return nil
+		}
+		change := &Change{Attr: attr, Reason: Updated}
+		if oldValue != "" {
+			change.Removed = []string{oldValue}
+		}
+		if newValue != "" {
+			change.Added = []string{newValue}
+		}
+		return []*Change{change}
+	}
+
+	added, removed := difference(newValues, oldValues), difference(oldValues, newValues)
+	var ret []*Change
+	if len(added) > 0 {
+		ret = append(ret, &Change{Attr: attr, Reason: reason(attr, true), Added: added})
+	}
+	if len(removed) > 0 {
+		ret = append(ret, &Change{Attr: attr, Reason: reason(attr, false), Removed: removed})
+	}
+	return ret
+}
+
+// reason returns the reason that values were added to or removed from an attribute
+func reason(attr string, added bool) Reason {
+	switch {
+	case attr == "visibility":
+		return Visibility
+	case strings.HasSuffix(attr, "deps"):
+		if added {
+			return MissingDep
+		}
+		return UnusedDep
+	case strings.HasSuffix(attr, "srcs") || attr == "hdrs":
+		if added {
+			return MissingSrc
+		}
+		return DeletedSrc
+	}
+	return Updated
+}
+
+// stringList returns the values of a list of strings. Missing attributes are treated as empty lists.
+func stringList(expr build.Expr) ([]string, bool) {
+	if expr == nil {
+		return nil, true
+	}
+	list, ok := expr.(*build.ListExpr)
+	if !ok {
+		return nil, false
+	}
+	ret := make([]string, 0, len(list.List))
+	for _, elem := range list.List {
+		str, ok := elem.(*build.StringExpr)
+		if !ok {
+			return nil, false
+		}
+		ret = append(ret, str.Value)
+	}
+	return ret, true
+}
+
+// difference returns the values in a that aren't in b, in the order they're in a
+func difference(a, b []string) []string {
+	in := make(map[string]bool, len(b))
+	for _, v := range b {
+		in[v] = true
+	}
+	var ret []string
+	for _, v := range a {
+		if !in[v] {
+			in[v] = true
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+func format(expr build.Expr) string {
+	if expr == nil {
+		return ""
+	}
+	return build.FormatString(expr)
+}
+
+// subincludesOf returns the labels that a file subincludes at the top level
+func subincludesOf(file *build.File) []string {
+	var ret []string
+	for _, stmt := range file.Stmt {
+		call, ok := stmt.(*build.CallExpr)
+		if !ok {
+			continue
+		}
+		if ident, ok := call.X.(*build.Ident); !ok || ident.Name != "subinclude" {
+			continue
+		}
+		for _, arg := range call.List {
+			if str, ok := arg.(*build.StringExpr); ok {
+				ret = append(ret, str.Value)
+			}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,109 @@
+package report
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, content string) *build.File {
+	t.Helper()
+	file, err := build.ParseBuild("foo/BUILD", []byte(content))
+	require.NoError(t, err)
+	return file
+}
+
+func TestDiff(t *testing.T) {
+	before := parse(t, `
+go_library(
+    name = "foo",
+    srcs = ["foo.go", "deleted.go"],
+    deps = ["//bar", "//unused"],
+)
+
+go_test(
+    name = "foo_test",
+    srcs = glob(["*_test.go"]),
+    deps = [":foo"],
+)
+
+go_binary(
+    name = "old",
+    srcs = ["main.go"],
+)
+`)
+	after := parse(t, `
+subinclude("///go//build_defs:go")
+
+go_library(
+    name = "foo",
+    srcs = ["foo.go", "new.go"],
+    visibility = ["//baz:all"],
+    deps = ["//bar", "//qux"],
+)
+
+go_test(
+    name = "foo_test",
+    srcs = glob(["*_test.go"]),
+    external = True,
+    deps = [":foo"],
+)
+
+go_library(
+    name = "lib",
+    srcs = ["lib.go"],
+)
+`)
+
+	file := Diff("foo/BUILD", before, after)
+	assert.Equal(t, "foo/BUILD", file.Path)
+	assert.Equal(t, []string{"///go//build_defs:go"}, file.Subincludes)
+	assert.Equal(t, []*Rule{
+		{
+			Name:   "foo",
+			Kind:   "go_library",
+			Status: Changed,
+			Attrs: []*Change{
+				{Attr: "deps", Reason: MissingDep, Added: []string{"//qux"}},
+				{Attr: "deps", Reason: UnusedDep, Removed: []string{"//unused"}},
+				{Attr: "srcs", Reason: MissingSrc, Added: []string{"new.go"}},
+				{Attr: "srcs", Reason: DeletedSrc, Removed: []string{"deleted.go"}},
+				{Attr: "visibility", Reason: Visibility, Added: []string{"//baz:all"}},
+			},
+		},
+		{
+			Name:   "foo_test",
+			Kind:   "go_test",
+			Status: Changed,
+			Attrs: []*Change{
+				{Attr: "external", Reason: Updated, Added: []string{"True"}},
+			},
+		},
+		{
+			Name:   "lib",
+			Kind:   "go_library",
+			Status: Added,
+			Attrs: []*Change{
+				{Attr: "srcs", Reason: MissingSrc, Added: []string{"lib.go"}},
+			},
+		},
+		{
+			Name:   "old",
+			Kind:   "go_binary",
+			Status: Removed,
+		},
+	}, file.Rules)
+}
+
+func TestDiffNewFile(t *testing.T) {
+	file := Diff("foo/BUILD", nil, parse(t, `go_library(name = "foo", srcs = ["foo.go"])`))
+	require.Len(t, file.Rules, 1)
+	assert.Equal(t, Added, file.Rules[0].Status)
+}
+
+func TestDiffReordered(t *testing.T) {
+	file := Diff("foo/BUILD", parse(t, `go_library(name = "foo", srcs = ["b.go", "a.go"])`), parse(t, `go_library(name = "foo", srcs = ["a.go", "b.go"])`))
+	assert.Empty(t, file.Rules, "reordering the srcs isn't a change to the rule")
+}
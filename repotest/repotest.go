@@ -0,0 +1,81 @@
+// Package repotest runs tests in a repo of files written for them. Puku runs from the root of the repo, reading and
+// writing files relative to it, so these tests need the repo as their working directory. Rather than changing the
+// working directory of the whole test binary, which every other test would see too, each test is run again in a child
+// process started in the repo.
+package repotest
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/please"
+)
+
+// envVar is set to the name of the test that the child process should run in the repo
+const envVar = "PUKU_REPOTEST"
+
+// Run writes the files, keyed by their path from the root of the repo, to a temporary directory and runs test with it
+// as the working directory. As test runs in a child process, the test function is run again from the start there, so
+// anything before Run shouldn't have side effects. Tests should call Run at most once.
+func Run(t *testing.T, files map[string]string, test func(t *testing.T)) {
+	t.Helper()
+
+	if os.Getenv(envVar) == t.Name() {
+		test(t)
+		return
+	}
+
+	dir := t.TempDir()
+	WriteFiles(t, dir, files)
+
+	exe, err := os.Executable()
+	require.NoError(t, err)
+
+	cmd := exec.Command(exe, "-test.run="+runPattern(t.Name()), "-test.v")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), envVar+"="+t.Name())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("%v in %v:\n%s", err, dir, out)
+	}
+	if !strings.Contains(string(out), "--- PASS: "+t.Name()+" (") {
+		t.Fatalf("%v didn't run in %v:\n%s", t.Name(), dir, out)
+	}
+	if testing.Verbose() {
+		t.Logf("%s", out)
+	}
+}
+
+// WriteFiles writes the files, keyed by their path relative to dir, creating any directories they're in. Tests can use
+// this to add files to the repo once they're running in it, with "." as the dir.
+func WriteFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for path, content := range files {
+		path = filepath.Join(dir, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+// runPattern returns the -test.run pattern that only matches the test with the given name
+func runPattern(name string) string {
+	parts := strings.Split(name, "/")
+	for i, part := range parts {
+		parts[i] = "^" + regexp.QuoteMeta(part) + "$"
+	}
+	return strings.Join(parts, "/")
+}
+
+// PleaseConfig returns the Please config for a repo whose BUILD files are named BUILD
+func PleaseConfig() *please.Config {
+	plzConf := new(please.Config)
+	plzConf.Parse.BuildFileName = []string{"BUILD"}
+	return plzConf
+}
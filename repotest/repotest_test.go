@@ -0,0 +1,29 @@
+package repotest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	Run(t, map[string]string{"foo/bar.txt": "bar"}, func(t *testing.T) {
+		content, err := os.ReadFile("foo/bar.txt")
+		require.NoError(t, err)
+		assert.Equal(t, "bar", string(content))
+	})
+
+	after, err := os.Getwd()
+	require.NoError(t, err)
+	assert.Equal(t, wd, after, "the working directory of the test binary isn't changed")
+}
+
+func TestRunPattern(t *testing.T) {
+	assert.Equal(t, "^TestFoo$", runPattern("TestFoo"))
+	assert.Equal(t, "^TestFoo$/^bar_\\(baz\\)$", runPattern("TestFoo/bar_(baz)"))
+}
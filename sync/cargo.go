@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"golang.org/x/mod/semver"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/generate/rust"
+)
+
+// rustCrate is a third party crate locked in a Cargo.lock
+type rustCrate struct {
+	Name    string
+	Version string
+	// Deps are the crates this one depends on. Where several versions of a crate are locked, these include the version,
+	// e.g. "syn 1.0.109".
+	Deps []string
+}
+
+// parseCargoLock parses the crates from a Cargo.lock. Packages without a source are the workspace's own crates, so are
+// skipped.
+func parseCargoLock(bs []byte) []*rustCrate {
+	var crates []*rustCrate
+	var crate *rustCrate
+	registry := false
+	table := ""
+
+	done := func() {
+		if crate != nil && registry && crate.Name != "" && crate.Version != "" {
+			crates = append(crates, crate)
+		}
+		crate, registry = nil, false
+	}
+
+	for _, stmt := range tomlStatements(bs) {
+		if match := tableHeader.FindStringSubmatch(stmt); match != nil {
+			table = strings.TrimSpace(match[1])
+			if strings.HasPrefix(stmt, "[[") && table == "package" {
+				done()
+				crate = &rustCrate{Deps: []string{}}
+			}
+			continue
+		}
+		match := keyValue.FindStringSubmatch(stmt)
+		if match == nil || crate == nil || table != "package" {
+			continue
+		}
+		key, value := strings.Trim(match[1], `"`), strings.TrimSpace(match[2])
+
+		switch key {
+		case "name":
+			crate.Name = unquote(value)
+		case "version":
+			crate.Version = unquote(value)
+		case "source":
+			registry = strings.HasPrefix(unquote(value), "registry+") || strings.HasPrefix(unquote(value), "sparse+")
+		case "dependencies":
+			for _, dep := range strings.Split(strings.Trim(value, "[]"), ",") {
+				// Older lock files also include the source, e.g. "syn 1.0.109 (registry+https://...)"
+				fields := strings.Fields(unquote(strings.TrimSpace(dep)))
+				if len(fields) > 2 {
+					fields = fields[:2]
+				}
+				if len(fields) > 0 {
+					crate.Deps = append(crate.Deps, strings.Join(fields, " "))
+				}
+			}
+		}
+	}
+	done()
+	return crates
+}
+
+// syncRust adds a cargo_crate to the Rust third party directory for each crate in the Cargo.lock, or updates the
+// version of the existing rule, and sets their deps to the crates they depend on. Where several versions of a crate are
+// locked, the newest is named after the crate and the others have their version appended to their name.
+func (s *syncer) syncRust(conf *config.Config) error {
+	path := conf.GetCargoLock()
+	bs, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("failed to read %v: %v", path, err)
+	}
+	crates := parseCargoLock(bs)
+
+	file, err := s.graph.LoadFile(conf.GetRustThirdPartyDir())
+	if err != nil {
+		return err
+	}
+
+	names := cargoCrateNames(crates)
+	rules := map[string]*build.Rule{}
+	for _, rule := range file.Rules("cargo_crate") {
+		rules[rule.Name()] = rule
+	}
+	for _, crate := range crates {
+		name := names[crate.Name+" "+crate.Version]
+		rule, ok := rules[name]
+		if !ok {
+			rule = edit.NewRuleExpr("cargo_crate", name)
+			if name != crate.Name {
+				rule.SetAttr("crate_name", edit.NewStringExpr(crate.Name))
+			}
+			file.Stmt = append(file.Stmt, rule.Call)
+			rules[name] = rule
+		}
+		rule.SetAttr("version", edit.NewStringExpr(crate.Version))
+	}
+
+	for _, crate := range crates {
+		deps := make([]string, 0, len(crate.Deps))
+		for _, dep := range crate.Deps {
+			if name, ok := names[dep]; ok {
+				deps = append(deps, ":"+name)
+			}
+		}
+		sort.Strings(deps)
+		rule := rules[names[crate.Name+" "+crate.Version]]
+		edit.NewRule(rule, nil, file.Pkg).SetOrDeleteAttr("deps", deps)
+	}
+
+	if len(rules) > 0 && !s.plzConf.IsPreloaded(rust.Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, rust.Subinclude)
+	}
+	return nil
+}
+
+// cargoCrateNames returns the target name for each crate, keyed by both "name version" and, for the newest version of
+// each crate, just its name as Cargo.lock refers to them in dependencies.
+func cargoCrateNames(crates []*rustCrate) map[string]string {
+	newest := map[string]*rustCrate{}
+	for _, crate := range crates {
+		if n, ok := newest[crate.Name]; !ok || semver.Compare("v"+crate.Version, "v"+n.Version) > 0 {
+			newest[crate.Name] = crate
+		}
+	}
+
+	names := map[string]string{}
+	for _, crate := range crates {
+		name := rust.CrateName(crate.Name)
+		if newest[crate.Name] == crate {
+			names[crate.Name] = name
+		} else {
+			name += "_" + strings.NewReplacer(".", "_", "-", "_", "+", "_").Replace(crate.Version)
+		}
+		names[crate.Name+" "+crate.Version] = name
+	}
+	return names
+}
@@ -0,0 +1,113 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+const cargoLock = `# This file is automatically @generated by Cargo.
+# It is not intended for manual editing.
+version = 3
+
+[[package]]
+name = "my-app"
+version = "0.1.0"
+dependencies = [
+ "proc-macro2",
+ "syn 2.0.39",
+]
+
+[[package]]
+name = "proc-macro2"
+version = "1.0.69"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+checksum = "134c189feb4956b20f6f547d2cf727d4c0fe06722b20a0eec87ed445a97f92da"
+dependencies = [
+ "unicode-ident",
+]
+
+[[package]]
+name = "syn"
+version = "1.0.109"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+dependencies = [
+ "proc-macro2",
+]
+
+[[package]]
+name = "syn"
+version = "2.0.39"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+dependencies = [
+ "proc-macro2",
+ "unicode-ident",
+]
+
+[[package]]
+name = "unicode-ident"
+version = "1.0.12"
+source = "registry+https://github.com/rust-lang/crates.io-index"
+`
+
+func TestParseCargoLock(t *testing.T) {
+	crates := parseCargoLock([]byte(cargoLock))
+	assert.Equal(t, []*rustCrate{
+		{Name: "proc-macro2", Version: "1.0.69", Deps: []string{"unicode-ident"}},
+		{Name: "syn", Version: "1.0.109", Deps: []string{"proc-macro2"}},
+		{Name: "syn", Version: "2.0.39", Deps: []string{"proc-macro2", "unicode-ident"}},
+		{Name: "unicode-ident", Version: "1.0.12", Deps: []string{}},
+	}, crates)
+}
+
+func TestSyncRust(t *testing.T) {
+	files := map[string]string{
+		"Cargo.lock": cargoLock,
+		"third_party/rust/BUILD": `cargo_crate(
+    name = "unicode_ident",
+    crate_name = "unicode-ident",
+    version = "1.0.11",
+    licences = ["MIT"],
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		plzConf.Parse.PreloadSubincludes = []string{"///rust//build_defs:rust"}
+		s := &syncer{plzConf: plzConf, graph: graph.New(plzConf.BuildFileNames(), options.TestOptions)}
+		require.NoError(t, s.syncRust(&config.Config{Languages: []string{"rust"}}))
+
+		file, err := s.graph.LoadFile("third_party/rust")
+		require.NoError(t, err)
+		require.Len(t, file.Rules("cargo_crate"), 4)
+
+		ident := edit.FindTargetByName(file, "unicode_ident")
+		assert.Equal(t, "1.0.12", ident.AttrString("version"))
+		assert.Equal(t, []string{"MIT"}, ident.AttrStrings("licences"))
+		assert.Nil(t, ident.Attr("deps"))
+
+		proc := edit.FindTargetByName(file, "proc_macro2")
+		require.NotNil(t, proc)
+		assert.Equal(t, "proc-macro2", proc.AttrString("crate_name"))
+		assert.Equal(t, []string{":unicode_ident"}, proc.AttrStrings("deps"))
+
+		syn := edit.FindTargetByName(file, "syn")
+		require.NotNil(t, syn)
+		assert.Equal(t, "2.0.39", syn.AttrString("version"))
+		assert.Equal(t, "", syn.AttrString("crate_name"))
+		assert.Equal(t, []string{":proc_macro2", ":unicode_ident"}, syn.AttrStrings("deps"))
+
+		oldSyn := edit.FindTargetByName(file, "syn_1_0_109")
+		require.NotNil(t, oldSyn)
+		assert.Equal(t, "syn", oldSyn.AttrString("crate_name"))
+		assert.Equal(t, "1.0.109", oldSyn.AttrString("version"))
+	})
+}
@@ -0,0 +1,190 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/generate/java"
+)
+
+// mavenArtifact is a third party artifact pinned to a version
+type mavenArtifact struct {
+	Group    string
+	Artifact string
+	Version  string
+}
+
+func (a *mavenArtifact) id() string {
+	return a.Group + ":" + a.Artifact
+}
+
+// readMavenDependencies reads the pinned artifacts from a gradle.lockfile, or a Maven BOM or pom.xml
+func readMavenDependencies(path string) ([]*mavenArtifact, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Ext(path) == ".xml" || strings.HasSuffix(path, ".pom") {
+		return parsePom(bs)
+	}
+	return parseGradleLockfile(bs), nil
+}
+
+// parseGradleLockfile parses the artifacts from a gradle.lockfile, where each line is group:artifact:version followed by
+// the configurations that use it
+func parseGradleLockfile(bs []byte) []*mavenArtifact {
+	var artifacts []*mavenArtifact
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		coords, _, _ := strings.Cut(line, "=")
+		parts := strings.Split(coords, ":")
+		if len(parts) != 3 {
+			continue // e.g. the empty= line listing configurations without dependencies
+		}
+		artifacts = append(artifacts, &mavenArtifact{Group: parts[0], Artifact: parts[1], Version: parts[2]})
+	}
+	return artifacts
+}
+
+type pomDependency struct {
+	GroupID    string `xml:"groupId"`
+	ArtifactID string `xml:"artifactId"`
+	Version    string `xml:"version"`
+	Scope      string `xml:"scope"`
+	Type       string `xml:"type"`
+}
+
+type pom struct {
+	Version    string `xml:"version"`
+	Properties struct {
+		Values []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"properties"`
+	DependencyManagement struct {
+		Dependencies []pomDependency `xml:"dependencies>dependency"`
+	} `xml:"dependencyManagement"`
+	Dependencies []pomDependency `xml:"dependencies>dependency"`
+}
+
+var pomProperty = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// parsePom parses the artifacts from a pom.xml. These are the dependencies it manages, as a BOM does, and the ones it
+// depends on directly. Versions can refer to the pom's properties. Imported BOMs, and dependencies without a version,
+// are skipped.
+func parsePom(bs []byte) ([]*mavenArtifact, error) {
+	p := new(pom)
+	if err := xml.Unmarshal(bs, p); err != nil {
+		return nil, err
+	}
+
+	props := map[string]string{"project.version": p.Version}
+	for _, prop := range p.Properties.Values {
+		props[prop.XMLName.Local] = strings.TrimSpace(prop.Value)
+	}
+	expand := func(s string) string {
+		return pomProperty.ReplaceAllStringFunc(strings.TrimSpace(s), func(match string) string {
+			if value, ok := props[match[2:len(match)-1]]; ok {
+				return value
+			}
+			return match
+		})
+	}
+
+	var artifacts []*mavenArtifact
+	seen := map[string]bool{}
+	for _, dep := range append(p.DependencyManagement.Dependencies, p.Dependencies...) {
+		a := &mavenArtifact{Group: expand(dep.GroupID), Artifact: expand(dep.ArtifactID), Version: expand(dep.Version)}
+		if dep.Scope == "import" || dep.Type == "pom" || a.Version == "" || strings.Contains(a.Version, "${") {
+			continue
+		}
+		if seen[a.id()] {
+			continue
+		}
+		seen[a.id()] = true
+		artifacts = append(artifacts, a)
+	}
+	return artifacts, nil
+}
+
+// syncMaven adds a maven_jar to the Java third party directory for each artifact in the gradle.lockfile or BOM, or
+// updates the version of the existing rule
+func (s *syncer) syncMaven(conf *config.Config) error {
+	path, err := mavenDependenciesFile(conf.GetMavenDependencies())
+	if err != nil || path == "" {
+		return err
+	}
+	artifacts, err := readMavenDependencies(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %v", path, err)
+	}
+
+	file, err := s.graph.LoadFile(conf.GetJavaThirdPartyDir())
+	if err != nil {
+		return err
+	}
+
+	rules := mavenJars(file)
+	for _, a := range artifacts {
+		rule, ok := rules[a.id()]
+		if !ok {
+			name := java.TargetName(a.Artifact)
+			if edit.FindTargetByName(file, name) != nil {
+				name = java.TargetName(a.Group + "_" + a.Artifact)
+			}
+			rule = edit.NewRuleExpr("maven_jar", name)
+			file.Stmt = append(file.Stmt, rule.Call)
+			rules[a.id()] = rule
+		}
+		rule.SetAttr("id", edit.NewStringExpr(a.id()+":"+a.Version))
+	}
+
+	subinclude := java.Subincludes["java"]
+	if len(rules) > 0 && !s.plzConf.IsPreloaded(subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, subinclude)
+	}
+	return nil
+}
+
+// mavenJars returns the maven_jar rules in the file, keyed by the group and artifact from their id
+func mavenJars(file *build.File) map[string]*build.Rule {
+	rules := map[string]*build.Rule{}
+	for _, rule := range file.Rules("maven_jar") {
+		parts := strings.Split(rule.AttrString("id"), ":")
+		if len(parts) >= 2 {
+			rules[parts[0]+":"+parts[1]] = rule
+		}
+	}
+	return rules
+}
+
+// mavenDependenciesFile returns the file to sync third party artifacts from. Unless one is configured, this is
+// gradle.lockfile, or otherwise pom.xml, at the repo root.
+func mavenDependenciesFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	for _, name := range []string{"gradle.lockfile", "pom.xml"} {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check for %v: %w", name, err)
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestParseGradleLockfile(t *testing.T) {
+	artifacts := parseGradleLockfile([]byte(`# This is a Gradle generated file for dependency locking.
+# Manual edits can break the build and are not advised.
+# This file is expected to be part of source control.
+com.google.guava:guava:32.1.3-jre=compileClasspath,runtimeClasspath
+org.slf4j:slf4j-api:2.0.9=runtimeClasspath
+empty=annotationProcessor
+`))
+	assert.Equal(t, []*mavenArtifact{
+		{Group: "com.google.guava", Artifact: "guava", Version: "32.1.3-jre"},
+		{Group: "org.slf4j", Artifact: "slf4j-api", Version: "2.0.9"},
+	}, artifacts)
+}
+
+func TestParsePom(t *testing.T) {
+	artifacts, err := parsePom([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<project xmlns="http://maven.apache.org/POM/4.0.0">
+  <modelVersion>4.0.0</modelVersion>
+  <groupId>com.example</groupId>
+  <artifactId>bom</artifactId>
+  <version>1.0.0</version>
+  <packaging>pom</packaging>
+  <properties>
+    <jackson.version>2.16.0</jackson.version>
+  </properties>
+  <dependencyManagement>
+    <dependencies>
+      <dependency>
+        <groupId>com.fasterxml.jackson.core</groupId>
+        <artifactId>jackson-databind</artifactId>
+        <version>${jackson.version}</version>
+      </dependency>
+      <dependency>
+        <groupId>org.junit</groupId>
+        <artifactId>junit-bom</artifactId>
+        <version>5.10.1</version>
+        <type>pom</type>
+        <scope>import</scope>
+      </dependency>
+      <dependency>
+        <groupId>com.example</groupId>
+        <artifactId>core</artifactId>
+        <version>${project.version}</version>
+      </dependency>
+    </dependencies>
+  </dependencyManagement>
+  <dependencies>
+    <dependency>
+      <groupId>com.fasterxml.jackson.core</groupId>
+      <artifactId>jackson-databind</artifactId>
+    </dependency>
+    <dependency>
+      <groupId>org.slf4j</groupId>
+      <artifactId>slf4j-api</artifactId>
+      <version>2.0.9</version>
+    </dependency>
+  </dependencies>
+</project>
+`))
+	require.NoError(t, err)
+	assert.Equal(t, []*mavenArtifact{
+		{Group: "com.fasterxml.jackson.core", Artifact: "jackson-databind", Version: "2.16.0"},
+		{Group: "com.example", Artifact: "core", Version: "1.0.0"},
+		{Group: "org.slf4j", Artifact: "slf4j-api", Version: "2.0.9"},
+	}, artifacts)
+}
+
+func TestSyncMaven(t *testing.T) {
+	files := map[string]string{
+		"gradle.lockfile": `com.google.guava:guava:32.1.3-jre=compileClasspath
+org.slf4j:slf4j-api:2.0.9=compileClasspath
+com.example:slf4j-api:1.0.0=compileClasspath
+`,
+		"third_party/java/BUILD": `maven_jar(
+    name = "google_guava",
+    id = "com.google.guava:guava:31.0-jre",
+    licences = ["Apache-2.0"],
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		plzConf.Parse.PreloadSubincludes = []string{"///java//build_defs:java"}
+		s := &syncer{plzConf: plzConf, graph: graph.New(plzConf.BuildFileNames(), options.TestOptions)}
+		require.NoError(t, s.syncMaven(&config.Config{Languages: []string{"java"}}))
+
+		file, err := s.graph.LoadFile("third_party/java")
+		require.NoError(t, err)
+		require.Len(t, file.Rules("maven_jar"), 3)
+
+		guava := edit.FindTargetByName(file, "google_guava")
+		assert.Equal(t, "com.google.guava:guava:32.1.3-jre", guava.AttrString("id"))
+		assert.Equal(t, []string{"Apache-2.0"}, guava.AttrStrings("licences"))
+
+		slf4j := edit.FindTargetByName(file, "slf4j_api")
+		require.NotNil(t, slf4j)
+		assert.Equal(t, "org.slf4j:slf4j-api:2.0.9", slf4j.AttrString("id"))
+
+		clash := edit.FindTargetByName(file, "com_example_slf4j_api")
+		require.NotNil(t, clash)
+		assert.Equal(t, "com.example:slf4j-api:1.0.0", clash.AttrString("id"))
+	})
+}
@@ -0,0 +1,156 @@
+package sync
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"github.com/please-build/puku/config"
+)
+
+// unusedModules returns the modules required in the go.mod that nothing in the repo needs. A module is needed if a Go
+// file in the repo imports one of its packages, it's in the keep list, or it's required by another module that's
+// needed.
+func (s *syncer) unusedModules(conf *config.Config, f *modfile.File) (map[string]bool, error) {
+	imports, err := repoImports(".", conf.GetThirdPartyDir())
+	if err != nil {
+		return nil, err
+	}
+	return usedModules(f, imports, conf.GetKeepModules(), s.proxy.Requirements)
+}
+
+// usedModules works out which of the required modules are needed, returning the ones that aren't. The requirements
+// function returns the modules required by the go.mod of a module version.
+func usedModules(f *modfile.File, imports, keep []string, requirements func(mod, ver string) ([]string, error)) (map[string]bool, error) {
+	versions := make(map[string]string, len(f.Require))
+	for _, req := range f.Require {
+		versions[req.Mod.Path] = req.Mod.Version
+	}
+
+	used := map[string]bool{}
+	var queue []string
+	use := func(mod string) {
+		if _, ok := versions[mod]; ok && !used[mod] {
+			used[mod] = true
+			queue = append(queue, mod)
+		}
+	}
+	for _, mod := range keep {
+		use(mod)
+	}
+	for _, i := range imports {
+		use(moduleForImport(versions, i))
+	}
+
+	for len(queue) > 0 {
+		mod := queue[0]
+		queue = queue[1:]
+
+		path, version := mod, versions[mod]
+		if replace := findReplace(f, module.Version{Path: mod, Version: version}); replace != nil {
+			if modfile.IsDirectoryPath(replace.New.Path) {
+				continue
+			}
+			path, version = replace.New.Path, replace.New.Version
+		}
+		reqs, err := requirements(path, version)
+		if err != nil {
+			return nil, err
+		}
+		for _, req := range reqs {
+			use(req)
+		}
+	}
+
+	unused := map[string]bool{}
+	for mod := range versions {
+		if !used[mod] {
+			unused[mod] = true
+		}
+	}
+	return unused, nil
+}
+
+// removedModules returns the modules with third party rules that the go.mod no longer requires, other than the ones in
+// the keep list
+func removedModules(f *modfile.File, existing map[string]*build.Rule, keep []string) []string {
+	required := make(map[string]bool, len(f.Require)+len(keep))
+	for _, req := range f.Require {
+		required[req.Mod.Path] = true
+	}
+	for _, mod := range keep {
+		required[mod] = true
+	}
+
+	var ret []string
+	for mod := range existing {
+		if !required[mod] {
+			ret = append(ret, mod)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// moduleForImport returns the module that provides the imported package, i.e. the longest module path that's a prefix
+// of the import path
+func moduleForImport(versions map[string]string, importPath string) string {
+	mod := ""
+	for path := range versions {
+		if (importPath == path || strings.HasPrefix(importPath, path+"/")) && len(path) > len(mod) {
+			mod = path
+		}
+	}
+	return mod
+}
+
+// repoImports returns the imports of all the Go files in the repo, skipping plz-out, hidden directories, testdata, and
+// the third party directory
+func repoImports(root, thirdPartyDir string) ([]string, error) {
+	seen := map[string]bool{}
+	var imports []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if path != root && (name == "plz-out" || name == "testdata" || strings.HasPrefix(name, ".") || path == thirdPartyDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		file, err := parser.ParseFile(token.NewFileSet(), path, bs, parser.ImportsOnly)
+		if err != nil {
+			log.Warningf("failed to parse %v: %v", path, err)
+			return nil
+		}
+		for _, spec := range file.Imports {
+			i, err := strconv.Unquote(spec.Path.Value)
+			if err != nil || seen[i] {
+				continue
+			}
+			seen[i] = true
+			imports = append(imports, i)
+		}
+		return nil
+	})
+	return imports, err
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/modfile"
+
+	"github.com/please-build/puku/edit"
+)
+
+func TestUsedModules(t *testing.T) {
+	goMod := `module example.com/repo
+
+require (
+	example.com/direct v1.0.0
+	example.com/direct/nested v1.0.0
+	example.com/transitive v1.0.0 // indirect
+	example.com/replaced v1.0.0 // indirect
+	example.com/kept v1.0.0
+	example.com/unused v1.0.0 // indirect
+)
+
+replace example.com/replaced => example.com/fork v1.1.0
+`
+	f, err := modfile.Parse("go.mod", []byte(goMod), nil)
+	require.NoError(t, err)
+
+	requirements := func(mod, ver string) ([]string, error) {
+		switch mod + "@" + ver {
+		case "example.com/direct@v1.0.0":
+			return []string{"example.com/transitive", "example.com/not-in-go-mod"}, nil
+		case "example.com/transitive@v1.0.0":
+			return []string{"example.com/replaced"}, nil
+		case "example.com/direct/nested@v1.0.0", "example.com/fork@v1.1.0", "example.com/kept@v1.0.0":
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unexpected module %v@%v", mod, ver)
+	}
+
+	imports := []string{"fmt", "example.com/direct/pkg", "example.com/direct/nested", "example.com/repo/foo"}
+	unused, err := usedModules(f, imports, []string{"example.com/kept"}, requirements)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"example.com/unused": true}, unused)
+}
+
+func TestRemovedModules(t *testing.T) {
+	f, err := modfile.Parse("go.mod", []byte("module example.com/repo\n\nrequire example.com/required v1.0.0\n"), nil)
+	require.NoError(t, err)
+
+	existing := map[string]*build.Rule{
+		"example.com/required": edit.NewRuleExpr("go_repo", "required"),
+		"example.com/removed":  edit.NewRuleExpr("go_repo", "removed"),
+		"example.com/kept":     edit.NewRuleExpr("go_repo", "kept"),
+	}
+	assert.Equal(t, []string{"example.com/removed"}, removedModules(f, existing, []string{"example.com/kept"}))
+}
+
+func TestRepoImports(t *testing.T) {
+	dir := t.TempDir()
+	write := func(path, content string) {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, path), []byte(content), 0644))
+	}
+	write("foo/foo.go", "package foo\n\nimport (\n\t\"fmt\"\n\t\"example.com/foo\"\n)\n")
+	write("bar/bar_test.go", "package bar\n\nimport \"example.com/bar\"\n")
+	write("plz-out/gen/gen.go", "package gen\n\nimport \"example.com/gen\"\n")
+	write("foo/testdata/data.go", "package data\n\nimport \"example.com/data\"\n")
+	write("third_party/go/tools.go", "package tools\n\nimport \"example.com/tools\"\n")
+
+	imports, err := repoImports(dir, filepath.Join(dir, "third_party/go"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"fmt", "example.com/foo", "example.com/bar"}, imports)
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/please-build/buildtools/build"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/generate/python"
+)
+
+// syncPython adds a pip_library to the Python third party directory for each package in the requirements, or updates
+// the version of the existing rule. When the requirements come from a lock file, the rules' deps are set to the
+// packages each one depends on.
+func (s *syncer) syncPython(conf *config.Config) error {
+	path, err := pythonRequirementsFile(conf.GetPythonRequirements())
+	if err != nil || path == "" {
+		return err
+	}
+	pkgs, err := readPythonRequirements(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %v", path, err)
+	}
+
+	file, err := s.graph.LoadFile(conf.GetPythonThirdPartyDir())
+	if err != nil {
+		return err
+	}
+
+	rules := pipLibraries(file)
+	for _, pkg := range pkgs {
+		name := python.TargetName(pkg.Name)
+		rule, ok := rules[name]
+		if !ok {
+			rule = edit.NewRuleExpr("pip_library", name)
+			if name != pkg.Name {
+				rule.SetAttr("package_name", edit.NewStringExpr(pkg.Name))
+			}
+			file.Stmt = append(file.Stmt, rule.Call)
+			rules[name] = rule
+		}
+		rule.SetAttr("version", edit.NewStringExpr(pkg.Version))
+	}
+
+	// Now all the rules exist, we can point them at each other
+	for _, pkg := range pkgs {
+		if pkg.Deps == nil {
+			continue
+		}
+		deps := make([]string, 0, len(pkg.Deps))
+		for _, dep := range pkg.Deps {
+			if rule, ok := rules[python.TargetName(dep)]; ok {
+				deps = append(deps, ":"+rule.Name())
+			}
+		}
+		sort.Strings(deps)
+		edit.NewRule(rules[python.TargetName(pkg.Name)], nil, file.Pkg).SetOrDeleteAttr("deps", deps)
+	}
+
+	if len(rules) > 0 && !s.plzConf.IsPreloaded(python.Subinclude) && conf.ShouldEnsureSubincludes() {
+		edit.EnsureSubincludeOf(file, python.Subinclude)
+	}
+	return nil
+}
+
+// pipLibraries returns the pip_library rules in the file, keyed by the target name for the distribution they install
+func pipLibraries(file *build.File) map[string]*build.Rule {
+	rules := map[string]*build.Rule{}
+	for _, rule := range file.Rules("pip_library") {
+		dist := rule.AttrString("package_name")
+		if dist == "" {
+			dist = rule.Name()
+		}
+		rules[python.TargetName(dist)] = rule
+	}
+	return rules
+}
@@ -0,0 +1,171 @@
+package sync
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/please-build/puku/config"
+	"github.com/please-build/puku/edit"
+	"github.com/please-build/puku/graph"
+	"github.com/please-build/puku/options"
+	"github.com/please-build/puku/repotest"
+)
+
+func TestReadRequirementsTxt(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "requirements.txt"), []byte(`# Our requirements
+--index-url https://pypi.example.com/simple
+-r base.txt
+requests==2.31.0  # for http
+PyYAML[libyaml] == 6.0.1 ; python_version >= "3.8"
+six==1.16.0 \
+    --hash=sha256:1e61c37477a1626458e36f7b1d82aa5c9b094fa4802892072e49de9c60c4c926
+flask>=2.0
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "base.txt"), []byte("urllib3==2.0.7\n"), 0644))
+
+	pkgs, err := readPythonRequirements(filepath.Join(dir, "requirements.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, []*pythonPackage{
+		{Name: "urllib3", Version: "2.0.7"},
+		{Name: "requests", Version: "2.31.0"},
+		{Name: "PyYAML", Version: "6.0.1"},
+		{Name: "six", Version: "1.16.0"},
+	}, pkgs)
+}
+
+func TestParseLockFile(t *testing.T) {
+	t.Run("poetry", func(t *testing.T) {
+		pkgs := parseLockFile([]byte(`# This file is automatically @generated by Poetry and should not be changed by hand.
+
+[[package]]
+name = "requests"
+version = "2.31.0"
+description = "Python HTTP for Humans."
+optional = false
+python-versions = ">=3.7"
+files = [
+    {file = "requests-2.31.0-py3-none-any.whl", hash = "sha256:58cd2187c01e70e6e26505bca751777aa9f2ee0b7f4300988b709f44e013003f"},
+]
+
+[package.dependencies]
+charset-normalizer = ">=2,<4"
+urllib3 = ">=1.21.1,<3"
+PySocks = {version = ">=1.5.6,!=1.5.7", optional = true}
+
+[package.extras]
+socks = ["PySocks (>=1.5.6,!=1.5.7)"]
+
+[[package]]
+name = "charset-normalizer"
+version = "3.3.2"
+description = "The Real First Universal Charset Detector."
+optional = false
+python-versions = ">=3.7.0"
+files = []
+
+[[package]]
+name = "local-lib"
+version = "0.1.0"
+description = ""
+optional = false
+python-versions = "*"
+files = []
+
+[package.source]
+type = "directory"
+url = "../local-lib"
+
+[metadata]
+lock-version = "2.0"
+`))
+		assert.Equal(t, []*pythonPackage{
+			{Name: "requests", Version: "2.31.0", Deps: []string{"charset-normalizer", "urllib3"}},
+			{Name: "charset-normalizer", Version: "3.3.2", Deps: []string{}},
+		}, pkgs)
+	})
+
+	t.Run("uv", func(t *testing.T) {
+		pkgs := parseLockFile([]byte(`version = 1
+requires-python = ">=3.12"
+
+[[package]]
+name = "anyio"
+version = "4.4.0"
+source = { registry = "https://pypi.org/simple" }
+dependencies = [
+    { name = "idna" },
+    { name = "sniffio", marker = "python_version < '3.13'" },
+]
+sdist = { url = "https://files.pythonhosted.org/anyio-4.4.0.tar.gz", hash = "sha256:5aadc6a1bbb7cdb0bede386cac5e2940f5e2ff3aa20277e991cf028e0585ce94" }
+
+[package.optional-dependencies]
+trio = [
+    { name = "trio" },
+]
+
+[[package]]
+name = "myproject"
+version = "0.1.0"
+source = { editable = "." }
+dependencies = [
+    { name = "anyio" },
+]
+`))
+		assert.Equal(t, []*pythonPackage{
+			{Name: "anyio", Version: "4.4.0", Deps: []string{"idna", "sniffio"}},
+		}, pkgs)
+	})
+}
+
+func TestSyncPython(t *testing.T) {
+	files := map[string]string{
+		"poetry.lock": `[[package]]
+name = "requests"
+version = "2.31.0"
+
+[package.dependencies]
+urllib3 = ">=1.21.1,<3"
+
+[[package]]
+name = "urllib3"
+version = "2.0.7"
+
+[[package]]
+name = "PyYAML"
+version = "6.0.1"
+`,
+		"third_party/python/BUILD": `pip_library(
+    name = "requests",
+    version = "2.30.0",
+    licences = ["Apache-2.0"],
+)
+`,
+	}
+
+	repotest.Run(t, files, func(t *testing.T) {
+		plzConf := repotest.PleaseConfig()
+		plzConf.Parse.PreloadSubincludes = []string{"///python//build_defs:python"}
+		s := &syncer{plzConf: plzConf, graph: graph.New(plzConf.BuildFileNames(), options.TestOptions)}
+		require.NoError(t, s.syncPython(&config.Config{Languages: []string{"python"}}))
+
+		file, err := s.graph.LoadFile("third_party/python")
+		require.NoError(t, err)
+		require.Len(t, file.Rules("pip_library"), 3)
+
+		requests := edit.FindTargetByName(file, "requests")
+		assert.Equal(t, "2.31.0", requests.AttrString("version"))
+		assert.Equal(t, []string{"Apache-2.0"}, requests.AttrStrings("licences"))
+		assert.Equal(t, []string{":urllib3"}, requests.AttrStrings("deps"))
+
+		pyyaml := edit.FindTargetByName(file, "pyyaml")
+		require.NotNil(t, pyyaml)
+		assert.Equal(t, "PyYAML", pyyaml.AttrString("package_name"))
+		assert.Equal(t, "6.0.1", pyyaml.AttrString("version"))
+		assert.Nil(t, pyyaml.Attr("deps"))
+	})
+}
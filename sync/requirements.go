@@ -0,0 +1,213 @@
+package sync
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// pythonPackage is a pinned third party Python distribution
+type pythonPackage struct {
+	Name    string
+	Version string
+	// Deps are the names of the distributions this one depends on. This is nil when the source doesn't say what they
+	// are, i.e. for requirements.txt.
+	Deps []string
+}
+
+// readPythonRequirements reads the pinned packages from a requirements.txt, poetry.lock or uv.lock file
+func readPythonRequirements(path string) ([]*pythonPackage, error) {
+	switch filepath.Base(path) {
+	case "poetry.lock", "uv.lock":
+		bs, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return parseLockFile(bs), nil
+	default:
+		return readRequirementsTxt(path, map[string]bool{})
+	}
+}
+
+var pinnedRequirement = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9._-]*)\s*(?:\[[^\]]*\])?\s*===?\s*([^\s;,]+)`)
+
+// readRequirementsTxt reads the requirements from a pip requirements file, following any -r includes. Only pinned
+// requirements, i.e. name==version, can be turned into pip_library rules.
+func readRequirementsTxt(path string, seen map[string]bool) ([]*pythonPackage, error) {
+	if seen[path] {
+		return nil, nil
+	}
+	seen[path] = true
+
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkgs []*pythonPackage
+	scanner := bufio.NewScanner(bytes.NewReader(bs))
+	line := ""
+	for scanner.Scan() {
+		line += scanner.Text()
+		if strings.HasSuffix(line, "\\") {
+			line = strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		req := line
+		line = ""
+		if i := strings.Index(req, "#"); i == 0 || (i > 0 && (req[i-1] == ' ' || req[i-1] == '\t')) {
+			req = req[:i]
+		}
+		req = strings.TrimSpace(req)
+		if req == "" {
+			continue
+		}
+
+		if strings.HasPrefix(req, "-") {
+			fields := strings.Fields(strings.Replace(req, "=", " ", 1))
+			if (fields[0] == "-r" || fields[0] == "--requirement") && len(fields) > 1 {
+				included, err := readRequirementsTxt(filepath.Join(filepath.Dir(path), fields[1]), seen)
+				if err != nil {
+					return nil, err
+				}
+				pkgs = append(pkgs, included...)
+			}
+			continue // Other options, e.g. --index-url, don't affect what's installed
+		}
+
+		match := pinnedRequirement.FindStringSubmatch(req)
+		if match == nil {
+			log.Warningf("skipping %q in %v as it isn't pinned to a version", req, path)
+			continue
+		}
+		pkgs = append(pkgs, &pythonPackage{Name: match[1], Version: match[2]})
+	}
+	return pkgs, scanner.Err()
+}
+
+var (
+	tableHeader     = regexp.MustCompile(`^\[\[?([^\]]+)\]\]?$`)
+	keyValue        = regexp.MustCompile(`^("?[A-Za-z0-9._-]+"?)\s*=\s*(.*)$`)
+	inlineTableName = regexp.MustCompile(`name\s*=\s*"([^"]+)"`)
+	nonRegistry     = regexp.MustCompile(`\b(editable|virtual|directory|path|git|url)\s*=`)
+	optionalDep     = regexp.MustCompile(`optional\s*=\s*true`)
+)
+
+// parseLockFile parses the packages from a poetry.lock or uv.lock file. These are both TOML with a [[package]] array
+// of tables for the locked distributions. Poetry lists their dependencies in a [package.dependencies] table, while uv
+// has a dependencies array of inline tables. Rather than parsing the full TOML grammar, this reads the few keys these
+// tools write that we need. Packages that aren't from a package index, e.g. the project itself, are skipped.
+func parseLockFile(bs []byte) []*pythonPackage {
+	var pkgs []*pythonPackage
+	var pkg *pythonPackage
+	skip := false
+	table := ""
+
+	done := func() {
+		if pkg != nil && !skip && pkg.Name != "" && pkg.Version != "" {
+			pkgs = append(pkgs, pkg)
+		}
+		pkg, skip = nil, false
+	}
+
+	for _, stmt := range tomlStatements(bs) {
+		if match := tableHeader.FindStringSubmatch(stmt); match != nil {
+			table = strings.TrimSpace(match[1])
+			if strings.HasPrefix(stmt, "[[") && table == "package" {
+				done()
+				pkg = &pythonPackage{Deps: []string{}}
+			}
+			continue
+		}
+		match := keyValue.FindStringSubmatch(stmt)
+		if match == nil || pkg == nil {
+			continue
+		}
+		key, value := strings.Trim(match[1], `"`), strings.TrimSpace(match[2])
+
+		switch table {
+		case "package":
+			switch key {
+			case "name":
+				pkg.Name = unquote(value)
+			case "version":
+				pkg.Version = unquote(value)
+			case "source":
+				skip = skip || nonRegistry.MatchString(value)
+			case "dependencies":
+				for _, dep := range inlineTableName.FindAllStringSubmatch(value, -1) {
+					pkg.Deps = append(pkg.Deps, dep[1])
+				}
+			}
+		case "package.source":
+			if key == "type" && unquote(value) != "legacy" {
+				skip = true
+			}
+		case "package.dependencies":
+			if !optionalDep.MatchString(value) {
+				pkg.Deps = append(pkg.Deps, key)
+			}
+		}
+	}
+	done()
+	return pkgs
+}
+
+// tomlStatements splits TOML into its lines, joining arrays and inline tables that span multiple lines, and removing
+// comments
+func tomlStatements(bs []byte) []string {
+	var stmts []string
+	current := ""
+	depth := 0
+	for _, line := range strings.Split(string(bs), "\n") {
+		inString := false
+		for i := 0; i < len(line); i++ {
+			c := line[i]
+			switch {
+			case c == '\\' && inString:
+				i++
+			case c == '"':
+				inString = !inString
+			case inString:
+			case c == '#':
+				line = line[:i]
+			case c == '[' || c == '{':
+				depth++
+			case c == ']' || c == '}':
+				depth--
+			}
+		}
+		current += " " + strings.TrimSpace(line)
+		if depth <= 0 {
+			if stmt := strings.TrimSpace(current); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			current, depth = "", 0
+		}
+	}
+	return stmts
+}
+
+func unquote(s string) string {
+	return strings.Trim(s, `"'`)
+}
+
+// pythonRequirementsFile returns the file to sync third party Python packages from. Unless one is configured, this is
+// the first of uv.lock, poetry.lock and requirements.txt found at the repo root.
+func pythonRequirementsFile(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	for _, name := range []string{"uv.lock", "poetry.lock", "requirements.txt"} {
+		if _, err := os.Stat(name); err == nil {
+			return name, nil
+		} else if !os.IsNotExist(err) {
+			return "", fmt.Errorf("failed to check for %v: %w", name, err)
+		}
+	}
+	return "", nil
+}
@@ -3,10 +3,12 @@ package sync
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/please-build/buildtools/build"
 	"github.com/please-build/buildtools/labels"
 	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
 
 	"github.com/please-build/puku/config"
 	"github.com/please-build/puku/edit"
@@ -22,17 +24,19 @@ var log = logging.GetLogger()
 type syncer struct {
 	plzConf  *please.Config
 	graph    *graph.Graph
+	proxy    *proxy.Proxy
 	licences *licences.Licenses
 }
 
 const ReplaceLabel = "go_replace_directive"
 
 func newSyncer(plzConf *please.Config, g *graph.Graph) *syncer {
-	p := proxy.New(proxy.DefaultURL)
+	p := proxy.NewFromEnv()
 	l := licences.New(p, g)
 	return &syncer{
 		plzConf:  plzConf,
 		graph:    g,
+		proxy:    p,
 		licences: l,
 	}
 }
@@ -58,15 +62,33 @@ func SyncToStdout(format string, plzConf *please.Config, g *graph.Graph) error {
 }
 
 func (s *syncer) sync() error {
-	if s.plzConf.ModFile() == "" {
-		return nil
-	}
-
 	conf, err := config.ReadConfig(".")
 	if err != nil {
 		return err
 	}
 
+	if conf.HasLanguage("python") {
+		if err := s.syncPython(conf); err != nil {
+			return fmt.Errorf("failed to sync Python requirements: %v", err)
+		}
+	}
+
+	if conf.HasLanguage("rust") {
+		if err := s.syncRust(conf); err != nil {
+			return fmt.Errorf("failed to sync Rust crates: %v", err)
+		}
+	}
+
+	if conf.HasLanguage("java") || conf.HasLanguage("kotlin") {
+		if err := s.syncMaven(conf); err != nil {
+			return fmt.Errorf("failed to sync Maven dependencies: %v", err)
+		}
+	}
+
+	if s.plzConf.ModFile() == "" {
+		return nil
+	}
+
 	file, err := s.graph.LoadFile(conf.GetThirdPartyDir())
 	if err != nil {
 		return err
@@ -103,18 +125,27 @@ func (s *syncer) syncModFile(conf *config.Config, file *build.File, existingRule
 		return err
 	}
 
+	if err := s.syncToolchain(f); err != nil {
+		log.Warningf("Failed to sync the Go toolchain version: %v", err)
+	}
+
+	// Vendored modules are built from the vendor directory, so there are no third party rules to sync
+	if conf.GetVendorDir() != "" {
+		return nil
+	}
+
+	// The go.sum lives alongside the go.mod in the source tree, rather than being an output of the go.mod target
+	sums, err := proxy.ReadGoSum(filepath.Join(labels.Parse(s.plzConf.ModFile()).Package, "go.sum"))
+	if err != nil {
+		return err
+	}
+	s.proxy.VerifyWith(sums)
+
 	// Remove "go_replace_directive" label from any rules which lack a replace directive
 	for modPath, rule := range existingRules {
-		// Find any matching replace directive
-		var matchingReplace *modfile.Replace
-		for _, replace := range f.Replace {
-			if replace.Old.Path == modPath {
-				matchingReplace = replace
-			}
-		}
-
-		// Remove the replace label if not needed
-		if matchingReplace == nil {
+		// Remove the replace label if not needed. Replace directives for a specific version only apply to the version
+		// that's required.
+		if findReplace(f, module.Version{Path: modPath, Version: requiredVersion(f, modPath)}) == nil {
 			err := edit.RemoveLabel(rule, ReplaceLabel)
 			if err != nil {
 				log.Warningf("Failed to remove replace label from %v: %v", modPath, err)
@@ -122,14 +153,45 @@ func (s *syncer) syncModFile(conf *config.Config, file *build.File, existingRule
 		}
 	}
 
+	var unused map[string]bool
+	if conf.GetPruneModules() {
+		if unused, err = s.unusedModules(conf, f); err != nil {
+			return fmt.Errorf("failed to determine which modules are used: %v", err)
+		}
+		for _, mod := range removedModules(f, existingRules, conf.GetKeepModules()) {
+			log.Infof("Removing the rule for %v as it's no longer in the go.mod", mod)
+			s.removeModule(file, mod)
+		}
+	}
+
 	// Check all modules listed in go.mod
 	for _, req := range f.Require {
-		// Find any matching replace directive
-		var matchingReplace *modfile.Replace
-		for _, replace := range f.Replace {
-			if replace.Old.Path == req.Mod.Path {
-				matchingReplace = replace
+		if isExcluded(f, req.Mod) {
+			log.Warningf("%v@%v is excluded in go.mod, so it won't be synced. Run go mod tidy to update the requirement.", req.Mod.Path, req.Mod.Version)
+			continue
+		}
+
+		if unused[req.Mod.Path] {
+			if _, ok := existingRules[req.Mod.Path]; ok {
+				log.Infof("Removing the rule for %v as nothing imports it", req.Mod.Path)
+				s.removeModule(file, req.Mod.Path)
+			}
+			continue
+		}
+
+		matchingReplace := findReplace(f, req.Mod)
+
+		// Modules replaced with a local directory are built from the sources in the repo, so they don't need a
+		// third party rule
+		if matchingReplace != nil && modfile.IsDirectoryPath(matchingReplace.New.Path) {
+			if _, ok := existingRules[req.Mod.Path]; ok {
+				log.Warningf("%v is replaced with %v, so its third party rule is no longer needed", req.Mod.Path, matchingReplace.New.Path)
 			}
+			continue
+		}
+
+		if mod, ok := missingSum(sums, req.Mod, matchingReplace); ok {
+			log.Warningf("%v@%v is missing from go.sum so it can't be verified. Run go mod tidy to add it.", mod.Path, mod.Version)
 		}
 
 		// Existing rule will point to the go_mod_download with the version on it so we should use the original path
@@ -139,11 +201,19 @@ func (s *syncer) syncModFile(conf *config.Config, file *build.File, existingRule
 				// Looks like we've added in a replace directive for this module which changes the path, so we need to
 				// delete the old go_repo rule and regenerate it with a go_mod_download and a go_repo.
 				edit.RemoveTarget(file, rule)
-			} else {
-				s.syncExistingRule(rule, req, matchingReplace)
-				// No other changes needed
+				if err = s.addNewRule(file, req, matchingReplace); err != nil {
+					return fmt.Errorf("failed to add new rule %v: %v", req.Mod.Path, err)
+				}
+				// Keep anything that was set by hand, e.g. patches, on the new rule
+				if newRule := findRepoRule(file, req.Mod.Path); newRule != nil {
+					copyCustomAttrs(rule, newRule)
+				}
+				warnPatches(rule, req.Mod.Path, "it's been replaced with "+matchingReplace.New.Path)
 				continue
 			}
+			s.syncExistingRule(rule, req, matchingReplace)
+			// No other changes needed
+			continue
 		}
 
 		// Add a new rule to the build file if one does not exist
@@ -155,6 +225,79 @@ func (s *syncer) syncModFile(conf *config.Config, file *build.File, existingRule
 	return nil
 }
 
+// missingSum returns the module that's downloaded for a requirement, and whether go.sum is missing it so it can't be
+// verified. Modules replaced with a local directory aren't downloaded, so they're never missing. Nothing is missing if
+// there's no go.sum at all.
+func missingSum(sums proxy.GoSum, mod module.Version, replace *modfile.Replace) (module.Version, bool) {
+	if replace != nil {
+		if modfile.IsDirectoryPath(replace.New.Path) {
+			return module.Version{}, false
+		}
+		mod = replace.New
+	}
+	return mod, sums != nil && !sums.Has(mod.Path, mod.Version)
+}
+
+// removeModule removes the go_repo rule for the module, along with its go_mod_download rule if it has one
+func (s *syncer) removeModule(file *build.File, modPath string) {
+	for _, repoRule := range append(file.Rules("go_repo"), file.Rules("go_module")...) {
+		if repoRule.AttrString("module") != modPath {
+			continue
+		}
+		edit.RemoveTarget(file, repoRule)
+
+		if download := repoRule.AttrString("download"); download != "" {
+			t := labels.ParseRelative(download, file.Pkg)
+			downloadFile, err := s.graph.LoadFile(t.Package)
+			if err != nil {
+				log.Warningf("Failed to remove %v: %v", download, err)
+				continue
+			}
+			if rule := edit.FindTargetByName(downloadFile, t.Target); rule != nil {
+				edit.RemoveTarget(downloadFile, rule)
+			}
+		}
+	}
+}
+
+// findReplace returns the replace directive that applies to the module, if any. Replace directives for a specific
+// version take precedence over ones for all versions, as they do for the go tool.
+func findReplace(f *modfile.File, mod module.Version) *modfile.Replace {
+	var matchingReplace *modfile.Replace
+	for _, replace := range f.Replace {
+		if replace.Old.Path != mod.Path {
+			continue
+		}
+		if replace.Old.Version == mod.Version {
+			return replace
+		}
+		if replace.Old.Version == "" {
+			matchingReplace = replace
+		}
+	}
+	return matchingReplace
+}
+
+// requiredVersion returns the version of the module that go.mod requires, or an empty string if it isn't required
+func requiredVersion(f *modfile.File, modPath string) string {
+	for _, req := range f.Require {
+		if req.Mod.Path == modPath {
+			return req.Mod.Version
+		}
+	}
+	return ""
+}
+
+// isExcluded returns whether the module version is excluded by an exclude directive
+func isExcluded(f *modfile.File, mod module.Version) bool {
+	for _, exclude := range f.Exclude {
+		if exclude.Mod == mod {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *syncer) syncExistingRule(rule *build.Rule, requireDirective *modfile.Require, replaceDirective *modfile.Replace) {
 	reqVersion := requireDirective.Mod.Version
 	// Add label for the replace directive
@@ -166,10 +309,50 @@ func (s *syncer) syncExistingRule(rule *build.Rule, requireDirective *modfile.Re
 		// Update the requested version
 		reqVersion = replaceDirective.New.Version
 	}
-	// Make sure the version is up-to-date
+	// Make sure the version is up-to-date. Only the version is changed so anything set by hand is kept.
+	if oldVersion := rule.AttrString("version"); oldVersion != reqVersion {
+		warnPatches(rule, requireDirective.Mod.Path, fmt.Sprintf("it's been updated from %v to %v", oldVersion, reqVersion))
+	}
 	rule.SetAttr("version", edit.NewStringExpr(reqVersion))
 }
 
+// generatedAttrs are the attributes of go_repo rules that sync generates. Any others have been set by hand.
+var generatedAttrs = map[string]bool{
+	"name":     true,
+	"module":   true,
+	"version":  true,
+	"download": true,
+	"licences": true,
+	"labels":   true,
+}
+
+// copyCustomAttrs copies any attributes that weren't generated by sync, e.g. patch or pre_build, from one rule to another
+func copyCustomAttrs(from, to *build.Rule) {
+	for _, key := range from.AttrKeys() {
+		if generatedAttrs[key] || to.Attr(key) != nil {
+			continue
+		}
+		to.SetAttr(key, from.Attr(key))
+	}
+}
+
+// warnPatches warns that the patches on a rule may no longer apply after the module has changed
+func warnPatches(rule *build.Rule, modPath, reason string) {
+	if rule.Attr("patch") != nil {
+		log.Warningf("%v has patches that may no longer apply now %v", modPath, reason)
+	}
+}
+
+// findRepoRule returns the go_repo rule for the module in the file, if there is one
+func findRepoRule(file *build.File, modPath string) *build.Rule {
+	for _, rule := range file.Rules("go_repo") {
+		if rule.AttrString("module") == modPath {
+			return rule
+		}
+	}
+	return nil
+}
+
 func (s *syncer) addNewRule(file *build.File, requireDirective *modfile.Require, replaceDirective *modfile.Replace) error {
 	// List licences
 	ls, err := s.licences.Get(requireDirective.Mod.Path, requireDirective.Mod.Version)
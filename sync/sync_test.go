@@ -0,0 +1,152 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/module"
+
+	"github.com/please-build/puku/proxy"
+)
+
+func TestFindReplace(t *testing.T) {
+	goMod := `module example.com/repo
+
+replace (
+	example.com/all => example.com/fork v1.2.0
+	example.com/versioned v1.0.0 => example.com/versioned v1.0.1
+	example.com/versioned => ./versioned
+	example.com/local => ./local
+)
+`
+	f, err := modfile.Parse("go.mod", []byte(goMod), nil)
+	require.NoError(t, err)
+
+	replace := findReplace(f, module.Version{Path: "example.com/all", Version: "v1.0.0"})
+	require.NotNil(t, replace)
+	assert.Equal(t, "example.com/fork", replace.New.Path)
+
+	replace = findReplace(f, module.Version{Path: "example.com/versioned", Version: "v1.0.0"})
+	require.NotNil(t, replace)
+	assert.Equal(t, "v1.0.1", replace.New.Version)
+
+	replace = findReplace(f, module.Version{Path: "example.com/versioned", Version: "v2.0.0"})
+	require.NotNil(t, replace)
+	assert.True(t, modfile.IsDirectoryPath(replace.New.Path))
+
+	assert.Nil(t, findReplace(f, module.Version{Path: "example.com/other", Version: "v1.0.0"}))
+}
+
+func TestMissingSum(t *testing.T) {
+	sums := proxy.GoSum{
+		{Module: "example.com/foo", Version: "v1.0.0/go.mod"}:  "h1:abc=",
+		{Module: "example.com/fork", Version: "v1.2.0/go.mod"}: "h1:def=",
+	}
+	foo := module.Version{Path: "example.com/foo", Version: "v1.0.0"}
+
+	_, missing := missingSum(sums, foo, nil)
+	assert.False(t, missing)
+
+	mod, missing := missingSum(sums, module.Version{Path: "example.com/bar", Version: "v1.0.0"}, nil)
+	assert.True(t, missing)
+	assert.Equal(t, "example.com/bar", mod.Path)
+
+	// The replacement is what's downloaded, so that's what needs to be in go.sum
+	_, missing = missingSum(sums, foo, &modfile.Replace{New: module.Version{Path: "example.com/fork", Version: "v1.2.0"}})
+	assert.False(t, missing)
+	_, missing = missingSum(sums, foo, &modfile.Replace{New: module.Version{Path: "example.com/fork", Version: "v1.3.0"}})
+	assert.True(t, missing)
+
+	// Local directories are never downloaded
+	_, missing = missingSum(sums, foo, &modfile.Replace{New: module.Version{Path: "./foo"}})
+	assert.False(t, missing)
+
+	_, missing = missingSum(nil, foo, nil)
+	assert.False(t, missing)
+}
+
+func TestIsExcluded(t *testing.T) {
+	goMod := `module example.com/repo
+
+exclude example.com/foo v1.0.0
+`
+	f, err := modfile.Parse("go.mod", []byte(goMod), nil)
+	require.NoError(t, err)
+
+	assert.True(t, isExcluded(f, module.Version{Path: "example.com/foo", Version: "v1.0.0"}))
+	assert.False(t, isExcluded(f, module.Version{Path: "example.com/foo", Version: "v1.0.1"}))
+	assert.False(t, isExcluded(f, module.Version{Path: "example.com/bar", Version: "v1.0.0"}))
+}
+
+func TestToolchainVersion(t *testing.T) {
+	parse := func(goMod string) *modfile.File {
+		f, err := modfile.Parse("go.mod", []byte("module example.com/repo\n\n"+goMod), nil)
+		require.NoError(t, err)
+		return f
+	}
+
+	t.Run("go directive is a minimum", func(t *testing.T) {
+		f := parse("go 1.22.3\n")
+		assert.Equal(t, "", toolchainVersion(f, "1.22.3"))
+		assert.Equal(t, "", toolchainVersion(f, "1.23.0"))
+		assert.Equal(t, "1.22.3", toolchainVersion(f, "1.21.5"))
+	})
+
+	t.Run("language versions use the first release", func(t *testing.T) {
+		assert.Equal(t, "1.22.0", toolchainVersion(parse("go 1.22\n"), "1.21.5"))
+		assert.Equal(t, "1.20", toolchainVersion(parse("go 1.20\n"), "1.19.2"))
+	})
+
+	t.Run("toolchain directive is exact", func(t *testing.T) {
+		f := parse("go 1.22\n\ntoolchain go1.23.1\n")
+		assert.Equal(t, "1.23.1", toolchainVersion(f, "1.23.2"))
+		assert.Equal(t, "", toolchainVersion(f, "1.23.1"))
+	})
+}
+
+func TestSyncExistingRuleKeepsCustomAttrs(t *testing.T) {
+	file, err := build.ParseBuild("BUILD", []byte(`go_repo(
+    module = "example.com/foo",
+    version = "v1.0.0",
+    patch = ["foo.patch"],
+    pre_build = "echo hello",
+)
+`))
+	require.NoError(t, err)
+	rule := file.Rules("go_repo")[0]
+
+	s := &syncer{}
+	s.syncExistingRule(rule, &modfile.Require{Mod: module.Version{Path: "example.com/foo", Version: "v1.1.0"}}, nil)
+	assert.Equal(t, "v1.1.0", rule.AttrString("version"))
+	assert.Equal(t, []string{"foo.patch"}, rule.AttrStrings("patch"))
+	assert.Equal(t, "echo hello", rule.AttrString("pre_build"))
+}
+
+func TestCopyCustomAttrs(t *testing.T) {
+	file, err := build.ParseBuild("BUILD", []byte(`go_repo(
+    name = "foo",
+    module = "example.com/foo",
+    version = "v1.0.0",
+    patch = ["foo.patch"],
+    install = ["..."],
+)
+
+go_repo(
+    module = "example.com/foo",
+    download = ":example.com_fork_dl",
+    install = ["bar"],
+)
+`))
+	require.NoError(t, err)
+	rules := file.Rules("go_repo")
+
+	copyCustomAttrs(rules[0], rules[1])
+	assert.Equal(t, []string{"foo.patch"}, rules[1].AttrStrings("patch"))
+	assert.Equal(t, []string{"bar"}, rules[1].AttrStrings("install"), "existing attributes shouldn't be overwritten")
+	assert.Equal(t, "", rules[1].AttrString("version"))
+	assert.Equal(t, ":example.com_fork_dl", rules[1].AttrString("download"))
+	assert.Nil(t, findRepoRule(file, "example.com/bar"))
+}
@@ -0,0 +1,79 @@
+package sync
+
+import (
+	"fmt"
+	"go/version"
+	"strings"
+
+	"github.com/please-build/buildtools/build"
+	"github.com/please-build/buildtools/labels"
+	"golang.org/x/mod/modfile"
+
+	"github.com/please-build/puku/edit"
+)
+
+// syncToolchain updates the version of the go_toolchain target configured as the Go plugin's GoTool to match the
+// go.mod. If the version is set by something other than a string, e.g. CONFIG.GO_VERSION, we can't update it, so we
+// warn if it contradicts the go.mod instead.
+func (s *syncer) syncToolchain(f *modfile.File) error {
+	tool, _, _ := strings.Cut(s.plzConf.GoTool(), "|")
+	if !strings.HasPrefix(tool, "//") || strings.HasPrefix(tool, "///") {
+		return nil // Not a go_toolchain target in this repo
+	}
+
+	label := labels.Parse(tool)
+	file, err := s.graph.LoadFile(label.Package)
+	if err != nil {
+		return err
+	}
+	rule := edit.FindTargetByName(file, label.Target)
+	if rule == nil || rule.Kind() != "go_toolchain" {
+		return nil
+	}
+
+	switch v := rule.Attr("version").(type) {
+	case *build.StringExpr:
+		if want := toolchainVersion(f, v.Value); want != "" {
+			log.Infof("Updating %v to Go %v to match go.mod", tool, want)
+			rule.SetAttr("version", edit.NewStringExpr(want))
+		}
+	case *build.DotExpr:
+		if ident, ok := v.X.(*build.Ident); ok && ident.Name == "CONFIG" {
+			current := s.plzConf.BuildConfigValue(v.Name)
+			if want := toolchainVersion(f, current); want != "" {
+				log.Warningf("%v uses Go %v from CONFIG.%v, which doesn't match go.mod. Update it to %v.", tool, current, v.Name, want)
+			}
+			return nil
+		}
+		return fmt.Errorf("can't determine the version of %v", tool)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("can't determine the version of %v", tool)
+	}
+	return nil
+}
+
+// toolchainVersion returns the version of Go that the toolchain should be updated to, or an empty string if the current
+// version is consistent with the go.mod. A toolchain directive determines the exact version to use, whereas the go
+// directive only sets a minimum.
+func toolchainVersion(f *modfile.File, current string) string {
+	if f.Toolchain != nil && f.Toolchain.Name != "default" {
+		want := strings.TrimPrefix(f.Toolchain.Name, "go")
+		if want != current {
+			return want
+		}
+		return ""
+	}
+
+	if f.Go == nil || version.Compare("go"+current, "go"+f.Go.Version) >= 0 {
+		return ""
+	}
+
+	// From Go 1.21, the first release of a language version is x.y.0 rather than x.y
+	want := f.Go.Version
+	if version.Lang("go"+want) == "go"+want && version.Compare("go"+want, "go1.21") >= 0 {
+		want += ".0"
+	}
+	return want
+}
@@ -10,8 +10,8 @@ import (
 	"github.com/fsnotify/fsnotify"
 
 	"github.com/please-build/puku/generate"
+	"github.com/please-build/puku/language"
 	"github.com/please-build/puku/logging"
-	"github.com/please-build/puku/options"
 	"github.com/please-build/puku/please"
 )
 
@@ -20,13 +20,14 @@ var log = logging.GetLogger()
 const debounceDuration = 200 * time.Millisecond
 
 // debouncer batches up updates to paths, waiting for a debounceDuration to pass. This avoids running puku many times
-// during git checkouts etc. but it also avoids inconsistent state when files are being moved around rapidly.
+// during git checkouts etc. but it also avoids inconsistent state when files are being moved around rapidly. The
+// session is kept between batches, so only the BUILD files in the paths, and ones that have been edited, are parsed again.
 type debouncer struct {
-	paths  map[string]struct{}
-	timer  *time.Timer
-	mux    sync.Mutex
-	config *please.Config
-	opts   options.Options
+	paths   map[string]struct{}
+	edited  map[string]struct{}
+	timer   *time.Timer
+	mux     sync.Mutex
+	session *generate.Session
 }
 
 // updatePath adds a path to the batch and resets the timer to the deboundDuration
@@ -44,17 +45,33 @@ func (d *debouncer) updatePath(path string) {
 	}
 }
 
+// forgetPath records that the BUILD file in a directory has been edited, so it's parsed again before the next update.
+// This doesn't trigger an update itself, as puku edits BUILD files when it updates them.
+func (d *debouncer) forgetPath(path string) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	d.edited[path] = struct{}{}
+}
+
 // wait waits for the timer to fire before updating the paths
 func (d *debouncer) wait() {
 	<-d.timer.C
 
 	d.mux.Lock()
 
+	edited := make([]string, 0, len(d.edited))
+	for p := range d.edited {
+		edited = append(edited, p)
+	}
+	d.session.Forget(edited...)
+	d.edited = map[string]struct{}{}
+
 	paths := make([]string, 0, len(d.paths))
 	for p := range d.paths {
 		paths = append(paths, p)
 	}
-	if err := generate.Update(d.config, d.opts, paths...); err != nil {
+	if err := d.session.Update(paths...); err != nil {
 		log.Warningf("failed to update: %v", err)
 	} else {
 		log.Infof("Updated paths: %v ", strings.Join(paths, ", "))
@@ -66,7 +83,9 @@ func (d *debouncer) wait() {
 	d.wait() // infinite recursive calls are a lint error but it's what we want here
 }
 
-func Watch(config *please.Config, opts options.Options, paths ...string) error {
+// Watch watches the given paths, and their subdirectories, updating the BUILD files in them as their sources change.
+// The session should have updated the paths already.
+func Watch(config *please.Config, session *generate.Session, paths ...string) error {
 	if len(paths) < 1 {
 		return nil
 	}
@@ -77,9 +96,13 @@ func Watch(config *please.Config, opts options.Options, paths ...string) error {
 	defer watcher.Close()
 
 	d := &debouncer{
-		paths:  map[string]struct{}{},
-		config: config,
-		opts:   opts,
+		paths:   map[string]struct{}{},
+		edited:  map[string]struct{}{},
+		session: session,
+	}
+	isBuildFile := map[string]bool{}
+	for _, name := range config.BuildFileNames() {
+		isBuildFile[name] = true
 	}
 
 	go func() {
@@ -93,11 +116,16 @@ func Watch(config *please.Config, opts options.Options, paths ...string) error {
 					break
 				}
 
-				if filepath.Ext(event.Name) == ".go" {
+				if filepath.Ext(event.Name) == ".go" || language.IsSource(filepath.Base(event.Name)) {
 					d.updatePath(filepath.Dir(event.Name))
 					break
 				}
 
+				if isBuildFile[filepath.Base(event.Name)] {
+					d.forgetPath(filepath.Dir(event.Name))
+					break
+				}
+
 				if event.Has(fsnotify.Create) {
 					if info, err := os.Lstat(event.Name); err == nil {
 						if info.IsDir() {